@@ -0,0 +1,106 @@
+// ocuai-detector-stub - простой сервер инференса для проверки http бэкендов.
+// /detect возвращает одну детекцию заданного класса в центре присланного кадра,
+// /ocr - заданный номер для любой присланной вырезки.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Detection формат детекции, ожидаемый http бэкендом
+type Detection struct {
+	Class      string  `json:"class"`
+	Confidence float32 `json:"confidence"`
+	BBox       BBox    `json:"bbox"`
+}
+
+// BBox ограничивающий прямоугольник в координатах кадра
+type BBox struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	class := flag.String("class", "person", "class of the returned detection")
+	confidence := flag.Float64("confidence", 0.9, "confidence of the returned detection")
+	plate := flag.String("plate", "A123BC77", "plate text returned by /ocr")
+	apiKey := flag.String("api-key", "", "required bearer token")
+	flag.Parse()
+
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return false
+		}
+
+		if *apiKey != "" && strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") != *apiKey {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return false
+		}
+
+		return true
+	}
+
+	http.HandleFunc("/detect", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+
+		frame, format, err := image.DecodeConfig(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		detection := Detection{
+			Class:      *class,
+			Confidence: float32(*confidence),
+			BBox: BBox{
+				X:      frame.Width / 4,
+				Y:      frame.Height / 4,
+				Width:  frame.Width / 2,
+				Height: frame.Height / 2,
+			},
+		}
+
+		log.Printf("Detect: %dx%d %s frame", frame.Width, frame.Height, format)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]Detection{
+			"detections": {detection},
+		})
+	})
+
+	http.HandleFunc("/ocr", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+
+		crop, format, err := image.DecodeConfig(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("OCR: %dx%d %s crop", crop.Width, crop.Height, format)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"text":       *plate,
+			"confidence": *confidence,
+		})
+	})
+
+	log.Printf("Detector stub listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
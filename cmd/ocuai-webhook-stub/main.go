@@ -0,0 +1,71 @@
+// ocuai-webhook-stub - простой приемник вебхуков для проверки доставки событий.
+// Проверяет подпись запроса и печатает полученные события; -fail N отвечает ошибкой
+// на первые N запросов, чтобы увидеть повторы.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"ocuai/internal/webhooks"
+)
+
+func main() {
+	addr := flag.String("addr", ":9100", "listen address")
+	secret := flag.String("secret", "", "webhook secret, empty - do not verify signatures")
+	fail := flag.Int("fail", 0, "respond with 500 to the first N requests")
+	flag.Parse()
+
+	var mu sync.Mutex
+	received := 0
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if *secret != "" {
+			expected := webhooks.Sign(*secret, r.Header.Get(webhooks.HeaderTimestamp), body)
+			if r.Header.Get(webhooks.HeaderSignature) != expected {
+				log.Printf("Invalid signature for delivery %s", r.Header.Get(webhooks.HeaderDelivery))
+				http.Error(w, "Invalid signature", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		mu.Lock()
+		received++
+		n := received
+		mu.Unlock()
+
+		if n <= *fail {
+			log.Printf("Failing request %d (delivery %s)", n, r.Header.Get(webhooks.HeaderDelivery))
+			http.Error(w, "Simulated failure", http.StatusInternalServerError)
+			return
+		}
+
+		var payload webhooks.Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, "Invalid payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Delivery %s: %s %s %q thumbnail=%s", r.Header.Get(webhooks.HeaderDelivery), payload.Event.Type,
+			payload.Event.CameraID, payload.Event.Description, payload.ThumbnailURL)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Webhook stub listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -13,12 +13,21 @@ import (
 	"time"
 
 	"ocuai/internal/ai"
+	"ocuai/internal/arming"
 	"ocuai/internal/config"
+	"ocuai/internal/counting"
 	"ocuai/internal/events"
+	"ocuai/internal/export"
+	"ocuai/internal/mqtt"
+	"ocuai/internal/reanalysis"
+	"ocuai/internal/retention"
+	"ocuai/internal/rules"
 	"ocuai/internal/storage"
 	"ocuai/internal/streaming"
 	"ocuai/internal/telegram"
 	"ocuai/internal/web"
+	"ocuai/internal/webhooks"
+	"ocuai/internal/websocket"
 )
 
 // TODO: Fix embed path after build
@@ -64,6 +73,22 @@ func main() {
 
 	// Инициализация менеджера событий
 	eventManager := events.New(store, cfg)
+	defer eventManager.Close()
+
+	// Режимы охраны: какие камеры уведомляют, записывают и запускают AI
+	armingManager := arming.New(store, eventManager)
+	if err := armingManager.Start(); err != nil {
+		log.Printf("Warning: Failed to load arming mode: %v", err)
+	}
+	defer armingManager.Stop()
+
+	// Очистка записей по сроку хранения и бюджету диска
+	retentionManager := retention.New(store, cfg, eventManager)
+	retentionManager.Start()
+	defer retentionManager.Stop()
+
+	// Счетчики людей и транспорта для отчетов
+	counting.New(store, eventManager).Start()
 
 	// Инициализация AI процессора
 	aiProcessor, err := ai.New(cfg.AI)
@@ -72,10 +97,26 @@ func main() {
 	}
 	defer aiProcessor.Close()
 
+	// Распознавание лиц по галерее известных людей
+	faceRecognizer, err := ai.NewFaceRecognizer(cfg.AI.Faces)
+	if err != nil {
+		log.Printf("Failed to initialize face recognition, running without it: %v", err)
+		faceRecognizer = nil
+	}
+	defer faceRecognizer.Close()
+
+	// Распознавание номеров транспорта на камерах с alpr: true
+	plateRecognizer, err := ai.NewPlateRecognizer(cfg.AI.Plates)
+	if err != nil {
+		log.Printf("Failed to initialize plate recognition, running without it: %v", err)
+		plateRecognizer = nil
+	}
+	defer plateRecognizer.Close()
+
 	// Инициализация Telegram бота
 	var telegramBot *telegram.Bot
 	if cfg.Telegram.Token != "" {
-		telegramBot, err = telegram.New(cfg.Telegram, eventManager)
+		telegramBot, err = telegram.New(cfg.Telegram, eventManager, armingManager)
 		if err != nil {
 			log.Printf("Warning: Failed to initialize Telegram bot: %v", err)
 		} else {
@@ -85,21 +126,108 @@ func main() {
 	}
 
 	// Инициализация стриминг сервера
-	streamingServer, err := streaming.New(cfg.Streaming, eventManager, aiProcessor)
+	streamingServer, err := streaming.New(cfg, store, eventManager, armingManager, aiProcessor, faceRecognizer, plateRecognizer)
 	if err != nil {
 		log.Fatalf("Failed to initialize streaming server: %v", err)
 	}
 	defer streamingServer.Close()
 
+	if err := streamingServer.ReloadFaceGallery(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	// Запуск стриминг сервера
 	go func() {
 		if err := streamingServer.Start(); err != nil {
 			log.Printf("Streaming server error: %v", err)
+			return
+		}
+
+		// Подключаем камеры из конфигурации
+		for _, camera := range cfg.Cameras {
+			if err := store.SaveCamera(&storage.Camera{
+				ID:              camera.ID,
+				Name:            camera.Name,
+				RTSPURL:         camera.RTSPURL,
+				Status:          "offline",
+				MotionDetection: camera.MotionDetection,
+				AIDetection:     camera.AIDetection,
+				Sensitivity:     camera.Sensitivity,
+			}); err != nil {
+				log.Printf("Failed to register camera %s: %v", camera.ID, err)
+			}
+
+			if err := streamingServer.AddCamera(camera); err != nil {
+				log.Printf("Failed to add camera %s: %v", camera.ID, err)
+			}
 		}
 	}()
 
+	// WebSocket hub для уведомлений в реальном времени
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	wsHub := websocket.NewHub()
+	go wsHub.Run(hubCtx)
+	notifications := websocket.NewNotificationService(wsHub)
+
+	// Новые события в веб-интерфейс
+	eventManager.Subscribe("websocket", func(event events.Event) error {
+		notifications.NotifyNewEvent(event)
+		return nil
+	})
+
+	// Экспорт записей в фоне
+	exportManager := export.New(store, cfg, func(job storage.Export) {
+		notifications.NotifyExportProgress(job)
+	})
+	exportManager.Start()
+	defer exportManager.Stop()
+
+	// Повторный анализ записей, не затрагивающий живую обработку: свой экземпляр детектора,
+	// чтобы задания не стояли в очереди к модели живых камер
+	reanalysisProcessor, err := ai.New(cfg.AI)
+	if err != nil {
+		log.Fatalf("Failed to initialize AI for reanalysis: %v", err)
+	}
+	defer reanalysisProcessor.Close()
+
+	reanalysisManager := reanalysis.New(store, cfg, reanalysisProcessor, func(job storage.ReanalysisJob) {
+		notifications.NotifyReanalysisProgress(job)
+	})
+	reanalysisManager.Start()
+	defer reanalysisManager.Stop()
+
+	// Отправка событий на внешние вебхуки
+	webhookManager := webhooks.New(store, cfg, eventManager)
+	if err := webhookManager.Start(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	defer webhookManager.Stop()
+
+	// Пользовательские правила: триггер, условия и действия на события
+	var notifier rules.Notifier
+	if telegramBot != nil {
+		notifier = telegramBot
+	}
+	ruleEngine := rules.New(store, notifier, webhookManager, streamingServer, armingManager)
+	if err := ruleEngine.Start(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	defer ruleEngine.Stop()
+	eventManager.SetRuleEvaluator(ruleEngine)
+
+	// Публикация статусов и событий камер в MQTT для Home Assistant
+	if cfg.MQTT.Enabled {
+		mqttPublisher := mqtt.New(cfg.MQTT, store, eventManager, streamingServer)
+		if err := mqttPublisher.Start(); err != nil {
+			log.Printf("Warning: Failed to start MQTT publisher: %v", err)
+		} else {
+			defer mqttPublisher.Stop()
+		}
+	}
+
 	// Инициализация веб-сервера
-	webServer, err := web.New(cfg, store, eventManager, streamingServer, webAssets, store.GetDB())
+	webServer, err := web.New(cfg, store, eventManager, streamingServer, webAssets, store.GetDB(), wsHub, exportManager, reanalysisManager, webhookManager, ruleEngine, armingManager)
 	if err != nil {
 		log.Fatalf("Failed to initialize web server: %v", err)
 	}
@@ -31,24 +31,19 @@ type BBox struct {
 
 // Processor обрабатывает видео с помощью AI
 type Processor struct {
-	config      config.AIConfig
-	net         *gocv.Net
-	outputNames []string
-	classes     []string
-	enabled     bool
-	mu          sync.RWMutex
+	config    config.AIConfig
+	detectors map[string]Detector // детекторы по имени модели, "" - модель по умолчанию
+	classes   map[string]bool     // классы, о которых сообщаем; пустой - все
+	enabled   bool
+	mu        sync.RWMutex
 }
 
 // New создает новый AI процессор
 func New(cfg config.AIConfig) (*Processor, error) {
-	processor := &Processor{
-		config:  cfg,
-		classes: cfg.Classes,
-		enabled: cfg.Enabled,
-	}
+	processor := newProcessor(cfg)
 
 	if cfg.Enabled {
-		if err := processor.loadModel(); err != nil {
+		if err := processor.loadModels(); err != nil {
 			log.Printf("Failed to load AI model, running without AI: %v", err)
 			processor.enabled = false
 		}
@@ -57,50 +52,70 @@ func New(cfg config.AIConfig) (*Processor, error) {
 	return processor, nil
 }
 
-// loadModel загружает ONNX модель
-func (p *Processor) loadModel() error {
-	if _, err := os.Stat(p.config.ModelPath); os.IsNotExist(err) {
-		return fmt.Errorf("model file not found: %s", p.config.ModelPath)
+// NewWithDetector создает AI процессор с готовым детектором модели по умолчанию
+func NewWithDetector(cfg config.AIConfig, detector Detector) *Processor {
+	processor := newProcessor(cfg)
+	processor.detectors[""] = detector
+	return processor
+}
+
+// newProcessor создает процессор без загруженных моделей
+func newProcessor(cfg config.AIConfig) *Processor {
+	processor := &Processor{
+		config:    cfg,
+		detectors: make(map[string]Detector),
+		classes:   make(map[string]bool),
+		enabled:   cfg.Enabled,
 	}
 
-	// Загружаем модель
-	net := gocv.ReadNet(p.config.ModelPath, "")
-	if net.Empty() {
-		return fmt.Errorf("failed to load model from %s", p.config.ModelPath)
+	for _, class := range cfg.Classes {
+		processor.classes[class] = true
 	}
 
-	// Устанавливаем backend
-	switch p.config.DeviceType {
-	case "gpu":
-		net.SetPreferableBackend(gocv.NetBackendCUDA)
-		net.SetPreferableTarget(gocv.NetTargetCUDA)
-	default:
-		net.SetPreferableBackend(gocv.NetBackendOpenCV)
-		net.SetPreferableTarget(gocv.NetTargetCPU)
+	return processor
+}
+
+// loadModels создает детекторы модели по умолчанию и дополнительных моделей
+func (p *Processor) loadModels() error {
+	detector, err := NewDetector(p.config.ModelConfig, p.config.Threshold, p.config.NMSThreshold)
+	if err != nil {
+		return err
 	}
+	p.detectors[""] = detector
 
-	// Получаем имена выходных слоев - исправлено для совместимости с gocv
-	outputNames := net.GetUnconnectedOutLayers()
-	var outputLayerNames []string
-	for _, layerIndex := range outputNames {
-		layerNames := net.GetLayerNames()
-		if layerIndex < len(layerNames) {
-			outputLayerNames = append(outputLayerNames, layerNames[layerIndex])
+	// Камеры с незагрузившейся моделью используют модель по умолчанию
+	for name, model := range p.config.Models {
+		detector, err := NewDetector(model, p.config.Threshold, p.config.NMSThreshold)
+		if err != nil {
+			log.Printf("Failed to load AI model %s, falling back to default: %v", name, err)
+			continue
 		}
+		p.detectors[name] = detector
+		log.Printf("AI model %s loaded (%s backend)", name, backendName(model.Backend))
 	}
 
-	p.net = &net
-	p.outputNames = outputLayerNames
-
-	log.Printf("AI model loaded successfully: %s", p.config.ModelPath)
 	return nil
 }
 
+// backendName возвращает имя бэкенда с учетом значения по умолчанию
+func backendName(backend string) string {
+	if backend == "" {
+		return BackendOpenCV
+	}
+	return backend
+}
+
 // Close закрывает процессор
 func (p *Processor) Close() {
-	if p.net != nil {
-		p.net.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, detector := range p.detectors {
+		if err := detector.Close(); err != nil {
+			log.Printf("Failed to close AI model %s: %v", name, err)
+		}
 	}
+	p.detectors = make(map[string]Detector)
 }
 
 // IsEnabled возвращает статус AI
@@ -115,8 +130,8 @@ func (p *Processor) SetEnabled(enabled bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if enabled && !p.enabled && p.net == nil {
-		if err := p.loadModel(); err != nil {
+	if enabled && !p.enabled && p.detectors[""] == nil {
+		if err := p.loadModels(); err != nil {
 			log.Printf("Failed to enable AI: %v", err)
 			return
 		}
@@ -126,163 +141,94 @@ func (p *Processor) SetEnabled(enabled bool) {
 	log.Printf("AI processing %s", map[bool]string{true: "enabled", false: "disabled"}[enabled])
 }
 
-// ProcessFrame обрабатывает кадр и возвращает детекции
-func (p *Processor) ProcessFrame(frame gocv.Mat) ([]Detection, error) {
-	if !p.IsEnabled() || p.net == nil {
-		return nil, nil
-	}
-
+// HasModel проверяет, загружена ли модель; пустое имя - модель по умолчанию
+func (p *Processor) HasModel(name string) bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
+	return p.detectors[name] != nil
+}
 
-	// Подготавливаем blob из изображения - исправлено количество аргументов
-	blob := gocv.BlobFromImage(frame, 1.0/255.0, image.Pt(640, 640), gocv.NewScalar(0, 0, 0, 0), true, false)
-	defer blob.Close()
-
-	// Устанавливаем входные данные
-	p.net.SetInput(blob, "")
-
-	// Выполняем forward pass
-	outputs := p.net.ForwardLayers(p.outputNames)
-	defer func() {
-		for _, output := range outputs {
-			output.Close()
-		}
-	}()
+// ProcessFrame обрабатывает кадр моделью по умолчанию и возвращает детекции
+func (p *Processor) ProcessFrame(frame gocv.Mat) ([]Detection, error) {
+	return p.ProcessFrameWithModel("", frame)
+}
 
-	if len(outputs) == 0 {
-		return nil, fmt.Errorf("no outputs from model")
+// ProcessFrameWithModel обрабатывает кадр указанной моделью.
+// Неизвестная модель заменяется моделью по умолчанию.
+func (p *Processor) ProcessFrameWithModel(model string, frame gocv.Mat) ([]Detection, error) {
+	if !p.IsEnabled() {
+		return nil, nil
 	}
 
-	// Парсим результаты
-	detections := p.parseDetections(outputs[0], frame.Cols(), frame.Rows())
-
-	// Фильтруем по confidence
-	var filteredDetections []Detection
-	for _, det := range detections {
-		if det.Confidence >= p.config.Threshold {
-			filteredDetections = append(filteredDetections, det)
-		}
+	detections, err := p.detect(model, frame)
+	if err != nil || detections == nil {
+		return nil, err
 	}
 
-	return filteredDetections, nil
+	return p.filter(detections, p.config.Threshold, p.classes), nil
 }
 
-// parseDetections парсит выходные данные модели
-func (p *Processor) parseDetections(output gocv.Mat, frameWidth, frameHeight int) []Detection {
-	var detections []Detection
-
-	// YOLOv8 output format: [batch_size, num_classes + 4, num_detections]
-	// где 4 - это x, y, width, height
-
-	rows := output.Size()[1] // num_detections
-	cols := output.Size()[2] // num_classes + 4
-
-	if cols < 4 {
-		return detections
+// ProcessFrameWithOptions обрабатывает кадр указанной моделью со своим порогом и классами,
+// не завися от выключателя AI живых камер. Используется для повторного анализа записей.
+// Порог ниже порога, с которым загружена модель, ничего не добавит.
+func (p *Processor) ProcessFrameWithOptions(model string, frame gocv.Mat, threshold float32, classes []string) ([]Detection, error) {
+	p.mu.RLock()
+	loaded := p.detectors[""] != nil || p.detectors[model] != nil
+	p.mu.RUnlock()
+	if !loaded {
+		return nil, fmt.Errorf("AI model is not loaded")
 	}
 
-	for i := 0; i < rows; i++ {
-		// Получаем координаты bounding box - исправлено количество аргументов
-		x := output.GetFloatAt(i, 0)
-		y := output.GetFloatAt(i, 1)
-		w := output.GetFloatAt(i, 2)
-		h := output.GetFloatAt(i, 3)
-
-		// Находим класс с максимальной вероятностью
-		maxConfidence := float32(0)
-		classID := -1
-
-		for j := 4; j < cols; j++ {
-			confidence := output.GetFloatAt(i, j)
-			if confidence > maxConfidence {
-				maxConfidence = confidence
-				classID = j - 4
-			}
-		}
-
-		// Проверяем threshold и валидность класса
-		if maxConfidence < p.config.Threshold || classID < 0 || classID >= len(p.classes) {
-			continue
-		}
-
-		// Преобразуем координаты относительно размера кадра
-		bbox := BBox{
-			X:      int((x - w/2) * float32(frameWidth)),
-			Y:      int((y - h/2) * float32(frameHeight)),
-			Width:  int(w * float32(frameWidth)),
-			Height: int(h * float32(frameHeight)),
-		}
+	detections, err := p.detect(model, frame)
+	if err != nil || detections == nil {
+		return nil, err
+	}
 
-		// Ограничиваем координаты размерами кадра
-		if bbox.X < 0 {
-			bbox.X = 0
-		}
-		if bbox.Y < 0 {
-			bbox.Y = 0
-		}
-		if bbox.X+bbox.Width > frameWidth {
-			bbox.Width = frameWidth - bbox.X
-		}
-		if bbox.Y+bbox.Height > frameHeight {
-			bbox.Height = frameHeight - bbox.Y
-		}
+	if threshold <= 0 {
+		threshold = p.config.Threshold
+	}
 
-		detection := Detection{
-			Class:      p.classes[classID],
-			Confidence: maxConfidence,
-			BBox:       bbox,
+	wanted := p.classes
+	if len(classes) > 0 {
+		wanted = make(map[string]bool)
+		for _, class := range classes {
+			wanted[class] = true
 		}
-
-		detections = append(detections, detection)
 	}
 
-	return detections
+	return p.filter(detections, threshold, wanted), nil
 }
 
-// DetectMotion обнаруживает движение между кадрами
-func DetectMotion(prevFrame, currentFrame gocv.Mat, threshold float64) bool {
-	if prevFrame.Empty() || currentFrame.Empty() {
-		return false
-	}
-
-	// Конвертируем в grayscale
-	var gray1, gray2 gocv.Mat
-	defer gray1.Close()
-	defer gray2.Close()
-
-	if prevFrame.Channels() > 1 {
-		gray1 = gocv.NewMat()
-		gocv.CvtColor(prevFrame, &gray1, gocv.ColorBGRToGray)
-	} else {
-		gray1 = prevFrame.Clone()
+// detect прогоняет кадр через детектор модели
+func (p *Processor) detect(model string, frame gocv.Mat) ([]Detection, error) {
+	p.mu.RLock()
+	detector, ok := p.detectors[model]
+	if !ok {
+		detector = p.detectors[""]
 	}
+	p.mu.RUnlock()
 
-	if currentFrame.Channels() > 1 {
-		gray2 = gocv.NewMat()
-		gocv.CvtColor(currentFrame, &gray2, gocv.ColorBGRToGray)
-	} else {
-		gray2 = currentFrame.Clone()
+	if detector == nil || frame.Empty() {
+		return nil, nil
 	}
 
-	// Вычисляем разность
-	diff := gocv.NewMat()
-	defer diff.Close()
-	gocv.AbsDiff(gray1, gray2, &diff)
-
-	// Применяем пороговое значение - исправлено на float32
-	thresh := gocv.NewMat()
-	defer thresh.Close()
-	gocv.Threshold(diff, &thresh, float32(threshold), 255, gocv.ThresholdBinary)
-
-	// Подсчитываем количество ненулевых пикселей
-	nonZero := gocv.CountNonZero(thresh)
+	return detector.Detect(frame)
+}
 
-	// Если изменилось более 1% пикселей, считаем что есть движение
-	totalPixels := thresh.Rows() * thresh.Cols()
-	motionPercent := float64(nonZero) / float64(totalPixels)
+// filter оставляет только уверенные детекции интересующих классов
+func (p *Processor) filter(detections []Detection, threshold float32, classes map[string]bool) []Detection {
+	filtered := detections[:0]
+	for _, det := range detections {
+		if det.Confidence < threshold {
+			continue
+		}
+		if len(classes) > 0 && !classes[det.Class] {
+			continue
+		}
+		filtered = append(filtered, det)
+	}
 
-	return motionPercent > 0.01
+	return filtered
 }
 
 // DrawDetections рисует детекции на кадре
@@ -0,0 +1,175 @@
+package ai
+
+import (
+	"sync"
+	"testing"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// fakeDetector возвращает заданные детекции и считает обработанные кадры
+type fakeDetector struct {
+	detections []Detection
+	calls      int
+	mu         sync.Mutex
+}
+
+func (d *fakeDetector) Detect(frame gocv.Mat) ([]Detection, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.calls++
+	return append([]Detection(nil), d.detections...), nil
+}
+
+func (d *fakeDetector) Close() error {
+	return nil
+}
+
+func newFrame(t *testing.T) gocv.Mat {
+	frame := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+	t.Cleanup(func() { frame.Close() })
+	return frame
+}
+
+func classes(detections []Detection) []string {
+	var result []string
+	for _, det := range detections {
+		result = append(result, det.Class)
+	}
+	return result
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var sampleDetections = []Detection{
+	{Class: "person", Confidence: 0.9},
+	{Class: "car", Confidence: 0.6},
+	{Class: "person", Confidence: 0.3},
+	{Class: "dog", Confidence: 0.8},
+}
+
+func TestProcessFrameFilters(t *testing.T) {
+	detector := &fakeDetector{detections: sampleDetections}
+	processor := NewWithDetector(config.AIConfig{
+		Enabled:   true,
+		Threshold: 0.5,
+		Classes:   []string{"person", "car"},
+	}, detector)
+
+	detections, err := processor.ProcessFrame(newFrame(t))
+	if err != nil {
+		t.Fatalf("ProcessFrame: %v", err)
+	}
+
+	if got, want := classes(detections), []string{"person", "car"}; !equal(got, want) {
+		t.Errorf("classes = %v, want %v", got, want)
+	}
+}
+
+func TestProcessFrameWithoutClassesKeepsAll(t *testing.T) {
+	processor := NewWithDetector(config.AIConfig{Enabled: true, Threshold: 0.5},
+		&fakeDetector{detections: sampleDetections})
+
+	detections, err := processor.ProcessFrame(newFrame(t))
+	if err != nil {
+		t.Fatalf("ProcessFrame: %v", err)
+	}
+
+	if got, want := classes(detections), []string{"person", "car", "dog"}; !equal(got, want) {
+		t.Errorf("classes = %v, want %v", got, want)
+	}
+}
+
+func TestProcessFrameDisabled(t *testing.T) {
+	detector := &fakeDetector{detections: sampleDetections}
+	processor := NewWithDetector(config.AIConfig{Enabled: false}, detector)
+
+	detections, err := processor.ProcessFrame(newFrame(t))
+	if err != nil || detections != nil {
+		t.Errorf("disabled processor returned %v, %v", detections, err)
+	}
+	if detector.calls != 0 {
+		t.Errorf("disabled processor ran the detector %d time(s)", detector.calls)
+	}
+}
+
+func TestProcessFrameSkipsEmptyFrame(t *testing.T) {
+	detector := &fakeDetector{detections: sampleDetections}
+	processor := NewWithDetector(config.AIConfig{Enabled: true}, detector)
+
+	detections, err := processor.ProcessFrame(gocv.NewMat())
+	if err != nil || detections != nil {
+		t.Errorf("empty frame returned %v, %v", detections, err)
+	}
+	if detector.calls != 0 {
+		t.Errorf("detector ran on an empty frame")
+	}
+}
+
+func TestProcessFrameWithModelFallsBackToDefault(t *testing.T) {
+	detector := &fakeDetector{detections: sampleDetections}
+	processor := NewWithDetector(config.AIConfig{Enabled: true, Threshold: 0.5}, detector)
+
+	if processor.HasModel("plates") {
+		t.Fatal("unknown model reported as loaded")
+	}
+
+	detections, err := processor.ProcessFrameWithModel("plates", newFrame(t))
+	if err != nil {
+		t.Fatalf("ProcessFrameWithModel: %v", err)
+	}
+	if len(detections) != 3 || detector.calls != 1 {
+		t.Errorf("got %d detection(s) after %d call(s), want 3 after 1", len(detections), detector.calls)
+	}
+}
+
+func TestProcessFrameWithOptions(t *testing.T) {
+	processor := NewWithDetector(config.AIConfig{
+		Enabled:   false, // повторный анализ не зависит от выключателя AI
+		Threshold: 0.7,
+		Classes:   []string{"person"},
+	}, &fakeDetector{detections: sampleDetections})
+
+	tests := []struct {
+		name      string
+		threshold float32
+		classes   []string
+		want      []string
+	}{
+		{"config defaults", 0, nil, []string{"person"}},
+		{"own threshold", 0.25, nil, []string{"person", "person"}},
+		{"own classes", 0, []string{"dog", "car"}, []string{"dog"}},
+		{"own threshold and classes", 0.5, []string{"dog", "car"}, []string{"car", "dog"}},
+	}
+
+	for _, tt := range tests {
+		detections, err := processor.ProcessFrameWithOptions("", newFrame(t), tt.threshold, tt.classes)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := classes(detections); !equal(got, tt.want) {
+			t.Errorf("%s: classes = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessFrameWithOptionsRequiresModel(t *testing.T) {
+	processor := newProcessor(config.AIConfig{Enabled: true})
+
+	if _, err := processor.ProcessFrameWithOptions("", newFrame(t), 0.5, nil); err == nil {
+		t.Error("expected an error without a loaded model")
+	}
+}
@@ -0,0 +1,225 @@
+package ai
+
+import "time"
+
+// Направления пересечения линии, если смотреть из точки A в точку B
+const (
+	DirectionAny         = "any"
+	DirectionLeftToRight = "left_to_right"
+	DirectionRightToLeft = "right_to_left"
+)
+
+// Tripwire виртуальная линия, пересечения которой считаются
+type Tripwire struct {
+	ID        int
+	Name      string
+	A, B      Point  // концы линии в долях кадра
+	Direction string // any, left_to_right, right_to_left
+	Classes   []string
+}
+
+// LoiteringArea область, задержка объекта в которой дольше MinDwell считается подозрительной
+type LoiteringArea struct {
+	ID       int
+	Name     string
+	Points   []Point
+	MinDwell time.Duration
+	Classes  []string
+}
+
+// Crossing пересечение линии объектом
+type Crossing struct {
+	Tripwire  Tripwire
+	Track     Track
+	Direction string // left_to_right, right_to_left
+}
+
+// Loitering задержка объекта в области
+type Loitering struct {
+	Area     LoiteringArea
+	Track    Track
+	Duration time.Duration
+}
+
+// AnalyticsUpdate события аналитики после очередного кадра
+type AnalyticsUpdate struct {
+	Crossings []Crossing
+	Loitering []Loitering
+}
+
+// trackPosition положение трека для аналитики
+type trackPosition struct {
+	point   Point
+	entered map[int]time.Time // время входа в области задержки
+	fired   map[int]bool      // области, о задержке в которых уже сообщено
+}
+
+// Analytics считает пересечения линий и задержки в областях по трекам камеры.
+// Положение объекта - середина нижней стороны рамки (точка опоры).
+type Analytics struct {
+	tripwires []Tripwire
+	areas     []LoiteringArea
+	positions map[string]*trackPosition
+}
+
+// NewAnalytics создает аналитику камеры. Возвращает nil, если нет ни линий, ни областей.
+func NewAnalytics(tripwires []Tripwire, areas []LoiteringArea) *Analytics {
+	if len(tripwires) == 0 && len(areas) == 0 {
+		return nil
+	}
+
+	return &Analytics{
+		tripwires: tripwires,
+		areas:     areas,
+		positions: make(map[string]*trackPosition),
+	}
+}
+
+// Update обрабатывает изменения треков и возвращает пересечения и задержки
+func (a *Analytics) Update(update TrackUpdate, width, height int, now time.Time) AnalyticsUpdate {
+	var result AnalyticsUpdate
+	if a == nil || width <= 0 || height <= 0 {
+		return result
+	}
+
+	for _, track := range append(update.Started, update.Updated...) {
+		point := FootPoint(track.BBox, width, height)
+
+		position, exists := a.positions[track.ID]
+		if !exists {
+			position = &trackPosition{
+				point:   point,
+				entered: make(map[int]time.Time),
+				fired:   make(map[int]bool),
+			}
+			a.positions[track.ID] = position
+		} else {
+			for _, wire := range a.tripwires {
+				if direction, ok := wire.crossed(position.point, point, track.Class, width, height); ok {
+					result.Crossings = append(result.Crossings, Crossing{Tripwire: wire, Track: track, Direction: direction})
+				}
+			}
+			position.point = point
+		}
+
+		for _, area := range a.areas {
+			if !matchesClass(area.Classes, track.Class) {
+				continue
+			}
+
+			if !PointInPolygon(point, area.Points) {
+				// Объект вышел - повторный вход снова отсчитывается с нуля
+				delete(position.entered, area.ID)
+				delete(position.fired, area.ID)
+				continue
+			}
+
+			entered, inside := position.entered[area.ID]
+			if !inside {
+				position.entered[area.ID] = now
+				entered = now
+			}
+
+			if dwell := now.Sub(entered); dwell >= area.MinDwell && !position.fired[area.ID] {
+				position.fired[area.ID] = true
+				result.Loitering = append(result.Loitering, Loitering{Area: area, Track: track, Duration: dwell})
+			}
+		}
+	}
+
+	for _, track := range update.Ended {
+		delete(a.positions, track.ID)
+	}
+
+	return result
+}
+
+// Reset забывает положения всех объектов
+func (a *Analytics) Reset() {
+	if a == nil {
+		return
+	}
+	a.positions = make(map[string]*trackPosition)
+}
+
+// crossed проверяет, пересек ли отрезок движения объекта линию, и возвращает направление
+func (w Tripwire) crossed(from, to Point, class string, width, height int) (string, bool) {
+	if !matchesClass(w.Classes, class) {
+		return "", false
+	}
+
+	// Считаем в пикселях, чтобы наклон линии не искажался соотношением сторон кадра
+	ax, ay := w.A.X*float64(width), w.A.Y*float64(height)
+	bx, by := w.B.X*float64(width), w.B.Y*float64(height)
+	px, py := from.X*float64(width), from.Y*float64(height)
+	qx, qy := to.X*float64(width), to.Y*float64(height)
+
+	sideFrom := cross(ax, ay, bx, by, px, py)
+	sideTo := cross(ax, ay, bx, by, qx, qy)
+	if sideFrom == 0 || sideTo == 0 || (sideFrom > 0) == (sideTo > 0) {
+		return "", false
+	}
+
+	// Объект должен пересечь сам отрезок, а не его продолжение
+	sideA := cross(px, py, qx, qy, ax, ay)
+	sideB := cross(px, py, qx, qy, bx, by)
+	if (sideA > 0) == (sideB > 0) {
+		return "", false
+	}
+
+	// В экранных координатах (y вниз) положительная сторона справа от направления A->B
+	direction := DirectionLeftToRight
+	if sideFrom > 0 {
+		direction = DirectionRightToLeft
+	}
+
+	if w.Direction != "" && w.Direction != DirectionAny && w.Direction != direction {
+		return "", false
+	}
+
+	return direction, true
+}
+
+// cross возвращает знак положения точки p относительно прямой a->b
+func cross(ax, ay, bx, by, px, py float64) float64 {
+	return (bx-ax)*(py-ay) - (by-ay)*(px-ax)
+}
+
+// FootPoint возвращает середину нижней стороны рамки в долях кадра
+func FootPoint(bbox BBox, width, height int) Point {
+	return Point{
+		X: (float64(bbox.X) + float64(bbox.Width)/2) / float64(width),
+		Y: float64(bbox.Y+bbox.Height) / float64(height),
+	}
+}
+
+// PointInPolygon проверяет попадание точки в полигон (метод трассировки луча)
+func PointInPolygon(point Point, polygon []Point) bool {
+	if len(polygon) < 3 {
+		return false
+	}
+
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		pi, pj := polygon[i], polygon[j]
+		if (pi.Y > point.Y) != (pj.Y > point.Y) &&
+			point.X < (pj.X-pi.X)*(point.Y-pi.Y)/(pj.Y-pi.Y)+pi.X {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// matchesClass проверяет класс объекта по списку; пустой список - любой класс
+func matchesClass(classes []string, class string) bool {
+	if len(classes) == 0 {
+		return true
+	}
+	for _, c := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
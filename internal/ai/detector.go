@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// Бэкенды детекции
+const (
+	BackendOpenCV = "opencv"
+	BackendHTTP   = "http"
+	BackendNoop   = "noop"
+)
+
+// Detector находит объекты на кадре
+type Detector interface {
+	// Detect возвращает объекты на кадре в координатах кадра
+	Detect(frame gocv.Mat) ([]Detection, error)
+	// Close освобождает ресурсы детектора
+	Close() error
+}
+
+// NewDetector создает детектор по конфигурации модели
+func NewDetector(cfg config.ModelConfig, threshold, nmsThreshold float32) (Detector, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", BackendOpenCV:
+		return newDNNDetector(cfg, threshold, nmsThreshold)
+	case BackendHTTP:
+		return newHTTPDetector(cfg, nmsThreshold)
+	case BackendNoop:
+		return noopDetector{}, nil
+	default:
+		return nil, fmt.Errorf("unknown detector backend: %s", cfg.Backend)
+	}
+}
+
+// noopDetector ничего не находит - позволяет держать AI включенным без модели
+type noopDetector struct{}
+
+// Detect всегда возвращает пустой результат
+func (noopDetector) Detect(frame gocv.Mat) ([]Detection, error) {
+	return nil, nil
+}
+
+// Close ничего не делает
+func (noopDetector) Close() error {
+	return nil
+}
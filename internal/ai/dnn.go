@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"sync"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// dnnDetector детектор YOLO на OpenCV DNN
+type dnnDetector struct {
+	net          gocv.Net
+	outputNames  []string
+	labels       []string // имена классов модели по индексу
+	modelType    string
+	inputSize    int
+	threshold    float32
+	nmsThreshold float32
+	mu           sync.Mutex
+}
+
+// newDNNDetector загружает ONNX модель
+func newDNNDetector(cfg config.ModelConfig, threshold, nmsThreshold float32) (*dnnDetector, error) {
+	if _, err := os.Stat(cfg.ModelPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("model file not found: %s", cfg.ModelPath)
+	}
+
+	// Загружаем модель
+	net := gocv.ReadNet(cfg.ModelPath, "")
+	if net.Empty() {
+		return nil, fmt.Errorf("failed to load model from %s", cfg.ModelPath)
+	}
+
+	// Устанавливаем backend
+	switch cfg.DeviceType {
+	case "gpu":
+		net.SetPreferableBackend(gocv.NetBackendCUDA)
+		net.SetPreferableTarget(gocv.NetTargetCUDA)
+	default:
+		net.SetPreferableBackend(gocv.NetBackendOpenCV)
+		net.SetPreferableTarget(gocv.NetTargetCPU)
+	}
+
+	// Получаем имена выходных слоев - исправлено для совместимости с gocv
+	layerNames := net.GetLayerNames()
+	var outputNames []string
+	for _, layerIndex := range net.GetUnconnectedOutLayers() {
+		if layerIndex < len(layerNames) {
+			outputNames = append(outputNames, layerNames[layerIndex])
+		}
+	}
+
+	detector := &dnnDetector{
+		net:          net,
+		outputNames:  outputNames,
+		labels:       cfg.Labels,
+		modelType:    cfg.ModelType,
+		inputSize:    cfg.InputSize,
+		threshold:    threshold,
+		nmsThreshold: nmsThreshold,
+	}
+
+	if len(detector.labels) == 0 {
+		detector.labels = cocoLabels
+	}
+	if detector.inputSize <= 0 {
+		detector.inputSize = 640
+	}
+	if detector.nmsThreshold <= 0 {
+		detector.nmsThreshold = 0.45
+	}
+
+	log.Printf("AI model loaded successfully: %s", cfg.ModelPath)
+	return detector, nil
+}
+
+// Detect прогоняет кадр через модель
+func (d *dnnDetector) Detect(frame gocv.Mat) ([]Detection, error) {
+	// Вписываем кадр в квадрат модели без искажения пропорций
+	box := newLetterbox(frame.Cols(), frame.Rows(), d.inputSize)
+	input := gocv.NewMat()
+	defer input.Close()
+	box.apply(frame, &input)
+
+	blob := gocv.BlobFromImage(input, 1.0/255.0, image.Pt(d.inputSize, d.inputSize), gocv.NewScalar(0, 0, 0, 0), true, false)
+	defer blob.Close()
+
+	// gocv.Net не потокобезопасен - кадры разных камер обрабатываются по очереди
+	d.mu.Lock()
+	d.net.SetInput(blob, "")
+	outputs := d.net.ForwardLayers(d.outputNames)
+	d.mu.Unlock()
+
+	defer func() {
+		for _, output := range outputs {
+			output.Close()
+		}
+	}()
+
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("no outputs from model")
+	}
+
+	detections, err := decodeYOLO(outputs[0], d.modelType, d.labels, d.threshold, box)
+	if err != nil {
+		return nil, err
+	}
+
+	return nonMaxSuppression(detections, d.nmsThreshold), nil
+}
+
+// Close освобождает модель
+func (d *dnnDetector) Close() error {
+	return d.net.Close()
+}
@@ -0,0 +1,427 @@
+package ai
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// Бэкенды распознавания лиц
+const (
+	FaceDetectorHaar = "haar"
+	FaceEmbedderONNX = "onnx"
+	FaceBackendStub  = "stub" // детерминированная заглушка без моделей
+)
+
+// defaultMatchThreshold порог косинусного сходства с галереей по умолчанию
+const defaultMatchThreshold = 0.5
+
+// FaceDetector находит лица на изображении
+type FaceDetector interface {
+	DetectFaces(img gocv.Mat) []image.Rectangle
+	Close() error
+}
+
+// FaceEmbedder вычисляет нормированный вектор признаков лица
+type FaceEmbedder interface {
+	Embed(face gocv.Mat) ([]float32, error)
+	Close() error
+}
+
+// GalleryFace эталонное лицо известного человека
+type GalleryFace struct {
+	PersonID  int
+	Name      string
+	Embedding []float32
+}
+
+// FaceMatch результат распознавания лица
+type FaceMatch struct {
+	Known      bool    `json:"known"`
+	PersonID   int     `json:"person_id,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Similarity float32 `json:"similarity"`
+	BBox       BBox    `json:"bbox"` // лицо в координатах кадра
+}
+
+// Label возвращает подпись лица для событий: "known: Alice" или "unknown face"
+func (m FaceMatch) Label() string {
+	if m.Known {
+		return "known: " + m.Name
+	}
+	return "unknown face"
+}
+
+// FaceRecognizer ищет лица в рамках людей и сравнивает их с галереей.
+// Нулевой указатель означает, что распознавание выключено.
+type FaceRecognizer struct {
+	detector    FaceDetector
+	embedder    FaceEmbedder
+	threshold   float32
+	minFaceSize int
+	gallery     []GalleryFace
+	galleryMu   sync.RWMutex
+	mu          sync.Mutex // каскад и сеть не потокобезопасны
+}
+
+// NewFaceRecognizer создает распознавание лиц. Возвращает nil, если оно выключено.
+func NewFaceRecognizer(cfg config.FaceConfig) (*FaceRecognizer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	detector, err := newFaceDetector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	embedder, err := newFaceEmbedder(cfg)
+	if err != nil {
+		detector.Close()
+		return nil, err
+	}
+
+	recognizer := NewFaceRecognizerWith(detector, embedder, cfg.MatchThreshold)
+	recognizer.minFaceSize = cfg.MinFaceSize
+
+	log.Printf("Face recognition enabled (detector: %s, embedder: %s)", cfg.Detector, cfg.Embedder)
+	return recognizer, nil
+}
+
+// NewFaceRecognizerWith создает распознавание лиц с готовыми детектором и эмбеддером.
+// Порог сходства threshold <= 0 заменяется порогом по умолчанию.
+func NewFaceRecognizerWith(detector FaceDetector, embedder FaceEmbedder, threshold float32) *FaceRecognizer {
+	if threshold <= 0 {
+		threshold = defaultMatchThreshold
+	}
+
+	return &FaceRecognizer{
+		detector:  detector,
+		embedder:  embedder,
+		threshold: threshold,
+	}
+}
+
+// SetGallery заменяет галерею известных лиц
+func (r *FaceRecognizer) SetGallery(faces []GalleryFace) {
+	if r == nil {
+		return
+	}
+
+	r.galleryMu.Lock()
+	r.gallery = faces
+	r.galleryMu.Unlock()
+}
+
+// Recognize ищет лицо в рамке человека. Возвращает nil, если лицо не найдено.
+func (r *FaceRecognizer) Recognize(frame gocv.Mat, person BBox) (*FaceMatch, error) {
+	if r == nil || frame.Empty() {
+		return nil, nil
+	}
+
+	rect := image.Rect(person.X, person.Y, person.X+person.Width, person.Y+person.Height).
+		Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	if rect.Empty() {
+		return nil, nil
+	}
+
+	crop := frame.Region(rect)
+	defer crop.Close()
+
+	face, embedding, err := r.embedLargestFace(crop, false)
+	if err != nil || embedding == nil {
+		return nil, err
+	}
+
+	match := r.match(embedding)
+	match.BBox = BBox{
+		X:      rect.Min.X + face.Min.X,
+		Y:      rect.Min.Y + face.Min.Y,
+		Width:  face.Dx(),
+		Height: face.Dy(),
+	}
+
+	return &match, nil
+}
+
+// EmbedPhoto вычисляет эмбеддинг крупнейшего лица на эталонном фото
+func (r *FaceRecognizer) EmbedPhoto(photo gocv.Mat) ([]float32, error) {
+	if r == nil {
+		return nil, fmt.Errorf("face recognition is disabled")
+	}
+	if photo.Empty() {
+		return nil, fmt.Errorf("empty photo")
+	}
+
+	_, embedding, err := r.embedLargestFace(photo, true)
+	if err != nil {
+		return nil, err
+	}
+	if embedding == nil {
+		return nil, fmt.Errorf("no face found on the photo")
+	}
+
+	return embedding, nil
+}
+
+// Close освобождает модели
+func (r *FaceRecognizer) Close() {
+	if r == nil {
+		return
+	}
+
+	r.detector.Close()
+	r.embedder.Close()
+}
+
+// embedLargestFace находит крупнейшее лицо и вычисляет его эмбеддинг.
+// Для эталонных фото мелкие лица не отбрасываются.
+func (r *FaceRecognizer) embedLargestFace(img gocv.Mat, photo bool) (image.Rectangle, []float32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var largest image.Rectangle
+	for _, face := range r.detector.DetectFaces(img) {
+		if face.Dx()*face.Dy() > largest.Dx()*largest.Dy() {
+			largest = face
+		}
+	}
+
+	if largest.Empty() || (!photo && (largest.Dx() < r.minFaceSize || largest.Dy() < r.minFaceSize)) {
+		return image.Rectangle{}, nil, nil
+	}
+
+	face := img.Region(largest)
+	defer face.Close()
+
+	embedding, err := r.embedder.Embed(face)
+	if err != nil {
+		return image.Rectangle{}, nil, fmt.Errorf("failed to compute face embedding: %w", err)
+	}
+
+	return largest, embedding, nil
+}
+
+// match сравнивает эмбеддинг с галереей по косинусному сходству
+func (r *FaceRecognizer) match(embedding []float32) FaceMatch {
+	r.galleryMu.RLock()
+	defer r.galleryMu.RUnlock()
+
+	// Пустая галерея или лица без сходства дают неизвестное лицо
+	var best FaceMatch
+	found := false
+	for _, face := range r.gallery {
+		similarity := CosineSimilarity(embedding, face.Embedding)
+		if similarity > best.Similarity {
+			best = FaceMatch{PersonID: face.PersonID, Name: face.Name, Similarity: similarity}
+			found = true
+		}
+	}
+
+	if !found || best.Similarity < r.threshold {
+		return FaceMatch{Similarity: best.Similarity}
+	}
+
+	best.Known = true
+	return best
+}
+
+// CosineSimilarity возвращает косинусное сходство векторов
+func CosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// normalize приводит вектор к единичной длине
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// newFaceDetector создает детектор лиц по конфигурации
+func newFaceDetector(cfg config.FaceConfig) (FaceDetector, error) {
+	switch strings.ToLower(cfg.Detector) {
+	case "", FaceDetectorHaar:
+		if _, err := os.Stat(cfg.DetectorModel); err != nil {
+			return nil, fmt.Errorf("face detector model not found: %s", cfg.DetectorModel)
+		}
+
+		classifier := gocv.NewCascadeClassifier()
+		if !classifier.Load(cfg.DetectorModel) {
+			classifier.Close()
+			return nil, fmt.Errorf("failed to load face detector from %s", cfg.DetectorModel)
+		}
+		return &haarFaceDetector{classifier: classifier, minSize: cfg.MinFaceSize}, nil
+	case FaceBackendStub:
+		return stubFaceDetector{}, nil
+	default:
+		return nil, fmt.Errorf("unknown face detector: %s", cfg.Detector)
+	}
+}
+
+// newFaceEmbedder создает модель эмбеддингов по конфигурации
+func newFaceEmbedder(cfg config.FaceConfig) (FaceEmbedder, error) {
+	switch strings.ToLower(cfg.Embedder) {
+	case "", FaceEmbedderONNX:
+		if _, err := os.Stat(cfg.EmbeddingModel); err != nil {
+			return nil, fmt.Errorf("face embedding model not found: %s", cfg.EmbeddingModel)
+		}
+
+		net := gocv.ReadNet(cfg.EmbeddingModel, "")
+		if net.Empty() {
+			return nil, fmt.Errorf("failed to load face embedding model from %s", cfg.EmbeddingModel)
+		}
+		net.SetPreferableBackend(gocv.NetBackendOpenCV)
+		net.SetPreferableTarget(gocv.NetTargetCPU)
+
+		size := cfg.InputSize
+		if size <= 0 {
+			size = 112
+		}
+		return &onnxFaceEmbedder{net: net, size: size}, nil
+	case FaceBackendStub:
+		return stubFaceEmbedder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown face embedder: %s", cfg.Embedder)
+	}
+}
+
+// haarFaceDetector детектор лиц на каскаде Хаара (CPU)
+type haarFaceDetector struct {
+	classifier gocv.CascadeClassifier
+	minSize    int
+}
+
+// DetectFaces находит лица на изображении
+func (d *haarFaceDetector) DetectFaces(img gocv.Mat) []image.Rectangle {
+	gray := gocv.NewMat()
+	defer gray.Close()
+
+	gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
+	gocv.EqualizeHist(gray, &gray)
+
+	minSize := image.Pt(d.minSize, d.minSize)
+	return d.classifier.DetectMultiScaleWithParams(gray, 1.1, 5, 0, minSize, image.Pt(0, 0))
+}
+
+// Close освобождает каскад
+func (d *haarFaceDetector) Close() error {
+	return d.classifier.Close()
+}
+
+// onnxFaceEmbedder модель эмбеддингов лиц в формате ONNX (ArcFace, MobileFaceNet)
+type onnxFaceEmbedder struct {
+	net  gocv.Net
+	size int
+}
+
+// Embed вычисляет нормированный эмбеддинг лица
+func (e *onnxFaceEmbedder) Embed(face gocv.Mat) ([]float32, error) {
+	blob := gocv.BlobFromImage(face, 1.0/127.5, image.Pt(e.size, e.size), gocv.NewScalar(127.5, 127.5, 127.5, 0), true, false)
+	defer blob.Close()
+
+	e.net.SetInput(blob, "")
+	output := e.net.Forward("")
+	defer output.Close()
+
+	data, err := output.DataPtrFloat32()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty embedding")
+	}
+
+	return normalize(append([]float32(nil), data...)), nil
+}
+
+// Close освобождает модель
+func (e *onnxFaceEmbedder) Close() error {
+	return e.net.Close()
+}
+
+// stubFaceDetector считает лицом все изображение
+type stubFaceDetector struct{}
+
+// DetectFaces возвращает рамку всего изображения
+func (stubFaceDetector) DetectFaces(img gocv.Mat) []image.Rectangle {
+	return []image.Rectangle{image.Rect(0, 0, img.Cols(), img.Rows())}
+}
+
+// Close ничего не делает
+func (stubFaceDetector) Close() error {
+	return nil
+}
+
+// stubFaceEmbedder детерминированный эмбеддинг: уменьшенное до 16x16 полутоновое изображение
+// без среднего. Одинаковые изображения дают сходство 1, непохожие - около 0.
+type stubFaceEmbedder struct{}
+
+// stubEmbeddingSize сторона уменьшенного изображения заглушки
+const stubEmbeddingSize = 16
+
+// Embed вычисляет эмбеддинг-заглушку
+func (stubFaceEmbedder) Embed(face gocv.Mat) ([]float32, error) {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(face, &gray, gocv.ColorBGRToGray)
+
+	small := gocv.NewMat()
+	defer small.Close()
+	gocv.Resize(gray, &small, image.Pt(stubEmbeddingSize, stubEmbeddingSize), 0, 0, gocv.InterpolationArea)
+
+	pixels := small.ToBytes()
+	if len(pixels) == 0 {
+		return nil, fmt.Errorf("empty face image")
+	}
+
+	var mean float32
+	for _, p := range pixels {
+		mean += float32(p)
+	}
+	mean /= float32(len(pixels))
+
+	embedding := make([]float32, len(pixels))
+	for i, p := range pixels {
+		embedding[i] = float32(p) - mean
+	}
+
+	return normalize(embedding), nil
+}
+
+// Close ничего не делает
+func (stubFaceEmbedder) Close() error {
+	return nil
+}
@@ -0,0 +1,154 @@
+package ai
+
+import (
+	"math"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// fakeEmbedder возвращает заданный эмбеддинг для любого лица
+type fakeEmbedder struct {
+	embedding []float32
+	calls     int
+}
+
+func (e *fakeEmbedder) Embed(face gocv.Mat) ([]float32, error) {
+	e.calls++
+	return e.embedding, nil
+}
+
+func (e *fakeEmbedder) Close() error {
+	return nil
+}
+
+var gallery = []GalleryFace{
+	{PersonID: 1, Name: "Alice", Embedding: []float32{1, 0, 0}},
+	{PersonID: 2, Name: "Bob", Embedding: []float32{0, 1, 0}},
+}
+
+func TestRecognizeGalleryMatch(t *testing.T) {
+	embedder := &fakeEmbedder{embedding: []float32{0.2, 0.9, 0.1}}
+	recognizer := NewFaceRecognizerWith(stubFaceDetector{}, embedder, 0.8)
+	recognizer.SetGallery(gallery)
+
+	face, err := recognizer.Recognize(newFrame(t), BBox{X: 100, Y: 50, Width: 60, Height: 120})
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if face == nil {
+		t.Fatal("face not found")
+	}
+
+	if !face.Known || face.PersonID != 2 || face.Name != "Bob" || face.Label() != "known: Bob" {
+		t.Errorf("face = %+v, want Bob", face)
+	}
+	if face.Similarity < 0.95 {
+		t.Errorf("similarity = %v, want about 0.97", face.Similarity)
+	}
+	// Заглушка считает лицом всю рамку человека
+	if want := (BBox{X: 100, Y: 50, Width: 60, Height: 120}); face.BBox != want {
+		t.Errorf("bbox = %+v, want %+v", face.BBox, want)
+	}
+}
+
+func TestRecognizeUnknownFace(t *testing.T) {
+	embedder := &fakeEmbedder{embedding: []float32{0.6, 0.6, 0.5}}
+	recognizer := NewFaceRecognizerWith(stubFaceDetector{}, embedder, 0.8)
+	recognizer.SetGallery(gallery)
+
+	face, err := recognizer.Recognize(newFrame(t), BBox{X: 0, Y: 0, Width: 100, Height: 100})
+	if err != nil || face == nil {
+		t.Fatalf("Recognize = %+v, %v", face, err)
+	}
+
+	// Лицо похоже на обоих, но ни на кого не выше порога
+	if face.Known || face.PersonID != 0 || face.Name != "" || face.Label() != "unknown face" {
+		t.Errorf("face = %+v, want an unknown face", face)
+	}
+	if face.Similarity <= 0 || face.Similarity >= 0.8 {
+		t.Errorf("similarity = %v, want the best similarity below the threshold", face.Similarity)
+	}
+}
+
+func TestRecognizeThreshold(t *testing.T) {
+	embedding := []float32{1, 0.6, 0} // сходство с Alice около 0.86
+	tests := []struct {
+		threshold float32
+		known     bool
+	}{
+		{0.85, true},
+		{0.9, false},
+		{0, true}, // порог по умолчанию
+	}
+
+	for _, tt := range tests {
+		recognizer := NewFaceRecognizerWith(stubFaceDetector{}, &fakeEmbedder{embedding: embedding}, tt.threshold)
+		recognizer.SetGallery(gallery)
+
+		face, err := recognizer.Recognize(newFrame(t), BBox{Width: 100, Height: 100})
+		if err != nil || face == nil {
+			t.Fatalf("threshold %v: Recognize = %+v, %v", tt.threshold, face, err)
+		}
+		if face.Known != tt.known || (tt.known && face.Name != "Alice") {
+			t.Errorf("threshold %v: face = %+v, want known %v", tt.threshold, face, tt.known)
+		}
+	}
+}
+
+func TestRecognizeEmptyGallery(t *testing.T) {
+	// С порогом по умолчанию и без галереи лицо не может оказаться известным
+	for _, threshold := range []float32{0, -1} {
+		recognizer := NewFaceRecognizerWith(stubFaceDetector{}, &fakeEmbedder{embedding: []float32{1, 0, 0}}, threshold)
+
+		face, err := recognizer.Recognize(newFrame(t), BBox{Width: 100, Height: 100})
+		if err != nil || face == nil {
+			t.Fatalf("Recognize = %+v, %v", face, err)
+		}
+		if face.Known || face.PersonID != 0 {
+			t.Errorf("threshold %v: face = %+v, want an unknown face", threshold, face)
+		}
+	}
+}
+
+func TestRecognizeSmallFace(t *testing.T) {
+	embedder := &fakeEmbedder{embedding: []float32{1, 0, 0}}
+	recognizer := NewFaceRecognizerWith(stubFaceDetector{}, embedder, 0.5)
+	recognizer.minFaceSize = 80
+	recognizer.SetGallery(gallery)
+
+	face, err := recognizer.Recognize(newFrame(t), BBox{X: 10, Y: 10, Width: 60, Height: 120})
+	if err != nil || face != nil {
+		t.Errorf("Recognize = %+v, %v, want no face", face, err)
+	}
+	if embedder.calls != 0 {
+		t.Error("small face was sent to the embedder")
+	}
+
+	// Для эталонных фото мелкие лица не отбрасываются
+	photo := gocv.NewMatWithSize(60, 60, gocv.MatTypeCV8UC3)
+	defer photo.Close()
+	if _, err := recognizer.EmbedPhoto(photo); err != nil {
+		t.Errorf("EmbedPhoto: %v", err)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float32
+	}{
+		{[]float32{1, 0}, []float32{2, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 3}, 0},
+		{[]float32{1, 1}, []float32{-1, -1}, -1},
+		{[]float32{1, 0}, []float32{1, 0, 0}, 0}, // разная длина
+		{[]float32{0, 0}, []float32{1, 0}, 0},
+		{nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		if got := CosineSimilarity(tt.a, tt.b); math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("CosineSimilarity(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
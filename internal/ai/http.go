@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"time"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// httpDetector отправляет кадры на внешний сервер инференса.
+// Кадр передается как image/jpeg, ответ - {"detections": [{"class", "confidence", "bbox"}]}
+// в координатах переданного кадра.
+type httpDetector struct {
+	url          string
+	apiKey       string
+	nmsThreshold float32
+	client       *http.Client
+}
+
+// httpDetectResponse ответ сервера инференса
+type httpDetectResponse struct {
+	Detections []Detection `json:"detections"`
+}
+
+// newHTTPDetector создает клиент сервера инференса
+func newHTTPDetector(cfg config.ModelConfig, nmsThreshold float32) (*httpDetector, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required for the http detector backend")
+	}
+
+	timeout := time.Duration(cfg.TimeoutMS) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	return &httpDetector{
+		url:          cfg.URL,
+		apiKey:       cfg.APIKey,
+		nmsThreshold: nmsThreshold,
+		client:       &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Detect отправляет кадр на сервер и возвращает найденные объекты
+func (d *httpDetector) Detect(frame gocv.Mat) ([]Detection, error) {
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, frame)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode frame: %w", err)
+	}
+	defer buf.Close()
+
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(buf.GetBytes()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "image/jpeg")
+	if d.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+d.apiKey)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("inference request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("inference server returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var result httpDetectResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode inference response: %w", err)
+	}
+
+	// Обрезаем рамки по границам кадра
+	bounds := image.Rect(0, 0, frame.Cols(), frame.Rows())
+	detections := make([]Detection, 0, len(result.Detections))
+	for _, detection := range result.Detections {
+		b := detection.BBox
+		rect := image.Rect(b.X, b.Y, b.X+b.Width, b.Y+b.Height).Intersect(bounds)
+		if rect.Empty() {
+			continue
+		}
+
+		detection.BBox = BBox{X: rect.Min.X, Y: rect.Min.Y, Width: rect.Dx(), Height: rect.Dy()}
+		detections = append(detections, detection)
+	}
+
+	if d.nmsThreshold > 0 {
+		detections = nonMaxSuppression(detections, d.nmsThreshold)
+	}
+
+	return detections, nil
+}
+
+// Close закрывает простаивающие соединения
+func (d *httpDetector) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ocuai/internal/config"
+)
+
+// newInferenceServer поднимает сервер инференса, отвечающий body со статусом status
+func newInferenceServer(t *testing.T, status int, body string, check func(r *http.Request)) *httpDetector {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	detector, err := newHTTPDetector(config.ModelConfig{URL: server.URL, APIKey: "key"}, 0.5)
+	if err != nil {
+		t.Fatalf("newHTTPDetector: %v", err)
+	}
+	t.Cleanup(func() { detector.Close() })
+	return detector
+}
+
+func TestHTTPDetectorRequest(t *testing.T) {
+	var contentType, authorization string
+	var size int
+	detector := newInferenceServer(t, http.StatusOK, `{"detections": []}`, func(r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		authorization = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		size = len(body)
+	})
+
+	if _, err := detector.Detect(newFrame(t)); err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+
+	if contentType != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want image/jpeg", contentType)
+	}
+	if authorization != "Bearer key" {
+		t.Errorf("Authorization = %q, want Bearer key", authorization)
+	}
+	if size == 0 {
+		t.Error("frame was not sent")
+	}
+}
+
+func TestHTTPDetectorClipsToFrame(t *testing.T) {
+	detector := newInferenceServer(t, http.StatusOK, `{"detections": [
+		{"class": "person", "confidence": 0.9, "bbox": {"x": -20, "y": 400, "width": 100, "height": 200}},
+		{"class": "person", "confidence": 0.8, "bbox": {"x": -10, "y": 410, "width": 100, "height": 200}},
+		{"class": "car", "confidence": 0.7, "bbox": {"x": 700, "y": 10, "width": 50, "height": 50}},
+		{"class": "dog", "confidence": 0.6, "bbox": {"x": 600, "y": 0, "width": 100, "height": 30}}
+	]}`, nil)
+
+	detections, err := detector.Detect(newFrame(t))
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+
+	// Вторая рамка человека подавлена NMS, машина целиком за кадром
+	want := []Detection{
+		{Class: "person", Confidence: 0.9, BBox: BBox{X: 0, Y: 400, Width: 80, Height: 80}},
+		{Class: "dog", Confidence: 0.6, BBox: BBox{X: 600, Y: 0, Width: 40, Height: 30}},
+	}
+	if len(detections) != len(want) {
+		t.Fatalf("detections = %+v, want %+v", detections, want)
+	}
+	for i := range want {
+		if detections[i] != want[i] {
+			t.Errorf("detection %d = %+v, want %+v", i, detections[i], want[i])
+		}
+	}
+}
+
+func TestHTTPDetectorErrors(t *testing.T) {
+	detector := newInferenceServer(t, http.StatusServiceUnavailable, "model is loading\n", nil)
+
+	_, err := detector.Detect(newFrame(t))
+	if err == nil || !strings.Contains(err.Error(), "503: model is loading") {
+		t.Errorf("err = %v, want the status and the server message", err)
+	}
+
+	detector = newInferenceServer(t, http.StatusOK, "not json", nil)
+	if _, err := detector.Detect(newFrame(t)); err == nil {
+		t.Error("expected an error for an invalid response")
+	}
+
+	if _, err := newHTTPDetector(config.ModelConfig{}, 0); err == nil {
+		t.Error("expected an error without url")
+	}
+}
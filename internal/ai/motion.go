@@ -0,0 +1,261 @@
+package ai
+
+import (
+	"image"
+	"strings"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// Алгоритмы детекции движения
+const (
+	MotionAlgorithmMOG2 = "mog2"
+	MotionAlgorithmKNN  = "knn"
+	MotionAlgorithmDiff = "diff"
+)
+
+// shadowThreshold значение маски переднего плана, ниже которого пиксель считается тенью (тени MOG2/KNN = 127)
+const shadowThreshold = 200
+
+// MotionDetector находит области движения на последовательности кадров камеры
+type MotionDetector interface {
+	// Detect принимает очередной кадр и возвращает области движения внутри зон
+	Detect(frame gocv.Mat, zones *ZoneMask) []BBox
+	// Close освобождает ресурсы детектора
+	Close()
+}
+
+// NewMotionDetector создает детектор движения по конфигурации и чувствительности камеры (0..1)
+func NewMotionDetector(cfg config.MotionConfig, sensitivity float32) MotionDetector {
+	pixelThreshold := MotionThreshold(sensitivity)
+	s := float64(normalizeSensitivity(sensitivity))
+
+	history := cfg.History
+	if history <= 0 {
+		history = 500
+	}
+
+	filter := newRegionFilter(cfg, sensitivity)
+
+	switch strings.ToLower(cfg.Algorithm) {
+	case MotionAlgorithmDiff:
+		return &frameDiffDetector{
+			threshold: pixelThreshold,
+			filter:    filter,
+			prev:      gocv.NewMat(),
+		}
+	case MotionAlgorithmKNN:
+		// При 0.5 получаем стандартный порог KNN (400)
+		subtractor := gocv.NewBackgroundSubtractorKNNWithParams(history, 100+600*(1-s), true)
+		return &backgroundDetector{
+			apply:   subtractor.Apply,
+			close:   subtractor.Close,
+			filter:  filter,
+			warmup:  history / 10,
+			blurred: gocv.NewMat(),
+			fg:      gocv.NewMat(),
+		}
+	default:
+		// При 0.5 получаем стандартный порог MOG2 (16)
+		subtractor := gocv.NewBackgroundSubtractorMOG2WithParams(history, 4+24*(1-s), true)
+		return &backgroundDetector{
+			apply:   subtractor.Apply,
+			close:   subtractor.Close,
+			filter:  filter,
+			warmup:  history / 10,
+			blurred: gocv.NewMat(),
+			fg:      gocv.NewMat(),
+		}
+	}
+}
+
+// MotionThreshold переводит чувствительность камеры (0..1) в порог разницы яркости пикселя.
+// 0 означает значение по умолчанию (0.5).
+func MotionThreshold(sensitivity float32) float32 {
+	s := float64(normalizeSensitivity(sensitivity))
+
+	// При 0.5 получаем прежние 30 единиц яркости
+	return float32(55 - 50*s)
+}
+
+// normalizeSensitivity приводит чувствительность к диапазону (0..1], 0 - значение по умолчанию
+func normalizeSensitivity(sensitivity float32) float32 {
+	if sensitivity <= 0 {
+		return 0.5
+	}
+	if sensitivity > 1 {
+		return 1
+	}
+	return sensitivity
+}
+
+// backgroundDetector детектор на основе вычитания фона (MOG2 или KNN)
+type backgroundDetector struct {
+	apply   func(src gocv.Mat, dst *gocv.Mat)
+	close   func() error
+	filter  *regionFilter
+	warmup  int
+	frames  int
+	blurred gocv.Mat
+	fg      gocv.Mat
+}
+
+// Detect обновляет модель фона и возвращает области переднего плана
+func (d *backgroundDetector) Detect(frame gocv.Mat, zones *ZoneMask) []BBox {
+	if frame.Empty() {
+		return nil
+	}
+
+	d.filter.blur(frame, &d.blurred)
+	d.apply(d.blurred, &d.fg)
+
+	// Пока модель фона не обучилась, весь кадр выглядит как передний план
+	d.frames++
+	if d.frames <= d.warmup {
+		return nil
+	}
+
+	// Отбрасываем тени
+	gocv.Threshold(d.fg, &d.fg, shadowThreshold, 255, gocv.ThresholdBinary)
+
+	return d.filter.regions(&d.fg, zones)
+}
+
+// Close освобождает ресурсы детектора
+func (d *backgroundDetector) Close() {
+	d.close()
+	d.blurred.Close()
+	d.fg.Close()
+	d.filter.Close()
+}
+
+// frameDiffDetector детектор на основе разницы соседних кадров
+type frameDiffDetector struct {
+	threshold float32
+	filter    *regionFilter
+	prev      gocv.Mat
+}
+
+// Detect сравнивает кадр с предыдущим и возвращает области изменений
+func (d *frameDiffDetector) Detect(frame gocv.Mat, zones *ZoneMask) []BBox {
+	if frame.Empty() {
+		return nil
+	}
+
+	gray := gocv.NewMat()
+	if frame.Channels() > 1 {
+		gocv.CvtColor(frame, &gray, gocv.ColorBGRToGray)
+	} else {
+		frame.CopyTo(&gray)
+	}
+	d.filter.blur(gray, &gray)
+
+	defer func() {
+		d.prev.Close()
+		d.prev = gray
+	}()
+
+	if d.prev.Empty() || d.prev.Cols() != gray.Cols() || d.prev.Rows() != gray.Rows() {
+		return nil
+	}
+
+	diff := gocv.NewMat()
+	defer diff.Close()
+	gocv.AbsDiff(d.prev, gray, &diff)
+	gocv.Threshold(diff, &diff, d.threshold, 255, gocv.ThresholdBinary)
+
+	return d.filter.regions(&diff, zones)
+}
+
+// Close освобождает ресурсы детектора
+func (d *frameDiffDetector) Close() {
+	d.prev.Close()
+	d.filter.Close()
+}
+
+// regionFilter общая обработка маски движения: размытие, морфология и отбор контуров по площади
+type regionFilter struct {
+	blurSize int
+	minArea  float64 // в долях площади кадра
+	kernel   gocv.Mat
+}
+
+// newRegionFilter создает фильтр областей движения
+func newRegionFilter(cfg config.MotionConfig, sensitivity float32) *regionFilter {
+	blurSize := cfg.BlurSize
+	if blurSize > 0 && blurSize%2 == 0 {
+		blurSize++
+	}
+
+	minArea := cfg.MinContourArea
+	if minArea <= 0 {
+		minArea = 0.001
+	}
+	// Чем выше чувствительность, тем меньшие объекты учитываются (при 0.5 - значение из конфигурации)
+	minArea *= 2 * (1 - float64(normalizeSensitivity(sensitivity)))
+
+	return &regionFilter{
+		blurSize: blurSize,
+		minArea:  minArea,
+		kernel:   gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(3, 3)),
+	}
+}
+
+// blur сглаживает шум сенсора перед анализом
+func (f *regionFilter) blur(src gocv.Mat, dst *gocv.Mat) {
+	if f.blurSize <= 1 {
+		src.CopyTo(dst)
+		return
+	}
+	gocv.GaussianBlur(src, dst, image.Pt(f.blurSize, f.blurSize), 0, 0, gocv.BorderDefault)
+}
+
+// regions применяет зоны и морфологию к бинарной маске и возвращает рамки крупных контуров
+func (f *regionFilter) regions(mask *gocv.Mat, zones *ZoneMask) []BBox {
+	width, height := mask.Cols(), mask.Rows()
+
+	if zones != nil {
+		zoneMask, area := zones.Mask(width, height)
+		if area == 0 {
+			return nil
+		}
+		gocv.BitwiseAnd(*mask, zoneMask, mask)
+	}
+
+	// Убираем одиночные пиксели и склеиваем соседние фрагменты объекта
+	gocv.MorphologyEx(*mask, mask, gocv.MorphOpen, f.kernel)
+	gocv.Dilate(*mask, mask, f.kernel)
+
+	contours := gocv.FindContours(*mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	minArea := f.minArea * float64(width*height)
+	if minArea < 16 {
+		minArea = 16
+	}
+
+	var regions []BBox
+	for i := 0; i < contours.Size(); i++ {
+		contour := contours.At(i)
+		if gocv.ContourArea(contour) < minArea {
+			continue
+		}
+
+		rect := gocv.BoundingRect(contour)
+		regions = append(regions, BBox{
+			X:      rect.Min.X,
+			Y:      rect.Min.Y,
+			Width:  rect.Dx(),
+			Height: rect.Dy(),
+		})
+	}
+
+	return regions
+}
+
+// Close освобождает ресурсы фильтра
+func (f *regionFilter) Close() {
+	f.kernel.Close()
+}
@@ -0,0 +1,319 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// Бэкенды распознавания номеров
+const (
+	PlateDetectorHaar = "haar"
+	PlateReaderHTTP   = "http"
+	PlateBackendStub  = "stub" // детектор-заглушка: номером считается вся рамка транспорта
+)
+
+// PlateDetector находит номерные знаки на изображении транспорта
+type PlateDetector interface {
+	DetectPlates(img gocv.Mat) []image.Rectangle
+	Close() error
+}
+
+// PlateReader распознает текст номерного знака (OCR)
+type PlateReader interface {
+	ReadPlate(plate gocv.Mat) (string, float32, error)
+	Close() error
+}
+
+// PlateRead распознанный номер
+type PlateRead struct {
+	Plate      string  `json:"plate"` // нормализованный номер
+	Confidence float32 `json:"confidence"`
+	BBox       BBox    `json:"bbox"` // номер в координатах кадра
+	Image      []byte  `json:"-"`    // JPEG вырезка номера
+}
+
+// PlateRecognizer ищет и читает номера в рамках транспорта.
+// Нулевой указатель означает, что распознавание выключено.
+type PlateRecognizer struct {
+	detector      PlateDetector
+	reader        PlateReader
+	classes       []string
+	minConfidence float32
+	minWidth      int
+	mu            sync.Mutex // каскад не потокобезопасен
+}
+
+// NewPlateRecognizer создает распознавание номеров. Возвращает nil, если оно выключено.
+func NewPlateRecognizer(cfg config.PlateConfig) (*PlateRecognizer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	detector, err := newPlateDetector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := newPlateReader(cfg)
+	if err != nil {
+		detector.Close()
+		return nil, err
+	}
+
+	recognizer := NewPlateRecognizerWith(detector, reader, cfg.MinConfidence)
+	recognizer.minWidth = cfg.MinPlateWidth
+	if len(cfg.Classes) > 0 {
+		recognizer.classes = cfg.Classes
+	}
+
+	log.Printf("License plate recognition enabled (detector: %s, reader: %s)", cfg.Detector, cfg.Reader)
+	return recognizer, nil
+}
+
+// NewPlateRecognizerWith создает распознавание номеров с готовыми детектором и OCR
+func NewPlateRecognizerWith(detector PlateDetector, reader PlateReader, minConfidence float32) *PlateRecognizer {
+	return &PlateRecognizer{
+		detector:      detector,
+		reader:        reader,
+		classes:       []string{"car", "truck", "bus", "motorcycle"},
+		minConfidence: minConfidence,
+	}
+}
+
+// IsVehicle проверяет, ищутся ли номера на объектах класса
+func (r *PlateRecognizer) IsVehicle(class string) bool {
+	return r != nil && matchesClass(r.classes, class)
+}
+
+// Recognize ищет и читает номер в рамке транспорта.
+// Возвращает nil, если номер не найден или прочитан с низкой уверенностью.
+func (r *PlateRecognizer) Recognize(frame gocv.Mat, vehicle BBox) (*PlateRead, error) {
+	if r == nil || frame.Empty() {
+		return nil, nil
+	}
+
+	rect := image.Rect(vehicle.X, vehicle.Y, vehicle.X+vehicle.Width, vehicle.Y+vehicle.Height).
+		Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	if rect.Empty() {
+		return nil, nil
+	}
+
+	crop := frame.Region(rect)
+	defer crop.Close()
+
+	r.mu.Lock()
+	plates := r.detector.DetectPlates(crop)
+	r.mu.Unlock()
+
+	var largest image.Rectangle
+	for _, plate := range plates {
+		if plate.Dx()*plate.Dy() > largest.Dx()*largest.Dy() {
+			largest = plate
+		}
+	}
+	if largest.Empty() || largest.Dx() < r.minWidth {
+		return nil, nil
+	}
+
+	plate := crop.Region(largest)
+	defer plate.Close()
+
+	text, confidence, err := r.reader.ReadPlate(plate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plate: %w", err)
+	}
+
+	text = NormalizePlate(text)
+	if text == "" || confidence < r.minConfidence {
+		return nil, nil
+	}
+
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, plate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plate image: %w", err)
+	}
+	defer buf.Close()
+
+	return &PlateRead{
+		Plate:      text,
+		Confidence: confidence,
+		BBox: BBox{
+			X:      rect.Min.X + largest.Min.X,
+			Y:      rect.Min.Y + largest.Min.Y,
+			Width:  largest.Dx(),
+			Height: largest.Dy(),
+		},
+		Image: append([]byte(nil), buf.GetBytes()...),
+	}, nil
+}
+
+// Close освобождает модели
+func (r *PlateRecognizer) Close() {
+	if r == nil {
+		return
+	}
+
+	r.detector.Close()
+	r.reader.Close()
+}
+
+// plateLookalikes кириллические буквы номеров и их латинские двойники
+var plateLookalikes = map[rune]rune{
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H',
+	'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X',
+}
+
+// NormalizePlate приводит номер к виду для сравнения и поиска: верхний регистр,
+// только буквы и цифры, кириллические буквы заменены латинскими двойниками
+func NormalizePlate(text string) string {
+	var b strings.Builder
+	for _, c := range strings.ToUpper(text) {
+		if latin, ok := plateLookalikes[c]; ok {
+			c = latin
+		}
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// newPlateDetector создает детектор номеров по конфигурации
+func newPlateDetector(cfg config.PlateConfig) (PlateDetector, error) {
+	switch strings.ToLower(cfg.Detector) {
+	case "", PlateDetectorHaar:
+		if _, err := os.Stat(cfg.DetectorModel); err != nil {
+			return nil, fmt.Errorf("plate detector model not found: %s", cfg.DetectorModel)
+		}
+
+		classifier := gocv.NewCascadeClassifier()
+		if !classifier.Load(cfg.DetectorModel) {
+			classifier.Close()
+			return nil, fmt.Errorf("failed to load plate detector from %s", cfg.DetectorModel)
+		}
+		return &haarPlateDetector{classifier: classifier}, nil
+	case PlateBackendStub:
+		return stubPlateDetector{}, nil
+	default:
+		return nil, fmt.Errorf("unknown plate detector: %s", cfg.Detector)
+	}
+}
+
+// newPlateReader создает OCR номеров по конфигурации
+func newPlateReader(cfg config.PlateConfig) (PlateReader, error) {
+	switch strings.ToLower(cfg.Reader) {
+	case "", PlateReaderHTTP:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("url is required for the http plate reader")
+		}
+
+		timeout := time.Duration(cfg.TimeoutMS) * time.Millisecond
+		if timeout <= 0 {
+			timeout = 2 * time.Second
+		}
+		return &httpPlateReader{url: cfg.URL, apiKey: cfg.APIKey, client: &http.Client{Timeout: timeout}}, nil
+	default:
+		return nil, fmt.Errorf("unknown plate reader: %s", cfg.Reader)
+	}
+}
+
+// haarPlateDetector детектор номеров на каскаде Хаара (CPU)
+type haarPlateDetector struct {
+	classifier gocv.CascadeClassifier
+}
+
+// DetectPlates находит номера на изображении
+func (d *haarPlateDetector) DetectPlates(img gocv.Mat) []image.Rectangle {
+	gray := gocv.NewMat()
+	defer gray.Close()
+
+	gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
+	return d.classifier.DetectMultiScaleWithParams(gray, 1.1, 3, 0, image.Pt(0, 0), image.Pt(0, 0))
+}
+
+// Close освобождает каскад
+func (d *haarPlateDetector) Close() error {
+	return d.classifier.Close()
+}
+
+// stubPlateDetector считает номером все изображение
+type stubPlateDetector struct{}
+
+// DetectPlates возвращает рамку всего изображения
+func (stubPlateDetector) DetectPlates(img gocv.Mat) []image.Rectangle {
+	return []image.Rectangle{image.Rect(0, 0, img.Cols(), img.Rows())}
+}
+
+// Close ничего не делает
+func (stubPlateDetector) Close() error {
+	return nil
+}
+
+// httpPlateReader отправляет вырезку номера на внешний сервер OCR.
+// Вырезка передается как image/jpeg, ответ - {"text": "A123BC77", "confidence": 0.93}.
+type httpPlateReader struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// httpPlateResponse ответ сервера OCR
+type httpPlateResponse struct {
+	Text       string  `json:"text"`
+	Confidence float32 `json:"confidence"`
+}
+
+// ReadPlate отправляет вырезку номера на сервер и возвращает текст
+func (r *httpPlateReader) ReadPlate(plate gocv.Mat) (string, float32, error) {
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, plate)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to encode plate: %w", err)
+	}
+	defer buf.Close()
+
+	req, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(buf.GetBytes()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "image/jpeg")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("ocr request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", 0, fmt.Errorf("ocr server returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var result httpPlateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("failed to decode ocr response: %w", err)
+	}
+
+	return result.Text, result.Confidence, nil
+}
+
+// Close ничего не делает
+func (r *httpPlateReader) Close() error {
+	return nil
+}
@@ -0,0 +1,168 @@
+package ai
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// fakePlateReader возвращает заданный номер
+type fakePlateReader struct {
+	text       string
+	confidence float32
+}
+
+func (r *fakePlateReader) ReadPlate(plate gocv.Mat) (string, float32, error) {
+	return r.text, r.confidence, nil
+}
+
+func (r *fakePlateReader) Close() error {
+	return nil
+}
+
+func TestNormalizePlate(t *testing.T) {
+	tests := map[string]string{
+		"A123BC77":     "A123BC77",
+		"а123вс 77":    "A123BC77", // кириллица
+		"A 123-BC/77":  "A123BC77",
+		" х001ху 197 ": "X001XY197",
+		"...":          "",
+	}
+
+	for input, want := range tests {
+		if got := NormalizePlate(input); got != want {
+			t.Errorf("NormalizePlate(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	// Один и тот же номер, набранный кириллицей и латиницей, совпадает
+	if NormalizePlate("Е777КХ99") != NormalizePlate("e777kx99") {
+		t.Error("cyrillic and latin spellings of a plate differ")
+	}
+}
+
+func TestRecognize(t *testing.T) {
+	reader := &fakePlateReader{text: "о777оо 77", confidence: 0.9}
+	recognizer := NewPlateRecognizerWith(stubPlateDetector{}, reader, 0.6)
+
+	frame := newFrame(t)
+	read, err := recognizer.Recognize(frame, BBox{X: 600, Y: 100, Width: 100, Height: 50})
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if read == nil {
+		t.Fatal("plate not recognized")
+	}
+
+	if read.Plate != "O777OO77" {
+		t.Errorf("plate = %q, want O777OO77", read.Plate)
+	}
+	// Рамка транспорта обрезана по кадру, номер в координатах кадра
+	if want := (BBox{X: 600, Y: 100, Width: 40, Height: 50}); read.BBox != want {
+		t.Errorf("bbox = %+v, want %+v", read.BBox, want)
+	}
+	if len(read.Image) == 0 {
+		t.Error("plate image is empty")
+	}
+}
+
+func TestRecognizeRejects(t *testing.T) {
+	frame := newFrame(t)
+	vehicle := BBox{X: 10, Y: 10, Width: 80, Height: 40}
+
+	tests := []struct {
+		name       string
+		text       string
+		confidence float32
+		minWidth   int
+		vehicle    BBox
+	}{
+		{"low confidence", "A123BC77", 0.4, 0, vehicle},
+		{"no text", " - ", 0.9, 0, vehicle},
+		{"narrow plate", "A123BC77", 0.9, 100, vehicle},
+		{"vehicle outside frame", "A123BC77", 0.9, 0, BBox{X: 700, Y: 10, Width: 80, Height: 40}},
+	}
+
+	for _, tt := range tests {
+		recognizer := NewPlateRecognizerWith(stubPlateDetector{}, &fakePlateReader{text: tt.text, confidence: tt.confidence}, 0.6)
+		recognizer.minWidth = tt.minWidth
+
+		read, err := recognizer.Recognize(frame, tt.vehicle)
+		if err != nil || read != nil {
+			t.Errorf("%s: Recognize = %+v, %v, want nothing", tt.name, read, err)
+		}
+	}
+
+	var disabled *PlateRecognizer
+	if read, err := disabled.Recognize(frame, vehicle); read != nil || err != nil || disabled.IsVehicle("car") {
+		t.Error("nil recognizer must do nothing")
+	}
+}
+
+func TestIsVehicle(t *testing.T) {
+	recognizer := NewPlateRecognizerWith(stubPlateDetector{}, &fakePlateReader{}, 0.5)
+
+	if !recognizer.IsVehicle("truck") || recognizer.IsVehicle("person") {
+		t.Error("default vehicle classes mismatch")
+	}
+}
+
+func TestHTTPPlateReader(t *testing.T) {
+	var contentType, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		authorization = r.Header.Get("Authorization")
+		if body, _ := io.ReadAll(r.Body); len(body) == 0 {
+			http.Error(w, "empty image", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"text": "A123BC77", "confidence": 0.93}`)
+	}))
+	defer server.Close()
+
+	reader, err := newPlateReader(config.PlateConfig{URL: server.URL, APIKey: "key"})
+	if err != nil {
+		t.Fatalf("newPlateReader: %v", err)
+	}
+
+	text, confidence, err := reader.ReadPlate(newFrame(t))
+	if err != nil {
+		t.Fatalf("ReadPlate: %v", err)
+	}
+	if text != "A123BC77" || confidence != 0.93 {
+		t.Errorf("ReadPlate = %q, %v", text, confidence)
+	}
+	if contentType != "image/jpeg" || authorization != "Bearer key" {
+		t.Errorf("headers: Content-Type %q, Authorization %q", contentType, authorization)
+	}
+}
+
+func TestHTTPPlateReaderErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	reader, err := newPlateReader(config.PlateConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("newPlateReader: %v", err)
+	}
+
+	_, _, err = reader.ReadPlate(newFrame(t))
+	if err == nil || !strings.Contains(err.Error(), "429: quota exceeded") {
+		t.Errorf("err = %v, want the status and the server message", err)
+	}
+
+	if _, err := newPlateReader(config.PlateConfig{}); err == nil {
+		t.Error("expected an error without url")
+	}
+	if _, err := newPlateReader(config.PlateConfig{Reader: "tesseract", URL: server.URL}); err == nil {
+		t.Error("expected an error for an unknown reader")
+	}
+}
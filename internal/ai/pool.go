@@ -0,0 +1,290 @@
+package ai
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// InferenceResult результат обработки кадра в пуле.
+// Frame принадлежит пулу и закрывается после возврата из обработчика.
+type InferenceResult struct {
+	CameraID   string
+	Frame      gocv.Mat
+	CapturedAt time.Time
+	Detections []Detection
+	Err        error
+}
+
+// InferenceHandler обрабатывает результат инференса
+type InferenceHandler func(result InferenceResult)
+
+// InferenceStats метрики инференса камеры
+type InferenceStats struct {
+	CameraID      string  `json:"camera_id"`
+	Submitted     uint64  `json:"submitted"`
+	Processed     uint64  `json:"processed"`
+	Dropped       uint64  `json:"dropped"` // кадры, замененные более свежими до начала обработки
+	Errors        uint64  `json:"errors"`
+	LastLatencyMS float64 `json:"last_latency_ms"`
+	AvgLatencyMS  float64 `json:"avg_latency_ms"`
+	MaxLatencyMS  float64 `json:"max_latency_ms"`
+	AvgQueueMS    float64 `json:"avg_queue_ms"` // ожидание кадра в очереди
+}
+
+// inferenceJob кадр, ожидающий обработки
+type inferenceJob struct {
+	model      string
+	frame      gocv.Mat
+	capturedAt time.Time
+	queuedAt   time.Time
+	handler    InferenceHandler
+}
+
+// inferenceSlot очередь камеры из одного кадра: новый кадр вытесняет необработанный
+type inferenceSlot struct {
+	job     *inferenceJob
+	queued  bool // камера стоит в очереди готовых
+	running bool // кадр камеры обрабатывается воркером
+	stats   InferenceStats
+	latency time.Duration
+	waiting time.Duration
+}
+
+// InferencePool ограниченный пул воркеров инференса.
+// У каждой камеры в обработке не больше одного кадра, поэтому результаты камеры
+// приходят по порядку, а медленная модель не тормозит чтение потока.
+type InferencePool struct {
+	processor *Processor
+	workers   int
+	slots     map[string]*inferenceSlot
+	ready     []string // камеры с кадром, ожидающим воркера, в порядке поступления
+	closed    bool
+	mu        sync.Mutex
+	cond      *sync.Cond
+	wg        sync.WaitGroup
+}
+
+// NewInferencePool создает пул из workers воркеров
+func NewInferencePool(processor *Processor, workers int) *InferencePool {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	pool := &InferencePool{
+		processor: processor,
+		workers:   workers,
+		slots:     make(map[string]*inferenceSlot),
+	}
+	pool.cond = sync.NewCond(&pool.mu)
+
+	return pool
+}
+
+// Start запускает воркеры
+func (p *InferencePool) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+
+	log.Printf("AI inference pool started with %d workers", p.workers)
+}
+
+// Close останавливает воркеры и освобождает необработанные кадры
+func (p *InferencePool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, slot := range p.slots {
+		if slot.job != nil {
+			slot.job.frame.Close()
+			slot.job = nil
+		}
+	}
+}
+
+// Submit ставит кадр камеры в очередь, забирая владение frame.
+// Возвращает false, если кадр вытеснил еще не обработанный кадр этой камеры.
+func (p *InferencePool) Submit(cameraID, model string, frame gocv.Mat, capturedAt time.Time, handler InferenceHandler) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		frame.Close()
+		return false
+	}
+
+	slot := p.slot(cameraID)
+	slot.stats.Submitted++
+
+	accepted := true
+	if slot.job != nil {
+		slot.job.frame.Close()
+		slot.stats.Dropped++
+		accepted = false
+	}
+
+	slot.job = &inferenceJob{
+		model:      model,
+		frame:      frame,
+		capturedAt: capturedAt,
+		queuedAt:   time.Now(),
+		handler:    handler,
+	}
+
+	if !slot.queued && !slot.running {
+		slot.queued = true
+		p.ready = append(p.ready, cameraID)
+		p.cond.Signal()
+	}
+
+	return accepted
+}
+
+// RemoveCamera отбрасывает кадр камеры и ждет завершения ее текущей обработки
+func (p *InferencePool) RemoveCamera(cameraID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slot, exists := p.slots[cameraID]
+	if !exists {
+		return
+	}
+
+	if slot.job != nil {
+		slot.job.frame.Close()
+		slot.job = nil
+	}
+
+	for slot.running {
+		p.cond.Wait()
+	}
+
+	ready := p.ready[:0]
+	for _, id := range p.ready {
+		if id != cameraID {
+			ready = append(ready, id)
+		}
+	}
+	p.ready = ready
+
+	delete(p.slots, cameraID)
+}
+
+// Stats возвращает метрики инференса по камерам
+func (p *InferencePool) Stats() []InferenceStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]InferenceStats, 0, len(p.slots))
+	for _, slot := range p.slots {
+		stats = append(stats, slot.stats)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].CameraID < stats[j].CameraID
+	})
+
+	return stats
+}
+
+// slot возвращает очередь камеры, создавая ее при необходимости
+func (p *InferencePool) slot(cameraID string) *inferenceSlot {
+	slot, exists := p.slots[cameraID]
+	if !exists {
+		slot = &inferenceSlot{stats: InferenceStats{CameraID: cameraID}}
+		p.slots[cameraID] = slot
+	}
+	return slot
+}
+
+// worker обрабатывает кадры камер по очереди
+func (p *InferencePool) worker() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		for len(p.ready) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+
+		cameraID := p.ready[0]
+		p.ready = p.ready[1:]
+
+		slot := p.slots[cameraID]
+		if slot.job == nil {
+			slot.queued = false
+			p.mu.Unlock()
+			continue
+		}
+
+		job := slot.job
+		slot.job = nil
+		slot.queued = false
+		slot.running = true
+		p.mu.Unlock()
+
+		p.run(cameraID, slot, job)
+	}
+}
+
+// run обрабатывает кадр и возвращает камеру в очередь, если пришел новый кадр
+func (p *InferencePool) run(cameraID string, slot *inferenceSlot, job *inferenceJob) {
+	started := time.Now()
+	detections, err := p.processor.ProcessFrameWithModel(job.model, job.frame)
+	latency := time.Since(started)
+
+	job.handler(InferenceResult{
+		CameraID:   cameraID,
+		Frame:      job.frame,
+		CapturedAt: job.capturedAt,
+		Detections: detections,
+		Err:        err,
+	})
+	job.frame.Close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slot.stats.Processed++
+	if err != nil {
+		slot.stats.Errors++
+	}
+	slot.latency += latency
+	slot.waiting += started.Sub(job.queuedAt)
+	slot.stats.LastLatencyMS = milliseconds(latency)
+	slot.stats.AvgLatencyMS = milliseconds(slot.latency) / float64(slot.stats.Processed)
+	slot.stats.AvgQueueMS = milliseconds(slot.waiting) / float64(slot.stats.Processed)
+	if slot.stats.LastLatencyMS > slot.stats.MaxLatencyMS {
+		slot.stats.MaxLatencyMS = slot.stats.LastLatencyMS
+	}
+
+	slot.running = false
+	if slot.job != nil && !slot.queued && !p.closed {
+		slot.queued = true
+		p.ready = append(p.ready, cameraID)
+	}
+
+	// Будим ожидающих воркеров и RemoveCamera
+	p.cond.Broadcast()
+}
+
+// milliseconds переводит длительность в миллисекунды
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeWindow суточный интервал времени. Нулевое значение - круглосуточно,
+// конец раньше начала означает интервал через полночь (22:00-06:00).
+type TimeWindow struct {
+	start int // минуты от начала суток
+	end   int
+	set   bool
+}
+
+// ParseTimeWindow разбирает интервал из строк ЧЧ:ММ; пустые строки - круглосуточно
+func ParseTimeWindow(start, end string) (TimeWindow, error) {
+	if start == "" && end == "" {
+		return TimeWindow{}, nil
+	}
+	if start == "" || end == "" {
+		return TimeWindow{}, fmt.Errorf("both start and end time are required")
+	}
+
+	from, err := time.Parse("15:04", start)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid start time %q, expected HH:MM", start)
+	}
+	to, err := time.Parse("15:04", end)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid end time %q, expected HH:MM", end)
+	}
+
+	return TimeWindow{
+		start: from.Hour()*60 + from.Minute(),
+		end:   to.Hour()*60 + to.Minute(),
+		set:   true,
+	}, nil
+}
+
+// Contains проверяет, попадает ли момент в интервал (по локальному времени)
+func (w TimeWindow) Contains(t time.Time) bool {
+	if !w.set || w.start == w.end {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// DetectionRule условие, при котором объект класса порождает событие или игнорируется
+type DetectionRule struct {
+	Class          string // пусто - любой класс
+	Ignore         bool
+	MinConfidence  float32
+	Zone           *ZoneMask // nil - весь кадр
+	Window         TimeWindow
+	MinArea        float64 // доля площади кадра
+	MinDwellFrames int
+}
+
+// RuleSet правила детекции камеры. Нулевой указатель пропускает все объекты.
+// Правила только ужесточают глобальные настройки: объекты ниже ai.threshold
+// и вне ai.classes отбрасываются еще до них.
+type RuleSet struct {
+	rules []DetectionRule
+}
+
+// NewRuleSet создает набор правил. Возвращает nil, если правил нет.
+func NewRuleSet(rules []DetectionRule) *RuleSet {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &RuleSet{rules: rules}
+}
+
+// Allows решает, пора ли сообщать об объекте:
+//   - совпавшее ignore-правило запрещает событие;
+//   - если для класса нет alert-правил, событие разрешено сразу;
+//   - иначе должно выполниться хотя бы одно alert-правило класса.
+//
+// Объект, не прошедший правила сейчас, может пройти их позже (подошел ближе, провел в кадре больше кадров).
+func (rs *RuleSet) Allows(track Track, width, height int, now time.Time) bool {
+	if rs == nil {
+		return true
+	}
+
+	hasAlertRules := false
+	allowed := false
+
+	for _, rule := range rs.rules {
+		if rule.Class != "" && rule.Class != track.Class {
+			continue
+		}
+
+		if rule.Ignore {
+			if rule.Window.Contains(now) && rule.Zone.Allows(track.BBox, width, height) {
+				return false
+			}
+			continue
+		}
+
+		hasAlertRules = true
+		if !allowed && rule.matches(track, width, height, now) {
+			allowed = true
+		}
+	}
+
+	return allowed || !hasAlertRules
+}
+
+// matches проверяет условия alert-правила
+func (r DetectionRule) matches(track Track, width, height int, now time.Time) bool {
+	if !r.Window.Contains(now) {
+		return false
+	}
+	if track.Confidence < r.MinConfidence {
+		return false
+	}
+	if track.Hits() < r.MinDwellFrames {
+		return false
+	}
+	if r.MinArea > 0 && width > 0 && height > 0 {
+		area := float64(track.BBox.Width*track.BBox.Height) / float64(width*height)
+		if area < r.MinArea {
+			return false
+		}
+	}
+
+	return r.Zone.Allows(track.BBox, width, height)
+}
+
+// Close освобождает маски зон правил
+func (rs *RuleSet) Close() {
+	if rs == nil {
+		return
+	}
+
+	for _, rule := range rs.rules {
+		rule.Zone.Close()
+	}
+}
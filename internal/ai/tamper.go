@@ -0,0 +1,223 @@
+package ai
+
+import (
+	"image"
+	"math"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// Причины саботажа камеры
+const (
+	TamperCovered   = "covered"   // объектив закрыт или залит краской: кадр однотонный и резко потемнел или не темный
+	TamperDefocused = "defocused" // резкость упала относительно обычной
+	TamperMoved     = "moved"     // камеру повернули: сцена не совпадает с эталоном
+)
+
+// tamperSize размер уменьшенного кадра для проверок
+var tamperSize = image.Pt(160, 120)
+
+// tamperWarmup сколько проверок копятся обычные резкость и яркость, прежде чем сравнивать с ними
+const tamperWarmup = 5
+
+// TamperMetrics показатели кадра для детекции саботажа
+type TamperMetrics struct {
+	Brightness  float64 // средняя яркость 0..255
+	Contrast    float64 // стандартное отклонение яркости
+	Sharpness   float64 // дисперсия лапласиана
+	SceneChange float64 // доля пикселей, отличающихся от эталона, -1 - эталона еще нет
+}
+
+// Data возвращает показатели в виде данных события
+func (m TamperMetrics) Data() map[string]interface{} {
+	return map[string]interface{}{
+		"brightness":   round2(m.Brightness),
+		"contrast":     round2(m.Contrast),
+		"sharpness":    round2(m.Sharpness),
+		"scene_change": round2(m.SceneChange),
+	}
+}
+
+// TamperAlert новый подтвержденный саботаж
+type TamperAlert struct {
+	Reason  string
+	Metrics TamperMetrics
+}
+
+// TamperDetector проверяет кадры камеры на закрытие объектива, расфокус и поворот.
+// Саботаж сообщается один раз, после ConfirmChecks подтверждений подряд,
+// и сообщается снова только после того, как камера вернулась в норму.
+type TamperDetector struct {
+	cfg        config.TamperConfig
+	reference  gocv.Mat // эталон сцены: уменьшенный выровненный полутоновый кадр
+	sharpness  float64  // обычная резкость камеры
+	brightness float64  // обычная яркость камеры
+	checks     int      // нормальные проверки, учтенные в резкости и яркости
+	streak     map[string]int
+	active     map[string]bool
+}
+
+// NewTamperDetector создает детектор саботажа камеры
+func NewTamperDetector(cfg config.TamperConfig) *TamperDetector {
+	if cfg.ConfirmChecks <= 0 {
+		cfg.ConfirmChecks = 1
+	}
+
+	return &TamperDetector{
+		cfg:       cfg,
+		reference: gocv.NewMat(),
+		streak:    make(map[string]int),
+		active:    make(map[string]bool),
+	}
+}
+
+// Check проверяет кадр и возвращает вновь подтвержденный саботаж
+func (d *TamperDetector) Check(frame gocv.Mat) []TamperAlert {
+	if frame.Empty() {
+		return nil
+	}
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(frame, &gray, gocv.ColorBGRToGray)
+	gocv.Resize(gray, &gray, tamperSize, 0, 0, gocv.InterpolationArea)
+
+	metrics := TamperMetrics{SceneChange: -1}
+	metrics.Brightness, metrics.Contrast = meanStdDev(gray)
+	metrics.Sharpness = laplacianVariance(gray)
+
+	equalized := gocv.NewMat()
+	defer equalized.Close()
+	gocv.EqualizeHist(gray, &equalized)
+
+	if !d.reference.Empty() {
+		metrics.SceneChange = sceneChange(d.reference, equalized)
+	}
+
+	alerts := d.evaluate(metrics)
+
+	// Эталон сцены обновляется, пока камера в норме, поэтому медленные изменения
+	// (освещение, тени) не считаются поворотом. После подтвержденного поворота
+	// новая сцена становится эталоном.
+	if !d.active[TamperCovered] && (metrics.SceneChange < d.cfg.SceneChange/2 || d.active[TamperMoved] || d.reference.Empty()) {
+		equalized.CopyTo(&d.reference)
+		// Поворот сообщается один раз: дальше сравниваем с новой сценой
+		if d.active[TamperMoved] {
+			d.active[TamperMoved] = false
+			d.streak[TamperMoved] = 0
+		}
+	}
+
+	return alerts
+}
+
+// evaluate обновляет состояние причин саботажа по показателям кадра
+func (d *TamperDetector) evaluate(metrics TamperMetrics) []TamperAlert {
+	warm := d.checks >= tamperWarmup
+
+	// Закрытый объектив дает однотонный кадр. Темный однотонный кадр бывает и ночью,
+	// поэтому он считается закрытием, только если яркость резко упала относительно обычной:
+	// в сумерках обычная яркость успевает опуститься вместе с кадром.
+	uniform := metrics.Contrast < d.cfg.UniformThreshold
+	dark := metrics.Brightness < d.cfg.DarkThreshold
+	darkened := warm && metrics.Brightness < d.brightness*d.cfg.DarkRatio
+	covered := uniform && (!dark || darkened)
+
+	// Закрытый объектив объясняет и падение резкости, и смену сцены
+	defocused := !covered && warm && metrics.Sharpness < d.sharpness*d.cfg.DefocusRatio
+	moved := !covered && d.cfg.SceneChange > 0 && metrics.SceneChange >= d.cfg.SceneChange
+
+	// Обычные резкость и яркость копятся только по нормальным кадрам
+	if !covered && !defocused {
+		d.checks++
+		if d.checks == 1 {
+			d.sharpness = metrics.Sharpness
+			d.brightness = metrics.Brightness
+		} else {
+			d.sharpness += (metrics.Sharpness - d.sharpness) * 0.1
+			d.brightness += (metrics.Brightness - d.brightness) * 0.1
+		}
+	}
+
+	var alerts []TamperAlert
+	for _, state := range []struct {
+		reason string
+		now    bool
+	}{
+		{TamperCovered, covered},
+		{TamperDefocused, defocused},
+		{TamperMoved, moved},
+	} {
+		if !state.now {
+			d.streak[state.reason] = 0
+			d.active[state.reason] = false
+			continue
+		}
+
+		d.streak[state.reason]++
+		if d.streak[state.reason] >= d.cfg.ConfirmChecks && !d.active[state.reason] {
+			d.active[state.reason] = true
+			alerts = append(alerts, TamperAlert{Reason: state.reason, Metrics: metrics})
+		}
+	}
+
+	return alerts
+}
+
+// Active возвращает причины саботажа, которые сейчас в силе
+func (d *TamperDetector) Active() []string {
+	var reasons []string
+	for _, reason := range []string{TamperCovered, TamperDefocused, TamperMoved} {
+		if d.active[reason] {
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons
+}
+
+// Close освобождает эталон сцены
+func (d *TamperDetector) Close() {
+	if d == nil {
+		return
+	}
+	d.reference.Close()
+}
+
+// meanStdDev возвращает среднее и стандартное отклонение яркости полутонового кадра
+func meanStdDev(gray gocv.Mat) (float64, float64) {
+	mean := gocv.NewMat()
+	defer mean.Close()
+	stddev := gocv.NewMat()
+	defer stddev.Close()
+
+	gocv.MeanStdDev(gray, &mean, &stddev)
+	return mean.GetDoubleAt(0, 0), stddev.GetDoubleAt(0, 0)
+}
+
+// laplacianVariance возвращает дисперсию лапласиана - меру резкости кадра
+func laplacianVariance(gray gocv.Mat) float64 {
+	laplacian := gocv.NewMat()
+	defer laplacian.Close()
+
+	gocv.Laplacian(gray, &laplacian, gocv.MatTypeCV64F, 3, 1, 0, gocv.BorderDefault)
+	_, stddev := meanStdDev(laplacian)
+	return stddev * stddev
+}
+
+// sceneChange возвращает долю пикселей, заметно отличающихся от эталона
+func sceneChange(reference, current gocv.Mat) float64 {
+	diff := gocv.NewMat()
+	defer diff.Close()
+
+	gocv.AbsDiff(reference, current, &diff)
+	gocv.Threshold(diff, &diff, 50, 255, gocv.ThresholdBinary)
+
+	return float64(gocv.CountNonZero(diff)) / float64(tamperSize.X*tamperSize.Y)
+}
+
+// round2 округляет показатель до сотых для данных события
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"ocuai/internal/config"
+)
+
+// trackSeq счетчик идентификаторов треков, уникальный между перезапусками
+var trackSeq = uint64(time.Now().UnixNano())
+
+// Track объект, отслеживаемый между кадрами
+type Track struct {
+	ID            string    `json:"id"`
+	Class         string    `json:"class"`
+	BBox          BBox      `json:"bbox"`
+	Confidence    float32   `json:"confidence"`
+	MaxConfidence float32   `json:"max_confidence"`
+	StartedAt     time.Time `json:"started_at"`
+	LastSeen      time.Time `json:"last_seen"`
+
+	hits      int
+	missed    int
+	confirmed bool
+	vx, vy    float64 // смещение центра за одно обновление
+}
+
+// Dwell возвращает время присутствия объекта в кадре
+func (t Track) Dwell() time.Duration {
+	return t.LastSeen.Sub(t.StartedAt)
+}
+
+// Hits возвращает количество кадров, в которых объект был найден
+func (t Track) Hits() int {
+	return t.hits
+}
+
+// TrackUpdate изменения треков после очередного кадра
+type TrackUpdate struct {
+	Started []Track // новые подтвержденные объекты
+	Updated []Track // объекты, которые все еще в кадре
+	Ended   []Track // объекты, покинувшие кадр
+}
+
+// Tracker сопоставляет детекции между кадрами по IoU с учетом скорости (в духе SORT)
+type Tracker struct {
+	iouThreshold float32
+	maxMissed    int
+	minHits      int
+	tracks       []*Track
+}
+
+// NewTracker создает трекер объектов для камеры
+func NewTracker(cfg config.TrackerConfig) *Tracker {
+	tracker := &Tracker{
+		iouThreshold: cfg.IoUThreshold,
+		maxMissed:    cfg.MaxMissed,
+		minHits:      cfg.MinHits,
+	}
+
+	if tracker.iouThreshold <= 0 {
+		tracker.iouThreshold = 0.3
+	}
+	if tracker.maxMissed <= 0 {
+		tracker.maxMissed = 3
+	}
+	if tracker.minHits <= 0 {
+		tracker.minHits = 1
+	}
+
+	return tracker
+}
+
+// Update сопоставляет детекции кадра с треками и возвращает изменения
+func (t *Tracker) Update(detections []Detection, now time.Time) TrackUpdate {
+	var update TrackUpdate
+
+	// Все пары трек-детекция одного класса с достаточным пересечением
+	type pair struct {
+		track, detection int
+		iou              float32
+	}
+	var pairs []pair
+	for ti, track := range t.tracks {
+		predicted := track.predict()
+		for di, detection := range detections {
+			if detection.Class != track.Class {
+				continue
+			}
+			if iou := IoU(predicted, detection.BBox); iou >= t.iouThreshold {
+				pairs = append(pairs, pair{ti, di, iou})
+			}
+		}
+	}
+
+	// Жадное сопоставление начиная с наибольшего пересечения
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].iou > pairs[j].iou })
+
+	trackMatched := make([]bool, len(t.tracks))
+	detectionMatched := make([]bool, len(detections))
+	for _, p := range pairs {
+		if trackMatched[p.track] || detectionMatched[p.detection] {
+			continue
+		}
+		trackMatched[p.track] = true
+		detectionMatched[p.detection] = true
+
+		track := t.tracks[p.track]
+		track.observe(detections[p.detection], now)
+
+		switch {
+		case track.confirmed:
+			update.Updated = append(update.Updated, *track)
+		case track.hits >= t.minHits:
+			track.confirmed = true
+			update.Started = append(update.Started, *track)
+		}
+	}
+
+	// Треки без детекции стареют; неподтвержденные удаляются сразу
+	alive := t.tracks[:0]
+	for i, track := range t.tracks {
+		if !trackMatched[i] {
+			track.missed++
+			if !track.confirmed {
+				continue
+			}
+			if track.missed > t.maxMissed {
+				update.Ended = append(update.Ended, *track)
+				continue
+			}
+		}
+		alive = append(alive, track)
+	}
+	t.tracks = alive
+
+	// Новые объекты
+	for i, detection := range detections {
+		if detectionMatched[i] {
+			continue
+		}
+
+		track := &Track{
+			ID:        fmt.Sprintf("trk_%d", atomic.AddUint64(&trackSeq, 1)),
+			Class:     detection.Class,
+			StartedAt: now,
+		}
+		track.observe(detection, now)
+		t.tracks = append(t.tracks, track)
+
+		if track.hits >= t.minHits {
+			track.confirmed = true
+			update.Started = append(update.Started, *track)
+		}
+	}
+
+	return update
+}
+
+// Flush завершает все подтвержденные треки (например, при отключении AI)
+func (t *Tracker) Flush() []Track {
+	var ended []Track
+	for _, track := range t.tracks {
+		if track.confirmed {
+			ended = append(ended, *track)
+		}
+	}
+	t.tracks = nil
+	return ended
+}
+
+// Active возвращает количество отслеживаемых объектов
+func (t *Tracker) Active() int {
+	return len(t.tracks)
+}
+
+// observe обновляет трек по сопоставленной детекции
+func (t *Track) observe(detection Detection, now time.Time) {
+	if t.hits > 0 {
+		// Скорость считаем за одно обновление с учетом пропущенных кадров
+		steps := float64(t.missed + 1)
+		t.vx = float64(centerX(detection.BBox)-centerX(t.BBox)) / steps
+		t.vy = float64(centerY(detection.BBox)-centerY(t.BBox)) / steps
+	}
+
+	t.BBox = detection.BBox
+	t.Confidence = detection.Confidence
+	if detection.Confidence > t.MaxConfidence {
+		t.MaxConfidence = detection.Confidence
+	}
+	t.LastSeen = now
+	t.hits++
+	t.missed = 0
+}
+
+// predict возвращает ожидаемое положение рамки в текущем кадре
+func (t *Track) predict() BBox {
+	steps := float64(t.missed + 1)
+	predicted := t.BBox
+	predicted.X += int(t.vx * steps)
+	predicted.Y += int(t.vy * steps)
+	return predicted
+}
+
+// centerX возвращает горизонтальную координату центра рамки
+func centerX(b BBox) int {
+	return b.X + b.Width/2
+}
+
+// centerY возвращает вертикальную координату центра рамки
+func centerY(b BBox) int {
+	return b.Y + b.Height/2
+}
@@ -0,0 +1,226 @@
+package ai
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+// Форматы выхода моделей YOLO
+const (
+	ModelTypeYOLOv5 = "yolov5"
+	ModelTypeYOLOv8 = "yolov8"
+)
+
+// cocoLabels имена классов COCO в порядке индексов моделей YOLO
+var cocoLabels = []string{
+	"person", "bicycle", "car", "motorcycle", "airplane", "bus", "train", "truck", "boat",
+	"traffic light", "fire hydrant", "stop sign", "parking meter", "bench", "bird", "cat",
+	"dog", "horse", "sheep", "cow", "elephant", "bear", "zebra", "giraffe", "backpack",
+	"umbrella", "handbag", "tie", "suitcase", "frisbee", "skis", "snowboard", "sports ball",
+	"kite", "baseball bat", "baseball glove", "skateboard", "surfboard", "tennis racket",
+	"bottle", "wine glass", "cup", "fork", "knife", "spoon", "bowl", "banana", "apple",
+	"sandwich", "orange", "broccoli", "carrot", "hot dog", "pizza", "donut", "cake", "chair",
+	"couch", "potted plant", "bed", "dining table", "toilet", "tv", "laptop", "mouse",
+	"remote", "keyboard", "cell phone", "microwave", "oven", "toaster", "sink",
+	"refrigerator", "book", "clock", "vase", "scissors", "teddy bear", "hair drier",
+	"toothbrush",
+}
+
+// letterbox параметры вписывания кадра во вход модели с сохранением пропорций
+type letterbox struct {
+	size   int
+	scale  float64
+	padX   int
+	padY   int
+	width  int // размер исходного кадра
+	height int
+}
+
+// newLetterbox рассчитывает масштаб и отступы для кадра width x height
+func newLetterbox(width, height, size int) letterbox {
+	scale := math.Min(float64(size)/float64(width), float64(size)/float64(height))
+
+	return letterbox{
+		size:   size,
+		scale:  scale,
+		padX:   (size - int(math.Round(float64(width)*scale))) / 2,
+		padY:   (size - int(math.Round(float64(height)*scale))) / 2,
+		width:  width,
+		height: height,
+	}
+}
+
+// apply вписывает кадр в квадрат модели, заполняя поля серым как при обучении YOLO
+func (l letterbox) apply(frame gocv.Mat, dst *gocv.Mat) {
+	width := int(math.Round(float64(l.width) * l.scale))
+	height := int(math.Round(float64(l.height) * l.scale))
+
+	resized := gocv.NewMat()
+	defer resized.Close()
+	gocv.Resize(frame, &resized, image.Pt(width, height), 0, 0, gocv.InterpolationLinear)
+
+	gray := color.RGBA{R: 114, G: 114, B: 114, A: 0}
+	gocv.CopyMakeBorder(resized, dst, l.padY, l.size-height-l.padY, l.padX, l.size-width-l.padX, gocv.BorderConstant, gray)
+}
+
+// toFrame переводит рамку (центр, ширина, высота) из координат входа модели в координаты кадра
+func (l letterbox) toFrame(cx, cy, w, h float32) BBox {
+	x0 := (float64(cx-w/2) - float64(l.padX)) / l.scale
+	y0 := (float64(cy-h/2) - float64(l.padY)) / l.scale
+	x1 := (float64(cx+w/2) - float64(l.padX)) / l.scale
+	y1 := (float64(cy+h/2) - float64(l.padY)) / l.scale
+
+	rect := image.Rect(int(x0), int(y0), int(x1), int(y1)).Intersect(image.Rect(0, 0, l.width, l.height))
+
+	return BBox{
+		X:      rect.Min.X,
+		Y:      rect.Min.Y,
+		Width:  rect.Dx(),
+		Height: rect.Dy(),
+	}
+}
+
+// decodeYOLO разбирает выход YOLOv5 ([1, N, 5+классы]) или YOLOv8 ([1, 4+классы, N]).
+// Пустой modelType означает автоопределение по форме тензора.
+func decodeYOLO(output gocv.Mat, modelType string, labels []string, threshold float32, box letterbox) ([]Detection, error) {
+	dims := output.Size()
+	if len(dims) == 3 {
+		dims = dims[1:]
+	}
+	if len(dims) != 2 {
+		return nil, fmt.Errorf("unexpected output shape %v", output.Size())
+	}
+
+	data, err := output.DataPtrFloat32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model output: %w", err)
+	}
+
+	// YOLOv8 экспортируется транспонированным: атрибутов меньше, чем кандидатов
+	channelsFirst := dims[0] < dims[1]
+
+	var boxes, attrs int
+	if channelsFirst {
+		attrs, boxes = dims[0], dims[1]
+	} else {
+		boxes, attrs = dims[0], dims[1]
+	}
+
+	if len(data) < boxes*attrs {
+		return nil, fmt.Errorf("model output is shorter than its shape %v", output.Size())
+	}
+
+	at := func(i, a int) float32 {
+		if channelsFirst {
+			return data[a*boxes+i]
+		}
+		return data[i*attrs+a]
+	}
+
+	// У YOLOv5 после координат идет objectness
+	var objectness bool
+	switch modelType {
+	case ModelTypeYOLOv5:
+		objectness = true
+	case ModelTypeYOLOv8:
+		objectness = false
+	default:
+		objectness = !channelsFirst && attrs != len(labels)+4
+	}
+
+	offset := 4
+	if objectness {
+		offset = 5
+	}
+	if attrs <= offset {
+		return nil, fmt.Errorf("model output has too few attributes: %d", attrs)
+	}
+
+	var detections []Detection
+	for i := 0; i < boxes; i++ {
+		score := float32(1)
+		if objectness {
+			score = at(i, 4)
+			if score < threshold {
+				continue
+			}
+		}
+
+		classID := -1
+		best := float32(0)
+		for a := offset; a < attrs; a++ {
+			if v := at(i, a); v > best {
+				best = v
+				classID = a - offset
+			}
+		}
+
+		confidence := best * score
+		if classID < 0 || classID >= len(labels) || confidence < threshold {
+			continue
+		}
+
+		bbox := box.toFrame(at(i, 0), at(i, 1), at(i, 2), at(i, 3))
+		if bbox.Width <= 0 || bbox.Height <= 0 {
+			continue
+		}
+
+		detections = append(detections, Detection{
+			Class:      labels[classID],
+			Confidence: confidence,
+			BBox:       bbox,
+		})
+	}
+
+	return detections, nil
+}
+
+// nonMaxSuppression оставляет по одной рамке на объект отдельно для каждого класса
+func nonMaxSuppression(detections []Detection, iouThreshold float32) []Detection {
+	sort.SliceStable(detections, func(i, j int) bool {
+		return detections[i].Confidence > detections[j].Confidence
+	})
+
+	suppressed := make([]bool, len(detections))
+	kept := make([]Detection, 0, len(detections))
+
+	for i := range detections {
+		if suppressed[i] {
+			continue
+		}
+		kept = append(kept, detections[i])
+
+		for j := i + 1; j < len(detections); j++ {
+			if !suppressed[j] && detections[j].Class == detections[i].Class &&
+				IoU(detections[i].BBox, detections[j].BBox) > iouThreshold {
+				suppressed[j] = true
+			}
+		}
+	}
+
+	return kept
+}
+
+// IoU возвращает отношение площади пересечения рамок к площади их объединения
+func IoU(a, b BBox) float32 {
+	ra := image.Rect(a.X, a.Y, a.X+a.Width, a.Y+a.Height)
+	rb := image.Rect(b.X, b.Y, b.X+b.Width, b.Y+b.Height)
+
+	inter := ra.Intersect(rb)
+	if inter.Empty() {
+		return 0
+	}
+
+	interArea := inter.Dx() * inter.Dy()
+	union := ra.Dx()*ra.Dy() + rb.Dx()*rb.Dy() - interArea
+	if union <= 0 {
+		return 0
+	}
+
+	return float32(interArea) / float32(union)
+}
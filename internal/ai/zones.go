@@ -0,0 +1,166 @@
+package ai
+
+import (
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+// minZoneOverlap минимальная доля площади детекции внутри зоны, если ее центр вне зоны
+const minZoneOverlap = 0.3
+
+// Point вершина полигона в долях ширины и высоты кадра (0..1)
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Zone полигональная зона камеры. Include-зоны ограничивают область анализа,
+// exclude-зоны вырезают из нее участки (деревья, дорога и т.п.).
+type Zone struct {
+	Exclude bool    `json:"exclude"`
+	Points  []Point `json:"points"`
+}
+
+// ZoneMask бинарная маска зон камеры, пересчитываемая под размер кадра.
+// Нулевой указатель означает, что анализируется весь кадр.
+type ZoneMask struct {
+	zones  []Zone
+	mask   gocv.Mat
+	width  int
+	height int
+	area   int
+}
+
+// NewZoneMask создает маску из зон камеры. Возвращает nil, если зон нет.
+func NewZoneMask(zones []Zone) *ZoneMask {
+	valid := make([]Zone, 0, len(zones))
+	for _, zone := range zones {
+		if len(zone.Points) >= 3 {
+			valid = append(valid, zone)
+		}
+	}
+
+	if len(valid) == 0 {
+		return nil
+	}
+
+	return &ZoneMask{
+		zones: valid,
+		mask:  gocv.NewMat(),
+	}
+}
+
+// Close освобождает маску
+func (m *ZoneMask) Close() {
+	if m != nil {
+		m.mask.Close()
+	}
+}
+
+// Mask возвращает маску под размер кадра и количество пикселей под наблюдением
+func (m *ZoneMask) Mask(width, height int) (gocv.Mat, int) {
+	if m.mask.Empty() || m.width != width || m.height != height {
+		m.build(width, height)
+	}
+
+	return m.mask, m.area
+}
+
+// build рисует маску: include-зоны (или весь кадр, если их нет) минус exclude-зоны
+func (m *ZoneMask) build(width, height int) {
+	hasInclude := false
+	for _, zone := range m.zones {
+		if !zone.Exclude {
+			hasInclude = true
+			break
+		}
+	}
+
+	background := 0.0
+	if !hasInclude {
+		background = 255
+	}
+
+	m.mask.Close()
+	m.mask = gocv.NewMatWithSizeFromScalar(gocv.NewScalar(background, 0, 0, 0), height, width, gocv.MatTypeCV8U)
+	m.width = width
+	m.height = height
+
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	black := color.RGBA{A: 255}
+
+	// Сначала include, затем exclude - исключение всегда побеждает
+	for _, exclude := range []bool{false, true} {
+		for _, zone := range m.zones {
+			if zone.Exclude != exclude {
+				continue
+			}
+
+			polygon := gocv.NewPointsVectorFromPoints([][]image.Point{zone.pixels(width, height)})
+			fill := white
+			if exclude {
+				fill = black
+			}
+			gocv.FillPoly(&m.mask, polygon, fill)
+			polygon.Close()
+		}
+	}
+
+	m.area = gocv.CountNonZero(m.mask)
+}
+
+// Allows проверяет, попадает ли детекция в зоны: центр рамки внутри зоны
+// или достаточная часть ее площади пересекается с зоной
+func (m *ZoneMask) Allows(bbox BBox, width, height int) bool {
+	if m == nil {
+		return true
+	}
+
+	mask, area := m.Mask(width, height)
+	if area == 0 {
+		return false
+	}
+
+	rect := image.Rect(bbox.X, bbox.Y, bbox.X+bbox.Width, bbox.Y+bbox.Height).Intersect(image.Rect(0, 0, width, height))
+	if rect.Empty() {
+		return false
+	}
+
+	center := image.Pt((rect.Min.X+rect.Max.X)/2, (rect.Min.Y+rect.Max.Y)/2)
+	if mask.GetUCharAt(center.Y, center.X) > 0 {
+		return true
+	}
+
+	region := mask.Region(rect)
+	defer region.Close()
+
+	overlap := float64(gocv.CountNonZero(region)) / float64(rect.Dx()*rect.Dy())
+	return overlap >= minZoneOverlap
+}
+
+// FilterDetections оставляет только детекции внутри зон
+func (m *ZoneMask) FilterDetections(detections []Detection, width, height int) []Detection {
+	if m == nil {
+		return detections
+	}
+
+	filtered := detections[:0]
+	for _, detection := range detections {
+		if m.Allows(detection.BBox, width, height) {
+			filtered = append(filtered, detection)
+		}
+	}
+
+	return filtered
+}
+
+// pixels переводит нормализованные координаты зоны в пиксели кадра
+func (z Zone) pixels(width, height int) []image.Point {
+	points := make([]image.Point, 0, len(z.Points))
+	for _, p := range z.Points {
+		points = append(points, image.Pt(int(p.X*float64(width)), int(p.Y*float64(height))))
+	}
+	return points
+}
@@ -0,0 +1,345 @@
+package arming
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"ocuai/internal/events"
+	"ocuai/internal/storage"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Режимы охраны
+const (
+	ModeHome     = "home"
+	ModeAway     = "away"
+	ModeNight    = "night"
+	ModeDisarmed = "disarmed"
+)
+
+// Источники смены режима
+const (
+	SourceAPI      = "api"
+	SourceTelegram = "telegram"
+	SourceSchedule = "schedule"
+)
+
+// Modes все режимы охраны в порядке показа
+var Modes = []string{ModeHome, ModeAway, ModeNight, ModeDisarmed}
+
+// weekdays дни недели расписания в формате cron
+var weekdays = map[string]bool{
+	"sun": true, "mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true,
+}
+
+// scheduleLookback насколько далеко назад искать пропущенное переключение по расписанию
+const scheduleLookback = 7 * 24 * time.Hour
+
+// Manager хранит текущий режим охраны и решает, какие камеры уведомляют,
+// записывают клипы и запускают AI. Режим переключается через API, Telegram и по расписанию.
+type Manager struct {
+	storage      *storage.Storage
+	eventManager *events.Manager
+	mode         string
+	profiles     map[string]storage.ArmingProfile
+	cron         *cron.Cron
+	mu           sync.RWMutex
+}
+
+// New создает менеджер режимов охраны. До Start действует режим away со всеми включенными камерами.
+func New(store *storage.Storage, eventManager *events.Manager) *Manager {
+	return &Manager{
+		storage:      store,
+		eventManager: eventManager,
+		mode:         ModeAway,
+		profiles:     defaultProfiles(),
+	}
+}
+
+// defaultProfiles профили режимов до настройки пользователем:
+// дома - запись и AI без уведомлений, снято с охраны - все выключено
+func defaultProfiles() map[string]storage.ArmingProfile {
+	all := []string{storage.AllCameras}
+	none := []string{}
+
+	return map[string]storage.ArmingProfile{
+		ModeHome:     {Mode: ModeHome, NotifyCameras: none, RecordCameras: all, AICameras: all},
+		ModeAway:     {Mode: ModeAway, NotifyCameras: all, RecordCameras: all, AICameras: all},
+		ModeNight:    {Mode: ModeNight, NotifyCameras: all, RecordCameras: all, AICameras: all},
+		ModeDisarmed: {Mode: ModeDisarmed, NotifyCameras: none, RecordCameras: none, AICameras: none},
+	}
+}
+
+// Start загружает профили и текущий режим, догоняет пропущенное переключение по расписанию
+// и запускает расписание
+func (m *Manager) Start() error {
+	profiles, err := m.storage.GetArmingProfiles()
+	if err != nil {
+		return err
+	}
+
+	mode, err := m.storage.GetSetting(storage.SettingArmingMode)
+	if err != nil {
+		return err
+	}
+	if !IsMode(mode) {
+		// Первый запуск: режим away сохраняет прежнее поведение всех камер
+		mode = ModeAway
+		if err := m.storage.SetSetting(storage.SettingArmingMode, mode); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	for _, profile := range profiles {
+		if IsMode(profile.Mode) {
+			m.profiles[profile.Mode] = profile
+		}
+	}
+	m.mode = mode
+	m.mu.Unlock()
+
+	log.Printf("Arming mode: %s", mode)
+
+	if err := m.catchUpSchedule(); err != nil {
+		log.Printf("Failed to apply missed arming schedule: %v", err)
+	}
+
+	return m.ReloadSchedules()
+}
+
+// Stop останавливает переключение по расписанию
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	c := m.cron
+	m.cron = nil
+	m.mu.Unlock()
+
+	if c != nil {
+		<-c.Stop().Done()
+	}
+}
+
+// Mode возвращает текущий режим охраны
+func (m *Manager) Mode() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mode
+}
+
+// SetMode переключает режим охраны и отправляет событие, которое попадает в журнал смен
+func (m *Manager) SetMode(mode, source string) error {
+	if !IsMode(mode) {
+		return fmt.Errorf("unknown arming mode: %s", mode)
+	}
+
+	m.mu.Lock()
+	previous := m.mode
+	if previous == mode {
+		m.mu.Unlock()
+		return nil
+	}
+	if err := m.storage.SetSetting(storage.SettingArmingMode, mode); err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.mode = mode
+	m.mu.Unlock()
+
+	log.Printf("Arming mode changed: %s -> %s (%s)", previous, mode, source)
+
+	// Событие в ленте и есть журнал смен режима
+	m.eventManager.EmitArmingChanged(mode, previous, source)
+	return nil
+}
+
+// Profiles возвращает профили всех режимов
+func (m *Manager) Profiles() []storage.ArmingProfile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	profiles := make([]storage.ArmingProfile, 0, len(Modes))
+	for _, mode := range Modes {
+		profiles = append(profiles, m.profiles[mode])
+	}
+	return profiles
+}
+
+// SaveProfile сохраняет профиль режима и сразу применяет его
+func (m *Manager) SaveProfile(profile *storage.ArmingProfile) error {
+	if !IsMode(profile.Mode) {
+		return fmt.Errorf("unknown arming mode: %s", profile.Mode)
+	}
+
+	if err := m.storage.SaveArmingProfile(profile); err != nil {
+		return err
+	}
+	profile.UpdatedAt = time.Now()
+
+	m.mu.Lock()
+	m.profiles[profile.Mode] = *profile
+	m.mu.Unlock()
+
+	return nil
+}
+
+// CanNotify проверяет, отправляет ли камера уведомления в текущем режиме
+func (m *Manager) CanNotify(cameraID string) bool {
+	return m.allowed(cameraID, func(p storage.ArmingProfile) []string { return p.NotifyCameras })
+}
+
+// CanRecord проверяет, записывает ли камера клипы событий в текущем режиме
+func (m *Manager) CanRecord(cameraID string) bool {
+	return m.allowed(cameraID, func(p storage.ArmingProfile) []string { return p.RecordCameras })
+}
+
+// CanRunAI проверяет, запускает ли камера AI детекцию в текущем режиме
+func (m *Manager) CanRunAI(cameraID string) bool {
+	return m.allowed(cameraID, func(p storage.ArmingProfile) []string { return p.AICameras })
+}
+
+// allowed проверяет, есть ли камера в списке профиля текущего режима
+func (m *Manager) allowed(cameraID string, cameras func(storage.ArmingProfile) []string) bool {
+	m.mu.RLock()
+	profile := m.profiles[m.mode]
+	m.mu.RUnlock()
+
+	for _, id := range cameras(profile) {
+		if id == storage.AllCameras || id == cameraID {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadSchedules перечитывает расписание после изменения
+func (m *Manager) ReloadSchedules() error {
+	schedules, err := m.storage.GetArmingSchedules()
+	if err != nil {
+		return err
+	}
+
+	c := cron.New()
+	for _, schedule := range schedules {
+		if !schedule.Enabled {
+			continue
+		}
+
+		mode := schedule.Mode
+		_, err := c.AddFunc(cronSpec(schedule), func() {
+			if err := m.SetMode(mode, SourceSchedule); err != nil {
+				log.Printf("Failed to switch arming mode by schedule: %v", err)
+			}
+		})
+		if err != nil {
+			log.Printf("Skipping arming schedule %d: %v", schedule.ID, err)
+		}
+	}
+
+	m.mu.Lock()
+	previous := m.cron
+	m.cron = c
+	m.mu.Unlock()
+
+	if previous != nil {
+		previous.Stop()
+	}
+	c.Start()
+
+	return nil
+}
+
+// catchUpSchedule включает режим последнего переключения по расписанию,
+// если оно пришлось на время, когда система была выключена
+func (m *Manager) catchUpSchedule() error {
+	schedules, err := m.storage.GetArmingSchedules()
+	if err != nil {
+		return err
+	}
+
+	changes, err := m.storage.GetArmingChanges(1, 0)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	since := now.Add(-scheduleLookback)
+	if len(changes) > 0 && changes[0].CreatedAt.After(since) {
+		since = changes[0].CreatedAt
+	}
+
+	var mode string
+	var last time.Time
+	for _, schedule := range schedules {
+		if !schedule.Enabled {
+			continue
+		}
+
+		spec, err := cron.ParseStandard(cronSpec(schedule))
+		if err != nil {
+			continue
+		}
+
+		// Переключения до создания или изменения записи не считаются пропущенными
+		from := since
+		if schedule.UpdatedAt.After(from) {
+			from = schedule.UpdatedAt
+		}
+
+		for t := spec.Next(from); !t.After(now); t = spec.Next(t) {
+			if t.After(last) {
+				last = t
+				mode = schedule.Mode
+			}
+		}
+	}
+
+	if mode == "" {
+		return nil
+	}
+	return m.SetMode(mode, SourceSchedule)
+}
+
+// cronSpec возвращает выражение cron для записи расписания
+func cronSpec(schedule storage.ArmingSchedule) string {
+	days := "*"
+	if len(schedule.Days) > 0 {
+		days = strings.Join(schedule.Days, ",")
+	}
+
+	at, _ := time.Parse("15:04", schedule.Time)
+	return fmt.Sprintf("%d %d * * %s", at.Minute(), at.Hour(), days)
+}
+
+// IsMode проверяет, что режим охраны существует
+func IsMode(mode string) bool {
+	for _, m := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSchedule проверяет режим, время и дни записи расписания
+func ValidateSchedule(schedule *storage.ArmingSchedule) error {
+	if !IsMode(schedule.Mode) {
+		return fmt.Errorf("unknown arming mode: %s", schedule.Mode)
+	}
+
+	if _, err := time.Parse("15:04", schedule.Time); err != nil {
+		return fmt.Errorf("invalid time %q, expected HH:MM", schedule.Time)
+	}
+
+	for _, day := range schedule.Days {
+		if !weekdays[day] {
+			return fmt.Errorf("unknown day %q, expected sun, mon, tue, wed, thu, fri or sat", day)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package audio
+
+import (
+	"math"
+	"time"
+
+	"ocuai/internal/config"
+)
+
+// Виды звуковых детекций
+const (
+	KindLoudness = "loudness" // громкий звук выше порога
+	KindSound    = "sound"    // звук, распознанный классификатором
+)
+
+// silenceLevel уровень тишины в dBFS
+const silenceLevel = -96.0
+
+// Detection звуковая детекция в окне PCM
+type Detection struct {
+	Kind       string    `json:"kind"`
+	Class      string    `json:"class"` // для loudness - "loud_noise"
+	Confidence float32   `json:"confidence"`
+	Level      float64   `json:"level_db"` // уровень окна в dBFS
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Detector анализирует окна PCM камеры: уровень громкости и классы звуков.
+// Не потокобезопасен - у каждой камеры свой детектор.
+type Detector struct {
+	threshold     float64
+	classifyLevel float64
+	classes       []string
+	minConfidence float32
+	cooldown      time.Duration
+	sampleRate    int
+	classifier    Classifier
+	lastFired     map[string]time.Time // последние события по классам звуков
+}
+
+// NewDetector создает детектор звуков
+func NewDetector(cfg config.AudioConfig, classifier Classifier) *Detector {
+	if classifier == nil {
+		classifier = noopClassifier{}
+	}
+
+	sampleRate := cfg.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+
+	return &Detector{
+		threshold:     cfg.LoudnessThreshold,
+		classifyLevel: cfg.ClassifyMinLevel,
+		classes:       cfg.Classes,
+		minConfidence: cfg.MinConfidence,
+		cooldown:      time.Duration(cfg.CooldownSeconds) * time.Second,
+		sampleRate:    sampleRate,
+		classifier:    classifier,
+		lastFired:     make(map[string]time.Time),
+	}
+}
+
+// Process анализирует окно PCM (моно, 16 бит) и возвращает новые детекции.
+// Один и тот же звук сообщается не чаще раза за cooldown.
+func (d *Detector) Process(samples []int16, now time.Time) ([]Detection, error) {
+	level := Level(samples)
+
+	var detections []Detection
+	if level >= d.threshold {
+		if detection, ok := d.fire(KindLoudness, "loud_noise", 1, level, now); ok {
+			detections = append(detections, detection)
+		}
+	}
+
+	// Тихие окна не отправляем в классификатор
+	if level < d.classifyLevel {
+		return detections, nil
+	}
+
+	sounds, err := d.classifier.Classify(samples, d.sampleRate)
+	if err != nil {
+		return detections, err
+	}
+
+	for _, sound := range sounds {
+		if sound.Confidence < d.minConfidence || !d.wanted(sound.Class) {
+			continue
+		}
+		if detection, ok := d.fire(KindSound, sound.Class, sound.Confidence, level, now); ok {
+			detections = append(detections, detection)
+		}
+	}
+
+	return detections, nil
+}
+
+// fire создает детекцию, если для класса прошел интервал тишины
+func (d *Detector) fire(kind, class string, confidence float32, level float64, now time.Time) (Detection, bool) {
+	if last, ok := d.lastFired[class]; ok && now.Sub(last) < d.cooldown {
+		return Detection{}, false
+	}
+	d.lastFired[class] = now
+
+	return Detection{
+		Kind:       kind,
+		Class:      class,
+		Confidence: confidence,
+		Level:      level,
+		Timestamp:  now,
+	}, true
+}
+
+// wanted проверяет, сообщаем ли о звуке класса; пустой список - о любом
+func (d *Detector) wanted(class string) bool {
+	if len(d.classes) == 0 {
+		return true
+	}
+	for _, c := range d.classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Level возвращает среднеквадратичный уровень окна в dBFS (0 - максимум, тишина - -96)
+func Level(samples []int16) float64 {
+	if len(samples) == 0 {
+		return silenceLevel
+	}
+
+	var sum float64
+	for _, s := range samples {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+
+	rms := math.Sqrt(sum / float64(len(samples)))
+	if rms == 0 {
+		return silenceLevel
+	}
+
+	return math.Max(20*math.Log10(rms), silenceLevel)
+}
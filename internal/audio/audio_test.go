@@ -0,0 +1,147 @@
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"ocuai/internal/config"
+)
+
+// fakeClassifier возвращает заданные звуки и считает классифицированные окна
+type fakeClassifier struct {
+	sounds []Sound
+	calls  int
+}
+
+func (c *fakeClassifier) Classify(samples []int16, sampleRate int) ([]Sound, error) {
+	c.calls++
+	return append([]Sound(nil), c.sounds...), nil
+}
+
+func (c *fakeClassifier) Close() error {
+	return nil
+}
+
+// window возвращает окно из одинаковых сэмплов
+func window(value int16) []int16 {
+	samples := make([]int16, 1600)
+	for i := range samples {
+		samples[i] = value
+	}
+	return samples
+}
+
+func kinds(detections []Detection) []string {
+	var result []string
+	for _, d := range detections {
+		result = append(result, d.Kind+":"+d.Class)
+	}
+	return result
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		samples []int16
+		want    float64
+	}{
+		{nil, silenceLevel},
+		{window(0), silenceLevel},
+		{window(16384), -6.02},
+		{window(-16384), -6.02},
+		{window(3277), -20},
+		{window(-32768), 0},
+		{window(1), -90.31}, // самый тихий ненулевой сигнал
+	}
+
+	for _, tt := range tests {
+		if got := Level(tt.samples); math.Abs(got-tt.want) > 0.05 {
+			t.Errorf("Level(%d samples) = %.2f, want %.2f", len(tt.samples), got, tt.want)
+		}
+	}
+}
+
+func TestProcessLoudnessCooldown(t *testing.T) {
+	classifier := &fakeClassifier{}
+	detector := NewDetector(config.AudioConfig{
+		LoudnessThreshold: -10,
+		ClassifyMinLevel:  -30,
+		CooldownSeconds:   5,
+	}, classifier)
+
+	start := time.Now()
+	steps := []struct {
+		offset  time.Duration
+		samples []int16
+		want    []string
+	}{
+		{0, window(16384), []string{"loudness:loud_noise"}},
+		{2 * time.Second, window(16384), nil}, // тот же звук внутри cooldown
+		{3 * time.Second, window(3277), nil},  // тише порога громкости
+		{5 * time.Second, window(16384), []string{"loudness:loud_noise"}},
+	}
+
+	for i, step := range steps {
+		detections, err := detector.Process(step.samples, start.Add(step.offset))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got := kinds(detections); !equal(got, step.want) {
+			t.Errorf("step %d: detections = %v, want %v", i, got, step.want)
+		}
+	}
+
+	if classifier.calls != 4 {
+		t.Errorf("classifier called %d time(s), want 4", classifier.calls)
+	}
+}
+
+func TestProcessSoundFilters(t *testing.T) {
+	classifier := &fakeClassifier{sounds: []Sound{
+		{Class: "glass_break", Confidence: 0.9},
+		{Class: "dog_bark", Confidence: 0.95},
+		{Class: "siren", Confidence: 0.3},
+	}}
+	detector := NewDetector(config.AudioConfig{
+		LoudnessThreshold: 0, // громкость не срабатывает
+		ClassifyMinLevel:  -30,
+		Classes:           []string{"glass_break", "siren"},
+		MinConfidence:     0.5,
+		CooldownSeconds:   10,
+	}, classifier)
+
+	now := time.Now()
+	detections, err := detector.Process(window(3277), now)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got, want := kinds(detections), []string{"sound:glass_break"}; !equal(got, want) {
+		t.Errorf("detections = %v, want %v", got, want)
+	}
+
+	// Cooldown считается по каждому классу отдельно
+	classifier.sounds = []Sound{{Class: "glass_break", Confidence: 0.9}, {Class: "siren", Confidence: 0.8}}
+	detections, _ = detector.Process(window(3277), now.Add(time.Second))
+	if got, want := kinds(detections), []string{"sound:siren"}; !equal(got, want) {
+		t.Errorf("detections = %v, want %v", got, want)
+	}
+
+	// Тихие окна не отправляются в классификатор
+	calls := classifier.calls
+	detections, _ = detector.Process(window(100), now.Add(time.Minute))
+	if len(detections) != 0 || classifier.calls != calls {
+		t.Errorf("quiet window: %v detection(s), classifier calls %d -> %d", kinds(detections), calls, classifier.calls)
+	}
+}
@@ -0,0 +1,118 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ocuai/internal/config"
+)
+
+// Бэкенды классификатора звуков
+const (
+	ClassifierNone = "none"
+	ClassifierHTTP = "http"
+)
+
+// Sound звук, распознанный классификатором
+type Sound struct {
+	Class      string  `json:"class"`
+	Confidence float32 `json:"confidence"`
+}
+
+// Classifier распознает звуки (разбитое стекло, сирена, лай) в окне PCM
+type Classifier interface {
+	Classify(samples []int16, sampleRate int) ([]Sound, error)
+	Close() error
+}
+
+// NewClassifier создает классификатор звуков по конфигурации
+func NewClassifier(cfg config.AudioConfig) (Classifier, error) {
+	switch strings.ToLower(cfg.Classifier) {
+	case "", ClassifierNone:
+		return noopClassifier{}, nil
+	case ClassifierHTTP:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("url is required for the http audio classifier")
+		}
+
+		timeout := time.Duration(cfg.TimeoutMS) * time.Millisecond
+		if timeout <= 0 {
+			timeout = 2 * time.Second
+		}
+		return &httpClassifier{url: cfg.URL, apiKey: cfg.APIKey, client: &http.Client{Timeout: timeout}}, nil
+	default:
+		return nil, fmt.Errorf("unknown audio classifier: %s", cfg.Classifier)
+	}
+}
+
+// noopClassifier не распознает звуков - остается только детекция громкости
+type noopClassifier struct{}
+
+// Classify ничего не распознает
+func (noopClassifier) Classify(samples []int16, sampleRate int) ([]Sound, error) {
+	return nil, nil
+}
+
+// Close ничего не делает
+func (noopClassifier) Close() error {
+	return nil
+}
+
+// httpClassifier отправляет окно на внешний классификатор (YAMNet и т.п.).
+// Окно передается как audio/L16 (моно, little-endian), ответ - {"sounds": [{"class", "confidence"}]}.
+type httpClassifier struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// httpClassifyResponse ответ классификатора
+type httpClassifyResponse struct {
+	Sounds []Sound `json:"sounds"`
+}
+
+// Classify отправляет окно на сервер и возвращает распознанные звуки
+func (c *httpClassifier) Classify(samples []int16, sampleRate int) ([]Sound, error) {
+	var body bytes.Buffer
+	if err := binary.Write(&body, binary.LittleEndian, samples); err != nil {
+		return nil, fmt.Errorf("failed to encode samples: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", fmt.Sprintf("audio/L16; rate=%d; channels=1", sampleRate))
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("classify request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("audio classifier returned %d: %s", resp.StatusCode, bytes.TrimSpace(text))
+	}
+
+	var result httpClassifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode classifier response: %w", err)
+	}
+
+	return result.Sounds, nil
+}
+
+// Close ничего не делает
+func (c *httpClassifier) Close() error {
+	return nil
+}
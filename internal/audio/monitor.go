@@ -0,0 +1,162 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"ocuai/internal/config"
+)
+
+// Пауза перед переподключением к потоку после обрыва
+const (
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = time.Minute
+)
+
+// DetectionHandler получает звуковые детекции камеры
+type DetectionHandler func(detection Detection)
+
+// Monitor читает аудиодорожку камеры через ffmpeg (PCM s16le, моно)
+// и передает детекции обработчику
+type Monitor struct {
+	cameraID   string
+	url        string
+	ffmpegPath string
+	sampleRate int
+	window     int // сэмплов в окне анализа
+	detector   *Detector
+	handler    DetectionHandler
+}
+
+// NewMonitor создает монитор звука камеры; url - RTSP поток go2rtc с аудио
+func NewMonitor(cameraID, url string, cfg config.AudioConfig, classifier Classifier, handler DetectionHandler) *Monitor {
+	detector := NewDetector(cfg, classifier)
+
+	windowMS := cfg.WindowMS
+	if windowMS <= 0 {
+		windowMS = 500
+	}
+
+	ffmpegPath := cfg.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	return &Monitor{
+		cameraID:   cameraID,
+		url:        url,
+		ffmpegPath: ffmpegPath,
+		sampleRate: detector.sampleRate,
+		window:     detector.sampleRate * windowMS / 1000,
+		detector:   detector,
+		handler:    handler,
+	}
+}
+
+// Run читает звук до отмены контекста, переподключаясь при обрывах потока
+func (m *Monitor) Run(ctx context.Context) {
+	log.Printf("Audio monitoring started for camera %s", m.cameraID)
+	defer log.Printf("Audio monitoring stopped for camera %s", m.cameraID)
+
+	delay := minRetryDelay
+	for {
+		windows, err := m.capture(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Поток работал - следующий обрыв снова ждем недолго
+		if windows > 0 {
+			delay = minRetryDelay
+		}
+		log.Printf("Audio capture for camera %s ended: %v, retrying in %s", m.cameraID, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// capture запускает ffmpeg и анализирует окна PCM, пока поток не оборвется.
+// Возвращает количество прочитанных окон.
+func (m *Monitor) capture(ctx context.Context) (int, error) {
+	cmd := exec.CommandContext(ctx, m.ffmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-rtsp_transport", "tcp",
+		"-i", m.url,
+		"-vn", "-ac", "1", "-ar", strconv.Itoa(m.sampleRate),
+		"-f", "s16le", "-",
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open ffmpeg output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	windows, readErr := m.read(bufio.NewReader(stdout))
+
+	// Дочитывать больше нечего - завершаем ffmpeg, если он еще жив
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+	cmd.Wait()
+
+	if message := bytes.TrimSpace(stderr.Bytes()); len(message) > 0 {
+		return windows, fmt.Errorf("%v: %s", readErr, lastLine(message))
+	}
+	return windows, readErr
+}
+
+// read читает окна PCM и передает детекции обработчику
+func (m *Monitor) read(r io.Reader) (int, error) {
+	samples := make([]int16, m.window)
+	windows := 0
+
+	for {
+		if err := binary.Read(r, binary.LittleEndian, samples); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return windows, fmt.Errorf("audio stream closed")
+			}
+			return windows, err
+		}
+		windows++
+
+		detections, err := m.detector.Process(samples, time.Now())
+		if err != nil {
+			log.Printf("Audio classification error for camera %s: %v", m.cameraID, err)
+		}
+
+		for _, detection := range detections {
+			m.handler(detection)
+		}
+	}
+}
+
+// lastLine возвращает последнюю строку вывода ffmpeg
+func lastLine(output []byte) []byte {
+	if i := bytes.LastIndexByte(output, '\n'); i >= 0 {
+		return output[i+1:]
+	}
+	return output
+}
@@ -19,21 +19,29 @@ type Config struct {
 	Telegram  TelegramConfig  `yaml:"telegram"`
 	Streaming StreamingConfig `yaml:"streaming"`
 	AI        AIConfig        `yaml:"ai"`
+	Recording RecordingConfig `yaml:"recording"`
+	Motion    MotionConfig    `yaml:"motion"`
+	Audio     AudioConfig     `yaml:"audio"`
+	Tamper    TamperConfig    `yaml:"tamper"`
+	MQTT      MQTTConfig      `yaml:"mqtt"`
 	Cameras   []CameraConfig  `yaml:"cameras"`
 }
 
 // ServerConfig конфигурация веб-сервера
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port string `yaml:"port"`
+	Host      string `yaml:"host"`
+	Port      string `yaml:"port"`
+	PublicURL string `yaml:"public_url"` // внешний адрес веб-интерфейса для ссылок в уведомлениях
 }
 
 // StorageConfig конфигурация хранилища
 type StorageConfig struct {
-	DatabasePath   string `yaml:"database_path"`
-	VideoPath      string `yaml:"video_path"`
-	RetentionDays  int    `yaml:"retention_days"`
-	MaxVideoSizeMB int    `yaml:"max_video_size_mb"`
+	DatabasePath        string  `yaml:"database_path"`
+	VideoPath           string  `yaml:"video_path"`
+	RetentionDays       int     `yaml:"retention_days"`
+	MaxVideoSizeMB      int     `yaml:"max_video_size_mb"`
+	MaxStorageGB        float64 `yaml:"max_storage_gb"`        // общий бюджет диска для видео, 0 - без ограничения
+	ExportRetentionDays int     `yaml:"export_retention_days"` // срок хранения архивов экспорта, 0 - без ограничения
 }
 
 // SecurityConfig конфигурация безопасности
@@ -55,27 +63,149 @@ type StreamingConfig struct {
 	BufferSizeKB int `yaml:"buffer_size_kb"`
 }
 
+// RecordingConfig конфигурация записи видео
+type RecordingConfig struct {
+	PreRollSeconds  int    `yaml:"pre_roll_seconds"`
+	PostRollSeconds int    `yaml:"post_roll_seconds"`
+	FPS             int    `yaml:"fps"`
+	Codec           string `yaml:"codec"`           // FourCC кодека, например avc1
+	SegmentMinutes  int    `yaml:"segment_minutes"` // длина сегмента непрерывной записи (1-10)
+	SegmentFormat   string `yaml:"segment_format"`  // ts, mkv, mp4
+}
+
+// MotionConfig конфигурация детектора движения
+type MotionConfig struct {
+	Algorithm      string  `yaml:"algorithm"`        // mog2, knn, diff
+	History        int     `yaml:"history"`          // количество кадров в модели фона
+	BlurSize       int     `yaml:"blur_size"`        // размер ядра размытия, 0 - без размытия
+	MinContourArea float64 `yaml:"min_contour_area"` // минимальная площадь области в долях кадра при чувствительности 0.5
+}
+
+// TamperConfig конфигурация детекции саботажа камер (закрытие, расфокус, поворот)
+type TamperConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	IntervalSeconds  int     `yaml:"interval_seconds"`  // как часто проверять кадр камеры
+	DarkThreshold    float64 `yaml:"dark_threshold"`    // средняя яркость (0..255), ниже которой однотонный кадр считается темным
+	DarkRatio        float64 `yaml:"dark_ratio"`        // доля обычной яркости, ниже которой темный кадр означает закрытый объектив
+	UniformThreshold float64 `yaml:"uniform_threshold"` // разброс яркости, ниже которого кадр считается однотонным
+	DefocusRatio     float64 `yaml:"defocus_ratio"`     // доля обычной резкости, ниже которой камера расфокусирована
+	SceneChange      float64 `yaml:"scene_change"`      // доля изменившихся пикселей относительно эталона для смены сцены
+	ConfirmChecks    int     `yaml:"confirm_checks"`    // сколько проверок подряд должно подтвердить саботаж
+}
+
+// AudioConfig конфигурация анализа звука с аудиодорожек камер
+type AudioConfig struct {
+	Enabled           bool     `yaml:"enabled"`
+	FFmpegPath        string   `yaml:"ffmpeg_path"`
+	SampleRate        int      `yaml:"sample_rate"`        // частота PCM, получаемого из потока
+	WindowMS          int      `yaml:"window_ms"`          // длина анализируемого окна
+	LoudnessThreshold float64  `yaml:"loudness_threshold"` // уровень громкого звука в dBFS
+	Classifier        string   `yaml:"classifier"`         // none, http
+	URL               string   `yaml:"url"`                // адрес классификатора звуков для http
+	APIKey            string   `yaml:"api_key"`
+	TimeoutMS         int      `yaml:"timeout_ms"`
+	ClassifyMinLevel  float64  `yaml:"classify_min_level"` // окна тише этого уровня (dBFS) не классифицируются
+	Classes           []string `yaml:"classes"`            // звуки, о которых сообщаем
+	MinConfidence     float32  `yaml:"min_confidence"`     // минимальная уверенность классификатора
+	CooldownSeconds   int      `yaml:"cooldown_seconds"`   // минимальный интервал между событиями одного звука
+}
+
+// MQTTConfig конфигурация публикации событий в MQTT брокер
+type MQTTConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	Broker           string   `yaml:"broker"` // tcp://host:1883, ssl://host:8883
+	ClientID         string   `yaml:"client_id"`
+	Username         string   `yaml:"username"`
+	Password         string   `yaml:"password"`
+	TopicPrefix      string   `yaml:"topic_prefix"`
+	Discovery        bool     `yaml:"discovery"`          // конфигурации автообнаружения Home Assistant
+	DiscoveryPrefix  string   `yaml:"discovery_prefix"`   // префикс топиков автообнаружения
+	MotionOffSeconds int      `yaml:"motion_off_seconds"` // через сколько секунд без событий датчик сбрасывается в OFF
+	SnapshotSeconds  int      `yaml:"snapshot_seconds"`   // период публикации снапшотов камер, 0 - только по событиям
+	PersonClasses    []string `yaml:"person_classes"`     // классы AI для датчика person
+	CarClasses       []string `yaml:"car_classes"`        // классы AI для датчика car
+}
+
 // AIConfig конфигурация AI модуля
 type AIConfig struct {
-	ModelPath  string   `yaml:"model_path"`
-	Enabled    bool     `yaml:"enabled"`
-	Threshold  float32  `yaml:"threshold"`
-	Classes    []string `yaml:"classes"`
+	ModelConfig  `yaml:",inline"`       // модель по умолчанию
+	Enabled      bool                   `yaml:"enabled"`
+	Threshold    float32                `yaml:"threshold"`
+	Classes      []string               `yaml:"classes"`       // классы, о которых сообщаем
+	NMSThreshold float32                `yaml:"nms_threshold"` // порог IoU для подавления дублей
+	Workers      int                    `yaml:"workers"`       // воркеры пула инференса
+	InferenceFPS float64                `yaml:"inference_fps"` // кадров в секунду на AI для камеры по умолчанию
+	Tracker      TrackerConfig          `yaml:"tracker"`
+	Faces        FaceConfig             `yaml:"faces"`
+	Plates       PlateConfig            `yaml:"plates"`
+	Models       map[string]ModelConfig `yaml:"models"` // дополнительные модели, выбираемые в настройках камеры
+}
+
+// ModelConfig конфигурация модели детекции
+type ModelConfig struct {
+	Backend    string   `yaml:"backend"`     // opencv, http, noop
+	ModelPath  string   `yaml:"model_path"`  // ONNX модель для opencv
 	DeviceType string   `yaml:"device_type"` // cpu, gpu
+	ModelType  string   `yaml:"model_type"`  // yolov8, yolov5, пусто - автоопределение
+	InputSize  int      `yaml:"input_size"`  // размер входа модели
+	Labels     []string `yaml:"labels"`      // имена классов модели, пусто - COCO
+	URL        string   `yaml:"url"`         // адрес сервера инференса для http
+	APIKey     string   `yaml:"api_key"`
+	TimeoutMS  int      `yaml:"timeout_ms"`
+}
+
+// FaceConfig конфигурация распознавания лиц на кадрах с людьми
+type FaceConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	Detector       string  `yaml:"detector"`        // haar, stub
+	DetectorModel  string  `yaml:"detector_model"`  // XML каскада Хаара
+	Embedder       string  `yaml:"embedder"`        // onnx, stub
+	EmbeddingModel string  `yaml:"embedding_model"` // ONNX модель эмбеддингов (ArcFace и т.п.)
+	InputSize      int     `yaml:"input_size"`      // размер входа модели эмбеддингов
+	MatchThreshold float32 `yaml:"match_threshold"` // минимальное косинусное сходство с галереей
+	MinFaceSize    int     `yaml:"min_face_size"`   // минимальный размер лица в пикселях
+}
+
+// PlateConfig конфигурация распознавания номеров (ALPR) на камерах с alpr: true
+type PlateConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	Detector      string   `yaml:"detector"`       // haar, stub
+	DetectorModel string   `yaml:"detector_model"` // XML каскада Хаара для номеров
+	Reader        string   `yaml:"reader"`         // http
+	URL           string   `yaml:"url"`            // адрес сервера OCR для http
+	APIKey        string   `yaml:"api_key"`
+	TimeoutMS     int      `yaml:"timeout_ms"`
+	Classes       []string `yaml:"classes"`         // классы транспорта, на которых ищутся номера
+	MinConfidence float32  `yaml:"min_confidence"`  // минимальная уверенность OCR
+	MinPlateWidth int      `yaml:"min_plate_width"` // минимальная ширина номера в пикселях
+}
+
+// TrackerConfig конфигурация трекера объектов
+type TrackerConfig struct {
+	IoUThreshold float32 `yaml:"iou_threshold"` // минимальное пересечение для сопоставления с треком
+	MaxMissed    int     `yaml:"max_missed"`    // сколько кадров анализа объект может отсутствовать
+	MinHits      int     `yaml:"min_hits"`      // сколько раз объект должен быть замечен до события
 }
 
 // CameraConfig конфигурация камеры
 type CameraConfig struct {
-	ID              string  `yaml:"id"`
-	Name            string  `yaml:"name"`
-	RTSPURL         string  `yaml:"rtsp_url"`
-	Username        string  `yaml:"username"`
-	Password        string  `yaml:"password"`
-	MotionDetection bool    `yaml:"motion_detection"`
-	AIDetection     bool    `yaml:"ai_detection"`
-	Sensitivity     float32 `yaml:"sensitivity"`
-	RecordMotion    bool    `yaml:"record_motion"`
-	SendTelegram    bool    `yaml:"send_telegram"`
+	ID               string  `yaml:"id"`
+	Name             string  `yaml:"name"`
+	RTSPURL          string  `yaml:"rtsp_url"`
+	Username         string  `yaml:"username"`
+	Password         string  `yaml:"password"`
+	MotionDetection  bool    `yaml:"motion_detection"`
+	AIDetection      bool    `yaml:"ai_detection"`
+	AIModel          string  `yaml:"ai_model"`      // имя модели из ai.models, пусто - модель по умолчанию
+	InferenceFPS     float64 `yaml:"inference_fps"` // 0 - значение из ai.inference_fps
+	ALPR             bool    `yaml:"alpr"`          // распознавание номеров транспорта
+	Sensitivity      float32 `yaml:"sensitivity"`
+	AudioDetection   bool    `yaml:"audio_detection"` // анализ звука с аудиодорожки
+	RecordMotion     bool    `yaml:"record_motion"`
+	RecordContinuous bool    `yaml:"record_continuous"`
+	SendTelegram     bool    `yaml:"send_telegram"`
+	RetentionDays    int     `yaml:"retention_days"` // 0 - используется общее значение
+	MaxStorageGB     float64 `yaml:"max_storage_gb"` // 0 - без отдельного ограничения
 }
 
 // Load загружает конфигурацию из файла или создает дефолтную
@@ -179,6 +309,9 @@ func (c *Config) overrideFromEnv() {
 	if v := os.Getenv("OCUAI_PORT"); v != "" {
 		c.Server.Port = v
 	}
+	if v := os.Getenv("OCUAI_PUBLIC_URL"); v != "" {
+		c.Server.PublicURL = v
+	}
 	if v := os.Getenv("OCUAI_DATABASE_PATH"); v != "" {
 		c.Storage.DatabasePath = v
 	}
@@ -216,10 +349,11 @@ func defaultConfig() *Config {
 			Port: "8080",
 		},
 		Storage: StorageConfig{
-			DatabasePath:   filepath.Join(dataDir, "db", "ocuai.db"),
-			VideoPath:      filepath.Join(dataDir, "videos"),
-			RetentionDays:  7,
-			MaxVideoSizeMB: 50,
+			DatabasePath:        filepath.Join(dataDir, "db", "ocuai.db"),
+			VideoPath:           filepath.Join(dataDir, "videos"),
+			RetentionDays:       7,
+			MaxVideoSizeMB:      50,
+			ExportRetentionDays: 3,
 		},
 		Security: SecurityConfig{
 			SessionSecret: "", // Будет сгенерирован автоматически
@@ -235,11 +369,93 @@ func defaultConfig() *Config {
 			BufferSizeKB: 1024,
 		},
 		AI: AIConfig{
-			ModelPath:  filepath.Join(dataDir, "models", "yolov8n.onnx"),
-			Enabled:    false,
-			Threshold:  0.5,
-			Classes:    []string{"person", "car", "truck", "bus", "motorcycle", "bicycle", "dog", "cat"},
-			DeviceType: "cpu",
+			ModelConfig: ModelConfig{
+				Backend:    "opencv",
+				ModelPath:  filepath.Join(dataDir, "models", "yolov8n.onnx"),
+				DeviceType: "cpu",
+				InputSize:  640,
+				TimeoutMS:  2000,
+			},
+			Enabled:      false,
+			Threshold:    0.5,
+			Classes:      []string{"person", "car", "truck", "bus", "motorcycle", "bicycle", "dog", "cat"},
+			NMSThreshold: 0.45,
+			Workers:      2,
+			InferenceFPS: 1,
+			Tracker: TrackerConfig{
+				IoUThreshold: 0.3,
+				MaxMissed:    3,
+				MinHits:      1,
+			},
+			Faces: FaceConfig{
+				Enabled:        false,
+				Detector:       "haar",
+				DetectorModel:  filepath.Join(dataDir, "models", "haarcascade_frontalface_default.xml"),
+				Embedder:       "onnx",
+				EmbeddingModel: filepath.Join(dataDir, "models", "face_embedding.onnx"),
+				InputSize:      112,
+				MatchThreshold: 0.5,
+				MinFaceSize:    40,
+			},
+			Plates: PlateConfig{
+				Enabled:       false,
+				Detector:      "haar",
+				DetectorModel: filepath.Join(dataDir, "models", "haarcascade_russian_plate_number.xml"),
+				Reader:        "http",
+				TimeoutMS:     2000,
+				Classes:       []string{"car", "truck", "bus", "motorcycle"},
+				MinConfidence: 0.7,
+				MinPlateWidth: 40,
+			},
+		},
+		Recording: RecordingConfig{
+			PreRollSeconds:  5,
+			PostRollSeconds: 10,
+			FPS:             10,
+			Codec:           "avc1",
+			SegmentMinutes:  5,
+			SegmentFormat:   "ts",
+		},
+		Motion: MotionConfig{
+			Algorithm:      "mog2",
+			History:        500,
+			BlurSize:       5,
+			MinContourArea: 0.001,
+		},
+		Tamper: TamperConfig{
+			Enabled:          true,
+			IntervalSeconds:  10,
+			DarkThreshold:    20,
+			DarkRatio:        0.5,
+			UniformThreshold: 6,
+			DefocusRatio:     0.3,
+			SceneChange:      0.6,
+			ConfirmChecks:    3,
+		},
+		Audio: AudioConfig{
+			Enabled:           false,
+			FFmpegPath:        "ffmpeg",
+			SampleRate:        16000,
+			WindowMS:          500,
+			LoudnessThreshold: -20,
+			Classifier:        "none",
+			TimeoutMS:         2000,
+			ClassifyMinLevel:  -45,
+			Classes:           []string{"glass_break", "alarm", "dog_bark"},
+			MinConfidence:     0.6,
+			CooldownSeconds:   10,
+		},
+		MQTT: MQTTConfig{
+			Enabled:          false,
+			Broker:           "tcp://localhost:1883",
+			ClientID:         "ocuai",
+			TopicPrefix:      "ocuai",
+			Discovery:        true,
+			DiscoveryPrefix:  "homeassistant",
+			MotionOffSeconds: 30,
+			SnapshotSeconds:  60,
+			PersonClasses:    []string{"person"},
+			CarClasses:       []string{"car", "truck", "bus", "motorcycle"},
 		},
 		Cameras: []CameraConfig{},
 	}
@@ -0,0 +1,70 @@
+package counting
+
+import (
+	"fmt"
+
+	"ocuai/internal/events"
+	"ocuai/internal/storage"
+)
+
+// Counter агрегирует события появления объектов и пересечения линий
+// в часовые счетчики по камерам и классам
+type Counter struct {
+	storage      *storage.Storage
+	eventManager *events.Manager
+}
+
+// New создает счетчик
+func New(store *storage.Storage, eventManager *events.Manager) *Counter {
+	return &Counter{
+		storage:      store,
+		eventManager: eventManager,
+	}
+}
+
+// Start подписывается на события
+func (c *Counter) Start() {
+	c.eventManager.Subscribe("counting", c.handleEvent, events.EventTypeAI, events.EventTypeLineCrossing)
+}
+
+// handleEvent считает событие подходящего вида
+func (c *Counter) handleEvent(event events.Event) error {
+	if event.Type == events.EventTypeLineCrossing {
+		return c.handleLineCrossing(event)
+	}
+	return c.handleDetection(event)
+}
+
+// handleDetection считает появление объекта
+func (c *Counter) handleDetection(event events.Event) error {
+	class := dataString(event.Data, "class")
+	if class == "" {
+		return nil
+	}
+
+	return c.increment(event, class, storage.CountKindDetection, "")
+}
+
+// handleLineCrossing считает пересечение линии с учетом направления
+func (c *Counter) handleLineCrossing(event events.Event) error {
+	class := dataString(event.Data, "class")
+	if class == "" {
+		return nil
+	}
+
+	return c.increment(event, class, storage.CountKindLineCrossing, dataString(event.Data, "direction"))
+}
+
+// increment сохраняет единицу счетчика
+func (c *Counter) increment(event events.Event, class, kind, direction string) error {
+	if err := c.storage.IncrementCount(event.CameraID, class, kind, direction, event.Timestamp, 1); err != nil {
+		return fmt.Errorf("failed to count %s of %s on camera %s: %w", kind, class, event.CameraID, err)
+	}
+	return nil
+}
+
+// dataString возвращает строковое поле данных события
+func dataString(data map[string]interface{}, key string) string {
+	value, _ := data[key].(string)
+	return value
+}
@@ -0,0 +1,377 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"ocuai/internal/storage"
+)
+
+// Параметры доставки событий подписчикам
+const (
+	deliveryBatch       = 100             // записей журнала за один запрос
+	deliveryPoll        = 5 * time.Second // проверка журнала, если подписчика не разбудили
+	maxDeliveryAttempts = 5               // попыток до записи в неудачные доставки
+	minRetryDelay       = time.Second     // пауза перед первой повторной попыткой
+	maxRetryDelay       = time.Minute     // предельная пауза между попытками
+	outboxRetention     = 7 * 24 * time.Hour
+)
+
+// DeliveryHandler доставляет событие подписчику. Ошибка означает, что доставку нужно повторить.
+type DeliveryHandler func(Event) error
+
+// subscriber постоянный подписчик журнала событий
+type subscriber struct {
+	name    string
+	handler DeliveryHandler
+	types   []string // пустой - все типы
+	wake    chan struct{}
+	stop    chan struct{} // закрывается при отписке
+	done    chan struct{} // закрывается, когда доставка остановлена
+}
+
+// SubscriberStats состояние доставки подписчику
+type SubscriberStats struct {
+	Name       string    `json:"name"`
+	Registered bool      `json:"registered"` // подписчик работает в этом процессе
+	Position   int64     `json:"position"`
+	Pending    int       `json:"pending"` // записей журнала ждут доставки
+	Failed     int       `json:"failed"`  // событий не доставлено после всех попыток
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// DeliveryStats состояние журнала событий
+type DeliveryStats struct {
+	LatestSequence int64             `json:"latest_sequence"`
+	Dropped        uint64            `json:"dropped"` // событий не записано в журнал с момента запуска
+	Subscribers    []SubscriberStats `json:"subscribers"`
+}
+
+// Subscribe регистрирует постоянного подписчика на события указанных типов (без типов - на все).
+// У подписчика свой курсор в журнале: события доставляются по порядку, хотя бы один раз,
+// с повторами при ошибке и продолжением с места остановки после перезапуска.
+// Новый подписчик получает только события, появившиеся после подписки.
+func (m *Manager) Subscribe(name string, handler DeliveryHandler, types ...EventType) {
+	sub := &subscriber{
+		name:    name,
+		handler: handler,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, t := range types {
+		sub.types = append(sub.types, string(t))
+	}
+
+	m.mu.Lock()
+	if _, exists := m.subscribers[name]; exists {
+		m.mu.Unlock()
+		log.Printf("Event subscriber %s is already registered", name)
+		return
+	}
+	m.subscribers[name] = sub
+	m.mu.Unlock()
+
+	position, found, err := m.storage.GetEventCursor(name)
+	if err != nil {
+		log.Printf("Event subscriber %s: %v", name, err)
+	}
+	if !found {
+		if position, err = m.storage.GetLatestOutboxID(); err != nil {
+			log.Printf("Event subscriber %s: %v", name, err)
+		}
+		if err := m.storage.SetEventCursor(name, position); err != nil {
+			log.Printf("Event subscriber %s: %v", name, err)
+		}
+	}
+
+	m.wg.Add(1)
+	go m.deliver(sub, position)
+}
+
+// Unsubscribe останавливает доставку подписчику. Курсор сохраняется: при повторной подписке
+// с тем же именем доставка продолжится с места остановки.
+func (m *Manager) Unsubscribe(name string) {
+	m.mu.Lock()
+	sub := m.subscribers[name]
+	delete(m.subscribers, name)
+	m.mu.Unlock()
+
+	if sub == nil {
+		return
+	}
+
+	close(sub.stop)
+	<-sub.done
+}
+
+// RemoveSubscriber останавливает доставку подписчику и удаляет его курсор и неудачные доставки
+func (m *Manager) RemoveSubscriber(name string) error {
+	m.Unsubscribe(name)
+
+	if err := m.storage.DeleteFailedDeliveries(name); err != nil {
+		return err
+	}
+	return m.storage.DeleteEventCursor(name)
+}
+
+// wakeSubscribers сообщает подписчикам о новой записи в журнале
+func (m *Manager) wakeSubscribers() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, sub := range m.subscribers {
+		select {
+		case sub.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// deliver читает журнал с позиции подписчика и доставляет ему события по порядку
+func (m *Manager) deliver(sub *subscriber, position int64) {
+	defer m.wg.Done()
+	defer close(sub.done)
+
+	for {
+		latest, err := m.storage.GetLatestOutboxID()
+		if err == nil {
+			var entries []storage.OutboxEntry
+			entries, err = m.storage.GetOutbox(position, latest, sub.types, deliveryBatch)
+			if err == nil {
+				for _, entry := range entries {
+					if !m.deliverEntry(sub, entry) {
+						return
+					}
+					position = entry.ID
+					m.saveCursor(sub, position)
+				}
+
+				if len(entries) == deliveryBatch {
+					continue
+				}
+
+				// Записи других типов подписчику не нужны - сдвигаем курсор за них
+				if position < latest {
+					position = latest
+					m.saveCursor(sub, position)
+				}
+			}
+		}
+		if err != nil {
+			log.Printf("Event subscriber %s: %v", sub.name, err)
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-sub.stop:
+			return
+		case <-sub.wake:
+		case <-time.After(deliveryPoll):
+		}
+	}
+}
+
+// deliverEntry доставляет запись журнала с повторами. Исчерпав попытки, записывает
+// неудачную доставку и идет дальше. Возвращает false, если менеджер останавливается
+// или подписчик отписан:
+// тогда курсор не сдвигается, и событие будет доставлено после перезапуска.
+func (m *Manager) deliverEntry(sub *subscriber, entry storage.OutboxEntry) bool {
+	delay := minRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := m.call(sub, entry)
+		if err == nil {
+			return true
+		}
+
+		if attempt >= maxDeliveryAttempts {
+			log.Printf("Failed to deliver event %d to %s after %d attempts: %v", entry.ID, sub.name, attempt, err)
+			delivery := &storage.FailedDelivery{
+				Subscriber: sub.name,
+				OutboxID:   entry.ID,
+				Type:       entry.Type,
+				Attempts:   attempt,
+				Error:      err.Error(),
+			}
+			if err := m.storage.SaveFailedDelivery(delivery); err != nil {
+				log.Printf("Event subscriber %s: %v", sub.name, err)
+			}
+			return true
+		}
+
+		log.Printf("Failed to deliver event %d to %s (attempt %d), retrying in %s: %v", entry.ID, sub.name, attempt, delay, err)
+
+		select {
+		case <-m.ctx.Done():
+			return false
+		case <-sub.stop:
+			return false
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// call декодирует запись журнала и вызывает обработчик подписчика; паника считается ошибкой
+func (m *Manager) call(sub *subscriber, entry storage.OutboxEntry) (err error) {
+	var event Event
+	if err := json.Unmarshal([]byte(entry.Payload), &event); err != nil {
+		return fmt.Errorf("failed to decode event: %w", err)
+	}
+	event.ID = entry.EventID
+	event.Sequence = entry.ID
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("event handler panic: %v", r)
+		}
+	}()
+
+	return sub.handler(event)
+}
+
+// saveCursor сохраняет позицию подписчика
+func (m *Manager) saveCursor(sub *subscriber, position int64) {
+	if err := m.storage.SetEventCursor(sub.name, position); err != nil {
+		log.Printf("Event subscriber %s: %v", sub.name, err)
+	}
+}
+
+// RetryFailedDelivery повторно доставляет событие, не доставленное подписчику.
+// При успехе запись о неудачной доставке удаляется.
+func (m *Manager) RetryFailedDelivery(id int) error {
+	delivery, err := m.storage.GetFailedDelivery(id)
+	if err != nil {
+		return err
+	}
+	if delivery == nil {
+		return fmt.Errorf("failed delivery not found")
+	}
+
+	m.mu.RLock()
+	sub := m.subscribers[delivery.Subscriber]
+	m.mu.RUnlock()
+	if sub == nil {
+		return fmt.Errorf("subscriber %s is not registered", delivery.Subscriber)
+	}
+
+	entry, err := m.storage.GetOutboxEntry(delivery.OutboxID)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("event is no longer in the outbox")
+	}
+
+	if err := m.call(sub, *entry); err != nil {
+		delivery.Attempts++
+		delivery.Error = err.Error()
+		if saveErr := m.storage.SaveFailedDelivery(delivery); saveErr != nil {
+			log.Printf("Event subscriber %s: %v", sub.name, saveErr)
+		}
+		return err
+	}
+
+	return m.storage.DeleteFailedDelivery(delivery.ID)
+}
+
+// GetDeliveryStats возвращает позиции подписчиков, очереди и количество потерянных событий
+func (m *Manager) GetDeliveryStats() (*DeliveryStats, error) {
+	latest, err := m.storage.GetLatestOutboxID()
+	if err != nil {
+		return nil, err
+	}
+
+	cursors, err := m.storage.GetEventCursors()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &DeliveryStats{
+		LatestSequence: latest,
+		Dropped:        atomic.LoadUint64(&m.dropped),
+		Subscribers:    []SubscriberStats{},
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, cursor := range cursors {
+		sub := m.subscribers[cursor.Subscriber]
+
+		var types []string
+		if sub != nil {
+			types = sub.types
+		}
+
+		pending, err := m.storage.CountOutbox(cursor.Position, types)
+		if err != nil {
+			return nil, err
+		}
+		failed, err := m.storage.CountFailedDeliveries(cursor.Subscriber)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Subscribers = append(stats.Subscribers, SubscriberStats{
+			Name:       cursor.Subscriber,
+			Registered: sub != nil,
+			Position:   cursor.Position,
+			Pending:    pending,
+			Failed:     failed,
+			UpdatedAt:  cursor.UpdatedAt,
+		})
+	}
+
+	return stats, nil
+}
+
+// pruneOutbox удаляет из журнала записи старше срока хранения, которые уже доставлены всем
+// работающим подписчикам и не нужны для повторной отправки неудачных доставок
+func (m *Manager) pruneOutbox() {
+	maxID, err := m.storage.GetLatestOutboxID()
+	if err != nil {
+		log.Printf("Failed to prune event outbox: %v", err)
+		return
+	}
+
+	cursors, err := m.storage.GetEventCursors()
+	if err != nil {
+		log.Printf("Failed to prune event outbox: %v", err)
+		return
+	}
+
+	m.mu.RLock()
+	for _, cursor := range cursors {
+		if m.subscribers[cursor.Subscriber] != nil && cursor.Position < maxID {
+			maxID = cursor.Position
+		}
+	}
+	m.mu.RUnlock()
+
+	failedID, found, err := m.storage.GetMinFailedOutboxID()
+	if err != nil {
+		log.Printf("Failed to prune event outbox: %v", err)
+		return
+	}
+	if found && failedID-1 < maxID {
+		maxID = failedID - 1
+	}
+
+	deleted, err := m.storage.DeleteOutboxBefore(time.Now().Add(-outboxRetention), maxID)
+	if err != nil {
+		log.Printf("Failed to prune event outbox: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Pruned %d event outbox entries", deleted)
+	}
+}
@@ -2,8 +2,11 @@ package events
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"ocuai/internal/config"
@@ -17,36 +20,88 @@ type EventType string
 
 const (
 	EventTypeMotion     EventType = "motion"
+	EventTypeAudio      EventType = "audio"
 	EventTypeAI         EventType = "ai_detection"
 	EventTypeCameraLost EventType = "camera_lost"
+	EventTypeTamper     EventType = "tamper"
 	EventTypeSystemLog  EventType = "system_log"
+
+	// Аналитика по трекам объектов
+	EventTypeLineCrossing EventType = "line_crossing"
+	EventTypeLoitering    EventType = "loitering"
+
+	// Распознанный номер из белого или черного списка
+	EventTypePlateMatch EventType = "plate_match"
+
+	// Обновления треков не создают новых событий, а дополняют событие появления объекта
+	EventTypeTrackUpdated EventType = "track_updated"
+	EventTypeTrackEnded   EventType = "track_ended"
+
+	// Смена режима охраны
+	EventTypeArming EventType = storage.EventTypeArmingChanged
 )
 
+// journalTypes типы событий, которые записываются в журнал и доступны подписчикам
+var journalTypes = map[EventType]bool{
+	EventTypeMotion:       true,
+	EventTypeAudio:        true,
+	EventTypeAI:           true,
+	EventTypeCameraLost:   true,
+	EventTypeTamper:       true,
+	EventTypeSystemLog:    true,
+	EventTypeLineCrossing: true,
+	EventTypeLoitering:    true,
+	EventTypePlateMatch:   true,
+	EventTypeTrackEnded:   true,
+	EventTypeArming:       true,
+}
+
+// IsJournalType проверяет, что события этого типа записываются в журнал
+// (на них можно подписаться вебхуком или правилом)
+func IsJournalType(t EventType) bool {
+	return journalTypes[t]
+}
+
 // Event представляет событие в системе
 type Event struct {
-	Type        EventType              `json:"type"`
-	CameraID    string                 `json:"camera_id"`
-	CameraName  string                 `json:"camera_name"`
-	Description string                 `json:"description"`
-	Confidence  float32                `json:"confidence"`
-	Timestamp   time.Time              `json:"timestamp"`
-	Data        map[string]interface{} `json:"data"`
+	ID            int                    `json:"id,omitempty"`       // ID события в ленте, 0 - событие не сохраняется
+	Sequence      int64                  `json:"sequence,omitempty"` // номер записи в журнале событий
+	Type          EventType              `json:"type"`
+	CameraID      string                 `json:"camera_id"`
+	CameraName    string                 `json:"camera_name"`
+	Description   string                 `json:"description"`
+	Confidence    float32                `json:"confidence"`
+	VideoPath     string                 `json:"video_path,omitempty"`
+	ThumbnailPath string                 `json:"thumbnail_path,omitempty"`
+	Timestamp     time.Time              `json:"timestamp"`
+	Data          map[string]interface{} `json:"data"`
+	Track         *storage.EventTrack    `json:"track,omitempty"`
 }
 
-// EventHandler функция-обработчик событий
-type EventHandler func(Event)
+// Media файлы, связанные с событием
+type Media struct {
+	VideoPath     string
+	ThumbnailPath string
+}
 
-// Manager управляет событиями системы
+// Manager управляет событиями системы. События сохраняются в журнал (outbox),
+// из которого каждый подписчик читает их по своему курсору.
 type Manager struct {
-	storage   *storage.Storage
-	config    *config.Config
-	handlers  map[EventType][]EventHandler
-	eventChan chan Event
-	ctx       context.Context
-	cancel    context.CancelFunc
-	wg        sync.WaitGroup
-	mu        sync.RWMutex
-	cron      *cron.Cron
+	storage     *storage.Storage
+	config      *config.Config
+	subscribers map[string]*subscriber
+	dropped     uint64 // события, которые не удалось записать в журнал
+	rules       RuleEvaluator
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	mu          sync.RWMutex
+	cron        *cron.Cron
+}
+
+// RuleEvaluator проверяет пользовательские правила для каждого события, записанного в журнал
+type RuleEvaluator interface {
+	Evaluate(event Event)
 }
 
 // New создает новый менеджер событий
@@ -54,19 +109,14 @@ func New(storage *storage.Storage, config *config.Config) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	manager := &Manager{
-		storage:   storage,
-		config:    config,
-		handlers:  make(map[EventType][]EventHandler),
-		eventChan: make(chan Event, 100),
-		ctx:       ctx,
-		cancel:    cancel,
-		cron:      cron.New(),
+		storage:     storage,
+		config:      config,
+		subscribers: make(map[string]*subscriber),
+		ctx:         ctx,
+		cancel:      cancel,
+		cron:        cron.New(),
 	}
 
-	// Запускаем обработчик событий
-	manager.wg.Add(1)
-	go manager.processEvents()
-
 	// Настраиваем cron задачи
 	manager.setupCronJobs()
 	manager.cron.Start()
@@ -78,54 +128,162 @@ func New(storage *storage.Storage, config *config.Config) *Manager {
 func (m *Manager) Close() {
 	m.cron.Stop()
 	m.cancel()
-	close(m.eventChan)
 	m.wg.Wait()
 }
 
-// Subscribe подписывается на события определенного типа
-func (m *Manager) Subscribe(eventType EventType, handler EventHandler) {
+// SetRuleEvaluator подключает движок пользовательских правил
+func (m *Manager) SetRuleEvaluator(rules RuleEvaluator) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-
-	if m.handlers[eventType] == nil {
-		m.handlers[eventType] = make([]EventHandler, 0)
-	}
-	m.handlers[eventType] = append(m.handlers[eventType], handler)
+	m.rules = rules
 }
 
-// Emit отправляет событие
+// Emit записывает событие в журнал до возврата, будит подписчиков и проверяет правила.
+// Событие, принятое Emit, не существует только в памяти: после сбоя его доставка продолжится
+// из журнала. События после Close тоже записываются и будут доставлены при следующем запуске.
 func (m *Manager) Emit(event Event) {
 	event.Timestamp = time.Now()
-
-	select {
-	case m.eventChan <- event:
-	case <-m.ctx.Done():
-		return
-	default:
-		log.Printf("Event channel is full, dropping event: %+v", event)
-	}
+	m.handleEvent(event)
 }
 
 // EmitMotionDetected отправляет событие обнаружения движения
-func (m *Manager) EmitMotionDetected(cameraID, cameraName string) {
+func (m *Manager) EmitMotionDetected(cameraID, cameraName string, media Media, data map[string]interface{}) {
 	m.Emit(Event{
-		Type:        EventTypeMotion,
-		CameraID:    cameraID,
-		CameraName:  cameraName,
-		Description: "Motion detected",
-		Confidence:  1.0,
+		Type:          EventTypeMotion,
+		CameraID:      cameraID,
+		CameraName:    cameraName,
+		Description:   "Motion detected",
+		Confidence:    1.0,
+		VideoPath:     media.VideoPath,
+		ThumbnailPath: media.ThumbnailPath,
+		Data:          data,
+	})
+}
+
+// EmitAudioDetected отправляет событие звука с аудиодорожки камеры
+func (m *Manager) EmitAudioDetected(cameraID, cameraName, description string, confidence float32, media Media, data map[string]interface{}) {
+	m.Emit(Event{
+		Type:          EventTypeAudio,
+		CameraID:      cameraID,
+		CameraName:    cameraName,
+		Description:   description,
+		Confidence:    confidence,
+		VideoPath:     media.VideoPath,
+		ThumbnailPath: media.ThumbnailPath,
+		Data:          data,
 	})
 }
 
 // EmitAIDetection отправляет событие AI детекции
-func (m *Manager) EmitAIDetection(cameraID, cameraName, objectClass string, confidence float32, data map[string]interface{}) {
+func (m *Manager) EmitAIDetection(cameraID, cameraName, objectClass string, confidence float32, media Media, data map[string]interface{}) {
+	m.Emit(Event{
+		Type:          EventTypeAI,
+		CameraID:      cameraID,
+		CameraName:    cameraName,
+		Description:   "Detected: " + objectClass,
+		Confidence:    confidence,
+		VideoPath:     media.VideoPath,
+		ThumbnailPath: media.ThumbnailPath,
+		Data:          data,
+	})
+}
+
+// EmitTrackStarted отправляет событие AI детекции для нового отслеживаемого объекта
+func (m *Manager) EmitTrackStarted(cameraID, cameraName, objectClass string, confidence float32, media Media, track storage.EventTrack, data map[string]interface{}) {
+	m.Emit(Event{
+		Type:          EventTypeAI,
+		CameraID:      cameraID,
+		CameraName:    cameraName,
+		Description:   "Detected: " + objectClass,
+		Confidence:    confidence,
+		VideoPath:     media.VideoPath,
+		ThumbnailPath: media.ThumbnailPath,
+		Data:          data,
+		Track:         &track,
+	})
+}
+
+// EmitTrackUpdated продлевает трек объекта, который все еще в кадре
+func (m *Manager) EmitTrackUpdated(cameraID, cameraName string, confidence float32, track storage.EventTrack) {
 	m.Emit(Event{
-		Type:        EventTypeAI,
+		Type:       EventTypeTrackUpdated,
+		CameraID:   cameraID,
+		CameraName: cameraName,
+		Confidence: confidence,
+		Track:      &track,
+	})
+}
+
+// EmitTrackEnded закрывает трек объекта, покинувшего кадр
+func (m *Manager) EmitTrackEnded(cameraID, cameraName, objectClass string, confidence float32, track storage.EventTrack) {
+	m.Emit(Event{
+		Type:        EventTypeTrackEnded,
 		CameraID:    cameraID,
 		CameraName:  cameraName,
-		Description: "Detected: " + objectClass,
+		Description: fmt.Sprintf("%s left after %.0fs", objectClass, track.DwellSeconds),
 		Confidence:  confidence,
-		Data:        data,
+		Track:       &track,
+	})
+}
+
+// EmitLineCrossing отправляет событие пересечения линии объектом
+func (m *Manager) EmitLineCrossing(cameraID, cameraName, objectClass, tripwire, direction string, confidence float32, media Media, data map[string]interface{}) {
+	m.Emit(Event{
+		Type:          EventTypeLineCrossing,
+		CameraID:      cameraID,
+		CameraName:    cameraName,
+		Description:   fmt.Sprintf("%s crossed %s (%s)", objectClass, tripwire, direction),
+		Confidence:    confidence,
+		VideoPath:     media.VideoPath,
+		ThumbnailPath: media.ThumbnailPath,
+		Data:          data,
+	})
+}
+
+// EmitLoitering отправляет событие задержки объекта в области
+func (m *Manager) EmitLoitering(cameraID, cameraName, objectClass, area string, dwell time.Duration, confidence float32, media Media, data map[string]interface{}) {
+	m.Emit(Event{
+		Type:          EventTypeLoitering,
+		CameraID:      cameraID,
+		CameraName:    cameraName,
+		Description:   fmt.Sprintf("%s loitering in %s for %.0fs", objectClass, area, dwell.Seconds()),
+		Confidence:    confidence,
+		VideoPath:     media.VideoPath,
+		ThumbnailPath: media.ThumbnailPath,
+		Data:          data,
+	})
+}
+
+// EmitPlateMatch отправляет событие совпадения номера со списком
+func (m *Manager) EmitPlateMatch(cameraID, cameraName, plate, list, label string, confidence float32, media Media, data map[string]interface{}) {
+	description := fmt.Sprintf("Plate %s on %s list", plate, list)
+	if label != "" {
+		description += fmt.Sprintf(" (%s)", label)
+	}
+
+	m.Emit(Event{
+		Type:          EventTypePlateMatch,
+		CameraID:      cameraID,
+		CameraName:    cameraName,
+		Description:   description,
+		Confidence:    confidence,
+		VideoPath:     media.VideoPath,
+		ThumbnailPath: media.ThumbnailPath,
+		Data:          data,
+	})
+}
+
+// EmitTamper отправляет событие саботажа камеры (covered, defocused, moved)
+func (m *Manager) EmitTamper(cameraID, cameraName, reason string, media Media, data map[string]interface{}) {
+	m.Emit(Event{
+		Type:          EventTypeTamper,
+		CameraID:      cameraID,
+		CameraName:    cameraName,
+		Description:   fmt.Sprintf("Camera tampering detected: %s", reason),
+		Confidence:    1.0,
+		VideoPath:     media.VideoPath,
+		ThumbnailPath: media.ThumbnailPath,
+		Data:          data,
 	})
 }
 
@@ -149,80 +307,98 @@ func (m *Manager) EmitSystemLog(message string) {
 	})
 }
 
-// processEvents обрабатывает события из очереди
-func (m *Manager) processEvents() {
-	defer m.wg.Done()
+// EmitArmingChanged отправляет событие смены режима охраны
+func (m *Manager) EmitArmingChanged(mode, previous, source string) {
+	m.Emit(Event{
+		Type:        EventTypeArming,
+		Description: fmt.Sprintf("Arming mode changed to %s (%s)", mode, source),
+		Confidence:  1.0,
+		Data: map[string]interface{}{
+			"mode":     mode,
+			"previous": previous,
+			"source":   source,
+		},
+	})
+}
+
+// handleEvent сохраняет событие в ленту и журнал одной транзакцией
+func (m *Manager) handleEvent(event Event) {
+	var dbEvent *storage.Event
 
-	for {
-		select {
-		case event, ok := <-m.eventChan:
-			if !ok {
-				return
+	switch event.Type {
+	case EventTypeSystemLog:
+		// Системные сообщения не сохраняются в ленту, но доставляются подписчикам
+
+	case EventTypeTrackUpdated, EventTypeTrackEnded:
+		// Дополняем событие, созданное при появлении объекта
+		if event.Track != nil {
+			if err := m.storage.UpdateEventTrack(*event.Track, event.Confidence); err != nil {
+				log.Printf("Failed to update event track: %v", err)
 			}
-			m.handleEvent(event)
-		case <-m.ctx.Done():
+		}
+		// Обновления треков приходят каждую секунду и в журнал не попадают
+		if event.Type == EventTypeTrackUpdated {
 			return
 		}
-	}
-}
 
-// handleEvent обрабатывает отдельное событие
-func (m *Manager) handleEvent(event Event) {
-	// Сохраняем событие в базу данных (если это не системное событие)
-	if event.Type != EventTypeSystemLog {
-		dbEvent := &storage.Event{
-			CameraID:    event.CameraID,
-			CameraName:  event.CameraName,
-			Type:        string(event.Type),
-			Description: event.Description,
-			Confidence:  event.Confidence,
-			CreatedAt:   event.Timestamp,
-			Processed:   false,
+	default:
+		dbEvent = &storage.Event{
+			CameraID:      event.CameraID,
+			CameraName:    event.CameraName,
+			Type:          string(event.Type),
+			Description:   event.Description,
+			Confidence:    event.Confidence,
+			VideoPath:     event.VideoPath,
+			ThumbnailPath: event.ThumbnailPath,
+			CreatedAt:     event.Timestamp,
+			Processed:     false,
+			Data:          event.Data,
+			Track:         event.Track,
 		}
+	}
 
-		if err := m.storage.SaveEvent(dbEvent); err != nil {
-			log.Printf("Failed to save event to database: %v", err)
-		} else {
-			log.Printf("Saved event: %s - %s", event.Type, event.Description)
-		}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		atomic.AddUint64(&m.dropped, 1)
+		log.Printf("Failed to encode event, dropping it: %v", err)
+		return
 	}
 
-	// Вызываем подписанные обработчики
+	entry := &storage.OutboxEntry{Type: string(event.Type), Payload: string(payload)}
+	err = m.storage.AppendOutbox(entry, dbEvent)
+	if err != nil && dbEvent != nil {
+		// Событие не сохранилось в ленту, но подписчики все равно должны его получить
+		log.Printf("Failed to save event to database: %v", err)
+		err = m.storage.AppendOutbox(entry, nil)
+	} else if err == nil && dbEvent != nil {
+		log.Printf("Saved event: %s - %s", event.Type, event.Description)
+	}
+	if err != nil {
+		atomic.AddUint64(&m.dropped, 1)
+		log.Printf("Failed to write event to outbox, dropping it: %v", err)
+		return
+	}
+
+	m.wakeSubscribers()
+	log.Printf("Event processed: %s - %s (Camera: %s)", event.Type, event.Description, event.CameraName)
+
 	m.mu.RLock()
-	handlers := m.handlers[event.Type]
+	rules := m.rules
 	m.mu.RUnlock()
 
-	for _, handler := range handlers {
-		go func(h EventHandler) {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("Event handler panic: %v", r)
-				}
-			}()
-			h(event)
-		}(handler)
+	if rules != nil {
+		event.ID = entry.EventID
+		event.Sequence = entry.ID
+		rules.Evaluate(event)
 	}
-
-	// Логируем событие
-	log.Printf("Event processed: %s - %s (Camera: %s)", event.Type, event.Description, event.CameraName)
 }
 
 // setupCronJobs настраивает периодические задачи
 func (m *Manager) setupCronJobs() {
-	// Очистка старых событий (ежедневно в 02:00)
-	_, err := m.cron.AddFunc("0 2 * * *", func() {
-		if err := m.storage.DeleteOldEvents(m.config.Storage.RetentionDays); err != nil {
-			log.Printf("Failed to cleanup old events: %v", err)
-		} else {
-			log.Printf("Cleaned up events older than %d days", m.config.Storage.RetentionDays)
-		}
-	})
-	if err != nil {
-		log.Printf("Failed to add cleanup cron job: %v", err)
-	}
+	// Очистка старых событий и записей выполняется пакетом retention
 
 	// Проверка статуса камер (каждые 30 минут)
-	_, err = m.cron.AddFunc("*/30 * * * *", func() {
+	_, err := m.cron.AddFunc("*/30 * * * *", func() {
 		m.checkCameraStatus()
 	})
 	if err != nil {
@@ -242,6 +418,14 @@ func (m *Manager) setupCronJobs() {
 	if err != nil {
 		log.Printf("Failed to add stats cron job: %v", err)
 	}
+
+	// Очистка журнала событий (каждый день в 04:00)
+	_, err = m.cron.AddFunc("0 4 * * *", func() {
+		m.pruneOutbox()
+	})
+	if err != nil {
+		log.Printf("Failed to add outbox cleanup cron job: %v", err)
+	}
 }
 
 // checkCameraStatus проверяет статус камер
@@ -0,0 +1,468 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ocuai/internal/config"
+	"ocuai/internal/storage"
+)
+
+// queueSize максимальное количество заданий в очереди
+const queueSize = 16
+
+// ProgressFunc вызывается при каждом изменении состояния задания
+type ProgressFunc func(job storage.Export)
+
+// Manager выполняет задания на экспорт видео в фоне по одному
+type Manager struct {
+	storage    *storage.Storage
+	dir        string
+	codec      string
+	fps        float64
+	onProgress ProgressFunc
+	queue      chan *storage.Export
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// CameraManifest описание экспортированной камеры
+type CameraManifest struct {
+	CameraID   string   `json:"camera_id"`
+	File       string   `json:"file,omitempty"`
+	Recordings int      `json:"recordings"`
+	Frames     int      `json:"frames"`
+	Clips      []string `json:"clips,omitempty"` // клипы событий в архиве
+}
+
+// archiveFile файл архива: имя в архиве и путь на диске
+type archiveFile struct {
+	name string
+	path string
+}
+
+// Manifest описание содержимого архива экспорта
+type Manifest struct {
+	ExportID  string           `json:"export_id"`
+	From      time.Time        `json:"from"`
+	To        time.Time        `json:"to"`
+	CreatedAt time.Time        `json:"created_at"`
+	Cameras   []CameraManifest `json:"cameras"`
+	Events    []storage.Event  `json:"events"`
+}
+
+// New создает менеджер экспорта
+func New(store *storage.Storage, cfg *config.Config, onProgress ProgressFunc) *Manager {
+	codec := cfg.Recording.Codec
+	if len(codec) != 4 {
+		codec = "avc1"
+	}
+
+	fps := cfg.Recording.FPS
+	if fps <= 0 {
+		fps = 10
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Manager{
+		storage:    store,
+		dir:        Dir(cfg),
+		codec:      codec,
+		fps:        float64(fps),
+		onProgress: onProgress,
+		queue:      make(chan *storage.Export, queueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Dir возвращает директорию архивов экспорта
+func Dir(cfg *config.Config) string {
+	return filepath.Join(cfg.Storage.VideoPath, "exports")
+}
+
+// Start запускает обработчик очереди экспорта
+func (m *Manager) Start() {
+	// Задания, прерванные перезапуском, уже не будут выполнены
+	if err := m.storage.FailInterruptedExports(); err != nil {
+		log.Printf("Export: %v", err)
+	}
+
+	m.wg.Add(1)
+	go m.worker()
+}
+
+// Stop прерывает текущее задание и останавливает обработчик
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Submit ставит задание на экспорт в очередь
+func (m *Manager) Submit(cameraIDs []string, from, to time.Time) (*storage.Export, error) {
+	job := &storage.Export{
+		ID:        fmt.Sprintf("exp_%d", time.Now().UnixNano()),
+		CameraIDs: cameraIDs,
+		From:      from,
+		To:        to,
+		Status:    storage.ExportStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	if err := m.storage.CreateExport(job); err != nil {
+		return nil, err
+	}
+
+	// Задание дальше меняется обработчиком, вызывающему отдаем копию
+	submitted := *job
+
+	select {
+	case m.queue <- job:
+	default:
+		job.Status = storage.ExportStatusFailed
+		job.Error = "export queue is full"
+		job.FinishedAt = time.Now()
+		m.update(job)
+		return nil, fmt.Errorf("export queue is full")
+	}
+
+	m.notify(&submitted)
+	return &submitted, nil
+}
+
+// Delete удаляет завершенное задание на экспорт вместе с архивом
+func (m *Manager) Delete(job *storage.Export) error {
+	if job.Status == storage.ExportStatusPending || job.Status == storage.ExportStatusRunning {
+		return fmt.Errorf("export %s is still in progress", job.ID)
+	}
+
+	if job.FilePath != "" {
+		if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove export archive: %w", err)
+		}
+	}
+
+	return m.storage.DeleteExport(job.ID)
+}
+
+// worker последовательно выполняет задания из очереди
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case job := <-m.queue:
+			m.run(job)
+		}
+	}
+}
+
+// run выполняет одно задание и сохраняет результат
+func (m *Manager) run(job *storage.Export) {
+	job.Status = storage.ExportStatusRunning
+	m.update(job)
+
+	log.Printf("Export %s started: cameras %v, %s - %s", job.ID, job.CameraIDs,
+		job.From.Format(time.RFC3339), job.To.Format(time.RFC3339))
+
+	path, sum, err := m.build(job)
+	job.FinishedAt = time.Now()
+	if err != nil {
+		log.Printf("Export %s failed: %v", job.ID, err)
+		job.Status = storage.ExportStatusFailed
+		job.Error = err.Error()
+	} else {
+		log.Printf("Export %s completed: %s", job.ID, path)
+		job.Status = storage.ExportStatusCompleted
+		job.Progress = 1
+		job.FilePath = path
+		job.SHA256 = sum
+	}
+
+	m.update(job)
+}
+
+// build обрезает записи, собирает манифест и упаковывает все в zip
+func (m *Manager) build(job *storage.Export) (string, string, error) {
+	workDir := filepath.Join(m.dir, job.ID)
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	// Собираем сегменты и события всех камер заранее, чтобы считать прогресс
+	recordings := make(map[string][]storage.Recording)
+	cameraEvents := make(map[string][]storage.Event)
+	totalSteps := 1 // упаковка архива
+	for _, cameraID := range job.CameraIDs {
+		list, err := m.storage.GetRecordings(cameraID, job.From, job.To)
+		if err != nil {
+			return "", "", err
+		}
+
+		complete := list[:0]
+		for _, recording := range list {
+			if recording.Status == storage.RecordingStatusComplete {
+				complete = append(complete, recording)
+			}
+		}
+		recordings[cameraID] = complete
+		totalSteps += len(complete)
+
+		events, err := m.storage.GetEventsInRange(cameraID, job.From, job.To)
+		if err != nil {
+			return "", "", err
+		}
+		cameraEvents[cameraID] = events
+	}
+
+	doneSteps := 0
+	lastReported := 0.0
+	step := func() {
+		doneSteps++
+		job.Progress = float64(doneSteps) / float64(totalSteps)
+		// Не засыпаем клиентов уведомлениями о каждом сегменте
+		if job.Progress-lastReported >= 0.01 {
+			lastReported = job.Progress
+			m.update(job)
+		}
+	}
+
+	manifest := Manifest{
+		ExportID:  job.ID,
+		From:      job.From,
+		To:        job.To,
+		CreatedAt: job.CreatedAt,
+		Events:    []storage.Event{},
+	}
+
+	var files []archiveFile
+	for _, cameraID := range job.CameraIDs {
+		camera := CameraManifest{
+			CameraID:   cameraID,
+			Recordings: len(recordings[cameraID]),
+		}
+
+		if len(recordings[cameraID]) > 0 {
+			name := cameraID + ".mp4"
+			path := filepath.Join(workDir, name)
+			frames, err := m.trimCamera(m.ctx, recordings[cameraID], job.From, job.To, path, step)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to export camera %s: %w", cameraID, err)
+			}
+			if frames > 0 {
+				camera.File = name
+				camera.Frames = frames
+				files = append(files, archiveFile{name: name, path: path})
+			}
+		}
+
+		// Клипы событий попадают в архив и без непрерывной записи камеры
+		clips := eventClips(cameraID, cameraEvents[cameraID])
+		for _, clip := range clips {
+			camera.Clips = append(camera.Clips, clip.name)
+		}
+		files = append(files, clips...)
+
+		manifest.Cameras = append(manifest.Cameras, camera)
+		manifest.Events = append(manifest.Events, cameraEvents[cameraID]...)
+	}
+
+	sort.Slice(manifest.Events, func(i, j int) bool {
+		return manifest.Events[i].CreatedAt.Before(manifest.Events[j].CreatedAt)
+	})
+
+	manifestPath := filepath.Join(workDir, "manifest.json")
+	if err := writeManifest(manifestPath, manifest); err != nil {
+		return "", "", err
+	}
+	eventsPath := filepath.Join(workDir, "events.csv")
+	if err := writeEventsCSV(eventsPath, manifest.Events); err != nil {
+		return "", "", err
+	}
+	files = append(files, archiveFile{name: "manifest.json", path: manifestPath}, archiveFile{name: "events.csv", path: eventsPath})
+
+	archivePath := filepath.Join(m.dir, job.ID+".zip")
+	if err := writeArchive(archivePath, files); err != nil {
+		os.Remove(archivePath)
+		return "", "", err
+	}
+
+	sum, err := fileSHA256(archivePath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return archivePath, sum, nil
+}
+
+// update сохраняет состояние задания и уведомляет подписчиков
+func (m *Manager) update(job *storage.Export) {
+	if err := m.storage.UpdateExport(job); err != nil {
+		log.Printf("Export: %v", err)
+	}
+	m.notify(job)
+}
+
+// notify сообщает о новом состоянии задания
+func (m *Manager) notify(job *storage.Export) {
+	if m.onProgress != nil {
+		m.onProgress(*job)
+	}
+}
+
+// writeManifest сохраняет манифест экспорта в JSON
+func writeManifest(path string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// writeEventsCSV сохраняет события экспорта в CSV
+func writeEventsCSV(path string, events []storage.Event) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create events csv: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"id", "created_at", "camera_id", "camera_name", "type", "description", "confidence", "starred"})
+	for _, event := range events {
+		writer.Write([]string{
+			strconv.Itoa(event.ID),
+			event.CreatedAt.UTC().Format(time.RFC3339),
+			event.CameraID,
+			event.CameraName,
+			event.Type,
+			event.Description,
+			strconv.FormatFloat(float64(event.Confidence), 'f', 3, 32),
+			strconv.FormatBool(event.Starred),
+		})
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write events csv: %w", err)
+	}
+
+	return nil
+}
+
+// eventClips возвращает клипы событий камеры, сохранившиеся на диске, по одному на файл.
+// В архиве клип лежит в clips/<камера>/ с датой в имени: имена клипов повторяются по дням.
+func eventClips(cameraID string, events []storage.Event) []archiveFile {
+	var clips []archiveFile
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if event.VideoPath == "" || seen[event.VideoPath] {
+			continue
+		}
+		seen[event.VideoPath] = true
+
+		if _, err := os.Stat(event.VideoPath); err != nil {
+			continue
+		}
+
+		day := filepath.Base(filepath.Dir(event.VideoPath))
+		name := "clips/" + cameraID + "/" + day + "_" + filepath.Base(event.VideoPath)
+		clips = append(clips, archiveFile{name: name, path: event.VideoPath})
+	}
+	return clips
+}
+
+// writeArchive упаковывает файлы в zip и добавляет SHA256SUMS с контрольными суммами
+func writeArchive(path string, files []archiveFile) error {
+	archive, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer archive.Close()
+
+	zipWriter := zip.NewWriter(archive)
+
+	var sums strings.Builder
+	for _, file := range files {
+		name := file.name
+		method := zip.Deflate
+		if strings.HasSuffix(name, ".mp4") {
+			// Видео уже сжато
+			method = zip.Store
+		}
+
+		entry, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   method,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add %s to archive: %w", name, err)
+		}
+
+		sum, err := copyWithSHA256(entry, file.path)
+		if err != nil {
+			return err
+		}
+		sums.WriteString(fmt.Sprintf("%s  %s\n", sum, name))
+	}
+
+	entry, err := zipWriter.Create("SHA256SUMS")
+	if err != nil {
+		return fmt.Errorf("failed to add checksums to archive: %w", err)
+	}
+	if _, err := io.WriteString(entry, sums.String()); err != nil {
+		return fmt.Errorf("failed to write checksums: %w", err)
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
+	return nil
+}
+
+// copyWithSHA256 копирует файл в writer и возвращает его контрольную сумму
+func copyWithSHA256(dst io.Writer, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, hash), file); err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fileSHA256 возвращает контрольную сумму файла
+func fileSHA256(path string) (string, error) {
+	return copyWithSHA256(io.Discard, path)
+}
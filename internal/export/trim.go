@@ -0,0 +1,105 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"time"
+
+	"ocuai/internal/storage"
+
+	"gocv.io/x/gocv"
+)
+
+// trimCamera склеивает сегменты камеры в один файл, оставляя только кадры из интервала [from, to].
+// Возвращает количество записанных кадров.
+func (m *Manager) trimCamera(ctx context.Context, recordings []storage.Recording, from, to time.Time, outPath string, step func()) (int, error) {
+	var writer *gocv.VideoWriter
+	var size image.Point
+	defer func() {
+		if writer != nil {
+			writer.Close()
+		}
+	}()
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+	resized := gocv.NewMat()
+	defer resized.Close()
+
+	written := 0
+	for _, recording := range recordings {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		capture, err := gocv.VideoCaptureFile(recording.Path)
+		if err != nil || !capture.IsOpened() {
+			log.Printf("Export: failed to open recording %s: %v", recording.Path, err)
+			if capture != nil {
+				capture.Close()
+			}
+			step()
+			continue
+		}
+
+		fps := capture.Get(gocv.VideoCaptureFPS)
+		if fps <= 0 {
+			fps = m.fps
+		}
+
+		// Перематываем к началу интервала внутри сегмента
+		if offset := from.Sub(recording.StartTime); offset > 0 {
+			capture.Set(gocv.VideoCapturePosMsec, float64(offset.Milliseconds()))
+		}
+
+		for {
+			if ctx.Err() != nil {
+				break
+			}
+			if !capture.Read(&frame) || frame.Empty() {
+				break
+			}
+
+			at := recording.StartTime.Add(time.Duration(capture.Get(gocv.VideoCapturePosMsec)) * time.Millisecond)
+			if at.Before(from) {
+				continue
+			}
+			if at.After(to) {
+				break
+			}
+
+			if writer == nil {
+				size = image.Pt(frame.Cols(), frame.Rows())
+				writer, err = gocv.VideoWriterFile(outPath, m.codec, fps, size.X, size.Y, true)
+				if err != nil {
+					capture.Close()
+					return written, fmt.Errorf("failed to create %s: %w", outPath, err)
+				}
+				if !writer.IsOpened() {
+					capture.Close()
+					return written, fmt.Errorf("failed to open video writer for %s", outPath)
+				}
+			}
+
+			// Разрешение камеры могло поменяться между сегментами
+			out := frame
+			if frame.Cols() != size.X || frame.Rows() != size.Y {
+				gocv.Resize(frame, &resized, size, 0, 0, gocv.InterpolationLinear)
+				out = resized
+			}
+
+			if err := writer.Write(out); err != nil {
+				capture.Close()
+				return written, fmt.Errorf("failed to write frame: %w", err)
+			}
+			written++
+		}
+
+		capture.Close()
+		step()
+	}
+
+	return written, ctx.Err()
+}
@@ -0,0 +1,77 @@
+package mqtt
+
+import (
+	"regexp"
+
+	"ocuai/internal/storage"
+)
+
+// unsafeID символы, недопустимые в идентификаторах Home Assistant
+var unsafeID = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// discoveryConfigs возвращает конфигурации автообнаружения Home Assistant для камеры по топикам:
+// датчики движения, людей, транспорта и связи, камеру со снапшотами и переключатели детекции
+func (p *Publisher) discoveryConfigs(camera storage.Camera) map[string]map[string]interface{} {
+	objectID := "ocuai_" + unsafeID.ReplaceAllString(camera.ID, "_")
+
+	name := camera.Name
+	if name == "" {
+		name = camera.ID
+	}
+
+	device := map[string]interface{}{
+		"identifiers":  []string{objectID},
+		"name":         name,
+		"manufacturer": "Ocuai",
+		"model":        "Camera",
+	}
+
+	entity := func(suffix, entityName string, fields map[string]interface{}) map[string]interface{} {
+		fields["name"] = entityName
+		fields["unique_id"] = objectID + suffix
+		fields["device"] = device
+		fields["availability_topic"] = p.availabilityTopic()
+		return fields
+	}
+
+	binarySensor := func(sensor, entityName, deviceClass string) map[string]interface{} {
+		fields := map[string]interface{}{
+			"state_topic": p.topic(camera.ID, sensor),
+			"payload_on":  payloadOn,
+			"payload_off": payloadOff,
+		}
+		if deviceClass != "" {
+			fields["device_class"] = deviceClass
+		}
+		return entity("_"+sensor, entityName, fields)
+	}
+
+	switchEntity := func(setting, entityName string) map[string]interface{} {
+		return entity("_"+setting, entityName, map[string]interface{}{
+			"state_topic":   p.topic(camera.ID, setting),
+			"command_topic": p.topic(camera.ID, setting, "set"),
+			"payload_on":    payloadOn,
+			"payload_off":   payloadOff,
+		})
+	}
+
+	prefix := p.config.DiscoveryPrefix
+
+	return map[string]map[string]interface{}{
+		prefix + "/binary_sensor/" + objectID + "/motion/config": binarySensor(sensorMotion, "Motion", "motion"),
+		prefix + "/binary_sensor/" + objectID + "/person/config": binarySensor(sensorPerson, "Person", "occupancy"),
+		prefix + "/binary_sensor/" + objectID + "/car/config":    binarySensor(sensorCar, "Car", ""),
+		prefix + "/binary_sensor/" + objectID + "/status/config": entity("_status", "Connection", map[string]interface{}{
+			"state_topic":     p.topic(camera.ID, "status"),
+			"payload_on":      "online",
+			"payload_off":     "offline",
+			"device_class":    "connectivity",
+			"entity_category": "diagnostic",
+		}),
+		prefix + "/camera/" + objectID + "/config": entity("_camera", name, map[string]interface{}{
+			"topic": p.topic(camera.ID, "snapshot"),
+		}),
+		prefix + "/switch/" + objectID + "/motion_detection/config": switchEntity(settingMotion, "Motion detection"),
+		prefix + "/switch/" + objectID + "/ai_detection/config":     switchEntity(settingAI, "AI detection"),
+	}
+}
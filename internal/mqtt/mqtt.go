@@ -0,0 +1,529 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ocuai/internal/config"
+	"ocuai/internal/events"
+	"ocuai/internal/storage"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	publishTimeout = 5 * time.Second
+	statusInterval = 30 * time.Second // проверка статусов и списка камер
+	connectTimeout = 5 * time.Second
+)
+
+// Значения состояний датчиков и переключателей
+const (
+	payloadOn  = "ON"
+	payloadOff = "OFF"
+)
+
+// Датчики камеры, которые включаются событиями и сбрасываются по таймеру
+const (
+	sensorMotion = "motion"
+	sensorPerson = "person"
+	sensorCar    = "car"
+)
+
+// Настройки камеры, переключаемые командами
+const (
+	settingMotion = "motion_detection"
+	settingAI     = "ai_detection"
+)
+
+// CameraController работающие камеры, статусы и снапшоты которых публикуются в брокер
+type CameraController interface {
+	GetCameraStatus(id string) string
+	GetSnapshot(cameraID string) ([]byte, error)
+	SetCameraDetection(cameraID, setting string, enabled bool) error
+}
+
+// Publisher публикует статусы камер, события и снапшоты в MQTT брокер, объявляет камеры
+// в Home Assistant и принимает команды включения детекции движения и AI.
+type Publisher struct {
+	config       config.MQTTConfig
+	storage      *storage.Storage
+	eventManager *events.Manager
+	cameras      CameraController
+	client       paho.Client
+	timers       map[string]*time.Timer     // таймеры сброса датчиков по camera/sensor
+	tracks       map[string]map[string]bool // активные треки по топику датчика: пока они есть, датчик включен
+	status       map[string]string          // последний опубликованный статус камеры
+	announced    map[string]bool            // камеры, для которых опубликовано автообнаружение
+	stop         chan struct{}
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+}
+
+// New создает публикатор MQTT
+func New(cfg config.MQTTConfig, store *storage.Storage, eventManager *events.Manager, cameras CameraController) *Publisher {
+	return &Publisher{
+		config:       cfg,
+		storage:      store,
+		eventManager: eventManager,
+		cameras:      cameras,
+		timers:       make(map[string]*time.Timer),
+		tracks:       make(map[string]map[string]bool),
+		status:       make(map[string]string),
+		announced:    make(map[string]bool),
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start подключается к брокеру и подписывается на события. Недоступный брокер не мешает
+// запуску: клиент переподключается в фоне.
+func (p *Publisher) Start() error {
+	if p.config.Broker == "" {
+		return fmt.Errorf("mqtt broker is not configured")
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(p.config.Broker).
+		SetClientID(p.config.ClientID).
+		SetUsername(p.config.Username).
+		SetPassword(p.config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetWill(p.availabilityTopic(), "offline", 1, true).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("MQTT connection lost: %v", err)
+		})
+
+	p.client = paho.NewClient(opts)
+	token := p.client.Connect()
+	if token.WaitTimeout(connectTimeout) && token.Error() != nil {
+		return fmt.Errorf("failed to connect to mqtt broker: %w", token.Error())
+	}
+
+	p.eventManager.Subscribe("mqtt", p.handleEvent,
+		events.EventTypeMotion, events.EventTypeAI, events.EventTypeTrackEnded, events.EventTypeCameraLost)
+
+	p.wg.Add(1)
+	go p.run()
+
+	log.Printf("MQTT publisher started: %s", p.config.Broker)
+	return nil
+}
+
+// Stop публикует статус offline и отключается от брокера
+func (p *Publisher) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+	p.eventManager.Unsubscribe("mqtt")
+
+	p.mu.Lock()
+	for key, timer := range p.timers {
+		timer.Stop()
+		delete(p.timers, key)
+	}
+	p.tracks = make(map[string]map[string]bool)
+	p.mu.Unlock()
+
+	if p.client.IsConnectionOpen() {
+		p.publish(p.availabilityTopic(), true, "offline")
+	}
+	p.client.Disconnect(250)
+}
+
+// run периодически обновляет статусы камер и публикует снапшоты
+func (p *Publisher) run() {
+	defer p.wg.Done()
+
+	statusTicker := time.NewTicker(statusInterval)
+	defer statusTicker.Stop()
+
+	var snapshots <-chan time.Time
+	if p.config.SnapshotSeconds > 0 {
+		snapshotTicker := time.NewTicker(time.Duration(p.config.SnapshotSeconds) * time.Second)
+		defer snapshotTicker.Stop()
+		snapshots = snapshotTicker.C
+	}
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-statusTicker.C:
+			if p.client.IsConnectionOpen() {
+				p.refreshCameras()
+			}
+		case <-snapshots:
+			if p.client.IsConnectionOpen() {
+				p.publishSnapshots()
+			}
+		}
+	}
+}
+
+// onConnect объявляет доступность, камеры и подписывается на команды при каждом подключении
+func (p *Publisher) onConnect(client paho.Client) {
+	log.Printf("MQTT connected to %s", p.config.Broker)
+
+	p.publish(p.availabilityTopic(), true, "online")
+
+	commands := p.config.TopicPrefix + "/+/+/set"
+	if token := client.Subscribe(commands, 1, p.handleCommand); token.WaitTimeout(publishTimeout) && token.Error() != nil {
+		log.Printf("Failed to subscribe to %s: %v", commands, token.Error())
+	}
+
+	// После переподключения брокер мог потерять retained сообщения - публикуем все заново
+	p.mu.Lock()
+	p.announced = make(map[string]bool)
+	p.status = make(map[string]string)
+	p.mu.Unlock()
+
+	go p.refreshCameras()
+}
+
+// refreshCameras объявляет новые камеры, убирает удаленные и публикует изменившиеся статусы
+func (p *Publisher) refreshCameras() {
+	cameras, err := p.storage.GetCameras()
+	if err != nil {
+		log.Printf("MQTT: failed to get cameras: %v", err)
+		return
+	}
+
+	current := make(map[string]bool, len(cameras))
+	for _, camera := range cameras {
+		current[camera.ID] = true
+
+		p.mu.Lock()
+		announced := p.announced[camera.ID]
+		p.announced[camera.ID] = true
+		p.mu.Unlock()
+
+		if !announced {
+			p.announce(camera)
+		}
+
+		status := p.cameras.GetCameraStatus(camera.ID)
+		if status != "online" {
+			status = "offline"
+		}
+		p.publishStatus(camera.ID, status)
+	}
+
+	p.mu.Lock()
+	var removed []string
+	for id := range p.announced {
+		if !current[id] {
+			removed = append(removed, id)
+			delete(p.announced, id)
+			delete(p.status, id)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, id := range removed {
+		p.unannounce(id)
+	}
+}
+
+// announce публикует автообнаружение и текущее состояние камеры
+func (p *Publisher) announce(camera storage.Camera) {
+	if p.config.Discovery {
+		for topic, payload := range p.discoveryConfigs(camera) {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				log.Printf("MQTT: failed to encode discovery config: %v", err)
+				continue
+			}
+			p.publish(topic, true, data)
+		}
+	}
+
+	p.publish(p.topic(camera.ID, settingMotion), true, onOff(camera.MotionDetection))
+	p.publish(p.topic(camera.ID, settingAI), true, onOff(camera.AIDetection))
+	// Датчики с объектами в кадре остаются включенными и после переподключения
+	for _, sensor := range []string{sensorMotion, sensorPerson, sensorCar} {
+		topic := p.topic(camera.ID, sensor)
+		p.mu.Lock()
+		tracked := len(p.tracks[topic]) > 0
+		p.mu.Unlock()
+		p.publish(topic, true, onOff(tracked))
+	}
+}
+
+// unannounce убирает удаленную камеру из Home Assistant
+func (p *Publisher) unannounce(cameraID string) {
+	if !p.config.Discovery {
+		return
+	}
+
+	for topic := range p.discoveryConfigs(storage.Camera{ID: cameraID}) {
+		p.publish(topic, true, "")
+	}
+}
+
+// publishStatus публикует статус камеры, если он изменился
+func (p *Publisher) publishStatus(cameraID, status string) {
+	p.mu.Lock()
+	changed := p.status[cameraID] != status
+	p.status[cameraID] = status
+	p.mu.Unlock()
+
+	if changed {
+		p.publish(p.topic(cameraID, "status"), true, status)
+	}
+}
+
+// publishSnapshots публикует текущие кадры работающих камер
+func (p *Publisher) publishSnapshots() {
+	cameras, err := p.storage.GetCameras()
+	if err != nil {
+		log.Printf("MQTT: failed to get cameras: %v", err)
+		return
+	}
+
+	for _, camera := range cameras {
+		snapshot, err := p.cameras.GetSnapshot(camera.ID)
+		if err != nil {
+			continue
+		}
+		p.publish(p.topic(camera.ID, "snapshot"), true, snapshot)
+	}
+}
+
+// handleEvent публикует событие, включает датчики и снапшот события
+func (p *Publisher) handleEvent(event events.Event) error {
+	// Уход объекта освобождает датчики даже по старому событию, иначе они остались бы включенными
+	if event.Type == events.EventTypeTrackEnded {
+		if event.Track != nil {
+			p.release(event.CameraID, event.Track.ID)
+		}
+		return nil
+	}
+
+	// После простоя брокера или перезапуска журнал отдает старые события: датчик по ним
+	// уже был бы сброшен в OFF, поэтому они не публикуются и не повторяются
+	maxAge := time.Duration(p.config.MotionOffSeconds) * time.Second
+	if maxAge > 0 && time.Since(event.Timestamp) > maxAge {
+		return nil
+	}
+
+	if !p.client.IsConnectionOpen() {
+		return fmt.Errorf("mqtt broker is not connected")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if err := p.publish(p.topic(event.CameraID, "events"), false, data); err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case events.EventTypeMotion:
+		p.trigger(event.CameraID, sensorMotion)
+	case events.EventTypeAI:
+		class, _ := event.Data["class"].(string)
+		trackID := eventTrackID(event)
+		if contains(p.config.PersonClasses, class) {
+			p.triggerTrack(event.CameraID, sensorPerson, trackID)
+		}
+		if contains(p.config.CarClasses, class) {
+			p.triggerTrack(event.CameraID, sensorCar, trackID)
+		}
+	case events.EventTypeCameraLost:
+		p.publishStatus(event.CameraID, "offline")
+		// Треки потерянной камеры уже не закончатся событием
+		p.release(event.CameraID, "")
+	}
+
+	if event.ThumbnailPath != "" {
+		if snapshot, err := os.ReadFile(event.ThumbnailPath); err == nil {
+			p.publish(p.topic(event.CameraID, "snapshot"), true, snapshot)
+		}
+	}
+
+	return nil
+}
+
+// trigger включает датчик камеры и сбрасывает его в OFF, если новых событий не было
+func (p *Publisher) trigger(cameraID, sensor string) {
+	p.triggerTrack(cameraID, sensor, "")
+}
+
+// triggerTrack включает датчик камеры для объекта трека. Пока у датчика есть активные треки,
+// он остается включенным; таймер сброса в OFF запускается, когда уходит последний объект.
+func (p *Publisher) triggerTrack(cameraID, sensor, trackID string) {
+	topic := p.topic(cameraID, sensor)
+	p.publish(topic, true, payloadOn)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if trackID != "" {
+		if p.tracks[topic] == nil {
+			p.tracks[topic] = make(map[string]bool)
+		}
+		p.tracks[topic][trackID] = true
+	}
+
+	if len(p.tracks[topic]) > 0 {
+		if timer, exists := p.timers[topic]; exists {
+			timer.Stop()
+			delete(p.timers, topic)
+		}
+		return
+	}
+
+	p.scheduleOff(topic)
+}
+
+// release отмечает уход объекта трека (пустой trackID - всех объектов камеры).
+// Датчик без активных треков сбрасывается в OFF по таймеру.
+func (p *Publisher) release(cameraID, trackID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, sensor := range []string{sensorPerson, sensorCar} {
+		topic := p.topic(cameraID, sensor)
+		tracks := p.tracks[topic]
+		if len(tracks) == 0 || (trackID != "" && !tracks[trackID]) {
+			continue
+		}
+
+		if trackID == "" {
+			tracks = nil
+		} else {
+			delete(tracks, trackID)
+		}
+		if len(tracks) == 0 {
+			delete(p.tracks, topic)
+			p.scheduleOff(topic)
+		}
+	}
+}
+
+// scheduleOff запускает или продлевает таймер сброса датчика в OFF; вызывается под p.mu
+func (p *Publisher) scheduleOff(topic string) {
+	delay := time.Duration(p.config.MotionOffSeconds) * time.Second
+
+	if timer, exists := p.timers[topic]; exists {
+		timer.Reset(delay)
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		// Таймер мог быть остановлен новым треком, пока ждал блокировку
+		p.mu.Lock()
+		current := p.timers[topic] == timer && len(p.tracks[topic]) == 0
+		if current {
+			delete(p.timers, topic)
+		}
+		p.mu.Unlock()
+
+		if current {
+			p.publish(topic, true, payloadOff)
+		}
+	})
+	p.timers[topic] = timer
+}
+
+// eventTrackID возвращает трек объекта AI события
+func eventTrackID(event events.Event) string {
+	if event.Track != nil {
+		return event.Track.ID
+	}
+	trackID, _ := event.Data["track_id"].(string)
+	return trackID
+}
+
+// handleCommand переключает детекцию движения или AI камеры по команде <prefix>/<camera>/<setting>/set
+func (p *Publisher) handleCommand(_ paho.Client, msg paho.Message) {
+	parts := strings.Split(strings.TrimPrefix(msg.Topic(), p.config.TopicPrefix+"/"), "/")
+	if len(parts) != 3 {
+		return
+	}
+	cameraID, setting := parts[0], parts[1]
+
+	enabled, ok := parseSwitch(string(msg.Payload()))
+	if !ok {
+		log.Printf("MQTT: invalid payload %q for %s", msg.Payload(), msg.Topic())
+		return
+	}
+
+	if err := p.applySetting(cameraID, setting, enabled); err != nil {
+		log.Printf("MQTT: failed to apply %s: %v", msg.Topic(), err)
+	}
+}
+
+// applySetting сохраняет и применяет настройку камеры и публикует новое состояние
+func (p *Publisher) applySetting(cameraID, setting string, enabled bool) error {
+	if setting != settingMotion && setting != settingAI {
+		return fmt.Errorf("unknown setting %s", setting)
+	}
+
+	if err := p.cameras.SetCameraDetection(cameraID, setting, enabled); err != nil {
+		return err
+	}
+
+	log.Printf("MQTT: camera %s %s set to %v", cameraID, setting, enabled)
+	return p.publish(p.topic(cameraID, setting), true, onOff(enabled))
+}
+
+// publish отправляет сообщение и ждет подтверждения брокера
+func (p *Publisher) publish(topic string, retained bool, payload interface{}) error {
+	token := p.client.Publish(topic, 1, retained, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		return fmt.Errorf("timeout publishing to %s", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// topic возвращает топик камеры
+func (p *Publisher) topic(cameraID string, parts ...string) string {
+	return strings.Join(append([]string{p.config.TopicPrefix, cameraID}, parts...), "/")
+}
+
+// availabilityTopic возвращает топик доступности Ocuai
+func (p *Publisher) availabilityTopic() string {
+	return p.config.TopicPrefix + "/status"
+}
+
+// parseSwitch разбирает команду переключателя
+func parseSwitch(payload string) (bool, bool) {
+	switch strings.ToUpper(strings.TrimSpace(payload)) {
+	case payloadOn, "TRUE", "1":
+		return true, true
+	case payloadOff, "FALSE", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// onOff возвращает состояние переключателя
+func onOff(value bool) string {
+	if value {
+		return payloadOn
+	}
+	return payloadOff
+}
+
+// contains проверяет, есть ли значение в списке
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
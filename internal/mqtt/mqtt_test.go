@@ -0,0 +1,486 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ocuai/internal/config"
+	"ocuai/internal/events"
+	"ocuai/internal/storage"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// brokerConn подключение клиента к тестовому брокеру
+type brokerConn struct {
+	conn    net.Conn
+	filters []string
+	mu      sync.Mutex // запись в соединение
+}
+
+func (c *brokerConn) write(packet packets.ControlPacket) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	packet.Write(c.conn)
+}
+
+// broker минимальный MQTT брокер в процессе теста: подписки с масками, retained сообщения и QoS 1
+type broker struct {
+	listener net.Listener
+	conns    map[*brokerConn]bool
+	retained map[string]string
+	mu       sync.Mutex
+}
+
+func newBroker(t *testing.T) *broker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	b := &broker{listener: listener, conns: make(map[*brokerConn]bool), retained: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&brokerConn{conn: conn})
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+		b.mu.Lock()
+		for c := range b.conns {
+			c.conn.Close()
+		}
+		b.mu.Unlock()
+	})
+	return b
+}
+
+// url возвращает адрес брокера для клиента
+func (b *broker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// retainedMessage возвращает retained сообщение топика
+func (b *broker) retainedMessage(topic string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	payload, ok := b.retained[topic]
+	return payload, ok
+}
+
+// retainedTopics возвращает топики retained сообщений с префиксом
+func (b *broker) retainedTopics(prefix string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var topics []string
+	for topic := range b.retained {
+		if strings.HasPrefix(topic, prefix) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+func (b *broker) serve(c *brokerConn) {
+	b.mu.Lock()
+	b.conns[c] = true
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, c)
+		b.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	for {
+		cp, err := packets.ReadPacket(c.conn)
+		if err != nil {
+			return
+		}
+
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			c.write(packets.NewControlPacket(packets.Connack))
+		case *packets.SubscribePacket:
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = make([]byte, len(p.Topics))
+
+			b.mu.Lock()
+			c.filters = append(c.filters, p.Topics...)
+			retained := make(map[string]string)
+			for topic, payload := range b.retained {
+				if matchAny(p.Topics, topic) {
+					retained[topic] = payload
+				}
+			}
+			b.mu.Unlock()
+
+			c.write(ack)
+			for topic, payload := range retained {
+				c.write(publishPacket(topic, payload, true))
+			}
+		case *packets.UnsubscribePacket:
+			ack := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			ack.MessageID = p.MessageID
+			c.write(ack)
+		case *packets.PublishPacket:
+			if p.Qos == 1 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				c.write(ack)
+			}
+			b.publish(p.TopicName, string(p.Payload), p.Retain)
+		case *packets.PingreqPacket:
+			c.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+// publish сохраняет retained сообщение и рассылает его подписчикам
+func (b *broker) publish(topic, payload string, retain bool) {
+	b.mu.Lock()
+	if retain {
+		if payload == "" {
+			delete(b.retained, topic)
+		} else {
+			b.retained[topic] = payload
+		}
+	}
+	var targets []*brokerConn
+	for c := range b.conns {
+		if matchAny(c.filters, topic) {
+			targets = append(targets, c)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, c := range targets {
+		c.write(publishPacket(topic, payload, false))
+	}
+}
+
+func publishPacket(topic, payload string, retain bool) *packets.PublishPacket {
+	p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	p.TopicName = topic
+	p.Payload = []byte(payload)
+	p.Retain = retain
+	return p
+}
+
+// matchAny проверяет, подходит ли топик под одну из масок подписки
+func matchAny(filters []string, topic string) bool {
+	for _, filter := range filters {
+		f := strings.Split(filter, "/")
+		t := strings.Split(topic, "/")
+
+		matched := len(f) == len(t)
+		for i, part := range f {
+			if part == "#" {
+				matched = true
+				break
+			}
+			if i >= len(t) || (part != "+" && part != t[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// detectionCall вызов SetCameraDetection
+type detectionCall struct {
+	cameraID string
+	setting  string
+	enabled  bool
+}
+
+// fakeCameras камеры, записывающие команды переключения детекции
+type fakeCameras struct {
+	calls []detectionCall
+	mu    sync.Mutex
+}
+
+func (f *fakeCameras) GetCameraStatus(id string) string { return "online" }
+
+func (f *fakeCameras) GetSnapshot(cameraID string) ([]byte, error) { return []byte("jpeg"), nil }
+
+func (f *fakeCameras) SetCameraDetection(cameraID, setting string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cameraID == "broken" {
+		return fmt.Errorf("camera %s is not running", cameraID)
+	}
+	f.calls = append(f.calls, detectionCall{cameraID, setting, enabled})
+	return nil
+}
+
+func (f *fakeCameras) received() []detectionCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]detectionCall(nil), f.calls...)
+}
+
+// newPublisher запускает публикатор с камерами из хранилища против тестового брокера
+func newPublisher(t *testing.T, b *broker, cameras ...storage.Camera) (*Publisher, *storage.Storage, *fakeCameras) {
+	store, err := storage.New(filepath.Join(t.TempDir(), "ocuai.db"))
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	for i := range cameras {
+		if err := store.SaveCamera(&cameras[i]); err != nil {
+			t.Fatalf("SaveCamera: %v", err)
+		}
+	}
+
+	eventManager := events.New(store, &config.Config{})
+	t.Cleanup(eventManager.Close)
+
+	controller := &fakeCameras{}
+	publisher := New(config.MQTTConfig{
+		Broker:           b.url(),
+		ClientID:         "ocuai-test",
+		TopicPrefix:      "ocuai",
+		Discovery:        true,
+		DiscoveryPrefix:  "homeassistant",
+		MotionOffSeconds: 30,
+	}, store, eventManager, controller)
+
+	if err := publisher.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(publisher.Stop)
+
+	return publisher, store, controller
+}
+
+// waitFor ждет выполнения условия
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitRetained ждет retained сообщение топика с ожидаемым значением
+func waitRetained(t *testing.T, b *broker, topic, want string) {
+	t.Helper()
+	waitFor(t, fmt.Sprintf("%s = %s", topic, want), func() bool {
+		payload, ok := b.retainedMessage(topic)
+		return ok && payload == want
+	})
+}
+
+func TestDiscoveryAnnouncesCamera(t *testing.T) {
+	b := newBroker(t)
+	newPublisher(t, b, storage.Camera{ID: "front.door", Name: "Front door", RTSPURL: "rtsp://camera", MotionDetection: true})
+
+	want := []string{
+		"homeassistant/binary_sensor/ocuai_front_door/motion/config",
+		"homeassistant/binary_sensor/ocuai_front_door/person/config",
+		"homeassistant/binary_sensor/ocuai_front_door/car/config",
+		"homeassistant/binary_sensor/ocuai_front_door/status/config",
+		"homeassistant/camera/ocuai_front_door/config",
+		"homeassistant/switch/ocuai_front_door/motion_detection/config",
+		"homeassistant/switch/ocuai_front_door/ai_detection/config",
+	}
+	waitFor(t, "discovery configs", func() bool {
+		return len(b.retainedTopics("homeassistant/")) == len(want)
+	})
+	for _, topic := range want {
+		if _, ok := b.retainedMessage(topic); !ok {
+			t.Errorf("no discovery config at %s, got %v", topic, b.retainedTopics("homeassistant/"))
+		}
+	}
+
+	discovered := func(topic string) map[string]interface{} {
+		payload, _ := b.retainedMessage(topic)
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(payload), &fields); err != nil {
+			t.Fatalf("%s: invalid config %q: %v", topic, payload, err)
+		}
+		return fields
+	}
+
+	motion := discovered("homeassistant/binary_sensor/ocuai_front_door/motion/config")
+	for key, value := range map[string]string{
+		"name":               "Motion",
+		"unique_id":          "ocuai_front_door_motion",
+		"state_topic":        "ocuai/front.door/motion",
+		"device_class":       "motion",
+		"payload_on":         "ON",
+		"payload_off":        "OFF",
+		"availability_topic": "ocuai/status",
+	} {
+		if motion[key] != value {
+			t.Errorf("motion config %s = %v, want %s", key, motion[key], value)
+		}
+	}
+	device, _ := motion["device"].(map[string]interface{})
+	if device["name"] != "Front door" || device["manufacturer"] != "Ocuai" {
+		t.Errorf("motion config device = %v", motion["device"])
+	}
+
+	if _, ok := discovered("homeassistant/binary_sensor/ocuai_front_door/car/config")["device_class"]; ok {
+		t.Error("car sensor has a device class")
+	}
+
+	aiSwitch := discovered("homeassistant/switch/ocuai_front_door/ai_detection/config")
+	if aiSwitch["state_topic"] != "ocuai/front.door/ai_detection" || aiSwitch["command_topic"] != "ocuai/front.door/ai_detection/set" {
+		t.Errorf("ai switch topics = %v, %v", aiSwitch["state_topic"], aiSwitch["command_topic"])
+	}
+
+	if camera := discovered("homeassistant/camera/ocuai_front_door/config"); camera["topic"] != "ocuai/front.door/snapshot" {
+		t.Errorf("camera topic = %v", camera["topic"])
+	}
+
+	// Вместе с конфигурациями публикуются текущие состояния камеры
+	waitRetained(t, b, "ocuai/status", "online")
+	waitRetained(t, b, "ocuai/front.door/status", "online")
+	waitRetained(t, b, "ocuai/front.door/motion_detection", "ON")
+	waitRetained(t, b, "ocuai/front.door/ai_detection", "OFF")
+	waitRetained(t, b, "ocuai/front.door/motion", "OFF")
+}
+
+func TestDiscoveryRemovesDeletedCamera(t *testing.T) {
+	b := newBroker(t)
+	publisher, store, _ := newPublisher(t, b,
+		storage.Camera{ID: "yard", Name: "Yard", RTSPURL: "rtsp://yard"},
+		storage.Camera{ID: "gate", Name: "Gate", RTSPURL: "rtsp://gate"},
+	)
+
+	waitFor(t, "discovery configs", func() bool {
+		return len(b.retainedTopics("homeassistant/")) == 14
+	})
+
+	if err := store.DeleteCamera("gate"); err != nil {
+		t.Fatalf("DeleteCamera: %v", err)
+	}
+	publisher.refreshCameras()
+
+	for _, topic := range b.retainedTopics("homeassistant/") {
+		if strings.Contains(topic, "ocuai_gate") {
+			t.Errorf("discovery config %s of a deleted camera is still retained", topic)
+		}
+	}
+	if got := len(b.retainedTopics("homeassistant/")); got != 7 {
+		t.Errorf("got %d discovery configs, want 7 for the remaining camera", got)
+	}
+}
+
+func TestSetCommandTogglesDetection(t *testing.T) {
+	b := newBroker(t)
+	_, _, controller := newPublisher(t, b,
+		storage.Camera{ID: "yard", Name: "Yard", RTSPURL: "rtsp://yard", MotionDetection: true},
+		storage.Camera{ID: "broken", Name: "Broken", RTSPURL: "rtsp://broken", AIDetection: true},
+	)
+	waitRetained(t, b, "ocuai/yard/ai_detection", "OFF")
+	waitRetained(t, b, "ocuai/broken/ai_detection", "ON")
+
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(b.url()).SetClientID("home-assistant"))
+	if token := client.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("connect: %v", token.Error())
+	}
+	t.Cleanup(func() { client.Disconnect(100) })
+
+	send := func(topic, payload string) {
+		if token := client.Publish(topic, 1, false, payload); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+			t.Fatalf("publish %s: %v", topic, token.Error())
+		}
+	}
+
+	// Неизвестная настройка, неверное значение и ошибка камеры не меняют состояние
+	send("ocuai/yard/recording/set", "ON")
+	send("ocuai/yard/ai_detection/set", "maybe")
+	send("ocuai/broken/ai_detection/set", "OFF")
+
+	send("ocuai/yard/ai_detection/set", "on")
+	send("ocuai/yard/motion_detection/set", "0")
+
+	waitRetained(t, b, "ocuai/yard/ai_detection", "ON")
+	waitRetained(t, b, "ocuai/yard/motion_detection", "OFF")
+
+	calls := controller.received()
+	want := map[detectionCall]bool{
+		{"yard", settingAI, true}:      true,
+		{"yard", settingMotion, false}: true,
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("SetCameraDetection calls = %+v, want %d", calls, len(want))
+	}
+	for _, call := range calls {
+		if !want[call] {
+			t.Errorf("unexpected SetCameraDetection call %+v", call)
+		}
+	}
+
+	if payload, _ := b.retainedMessage("ocuai/broken/ai_detection"); payload != "ON" {
+		t.Errorf("state of a camera that failed to apply the command = %s, want ON", payload)
+	}
+	if _, ok := b.retainedMessage("ocuai/yard/recording"); ok {
+		t.Error("state published for an unknown setting")
+	}
+}
+
+func TestPersonSensorStaysOnWhileTracked(t *testing.T) {
+	b := newBroker(t)
+	publisher, _, _ := newPublisher(t, b, storage.Camera{ID: "yard", Name: "Yard", RTSPURL: "rtsp://yard"})
+	publisher.config.PersonClasses = []string{"person"}
+	publisher.config.MotionOffSeconds = 1
+	waitRetained(t, b, "ocuai/yard/status", "online")
+
+	start := func(trackID string) {
+		now := time.Now()
+		publisher.eventManager.EmitTrackStarted("yard", "Yard", "person", 0.9, events.Media{},
+			storage.EventTrack{ID: trackID, StartedAt: now, LastSeen: now},
+			map[string]interface{}{"class": "person", "track_id": trackID})
+	}
+	end := func(trackID string) {
+		now := time.Now()
+		publisher.eventManager.EmitTrackEnded("yard", "Yard", "person", 0.9,
+			storage.EventTrack{ID: trackID, StartedAt: now, LastSeen: now, EndedAt: now})
+	}
+
+	start("trk_1")
+	start("trk_2")
+	waitRetained(t, b, "ocuai/yard/person", "ON")
+
+	// Люди в кадре дольше motion_off_seconds: датчик не сбрасывается по таймеру
+	time.Sleep(1500 * time.Millisecond)
+	end("trk_1")
+	time.Sleep(1500 * time.Millisecond)
+	if payload, _ := b.retainedMessage("ocuai/yard/person"); payload != "ON" {
+		t.Fatalf("person sensor = %s while a track is active, want ON", payload)
+	}
+
+	// Последний человек ушел - датчик выключается через motion_off_seconds
+	end("trk_2")
+	waitRetained(t, b, "ocuai/yard/person", "OFF")
+}
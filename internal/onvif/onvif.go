@@ -0,0 +1,246 @@
+package onvif
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPort    = 80
+	requestTimeout = 10 * time.Second
+	deviceService  = "/onvif/device_service"
+)
+
+// Пространства имен сервисов ONVIF
+const (
+	nsDevice = "http://www.onvif.org/ver10/device/wsdl"
+	nsMedia  = "http://www.onvif.org/ver10/media/wsdl"
+	nsPTZ    = "http://www.onvif.org/ver20/ptz/wsdl"
+)
+
+// Client управляет камерой по ONVIF: находит сервисы медиа и PTZ и поворачивает камеру в пресет.
+// Адрес и учетные данные берутся из RTSP URL камеры.
+type Client struct {
+	host     string
+	username string
+	password string
+	client   *http.Client
+}
+
+// Preset пресет PTZ камеры
+type Preset struct {
+	Token string `xml:"token,attr"`
+	Name  string `xml:"Name"`
+}
+
+// New создает клиент ONVIF для камеры с RTSP URL; port - порт ONVIF, 0 - 80
+func New(rtspURL string, port int) (*Client, error) {
+	u, err := url.Parse(rtspURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid camera url: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("camera url %q has no host", rtspURL)
+	}
+
+	if port <= 0 {
+		port = defaultPort
+	}
+
+	c := &Client{
+		host:   net.JoinHostPort(u.Hostname(), strconv.Itoa(port)),
+		client: &http.Client{Timeout: requestTimeout},
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	return c, nil
+}
+
+// GotoPreset поворачивает камеру в пресет, заданный токеном или именем
+func (c *Client) GotoPreset(preset string) error {
+	mediaURL, ptzURL, err := c.services()
+	if err != nil {
+		return err
+	}
+
+	profile, err := c.profileToken(mediaURL)
+	if err != nil {
+		return err
+	}
+
+	presets, err := c.presets(ptzURL, profile)
+	if err != nil {
+		return err
+	}
+
+	token := ""
+	for _, p := range presets {
+		if p.Token == preset || strings.EqualFold(p.Name, preset) {
+			token = p.Token
+			break
+		}
+	}
+	if token == "" {
+		return fmt.Errorf("camera has no ptz preset %q", preset)
+	}
+
+	body := fmt.Sprintf(`<GotoPreset xmlns="%s"><ProfileToken>%s</ProfileToken><PresetToken>%s</PresetToken></GotoPreset>`,
+		nsPTZ, escape(profile), escape(token))
+	return c.call(ptzURL, body, nil)
+}
+
+// presets возвращает пресеты PTZ профиля
+func (c *Client) presets(ptzURL, profile string) ([]Preset, error) {
+	var response struct {
+		Presets []Preset `xml:"Body>GetPresetsResponse>Preset"`
+	}
+
+	body := fmt.Sprintf(`<GetPresets xmlns="%s"><ProfileToken>%s</ProfileToken></GetPresets>`, nsPTZ, escape(profile))
+	if err := c.call(ptzURL, body, &response); err != nil {
+		return nil, err
+	}
+	return response.Presets, nil
+}
+
+// services возвращает адреса сервисов медиа и PTZ камеры
+func (c *Client) services() (string, string, error) {
+	var response struct {
+		Media string `xml:"Body>GetCapabilitiesResponse>Capabilities>Media>XAddr"`
+		PTZ   string `xml:"Body>GetCapabilitiesResponse>Capabilities>PTZ>XAddr"`
+	}
+
+	body := fmt.Sprintf(`<GetCapabilities xmlns="%s"><Category>All</Category></GetCapabilities>`, nsDevice)
+	if err := c.call("http://"+c.host+deviceService, body, &response); err != nil {
+		return "", "", err
+	}
+
+	if response.PTZ == "" {
+		return "", "", fmt.Errorf("camera does not support ptz")
+	}
+	if response.Media == "" {
+		return "", "", fmt.Errorf("camera has no media service")
+	}
+	return c.rebase(response.Media), c.rebase(response.PTZ), nil
+}
+
+// profileToken возвращает токен медиапрофиля с настройкой PTZ (или первого профиля)
+func (c *Client) profileToken(mediaURL string) (string, error) {
+	var response struct {
+		Profiles []struct {
+			Token string    `xml:"token,attr"`
+			PTZ   *struct{} `xml:"PTZConfiguration"`
+		} `xml:"Body>GetProfilesResponse>Profiles"`
+	}
+
+	body := fmt.Sprintf(`<GetProfiles xmlns="%s"/>`, nsMedia)
+	if err := c.call(mediaURL, body, &response); err != nil {
+		return "", err
+	}
+
+	if len(response.Profiles) == 0 {
+		return "", fmt.Errorf("camera has no media profiles")
+	}
+	for _, profile := range response.Profiles {
+		if profile.PTZ != nil {
+			return profile.Token, nil
+		}
+	}
+	return response.Profiles[0].Token, nil
+}
+
+// rebase заменяет хост в адресе сервиса на хост камеры: за NAT камера сообщает внутренний адрес
+func (c *Client) rebase(xaddr string) string {
+	u, err := url.Parse(xaddr)
+	if err != nil || u.Path == "" {
+		return "http://" + c.host + deviceService
+	}
+	u.Scheme = "http"
+	u.Host = c.host
+	return u.String()
+}
+
+// call отправляет SOAP запрос и разбирает ответ в result (может быть nil)
+func (c *Client) call(serviceURL, body string, result interface{}) error {
+	envelope := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope">` +
+		`<s:Header>` + c.security() + `</s:Header>` +
+		`<s:Body>` + body + `</s:Body></s:Envelope>`
+
+	resp, err := c.client.Post(serviceURL, "application/soap+xml; charset=utf-8", strings.NewReader(envelope))
+	if err != nil {
+		return fmt.Errorf("onvif request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read onvif response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var fault struct {
+			Reason string `xml:"Body>Fault>Reason>Text"`
+		}
+		if xml.Unmarshal(data, &fault) == nil && fault.Reason != "" {
+			return fmt.Errorf("onvif error: %s", fault.Reason)
+		}
+		return fmt.Errorf("onvif request failed with status %d", resp.StatusCode)
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := xml.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("invalid onvif response: %w", err)
+	}
+	return nil
+}
+
+// security возвращает заголовок WS-Security с дайджестом пароля
+func (c *Client) security() string {
+	if c.username == "" {
+		return ""
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	created := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+
+	return `<Security xmlns="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd">` +
+		`<UsernameToken><Username>` + escape(c.username) + `</Username>` +
+		`<Password Type="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest">` +
+		PasswordDigest(nonce, created, c.password) + `</Password>` +
+		`<Nonce EncodingType="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary">` +
+		base64.StdEncoding.EncodeToString(nonce) + `</Nonce>` +
+		`<Created xmlns="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">` +
+		created + `</Created></UsernameToken></Security>`
+}
+
+// PasswordDigest возвращает дайджест пароля WS-Security: base64(sha1(nonce + created + password))
+func PasswordDigest(nonce []byte, created, password string) string {
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(created))
+	h.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// escape экранирует значение для вставки в XML
+func escape(value string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
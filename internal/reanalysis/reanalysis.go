@@ -0,0 +1,478 @@
+package reanalysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ocuai/internal/ai"
+	"ocuai/internal/config"
+	"ocuai/internal/events"
+	"ocuai/internal/storage"
+
+	"gocv.io/x/gocv"
+)
+
+// queueSize максимальное количество заданий в очереди
+const queueSize = 16
+
+// defaultFPS сколько кадров записи в секунду анализируется по умолчанию
+const defaultFPS = 1
+
+// maxGap разрыв между сегментами, после которого объекты считаются ушедшими
+const maxGap = 10 * time.Second
+
+// errCanceled задание отменено пользователем
+var errCanceled = errors.New("canceled")
+
+// ProgressFunc вызывается при каждом изменении состояния задания
+type ProgressFunc func(job storage.ReanalysisJob)
+
+// Manager прогоняет записи камер через AI в фоне по одному заданию.
+// События заданий помечаются его ID и не попадают в живую ленту и уведомления.
+// Processor должен быть отдельным от живых камер экземпляром, чтобы задания не занимали их детектор;
+// после каждого кадра задание простаивает столько же, сколько шел инференс, уступая процессор камерам.
+type Manager struct {
+	storage    *storage.Storage
+	processor  *ai.Processor
+	tracker    config.TrackerConfig
+	threshold  float32
+	dir        string
+	onProgress ProgressFunc
+	queue      chan *storage.ReanalysisJob
+	running    string             // ID выполняемого задания
+	stopJob    context.CancelFunc // отменяет выполняемое задание
+	canceled   map[string]bool    // отмененные задания, еще стоящие в очереди
+	mu         sync.Mutex
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// New создает менеджер повторного анализа
+func New(store *storage.Storage, cfg *config.Config, processor *ai.Processor, onProgress ProgressFunc) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Manager{
+		storage:    store,
+		processor:  processor,
+		tracker:    cfg.AI.Tracker,
+		threshold:  cfg.AI.Threshold,
+		dir:        filepath.Join(cfg.Storage.VideoPath, "reanalysis"),
+		onProgress: onProgress,
+		queue:      make(chan *storage.ReanalysisJob, queueSize),
+		canceled:   make(map[string]bool),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start запускает обработчик очереди
+func (m *Manager) Start() {
+	// Задания, прерванные перезапуском, уже не будут выполнены
+	if err := m.storage.FailInterruptedReanalysisJobs(); err != nil {
+		log.Printf("Reanalysis: %v", err)
+	}
+
+	m.wg.Add(1)
+	go m.worker()
+}
+
+// Stop прерывает текущее задание и останавливает обработчик
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Submit ставит в очередь повторный анализ записей камеры.
+// Из запроса берутся камера, интервал, модель, порог, классы и частота кадров.
+func (m *Manager) Submit(request storage.ReanalysisJob) (*storage.ReanalysisJob, error) {
+	if request.Model != "" && !m.processor.HasModel(request.Model) {
+		return nil, fmt.Errorf("unknown model: %s", request.Model)
+	}
+
+	job := &storage.ReanalysisJob{
+		ID:        fmt.Sprintf("rea_%d", time.Now().UnixNano()),
+		CameraID:  request.CameraID,
+		From:      request.From,
+		To:        request.To,
+		Model:     request.Model,
+		Threshold: request.Threshold,
+		Classes:   request.Classes,
+		FPS:       request.FPS,
+		Status:    storage.ReanalysisStatusPending,
+		CreatedAt: time.Now(),
+	}
+	if job.Threshold <= 0 {
+		job.Threshold = m.threshold
+	}
+	if job.FPS <= 0 {
+		job.FPS = defaultFPS
+	}
+
+	if err := m.storage.CreateReanalysisJob(job); err != nil {
+		return nil, err
+	}
+
+	// Задание дальше меняется обработчиком, вызывающему отдаем копию
+	submitted := *job
+
+	select {
+	case m.queue <- job:
+	default:
+		job.Status = storage.ReanalysisStatusFailed
+		job.Error = "reanalysis queue is full"
+		job.FinishedAt = time.Now()
+		m.update(job)
+		return nil, fmt.Errorf("reanalysis queue is full")
+	}
+
+	m.notify(&submitted)
+	return &submitted, nil
+}
+
+// Cancel отменяет задание: ожидающее не будет выполнено, выполняемое прерывается,
+// уже найденные им события сохраняются
+func (m *Manager) Cancel(id string) error {
+	job, err := m.storage.GetReanalysisJob(id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("reanalysis job not found: %s", id)
+	}
+	if job.Status != storage.ReanalysisStatusPending && job.Status != storage.ReanalysisStatusRunning {
+		return fmt.Errorf("reanalysis job is already %s", job.Status)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running == id {
+		m.stopJob()
+	} else {
+		m.canceled[id] = true
+	}
+
+	return nil
+}
+
+// worker последовательно выполняет задания из очереди
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case job := <-m.queue:
+			m.run(job)
+		}
+	}
+}
+
+// run выполняет одно задание и сохраняет результат
+func (m *Manager) run(job *storage.ReanalysisJob) {
+	ctx, stop := context.WithCancelCause(m.ctx)
+	defer stop(nil)
+
+	m.mu.Lock()
+	if m.canceled[job.ID] {
+		delete(m.canceled, job.ID)
+		m.mu.Unlock()
+
+		job.Status = storage.ReanalysisStatusCanceled
+		job.FinishedAt = time.Now()
+		m.update(job)
+		return
+	}
+	m.running = job.ID
+	m.stopJob = func() { stop(errCanceled) }
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.running = ""
+		m.stopJob = nil
+		m.mu.Unlock()
+	}()
+
+	job.Status = storage.ReanalysisStatusRunning
+	m.update(job)
+
+	log.Printf("Reanalysis %s started: camera %s, %s - %s", job.ID, job.CameraID,
+		job.From.Format(time.RFC3339), job.To.Format(time.RFC3339))
+
+	err := m.analyze(ctx, job)
+	job.FinishedAt = time.Now()
+	if err != nil && errors.Is(context.Cause(ctx), errCanceled) {
+		log.Printf("Reanalysis %s canceled after %d events", job.ID, job.EventCount)
+		job.Status = storage.ReanalysisStatusCanceled
+	} else if err != nil {
+		log.Printf("Reanalysis %s failed after %d events: %v", job.ID, job.EventCount, err)
+		job.Status = storage.ReanalysisStatusFailed
+		job.Error = err.Error()
+	} else {
+		log.Printf("Reanalysis %s completed: %d events", job.ID, job.EventCount)
+		job.Status = storage.ReanalysisStatusCompleted
+		job.Progress = 1
+	}
+
+	m.update(job)
+}
+
+// analyze прогоняет сегменты записи через модель и сохраняет события по трекам объектов
+func (m *Manager) analyze(ctx context.Context, job *storage.ReanalysisJob) error {
+	camera, err := m.storage.GetCamera(job.CameraID)
+	if err != nil {
+		return err
+	}
+	if camera == nil {
+		return fmt.Errorf("camera not found: %s", job.CameraID)
+	}
+
+	recordings, err := m.storage.GetRecordings(job.CameraID, job.From, job.To)
+	if err != nil {
+		return err
+	}
+
+	run := &jobRun{
+		ctx:      ctx,
+		manager:  m,
+		job:      job,
+		camera:   camera,
+		tracker:  ai.NewTracker(m.tracker),
+		reported: make(map[string]bool),
+	}
+	defer run.flush()
+
+	for _, recording := range recordings {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if recording.Status != storage.RecordingStatusComplete {
+			continue
+		}
+		if err := run.analyzeRecording(recording); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// jobRun состояние выполняемого задания
+type jobRun struct {
+	ctx          context.Context
+	manager      *Manager
+	job          *storage.ReanalysisJob
+	camera       *storage.Camera
+	tracker      *ai.Tracker
+	reported     map[string]bool // треки, для которых создано событие
+	lastAt       time.Time
+	lastReported float64
+}
+
+// analyzeRecording анализирует кадры сегмента с заданной частотой
+func (r *jobRun) analyzeRecording(recording storage.Recording) error {
+	capture, err := gocv.VideoCaptureFile(recording.Path)
+	if err != nil || !capture.IsOpened() {
+		log.Printf("Reanalysis %s: failed to open recording %s: %v", r.job.ID, recording.Path, err)
+		if capture != nil {
+			capture.Close()
+		}
+		return nil
+	}
+	defer capture.Close()
+
+	// Между сегментами была пауза в записи - объекты из прошлого сегмента ушли
+	if !r.lastAt.IsZero() && recording.StartTime.Sub(r.lastAt) > maxGap {
+		r.flush()
+	}
+
+	// Перематываем к началу интервала внутри сегмента
+	if offset := r.job.From.Sub(recording.StartTime); offset > 0 {
+		capture.Set(gocv.VideoCapturePosMsec, float64(offset.Milliseconds()))
+	}
+
+	// Пропускаем кадры между анализируемыми, не декодируя их
+	skip := 0
+	if fps := capture.Get(gocv.VideoCaptureFPS); fps > r.job.FPS {
+		skip = int(math.Round(fps/r.job.FPS)) - 1
+	}
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+
+	for {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		if !capture.Read(&frame) || frame.Empty() {
+			return nil
+		}
+
+		at := recording.StartTime.Add(time.Duration(capture.Get(gocv.VideoCapturePosMsec)) * time.Millisecond)
+		if at.After(r.job.To) {
+			return nil
+		}
+		if !at.Before(r.job.From) {
+			if err := r.analyzeFrame(recording, frame, at); err != nil {
+				return err
+			}
+		}
+
+		if skip > 0 {
+			capture.Grab(skip)
+		}
+	}
+}
+
+// analyzeFrame прогоняет кадр через модель и трекер
+func (r *jobRun) analyzeFrame(recording storage.Recording, frame gocv.Mat, at time.Time) error {
+	started := time.Now()
+	detections, err := r.manager.processor.ProcessFrameWithOptions(r.job.Model, frame, r.job.Threshold, r.job.Classes)
+	if err != nil {
+		return fmt.Errorf("inference failed: %w", err)
+	}
+	r.lastAt = at
+
+	// Простаиваем столько же, сколько шел инференс, чтобы не отнимать процессор у живых камер
+	select {
+	case <-r.ctx.Done():
+	case <-time.After(time.Since(started)):
+	}
+
+	update := r.tracker.Update(detections, at)
+
+	thumbnail := ""
+	for _, track := range update.Started {
+		if thumbnail == "" {
+			thumbnail = r.saveThumbnail(frame, detections, at)
+		}
+		r.saveEvent(recording, track, thumbnail, at)
+	}
+	r.endTracks(update.Ended)
+
+	r.reportProgress(at)
+	return nil
+}
+
+// saveEvent сохраняет событие задания о появлении объекта.
+// Видео события - место в сегменте записи (recording_id, offset_seconds): путь к сегменту
+// в VideoPath не пишется, иначе удаление события по сроку хранения удалило бы и сам сегмент.
+func (r *jobRun) saveEvent(recording storage.Recording, track ai.Track, thumbnail string, at time.Time) {
+	event := &storage.Event{
+		CameraID:      r.camera.ID,
+		CameraName:    r.camera.Name,
+		Type:          string(events.EventTypeAI),
+		Description:   "Detected: " + track.Class,
+		Confidence:    track.Confidence,
+		ThumbnailPath: thumbnail,
+		CreatedAt:     at,
+		Processed:     true,
+		Data: map[string]interface{}{
+			"class":          track.Class,
+			"bbox":           track.BBox,
+			"track_id":       track.ID,
+			"recording_id":   recording.ID,
+			"offset_seconds": at.Sub(recording.StartTime).Seconds(),
+			"model":          r.job.Model,
+		},
+		Track: &storage.EventTrack{
+			ID:        track.ID,
+			StartedAt: track.StartedAt,
+			LastSeen:  track.LastSeen,
+		},
+		JobID: r.job.ID,
+	}
+
+	if err := r.manager.storage.SaveEvent(event); err != nil {
+		log.Printf("Reanalysis %s: %v", r.job.ID, err)
+		return
+	}
+
+	r.reported[track.ID] = true
+	r.job.EventCount++
+}
+
+// endTracks дописывает время присутствия ушедших объектов в их события
+func (r *jobRun) endTracks(tracks []ai.Track) {
+	for _, track := range tracks {
+		if !r.reported[track.ID] {
+			continue
+		}
+		delete(r.reported, track.ID)
+
+		eventTrack := storage.EventTrack{
+			ID:           track.ID,
+			StartedAt:    track.StartedAt,
+			LastSeen:     track.LastSeen,
+			EndedAt:      track.LastSeen,
+			DwellSeconds: track.Dwell().Seconds(),
+		}
+		if err := r.manager.storage.UpdateEventTrack(eventTrack, track.MaxConfidence); err != nil {
+			log.Printf("Reanalysis %s: %v", r.job.ID, err)
+		}
+	}
+}
+
+// flush завершает все треки, например в конце записи
+func (r *jobRun) flush() {
+	r.endTracks(r.tracker.Flush())
+}
+
+// reportProgress обновляет прогресс по доле проанализированного интервала
+func (r *jobRun) reportProgress(at time.Time) {
+	progress := float64(at.Sub(r.job.From)) / float64(r.job.To.Sub(r.job.From))
+	// Не засыпаем клиентов уведомлениями о каждом кадре
+	if progress-r.lastReported < 0.01 {
+		return
+	}
+
+	r.lastReported = progress
+	r.job.Progress = math.Min(progress, 1)
+	r.manager.update(r.job)
+}
+
+// saveThumbnail сохраняет кадр с рамками детекций в каталог задания
+func (r *jobRun) saveThumbnail(frame gocv.Mat, detections []ai.Detection, at time.Time) string {
+	dir := filepath.Join(r.manager.dir, r.job.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Reanalysis %s: failed to create thumbnail directory: %v", r.job.ID, err)
+		return ""
+	}
+
+	annotated := frame.Clone()
+	defer annotated.Close()
+	ai.DrawDetections(&annotated, detections)
+
+	path := filepath.Join(dir, at.UTC().Format("20060102_150405.000")+".jpg")
+	if !gocv.IMWrite(path, annotated) {
+		log.Printf("Reanalysis %s: failed to write thumbnail %s", r.job.ID, path)
+		return ""
+	}
+
+	return path
+}
+
+// update сохраняет состояние задания и уведомляет подписчиков
+func (m *Manager) update(job *storage.ReanalysisJob) {
+	if err := m.storage.UpdateReanalysisJob(job); err != nil {
+		log.Printf("Reanalysis: %v", err)
+	}
+	m.notify(job)
+}
+
+// notify сообщает о новом состоянии задания
+func (m *Manager) notify(job *storage.ReanalysisJob) {
+	if m.onProgress != nil {
+		m.onProgress(*job)
+	}
+}
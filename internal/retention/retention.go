@@ -0,0 +1,516 @@
+package retention
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ocuai/internal/config"
+	"ocuai/internal/events"
+	"ocuai/internal/export"
+	"ocuai/internal/storage"
+
+	"github.com/robfig/cron/v3"
+)
+
+// batchSize количество кандидатов на удаление, выбираемых за один запрос
+const batchSize = 100
+
+// Manager удаляет старые записи, клипы, миниатюры, события и номера по возрасту и бюджету диска
+type Manager struct {
+	storage      *storage.Storage
+	config       *config.Config
+	eventManager *events.Manager
+	cron         *cron.Cron
+	mu           sync.Mutex
+}
+
+// Result итог одного прохода очистки
+type Result struct {
+	EventsDeleted     int   `json:"events_deleted"`
+	RecordingsDeleted int   `json:"recordings_deleted"`
+	PlatesDeleted     int   `json:"plates_deleted"`
+	BytesFreed        int64 `json:"bytes_freed"`
+	EvictedEarly      int   `json:"evicted_early"` // удалено из-за превышения бюджета диска
+}
+
+// New создает менеджер хранения
+func New(store *storage.Storage, cfg *config.Config, eventManager *events.Manager) *Manager {
+	return &Manager{
+		storage:      store,
+		config:       cfg,
+		eventManager: eventManager,
+		cron:         cron.New(),
+	}
+}
+
+// Start запускает периодическую очистку (каждый час и сразу при старте)
+func (m *Manager) Start() {
+	_, err := m.cron.AddFunc("0 * * * *", func() {
+		m.Run()
+	})
+	if err != nil {
+		log.Printf("Failed to add retention cron job: %v", err)
+	}
+	m.cron.Start()
+
+	go m.Run()
+}
+
+// Stop останавливает периодическую очистку
+func (m *Manager) Stop() {
+	ctx := m.cron.Stop()
+	<-ctx.Done()
+}
+
+// Run выполняет один проход очистки
+func (m *Manager) Run() Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var total Result
+	now := time.Now()
+
+	// Очистка по возрасту с учетом срока хранения каждой камеры
+	retentionDays := make(map[string]int)
+	for _, camera := range m.config.Cameras {
+		if camera.RetentionDays > 0 {
+			retentionDays[camera.ID] = camera.RetentionDays
+		}
+	}
+
+	cameraIDs, err := m.storage.GetMediaCameraIDs()
+	if err != nil {
+		log.Printf("Retention: failed to list cameras: %v", err)
+	}
+	for _, cameraID := range cameraIDs {
+		days, ok := retentionDays[cameraID]
+		if !ok {
+			days = m.config.Storage.RetentionDays
+		}
+		if days > 0 {
+			total.add(m.expire(cameraID, now.AddDate(0, 0, -days)))
+		}
+	}
+
+	// События без камеры (смена режима охраны) хранятся по общему сроку
+	if days := m.config.Storage.RetentionDays; days > 0 {
+		deleted, err := m.storage.DeleteCameralessEventsBefore(now.AddDate(0, 0, -days))
+		if err != nil {
+			log.Printf("Retention: %v", err)
+		}
+		total.EventsDeleted += deleted
+	}
+
+	// Архивы экспорта хранятся отдельно от видео и удаляются только по сроку
+	if days := m.config.Storage.ExportRetentionDays; days > 0 {
+		m.expireExports(now.AddDate(0, 0, -days))
+	}
+
+	// Бюджет диска по камерам
+	for _, camera := range m.config.Cameras {
+		if camera.MaxStorageGB > 0 {
+			dir := filepath.Join(m.config.Storage.VideoPath, camera.ID)
+			result := m.enforceBudget(camera.ID, dir, gigabytes(camera.MaxStorageGB), now)
+			if result.EvictedEarly > 0 {
+				m.reportEviction(camera.Name, camera.MaxStorageGB, result)
+			}
+			total.add(result)
+		}
+	}
+
+	// Общий бюджет диска
+	if m.config.Storage.MaxStorageGB > 0 {
+		result := m.enforceBudget("", m.config.Storage.VideoPath, gigabytes(m.config.Storage.MaxStorageGB), now,
+			export.Dir(m.config))
+		if result.EvictedEarly > 0 {
+			m.reportEviction("all cameras", m.config.Storage.MaxStorageGB, result)
+		}
+		total.add(result)
+	}
+
+	if total.EventsDeleted > 0 || total.RecordingsDeleted > 0 || total.PlatesDeleted > 0 {
+		log.Printf("Retention: deleted %d events, %d recordings and %d plate reads, freed %d MB",
+			total.EventsDeleted, total.RecordingsDeleted, total.PlatesDeleted, total.BytesFreed/1024/1024)
+	}
+
+	return total
+}
+
+// expire удаляет все незащищенные данные камеры старше before
+func (m *Manager) expire(cameraID string, before time.Time) Result {
+	var result Result
+
+	for {
+		batch, err := m.storage.GetEvictableEvents(cameraID, before, batchSize)
+		if err != nil {
+			log.Printf("Retention: failed to get expired events: %v", err)
+			break
+		}
+
+		deleted := 0
+		for _, event := range batch {
+			if freed, ok := m.deleteEvent(event); ok {
+				result.BytesFreed += freed
+				result.EventsDeleted++
+				deleted++
+			}
+		}
+
+		if len(batch) < batchSize || deleted == 0 {
+			break
+		}
+	}
+
+	for {
+		batch, err := m.storage.GetEvictableRecordings(cameraID, before, batchSize)
+		if err != nil {
+			log.Printf("Retention: failed to get expired recordings: %v", err)
+			break
+		}
+
+		deleted := 0
+		for _, recording := range batch {
+			if freed, ok := m.deleteRecording(recording); ok {
+				result.BytesFreed += freed
+				result.RecordingsDeleted++
+				deleted++
+			}
+		}
+
+		if len(batch) < batchSize || deleted == 0 {
+			break
+		}
+	}
+
+	for {
+		batch, err := m.storage.GetEvictablePlateReads(cameraID, before, batchSize)
+		if err != nil {
+			log.Printf("Retention: failed to get expired plate reads: %v", err)
+			break
+		}
+
+		deleted := 0
+		for _, read := range batch {
+			if freed, ok := m.deletePlateRead(read); ok {
+				result.BytesFreed += freed
+				result.PlatesDeleted++
+				deleted++
+			}
+		}
+
+		if len(batch) < batchSize || deleted == 0 {
+			break
+		}
+	}
+
+	return result
+}
+
+// enforceBudget удаляет самые старые данные, пока размер dir без директорий skip превышает budget байт.
+// Размер перемеряется после каждой пачки. Файл, общий для нескольких событий (треки из одного кадра),
+// освобождается вместе с последним из них, поэтому такие события удаляются, даже если каждое
+// по отдельности места не освобождает.
+func (m *Manager) enforceBudget(cameraID, dir string, budget int64, now time.Time, skip ...string) Result {
+	var result Result
+
+	for {
+		usage, err := dirSize(dir, skip...)
+		if err != nil {
+			log.Printf("Retention: failed to measure %s: %v", dir, err)
+			break
+		}
+		if usage <= budget {
+			break
+		}
+
+		events, err := m.storage.GetEvictableEvents(cameraID, now, batchSize)
+		if err != nil {
+			log.Printf("Retention: failed to get events for eviction: %v", err)
+			break
+		}
+		recordings, err := m.storage.GetEvictableRecordings(cameraID, now, batchSize)
+		if err != nil {
+			log.Printf("Retention: failed to get recordings for eviction: %v", err)
+			break
+		}
+		plates, err := m.storage.GetEvictablePlateReads(cameraID, now, batchSize)
+		if err != nil {
+			log.Printf("Retention: failed to get plate reads for eviction: %v", err)
+			break
+		}
+
+		if len(events) == 0 && len(recordings) == 0 && len(plates) == 0 {
+			log.Printf("Retention: %s is over budget (%d MB > %d MB) but only protected data is left",
+				dir, usage/1024/1024, budget/1024/1024)
+			break
+		}
+
+		// Сливаем отсортированные списки, удаляя самые старые данные первыми
+		var batchFreed int64
+		var batchDeleted int
+		for usage > budget && (len(events) > 0 || len(recordings) > 0 || len(plates) > 0) {
+			var freed int64
+			var ok bool
+
+			switch oldest(events, recordings, plates) {
+			case kindEvent:
+				event := events[0]
+				events = events[1:]
+				// Событие без файлов на диске или с файлами избранного события места не освободит
+				if !m.hasEvictableMedia(event) {
+					continue
+				}
+				if freed, ok = m.deleteEvent(event); ok {
+					result.EventsDeleted++
+				}
+			case kindRecording:
+				if freed, ok = m.deleteRecording(recordings[0]); ok {
+					result.RecordingsDeleted++
+				}
+				recordings = recordings[1:]
+			case kindPlate:
+				read := plates[0]
+				plates = plates[1:]
+				// Номер без вырезки не занимает места на диске
+				if read.ImagePath == "" {
+					continue
+				}
+				if freed, ok = m.deletePlateRead(read); ok {
+					result.PlatesDeleted++
+				}
+			}
+
+			if !ok {
+				continue
+			}
+			usage -= freed
+			batchFreed += freed
+			batchDeleted++
+			result.EvictedEarly++
+		}
+
+		result.BytesFreed += batchFreed
+		if batchDeleted == 0 {
+			log.Printf("Retention: %s is over budget (%d MB > %d MB) but nothing more can be freed",
+				dir, usage/1024/1024, budget/1024/1024)
+			break
+		}
+	}
+
+	return result
+}
+
+// Виды данных, удаляемых по бюджету диска
+const (
+	kindEvent = iota
+	kindRecording
+	kindPlate
+)
+
+// oldest возвращает вид данных с самой старой первой записью среди непустых списков
+func oldest(events []storage.Event, recordings []storage.Recording, plates []storage.PlateRead) int {
+	kind := -1
+	var at time.Time
+
+	candidate := func(k int, t time.Time) {
+		if kind < 0 || t.Before(at) {
+			kind, at = k, t
+		}
+	}
+	if len(events) > 0 {
+		candidate(kindEvent, events[0].CreatedAt)
+	}
+	if len(recordings) > 0 {
+		candidate(kindRecording, recordings[0].StartTime)
+	}
+	if len(plates) > 0 {
+		candidate(kindPlate, plates[0].CreatedAt)
+	}
+
+	return kind
+}
+
+// hasEvictableMedia проверяет, есть ли у события файлы на диске, которые не удерживает избранное событие
+func (m *Manager) hasEvictableMedia(event storage.Event) bool {
+	for _, path := range []string{event.VideoPath, event.ThumbnailPath} {
+		if path == "" {
+			continue
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		starred, err := m.storage.IsMediaStarred(path, event.ID)
+		if err != nil {
+			log.Printf("Retention: %v", err)
+			continue
+		}
+		if !starred {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expireExports удаляет задания на экспорт и их архивы, завершенные до before
+func (m *Manager) expireExports(before time.Time) {
+	var deleted int
+	var freed int64
+
+	for {
+		batch, err := m.storage.GetExpiredExports(before, batchSize)
+		if err != nil {
+			log.Printf("Retention: failed to get expired exports: %v", err)
+			break
+		}
+
+		for _, job := range batch {
+			if err := m.storage.DeleteExport(job.ID); err != nil {
+				log.Printf("Retention: failed to delete export %s: %v", job.ID, err)
+				return
+			}
+			if job.FilePath != "" {
+				freed += removeFile(job.FilePath)
+			}
+			deleted++
+		}
+
+		if len(batch) < batchSize {
+			break
+		}
+	}
+
+	if deleted > 0 {
+		log.Printf("Retention: deleted %d exports, freed %d MB", deleted, freed/1024/1024)
+	}
+}
+
+// deleteEvent удаляет событие вместе с клипом и миниатюрой, если на них больше никто не ссылается
+func (m *Manager) deleteEvent(event storage.Event) (int64, bool) {
+	if err := m.storage.DeleteEvent(event.ID); err != nil {
+		log.Printf("Retention: failed to delete event %d: %v", event.ID, err)
+		return 0, false
+	}
+
+	var freed int64
+	for _, path := range []string{event.VideoPath, event.ThumbnailPath} {
+		if path == "" {
+			continue
+		}
+
+		referenced, err := m.storage.IsMediaReferenced(path)
+		if err != nil {
+			log.Printf("Retention: %v", err)
+			continue
+		}
+		if !referenced {
+			freed += removeFile(path)
+		}
+	}
+
+	return freed, true
+}
+
+// deleteRecording удаляет сегмент записи с диска и из индекса
+func (m *Manager) deleteRecording(recording storage.Recording) (int64, bool) {
+	if err := m.storage.DeleteRecording(recording.ID); err != nil {
+		log.Printf("Retention: failed to delete recording %d: %v", recording.ID, err)
+		return 0, false
+	}
+
+	return removeFile(recording.Path), true
+}
+
+// deletePlateRead удаляет распознанный номер вместе с вырезкой
+func (m *Manager) deletePlateRead(read storage.PlateRead) (int64, bool) {
+	if err := m.storage.DeletePlateRead(read.ID); err != nil {
+		log.Printf("Retention: failed to delete plate read %d: %v", read.ID, err)
+		return 0, false
+	}
+
+	if read.ImagePath == "" {
+		return 0, true
+	}
+	return removeFile(read.ImagePath), true
+}
+
+// reportEviction отправляет системное событие о досрочном удалении данных
+func (m *Manager) reportEviction(scope string, budgetGB float64, result Result) {
+	message := fmt.Sprintf("Storage budget of %.1f GB exceeded for %s: evicted %d items early (%d MB freed)",
+		budgetGB, scope, result.EvictedEarly, result.BytesFreed/1024/1024)
+
+	log.Printf("Retention: %s", message)
+	if m.eventManager != nil {
+		m.eventManager.EmitSystemLog(message)
+	}
+}
+
+// add суммирует результаты
+func (r *Result) add(other Result) {
+	r.EventsDeleted += other.EventsDeleted
+	r.RecordingsDeleted += other.RecordingsDeleted
+	r.PlatesDeleted += other.PlatesDeleted
+	r.BytesFreed += other.BytesFreed
+	r.EvictedEarly += other.EvictedEarly
+}
+
+// removeFile удаляет файл и пустую родительскую директорию, возвращая освобожденный объем
+func removeFile(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+
+	if err := os.Remove(path); err != nil {
+		log.Printf("Retention: failed to remove %s: %v", path, err)
+		return 0
+	}
+
+	// Удаляется только пустая директория
+	os.Remove(filepath.Dir(path))
+
+	return info.Size()
+}
+
+// dirSize возвращает суммарный размер файлов в директории, не заходя в директории skip
+func dirSize(dir string, skip ...string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			for _, s := range skip {
+				if filepath.Clean(path) == filepath.Clean(s) {
+					return fs.SkipDir
+				}
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}
+
+// gigabytes переводит гигабайты в байты
+func gigabytes(gb float64) int64 {
+	return int64(gb * 1024 * 1024 * 1024)
+}
@@ -0,0 +1,499 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"ocuai/internal/ai"
+	"ocuai/internal/arming"
+	"ocuai/internal/events"
+	"ocuai/internal/onvif"
+	"ocuai/internal/storage"
+)
+
+const (
+	actionQueueSize  = 100   // срабатываний правил, ожидающих выполнения действий
+	maxDryRunEvents  = 10000 // событий, проверяемых за один пробный прогон
+	defaultDryRunTop = 100   // совпадений в ответе пробного прогона по умолчанию
+)
+
+// Настройки камеры, переключаемые действием camera_setting
+const (
+	SettingMotionDetection = "motion_detection"
+	SettingAIDetection     = "ai_detection"
+)
+
+// Причины, по которым событие не подошло правилу
+const (
+	reasonEventType  = "event_type"
+	reasonCamera     = "camera"
+	reasonClass      = "class"
+	reasonZone       = "zone"
+	reasonConfidence = "min_confidence"
+	reasonTime       = "time_window"
+	reasonArming     = "arming_mode"
+	reasonCooldown   = "cooldown"
+)
+
+// Notifier рассылает уведомления пользователям
+type Notifier interface {
+	Broadcast(text, photoPath string) error
+}
+
+// WebhookSender отправляет событие на вебхук
+type WebhookSender interface {
+	Send(id int, event events.Event) error
+}
+
+// CameraController управляет работающими камерами
+type CameraController interface {
+	StartRecording(cameraID string) (string, error)
+	SetCameraDetection(cameraID, setting string, enabled bool) error
+}
+
+// Engine проверяет пользовательские правила для каждого события и выполняет их действия.
+// Проверка идет синхронно при записи события, действия выполняются в фоне по порядку.
+type Engine struct {
+	storage       *storage.Storage
+	notifier      Notifier
+	webhooks      WebhookSender
+	cameras       CameraController
+	arming        *arming.Manager
+	rules         []rule
+	lastTriggered map[int]time.Time
+	queue         chan trigger
+	stop          chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+}
+
+// rule правило с разобранным интервалом времени
+type rule struct {
+	storage.EventRule
+	window ai.TimeWindow
+}
+
+// trigger срабатывание правила на событие
+type trigger struct {
+	rule  storage.EventRule
+	event events.Event
+}
+
+// DryRunMatch событие, на которое сработало бы правило
+type DryRunMatch struct {
+	Event   storage.Event `json:"event"`
+	Actions []string      `json:"actions"`
+}
+
+// DryRunResult результат проверки правила на прошлых событиях
+type DryRunResult struct {
+	Checked int            `json:"checked"`
+	Matched int            `json:"matched"`
+	Skipped map[string]int `json:"skipped"` // причина -> количество неподошедших событий
+	Matches []DryRunMatch  `json:"matches"`
+}
+
+// New создает движок правил. notifier может быть nil, если Telegram не настроен.
+func New(store *storage.Storage, notifier Notifier, webhooks WebhookSender, cameras CameraController, armingManager *arming.Manager) *Engine {
+	return &Engine{
+		storage:       store,
+		notifier:      notifier,
+		webhooks:      webhooks,
+		cameras:       cameras,
+		arming:        armingManager,
+		lastTriggered: make(map[int]time.Time),
+		queue:         make(chan trigger, actionQueueSize),
+		stop:          make(chan struct{}),
+	}
+}
+
+// Start загружает правила и запускает выполнение действий
+func (e *Engine) Start() error {
+	if err := e.Reload(); err != nil {
+		return err
+	}
+
+	e.wg.Add(1)
+	go e.run()
+
+	return nil
+}
+
+// Stop останавливает выполнение действий
+func (e *Engine) Stop() {
+	close(e.stop)
+	e.wg.Wait()
+}
+
+// Reload перечитывает правила из базы после изменения
+func (e *Engine) Reload() error {
+	stored, err := e.storage.GetEventRules()
+	if err != nil {
+		return fmt.Errorf("failed to load event rules: %w", err)
+	}
+
+	loaded := make([]rule, 0, len(stored))
+	for _, r := range stored {
+		if !r.Enabled {
+			continue
+		}
+
+		window, err := ai.ParseTimeWindow(r.StartTime, r.EndTime)
+		if err != nil {
+			log.Printf("Skipping event rule %d: %v", r.ID, err)
+			continue
+		}
+
+		loaded = append(loaded, rule{EventRule: r, window: window})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = loaded
+	for _, r := range loaded {
+		if r.LastTriggeredAt.After(e.lastTriggered[r.ID]) {
+			e.lastTriggered[r.ID] = r.LastTriggeredAt
+		}
+	}
+
+	return nil
+}
+
+// Evaluate проверяет правила для события и ставит действия подошедших правил в очередь
+func (e *Engine) Evaluate(event events.Event) {
+	mode := e.arming.Mode()
+
+	var triggered []storage.EventRule
+
+	e.mu.Lock()
+	for _, r := range e.rules {
+		if e.match(r, event, mode) != "" {
+			continue
+		}
+		if e.coolingDown(r, e.lastTriggered[r.ID], event.Timestamp) {
+			continue
+		}
+		e.lastTriggered[r.ID] = event.Timestamp
+		triggered = append(triggered, r.EventRule)
+	}
+	e.mu.Unlock()
+
+	for _, r := range triggered {
+		select {
+		case e.queue <- trigger{rule: r, event: event}:
+		default:
+			log.Printf("Event rule %d (%s): action queue is full, skipping %s event", r.ID, r.Name, event.Type)
+		}
+	}
+}
+
+// DryRun проверяет правило на прошлых событиях за интервал, не выполняя действий.
+// Режим охраны берется текущий, перерывы между срабатываниями считаются по времени событий.
+func (e *Engine) DryRun(stored storage.EventRule, from, to time.Time, limit int) (*DryRunResult, error) {
+	window, err := ai.ParseTimeWindow(stored.StartTime, stored.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	r := rule{EventRule: stored, window: window}
+
+	if limit <= 0 {
+		limit = defaultDryRunTop
+	}
+
+	past, err := e.storage.GetEventsBetween(from, to, maxDryRunEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]string, 0, len(stored.Actions))
+	for _, action := range stored.Actions {
+		actions = append(actions, action.Type)
+	}
+
+	result := &DryRunResult{
+		Skipped: make(map[string]int),
+		Matches: []DryRunMatch{},
+	}
+	mode := e.arming.Mode()
+	var last time.Time
+
+	for _, stored := range past {
+		event := fromStorage(stored)
+		result.Checked++
+
+		reason := e.match(r, event, mode)
+		if reason == "" && e.coolingDown(r, last, event.Timestamp) {
+			reason = reasonCooldown
+		}
+		if reason != "" {
+			result.Skipped[reason]++
+			continue
+		}
+
+		last = event.Timestamp
+		result.Matched++
+		if len(result.Matches) < limit {
+			result.Matches = append(result.Matches, DryRunMatch{Event: stored, Actions: actions})
+		}
+	}
+
+	return result, nil
+}
+
+// match проверяет триггер и условия правила; возвращает причину несовпадения или пустую строку
+func (e *Engine) match(r rule, event events.Event, mode string) string {
+	if len(r.EventTypes) > 0 && !contains(r.EventTypes, string(event.Type)) {
+		return reasonEventType
+	}
+	if len(r.CameraIDs) > 0 && !contains(r.CameraIDs, event.CameraID) {
+		return reasonCamera
+	}
+	if len(r.Classes) > 0 {
+		class, _ := event.Data["class"].(string)
+		if !contains(r.Classes, class) {
+			return reasonClass
+		}
+	}
+	if event.Confidence < r.MinConfidence {
+		return reasonConfidence
+	}
+	if !r.window.Contains(event.Timestamp) {
+		return reasonTime
+	}
+	if len(r.ArmingModes) > 0 && !contains(r.ArmingModes, mode) {
+		return reasonArming
+	}
+	if r.ZoneID != 0 && !e.inZone(r.ZoneID, event) {
+		return reasonZone
+	}
+	return ""
+}
+
+// coolingDown проверяет, что с прошлого срабатывания правила прошло меньше паузы
+func (e *Engine) coolingDown(r rule, last, now time.Time) bool {
+	return r.CooldownSeconds > 0 && !last.IsZero() &&
+		now.Sub(last) < time.Duration(r.CooldownSeconds)*time.Second
+}
+
+// inZone проверяет, что объект события стоит в зоне камеры (по середине нижней стороны рамки)
+func (e *Engine) inZone(zoneID int, event events.Event) bool {
+	var bbox ai.BBox
+	data, err := json.Marshal(event.Data["bbox"])
+	if err != nil || json.Unmarshal(data, &bbox) != nil || bbox.Width == 0 {
+		return false
+	}
+
+	width, height := dataInt(event.Data, "frame_width"), dataInt(event.Data, "frame_height")
+	if width == 0 || height == 0 {
+		return false
+	}
+
+	zone, err := e.storage.GetZone(zoneID)
+	if err != nil {
+		log.Printf("Event rule zone %d: %v", zoneID, err)
+		return false
+	}
+	if zone == nil || zone.CameraID != event.CameraID {
+		return false
+	}
+
+	points := make([]ai.Point, 0, len(zone.Points))
+	for _, point := range zone.Points {
+		points = append(points, ai.Point{X: point.X, Y: point.Y})
+	}
+	return ai.PointInPolygon(ai.FootPoint(bbox, width, height), points)
+}
+
+// run выполняет действия сработавших правил по очереди
+func (e *Engine) run() {
+	defer e.wg.Done()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case t := <-e.queue:
+			e.execute(t.rule, t.event)
+		}
+	}
+}
+
+// execute выполняет действия правила для события
+func (e *Engine) execute(r storage.EventRule, event events.Event) {
+	log.Printf("Event rule %d (%s) triggered by %s on camera %s", r.ID, r.Name, event.Type, event.CameraID)
+
+	if err := e.storage.SetEventRuleTriggered(r.ID, event.Timestamp); err != nil {
+		log.Printf("Event rule %d: %v", r.ID, err)
+	}
+
+	for _, action := range r.Actions {
+		if err := e.runAction(r, action, event); err != nil {
+			log.Printf("Event rule %d (%s): %s action failed: %v", r.ID, r.Name, action.Type, err)
+		}
+	}
+}
+
+// runAction выполняет одно действие правила
+func (e *Engine) runAction(r storage.EventRule, action storage.EventAction, event events.Event) error {
+	cameraID := action.CameraID
+	if cameraID == "" {
+		cameraID = event.CameraID
+	}
+
+	switch action.Type {
+	case storage.EventActionTelegram:
+		if e.notifier == nil {
+			return fmt.Errorf("telegram bot is not configured")
+		}
+		return e.notifier.Broadcast(formatMessage(action.Message, r, event), event.ThumbnailPath)
+
+	case storage.EventActionWebhook:
+		return e.webhooks.Send(action.WebhookID, event)
+
+	case storage.EventActionRecord:
+		_, err := e.cameras.StartRecording(cameraID)
+		return err
+
+	case storage.EventActionCameraSetting:
+		return e.cameras.SetCameraDetection(cameraID, action.Setting, action.Enabled)
+
+	case storage.EventActionPTZ:
+		return e.gotoPreset(cameraID, action)
+	}
+
+	return fmt.Errorf("unknown action type %s", action.Type)
+}
+
+// gotoPreset поворачивает камеру в пресет по ONVIF с адресом и учетными данными из ее RTSP URL
+func (e *Engine) gotoPreset(cameraID string, action storage.EventAction) error {
+	camera, err := e.storage.GetCamera(cameraID)
+	if err != nil {
+		return err
+	}
+	if camera == nil {
+		return fmt.Errorf("camera %s not found", cameraID)
+	}
+
+	client, err := onvif.New(camera.RTSPURL, action.OnvifPort)
+	if err != nil {
+		return err
+	}
+	return client.GotoPreset(action.Preset)
+}
+
+// Validate проверяет триггер, условия и действия правила
+func Validate(r *storage.EventRule) error {
+	for _, t := range r.EventTypes {
+		if !events.IsJournalType(events.EventType(t)) {
+			return fmt.Errorf("unknown event type: %s", t)
+		}
+	}
+
+	for _, mode := range r.ArmingModes {
+		if !arming.IsMode(mode) {
+			return fmt.Errorf("unknown arming mode: %s", mode)
+		}
+	}
+
+	if _, err := ai.ParseTimeWindow(r.StartTime, r.EndTime); err != nil {
+		return err
+	}
+	if r.CooldownSeconds < 0 {
+		return fmt.Errorf("cooldown_seconds must not be negative")
+	}
+	if r.MinConfidence < 0 || r.MinConfidence > 1 {
+		return fmt.Errorf("min_confidence must be between 0 and 1")
+	}
+
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+	for _, action := range r.Actions {
+		switch action.Type {
+		case storage.EventActionTelegram, storage.EventActionRecord:
+		case storage.EventActionWebhook:
+			if action.WebhookID == 0 {
+				return fmt.Errorf("webhook action requires webhook_id")
+			}
+		case storage.EventActionPTZ:
+			if strings.TrimSpace(action.Preset) == "" {
+				return fmt.Errorf("ptz action requires preset")
+			}
+			if action.OnvifPort < 0 || action.OnvifPort > 65535 {
+				return fmt.Errorf("invalid onvif_port %d", action.OnvifPort)
+			}
+		case storage.EventActionCameraSetting:
+			if action.Setting != SettingMotionDetection && action.Setting != SettingAIDetection {
+				return fmt.Errorf("camera_setting action requires setting %q or %q", SettingMotionDetection, SettingAIDetection)
+			}
+		default:
+			return fmt.Errorf("unknown action type: %s", action.Type)
+		}
+	}
+
+	return nil
+}
+
+// formatMessage подставляет данные события в текст уведомления
+func formatMessage(message string, r storage.EventRule, event events.Event) string {
+	if message == "" {
+		message = "🔔 {rule}: {description} ({camera})"
+	}
+
+	camera := event.CameraName
+	if camera == "" {
+		camera = event.CameraID
+	}
+	class, _ := event.Data["class"].(string)
+
+	return strings.NewReplacer(
+		"{rule}", r.Name,
+		"{camera}", camera,
+		"{type}", string(event.Type),
+		"{class}", class,
+		"{description}", event.Description,
+	).Replace(message)
+}
+
+// fromStorage переводит сохраненное событие в событие менеджера для проверки правил
+func fromStorage(stored storage.Event) events.Event {
+	return events.Event{
+		ID:            stored.ID,
+		Type:          events.EventType(stored.Type),
+		CameraID:      stored.CameraID,
+		CameraName:    stored.CameraName,
+		Description:   stored.Description,
+		Confidence:    stored.Confidence,
+		VideoPath:     stored.VideoPath,
+		ThumbnailPath: stored.ThumbnailPath,
+		Timestamp:     stored.CreatedAt.Local(),
+		Data:          stored.Data,
+		Track:         stored.Track,
+	}
+}
+
+// dataInt возвращает целое значение из данных события (после JSON это float64)
+func dataInt(data map[string]interface{}, key string) int {
+	switch value := data[key].(type) {
+	case int:
+		return value
+	case float64:
+		return int(value)
+	}
+	return 0
+}
+
+// contains проверяет, есть ли значение в списке
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Типы аналитики камеры
+const (
+	AnalyticsTypeTripwire  = "tripwire"  // линия, пересечение которой считается
+	AnalyticsTypeLoitering = "loitering" // область, в которой объект не должен задерживаться
+)
+
+// Направления пересечения линии, если смотреть из первой точки во вторую
+const (
+	DirectionAny         = "any"
+	DirectionLeftToRight = "left_to_right"
+	DirectionRightToLeft = "right_to_left"
+)
+
+// AnalyticsZone линия пересечения или область задержки объектов на камере
+type AnalyticsZone struct {
+	ID         int         `json:"id"`
+	CameraID   string      `json:"camera_id"`
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`      // tripwire, loitering
+	Points     []ZonePoint `json:"points"`    // 2 точки линии или полигон области
+	Direction  string      `json:"direction"` // для линии: any, left_to_right, right_to_left
+	Classes    []string    `json:"classes"`   // пусто - любые объекты
+	MinSeconds float64     `json:"min_seconds"`
+	Enabled    bool        `json:"enabled"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// analyticsColumns колонки аналитики в порядке scanAnalyticsZone
+const analyticsColumns = `id, camera_id, name, type, points, direction, classes, min_seconds, enabled, created_at, updated_at`
+
+// GetAnalyticsZones возвращает линии и области аналитики камеры
+func (s *Storage) GetAnalyticsZones(cameraID string) ([]AnalyticsZone, error) {
+	query := `SELECT ` + analyticsColumns + ` FROM camera_analytics WHERE camera_id = ? ORDER BY id`
+
+	rows, err := s.db.Query(query, cameraID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query analytics zones: %w", err)
+	}
+	defer rows.Close()
+
+	var zones []AnalyticsZone
+	for rows.Next() {
+		zone, err := scanAnalyticsZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, *zone)
+	}
+
+	return zones, rows.Err()
+}
+
+// GetAnalyticsZone возвращает линию или область аналитики по ID
+func (s *Storage) GetAnalyticsZone(id int) (*AnalyticsZone, error) {
+	query := `SELECT ` + analyticsColumns + ` FROM camera_analytics WHERE id = ?`
+
+	zone, err := scanAnalyticsZone(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return zone, nil
+}
+
+// SaveAnalyticsZone создает линию или область аналитики или обновляет существующую
+func (s *Storage) SaveAnalyticsZone(zone *AnalyticsZone) error {
+	points, err := json.Marshal(zone.Points)
+	if err != nil {
+		return fmt.Errorf("failed to marshal analytics points: %w", err)
+	}
+
+	classes := zone.Classes
+	if classes == nil {
+		classes = []string{}
+	}
+	classesJSON, err := json.Marshal(classes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal analytics classes: %w", err)
+	}
+
+	if zone.ID == 0 {
+		query := `INSERT INTO camera_analytics (camera_id, name, type, points, direction, classes, min_seconds, enabled)
+				  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+		result, err := s.db.Exec(query, zone.CameraID, zone.Name, zone.Type, string(points), zone.Direction,
+			string(classesJSON), zone.MinSeconds, zone.Enabled)
+		if err != nil {
+			return fmt.Errorf("failed to create analytics zone: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get analytics zone ID: %w", err)
+		}
+
+		zone.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE camera_analytics SET name = ?, type = ?, points = ?, direction = ?, classes = ?, min_seconds = ?,
+			  enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+
+	_, err = s.db.Exec(query, zone.Name, zone.Type, string(points), zone.Direction, string(classesJSON),
+		zone.MinSeconds, zone.Enabled, zone.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update analytics zone: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAnalyticsZone удаляет линию или область аналитики
+func (s *Storage) DeleteAnalyticsZone(id int) error {
+	_, err := s.db.Exec("DELETE FROM camera_analytics WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete analytics zone: %w", err)
+	}
+	return nil
+}
+
+// scanAnalyticsZone читает линию или область аналитики из строки результата
+func scanAnalyticsZone(row interface{ Scan(...interface{}) error }) (*AnalyticsZone, error) {
+	var zone AnalyticsZone
+	var points, classes string
+
+	err := row.Scan(&zone.ID, &zone.CameraID, &zone.Name, &zone.Type, &points, &zone.Direction, &classes,
+		&zone.MinSeconds, &zone.Enabled, &zone.CreatedAt, &zone.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan analytics zone: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(points), &zone.Points); err != nil {
+		return nil, fmt.Errorf("failed to parse analytics points: %w", err)
+	}
+	if err := json.Unmarshal([]byte(classes), &zone.Classes); err != nil {
+		return nil, fmt.Errorf("failed to parse analytics classes: %w", err)
+	}
+
+	return &zone, nil
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AllCameras значение списка камер профиля охраны, означающее все камеры
+const AllCameras = "*"
+
+// ArmingProfile что делают камеры в режиме охраны: списки камер, которые
+// отправляют уведомления, записывают клипы и запускают AI
+type ArmingProfile struct {
+	Mode          string    `json:"mode"`
+	NotifyCameras []string  `json:"notify_cameras"` // ["*"] - все камеры, пусто - ни одна
+	RecordCameras []string  `json:"record_cameras"`
+	AICameras     []string  `json:"ai_cameras"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ArmingSchedule переключение режима охраны по расписанию
+type ArmingSchedule struct {
+	ID        int       `json:"id"`
+	Mode      string    `json:"mode"`
+	Days      []string  `json:"days"` // sun, mon, ... sat; пусто - каждый день
+	Time      string    `json:"time"` // ЧЧ:ММ
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventTypeArmingChanged тип события смены режима охраны в ленте: лента - единственный журнал смен
+const EventTypeArmingChanged = "arming_changed"
+
+// ArmingChange смена режима охраны, прочитанная из события ленты
+type ArmingChange struct {
+	ID        int       `json:"id"`
+	Mode      string    `json:"mode"`
+	Previous  string    `json:"previous"`
+	Source    string    `json:"source"` // api, telegram, schedule
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetArmingProfiles возвращает профили всех режимов охраны
+func (s *Storage) GetArmingProfiles() ([]ArmingProfile, error) {
+	rows, err := s.db.Query(`SELECT mode, notify_cameras, record_cameras, ai_cameras, updated_at FROM arming_profiles`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query arming profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []ArmingProfile
+	for rows.Next() {
+		var profile ArmingProfile
+		var notify, record, ai sql.NullString
+		if err := rows.Scan(&profile.Mode, &notify, &record, &ai, &profile.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan arming profile: %w", err)
+		}
+		profile.NotifyCameras = splitList(notify.String)
+		profile.RecordCameras = splitList(record.String)
+		profile.AICameras = splitList(ai.String)
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+// SaveArmingProfile сохраняет профиль режима охраны
+func (s *Storage) SaveArmingProfile(profile *ArmingProfile) error {
+	query := `INSERT OR REPLACE INTO arming_profiles (mode, notify_cameras, record_cameras, ai_cameras, updated_at)
+			  VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`
+
+	_, err := s.db.Exec(query, profile.Mode, strings.Join(profile.NotifyCameras, ","),
+		strings.Join(profile.RecordCameras, ","), strings.Join(profile.AICameras, ","))
+	if err != nil {
+		return fmt.Errorf("failed to save arming profile: %w", err)
+	}
+	return nil
+}
+
+// armingScheduleColumns колонки расписания охраны в порядке scanArmingSchedule
+const armingScheduleColumns = `id, mode, days, time, enabled, created_at, updated_at`
+
+// GetArmingSchedules возвращает расписание переключения режимов охраны
+func (s *Storage) GetArmingSchedules() ([]ArmingSchedule, error) {
+	rows, err := s.db.Query(`SELECT ` + armingScheduleColumns + ` FROM arming_schedules ORDER BY time, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query arming schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []ArmingSchedule
+	for rows.Next() {
+		schedule, err := scanArmingSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// GetArmingSchedule возвращает запись расписания охраны по ID
+func (s *Storage) GetArmingSchedule(id int) (*ArmingSchedule, error) {
+	schedule, err := scanArmingSchedule(s.db.QueryRow(`SELECT `+armingScheduleColumns+` FROM arming_schedules WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// SaveArmingSchedule создает запись расписания охраны или обновляет существующую
+func (s *Storage) SaveArmingSchedule(schedule *ArmingSchedule) error {
+	days := strings.Join(schedule.Days, ",")
+
+	if schedule.ID == 0 {
+		query := `INSERT INTO arming_schedules (mode, days, time, enabled) VALUES (?, ?, ?, ?)`
+
+		result, err := s.db.Exec(query, schedule.Mode, days, schedule.Time, schedule.Enabled)
+		if err != nil {
+			return fmt.Errorf("failed to create arming schedule: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get arming schedule ID: %w", err)
+		}
+
+		schedule.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE arming_schedules SET mode = ?, days = ?, time = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = ?`
+
+	if _, err := s.db.Exec(query, schedule.Mode, days, schedule.Time, schedule.Enabled, schedule.ID); err != nil {
+		return fmt.Errorf("failed to update arming schedule: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteArmingSchedule удаляет запись расписания охраны
+func (s *Storage) DeleteArmingSchedule(id int) error {
+	if _, err := s.db.Exec("DELETE FROM arming_schedules WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete arming schedule: %w", err)
+	}
+	return nil
+}
+
+// scanArmingSchedule читает запись расписания охраны из строки результата
+func scanArmingSchedule(row interface{ Scan(...interface{}) error }) (*ArmingSchedule, error) {
+	var schedule ArmingSchedule
+	var days sql.NullString
+
+	err := row.Scan(&schedule.ID, &schedule.Mode, &days, &schedule.Time, &schedule.Enabled,
+		&schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan arming schedule: %w", err)
+	}
+
+	schedule.Days = splitList(days.String)
+	return &schedule, nil
+}
+
+// GetArmingChanges возвращает смены режимов охраны из ленты событий, новые первыми
+func (s *Storage) GetArmingChanges(limit, offset int) ([]ArmingChange, error) {
+	query := `SELECT id, data, created_at FROM events WHERE type = ? AND camera_id IS NULL
+			  ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+
+	rows, err := s.db.Query(query, EventTypeArmingChanged, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query arming changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []ArmingChange
+	for rows.Next() {
+		var change ArmingChange
+		var data sql.NullString
+		if err := rows.Scan(&change.ID, &data, &change.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan arming change: %w", err)
+		}
+		if data.Valid && data.String != "" {
+			if err := json.Unmarshal([]byte(data.String), &change); err != nil {
+				return nil, fmt.Errorf("failed to parse arming change: %w", err)
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
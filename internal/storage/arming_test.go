@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetArmingChangesReadsEventFeed(t *testing.T) {
+	s := newStorage(t)
+
+	now := time.Now()
+	saved := []*Event{
+		{Type: EventTypeArmingChanged, Description: "Arming mode changed to away (api)", CreatedAt: now.Add(-2 * time.Minute),
+			Data: map[string]interface{}{"mode": "away", "previous": "home", "source": "api"}},
+		{Type: "system_log", Description: "started", CreatedAt: now.Add(-time.Minute)},
+		{Type: EventTypeArmingChanged, Description: "Arming mode changed to night (schedule)", CreatedAt: now,
+			Data: map[string]interface{}{"mode": "night", "previous": "away", "source": "schedule"}},
+	}
+	for _, event := range saved {
+		if err := s.SaveEvent(event); err != nil {
+			t.Fatalf("SaveEvent: %v", err)
+		}
+	}
+
+	changes, err := s.GetArmingChanges(10, 0)
+	if err != nil {
+		t.Fatalf("GetArmingChanges: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("changes = %+v, want 2", changes)
+	}
+
+	latest := changes[0]
+	if latest.ID != saved[2].ID || latest.Mode != "night" || latest.Previous != "away" || latest.Source != "schedule" {
+		t.Errorf("latest change = %+v", latest)
+	}
+	if changes[1].Mode != "away" || changes[1].Source != "api" {
+		t.Errorf("first change = %+v", changes[1])
+	}
+
+	if changes, _ := s.GetArmingChanges(1, 1); len(changes) != 1 || changes[0].Mode != "away" {
+		t.Errorf("second page = %+v", changes)
+	}
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Виды счетчиков
+const (
+	CountKindDetection    = "detection"     // появление объекта (AI детекция)
+	CountKindLineCrossing = "line_crossing" // пересечение линии
+)
+
+// Интервалы агрегации счетчиков
+const (
+	CountBucketHour = "hour"
+	CountBucketDay  = "day"
+)
+
+// CountBucket значение счетчика за интервал
+type CountBucket struct {
+	Start     time.Time `json:"start"`
+	CameraID  string    `json:"camera_id"`
+	Class     string    `json:"class"`
+	Kind      string    `json:"kind"`
+	Direction string    `json:"direction,omitempty"`
+	Count     int       `json:"count"`
+}
+
+// CountFilter параметры выборки счетчиков; пустые поля не фильтруют
+type CountFilter struct {
+	CameraID string
+	Class    string
+	Kind     string
+	From     time.Time
+	To       time.Time
+	Bucket   string // hour, day
+}
+
+// IncrementCount увеличивает часовой счетчик камеры и класса
+func (s *Storage) IncrementCount(cameraID, class, kind, direction string, at time.Time, delta int) error {
+	query := `INSERT INTO counts (camera_id, class, kind, direction, bucket_start, count)
+			  VALUES (?, ?, ?, ?, ?, ?)
+			  ON CONFLICT(camera_id, class, kind, direction, bucket_start) DO UPDATE SET count = count + excluded.count`
+
+	_, err := s.db.Exec(query, cameraID, class, kind, direction, at.Truncate(time.Hour).UTC(), delta)
+	if err != nil {
+		return fmt.Errorf("failed to increment count: %w", err)
+	}
+	return nil
+}
+
+// GetCounts возвращает счетчики за интервал, сгруппированные по часам или дням.
+// Дни считаются по локальному времени сервера.
+func (s *Storage) GetCounts(filter CountFilter) ([]CountBucket, error) {
+	conditions := []string{"bucket_start >= ?", "bucket_start < ?"}
+	args := []interface{}{filter.From.Truncate(time.Hour).UTC(), filter.To.UTC()}
+
+	if filter.CameraID != "" {
+		conditions = append(conditions, "camera_id = ?")
+		args = append(args, filter.CameraID)
+	}
+	if filter.Class != "" {
+		conditions = append(conditions, "class = ?")
+		args = append(args, filter.Class)
+	}
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, filter.Kind)
+	}
+
+	query := `SELECT bucket_start, camera_id, class, kind, direction, count FROM counts
+			  WHERE ` + strings.Join(conditions, " AND ") + `
+			  ORDER BY bucket_start, camera_id, class, kind, direction`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query counts: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []CountBucket
+	index := make(map[CountBucket]int) // ключ без Count -> позиция в buckets
+
+	for rows.Next() {
+		var bucket CountBucket
+		if err := rows.Scan(&bucket.Start, &bucket.CameraID, &bucket.Class, &bucket.Kind, &bucket.Direction, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan count: %w", err)
+		}
+
+		bucket.Start = bucket.Start.Local()
+		if filter.Bucket == CountBucketDay {
+			year, month, day := bucket.Start.Date()
+			bucket.Start = time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+		}
+
+		key := bucket
+		key.Count = 0
+		if i, exists := index[key]; exists {
+			buckets[i].Count += bucket.Count
+			continue
+		}
+
+		index[key] = len(buckets)
+		buckets = append(buckets, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// После слияния часов в дни восстанавливаем порядок внутри дня
+	sort.SliceStable(buckets, func(i, j int) bool {
+		a, b := buckets[i], buckets[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		if a.CameraID != b.CameraID {
+			return a.CameraID < b.CameraID
+		}
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Direction < b.Direction
+	})
+
+	return buckets, nil
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SettingArmingMode ключ настройки с текущим режимом охраны
+const SettingArmingMode = "arming_mode"
+
+// Действия правил событий
+const (
+	EventActionTelegram      = "telegram"       // уведомление в Telegram
+	EventActionWebhook       = "webhook"        // отправка события на вебхук
+	EventActionRecord        = "record"         // запись клипа камеры
+	EventActionPTZ           = "ptz"            // поворот камеры в пресет по ONVIF
+	EventActionCameraSetting = "camera_setting" // включение или выключение детекции камеры
+)
+
+// EventRule пользовательское правило: какие события (триггер) при каких условиях
+// запускают действия
+type EventRule struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+
+	// Триггер; пустой список - любое значение
+	EventTypes []string `json:"event_types"`
+	CameraIDs  []string `json:"camera_ids"`
+	Classes    []string `json:"classes"`
+	ZoneID     int      `json:"zone_id,omitempty"` // зона камеры, в которой находится объект; 0 - весь кадр
+
+	// Условия
+	StartTime       string   `json:"start_time"`   // ЧЧ:ММ, пусто - круглосуточно
+	EndTime         string   `json:"end_time"`     // ЧЧ:ММ, может быть раньше начала (через полночь)
+	ArmingModes     []string `json:"arming_modes"` // режимы охраны, в которых работает правило; пусто - любой
+	CooldownSeconds int      `json:"cooldown_seconds"`
+	MinConfidence   float32  `json:"min_confidence"`
+
+	Actions []EventAction `json:"actions"`
+
+	LastTriggeredAt time.Time `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// EventAction действие правила
+type EventAction struct {
+	Type      string `json:"type"`                 // telegram, webhook, record, ptz, camera_setting
+	Message   string `json:"message,omitempty"`    // telegram: текст с {rule}, {camera}, {type}, {class}, {description}; пусто - правило и описание события
+	WebhookID int    `json:"webhook_id,omitempty"` // webhook
+	CameraID  string `json:"camera_id,omitempty"`  // record, ptz, camera_setting: пусто - камера события
+	Preset    string `json:"preset,omitempty"`     // ptz: токен или имя пресета
+	OnvifPort int    `json:"onvif_port,omitempty"` // ptz: порт ONVIF камеры, 0 - 80
+	Setting   string `json:"setting,omitempty"`    // camera_setting: motion_detection, ai_detection
+	Enabled   bool   `json:"enabled,omitempty"`    // camera_setting: новое значение
+}
+
+// eventRuleColumns колонки правил событий в порядке scanEventRule
+const eventRuleColumns = `id, name, event_types, camera_ids, classes, zone_id, start_time, end_time, arming_modes,
+	cooldown_seconds, min_confidence, actions, enabled, last_triggered_at, created_at, updated_at`
+
+// GetEventRules возвращает все правила событий
+func (s *Storage) GetEventRules() ([]EventRule, error) {
+	rows, err := s.db.Query(`SELECT ` + eventRuleColumns + ` FROM event_rules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []EventRule
+	for rows.Next() {
+		rule, err := scanEventRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// GetEventRule возвращает правило событий по ID
+func (s *Storage) GetEventRule(id int) (*EventRule, error) {
+	rule, err := scanEventRule(s.db.QueryRow(`SELECT `+eventRuleColumns+` FROM event_rules WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// SaveEventRule создает правило событий или обновляет существующее
+func (s *Storage) SaveEventRule(rule *EventRule) error {
+	actions, err := json.Marshal(rule.Actions)
+	if err != nil {
+		return fmt.Errorf("failed to encode rule actions: %w", err)
+	}
+
+	// Правило без зоны хранит NULL, чтобы не нарушать внешний ключ
+	var zoneID interface{}
+	if rule.ZoneID != 0 {
+		zoneID = rule.ZoneID
+	}
+
+	args := []interface{}{rule.Name, strings.Join(rule.EventTypes, ","), strings.Join(rule.CameraIDs, ","),
+		strings.Join(rule.Classes, ","), zoneID, rule.StartTime, rule.EndTime, strings.Join(rule.ArmingModes, ","),
+		rule.CooldownSeconds, rule.MinConfidence, string(actions), rule.Enabled}
+
+	if rule.ID == 0 {
+		query := `INSERT INTO event_rules (name, event_types, camera_ids, classes, zone_id, start_time, end_time,
+				  arming_modes, cooldown_seconds, min_confidence, actions, enabled)
+				  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+		result, err := s.db.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to create event rule: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get event rule ID: %w", err)
+		}
+
+		rule.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE event_rules SET name = ?, event_types = ?, camera_ids = ?, classes = ?, zone_id = ?, start_time = ?,
+			  end_time = ?, arming_modes = ?, cooldown_seconds = ?, min_confidence = ?, actions = ?, enabled = ?,
+			  updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+
+	if _, err := s.db.Exec(query, append(args, rule.ID)...); err != nil {
+		return fmt.Errorf("failed to update event rule: %w", err)
+	}
+
+	return nil
+}
+
+// SetEventRuleTriggered сохраняет время последнего срабатывания правила
+func (s *Storage) SetEventRuleTriggered(id int, at time.Time) error {
+	if _, err := s.db.Exec(`UPDATE event_rules SET last_triggered_at = ? WHERE id = ?`, at.UTC(), id); err != nil {
+		return fmt.Errorf("failed to update event rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteEventRule удаляет правило событий
+func (s *Storage) DeleteEventRule(id int) error {
+	if _, err := s.db.Exec("DELETE FROM event_rules WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete event rule: %w", err)
+	}
+	return nil
+}
+
+// scanEventRule читает правило событий из строки результата
+func scanEventRule(row interface{ Scan(...interface{}) error }) (*EventRule, error) {
+	var rule EventRule
+	var eventTypes, cameraIDs, classes, armingModes sql.NullString
+	var zoneID sql.NullInt64
+	var actions string
+	var lastTriggeredAt sql.NullTime
+
+	err := row.Scan(&rule.ID, &rule.Name, &eventTypes, &cameraIDs, &classes, &zoneID, &rule.StartTime, &rule.EndTime,
+		&armingModes, &rule.CooldownSeconds, &rule.MinConfidence, &actions, &rule.Enabled, &lastTriggeredAt,
+		&rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan event rule: %w", err)
+	}
+
+	rule.EventTypes = splitList(eventTypes.String)
+	rule.CameraIDs = splitList(cameraIDs.String)
+	rule.Classes = splitList(classes.String)
+	rule.ArmingModes = splitList(armingModes.String)
+	rule.ZoneID = int(zoneID.Int64)
+	rule.LastTriggeredAt = lastTriggeredAt.Time
+
+	if err := json.Unmarshal([]byte(actions), &rule.Actions); err != nil {
+		return nil, fmt.Errorf("failed to decode rule actions: %w", err)
+	}
+
+	return &rule, nil
+}
+
+// splitList разбирает список, сохраненный через запятую
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
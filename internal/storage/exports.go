@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Статусы экспорта
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+// Export представляет задание на экспорт видео
+type Export struct {
+	ID         string    `json:"id"`
+	CameraIDs  []string  `json:"camera_ids"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Status     string    `json:"status"` // pending, running, completed, failed
+	Progress   float64   `json:"progress"`
+	FilePath   string    `json:"-"`
+	SHA256     string    `json:"sha256,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// exportColumns список колонок, читаемых scanExports
+const exportColumns = `id, camera_ids, start_time, end_time, status, progress, file_path, sha256, error, created_at, finished_at`
+
+// CreateExport сохраняет новое задание на экспорт
+func (s *Storage) CreateExport(export *Export) error {
+	query := `INSERT INTO exports (id, camera_ids, start_time, end_time, status) VALUES (?, ?, ?, ?, ?)`
+
+	_, err := s.db.Exec(query, export.ID, strings.Join(export.CameraIDs, ","),
+		export.From.UTC(), export.To.UTC(), export.Status)
+	if err != nil {
+		return fmt.Errorf("failed to create export: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateExport сохраняет состояние задания на экспорт
+func (s *Storage) UpdateExport(export *Export) error {
+	var finishedAt interface{}
+	if !export.FinishedAt.IsZero() {
+		finishedAt = export.FinishedAt.UTC()
+	}
+
+	query := `UPDATE exports SET status = ?, progress = ?, file_path = ?, sha256 = ?, error = ?, finished_at = ?
+			  WHERE id = ?`
+
+	_, err := s.db.Exec(query, export.Status, export.Progress, export.FilePath, export.SHA256,
+		export.Error, finishedAt, export.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update export: %w", err)
+	}
+
+	return nil
+}
+
+// GetExport возвращает задание на экспорт по ID
+func (s *Storage) GetExport(id string) (*Export, error) {
+	rows, err := s.db.Query(`SELECT `+exportColumns+` FROM exports WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export: %w", err)
+	}
+	defer rows.Close()
+
+	exports, err := scanExports(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(exports) == 0 {
+		return nil, nil
+	}
+
+	return &exports[0], nil
+}
+
+// GetExports возвращает последние задания на экспорт
+func (s *Storage) GetExports(limit int) ([]Export, error) {
+	rows, err := s.db.Query(`SELECT `+exportColumns+` FROM exports ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exports: %w", err)
+	}
+	defer rows.Close()
+
+	return scanExports(rows)
+}
+
+// GetExpiredExports возвращает завершенные задания на экспорт, закончившиеся до before
+func (s *Storage) GetExpiredExports(before time.Time, limit int) ([]Export, error) {
+	query := `SELECT ` + exportColumns + ` FROM exports
+			  WHERE status IN (?, ?) AND finished_at < ?
+			  ORDER BY finished_at LIMIT ?`
+
+	rows, err := s.db.Query(query, ExportStatusCompleted, ExportStatusFailed, before.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired exports: %w", err)
+	}
+	defer rows.Close()
+
+	return scanExports(rows)
+}
+
+// DeleteExport удаляет задание на экспорт
+func (s *Storage) DeleteExport(id string) error {
+	if _, err := s.db.Exec("DELETE FROM exports WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete export: %w", err)
+	}
+	return nil
+}
+
+// FailInterruptedExports помечает незавершенные задания как прерванные
+func (s *Storage) FailInterruptedExports() error {
+	query := `UPDATE exports SET status = ?, error = 'interrupted by restart', finished_at = CURRENT_TIMESTAMP
+			  WHERE status IN (?, ?)`
+
+	_, err := s.db.Exec(query, ExportStatusFailed, ExportStatusPending, ExportStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to update interrupted exports: %w", err)
+	}
+
+	return nil
+}
+
+// scanExports читает задания на экспорт из результата запроса
+func scanExports(rows *sql.Rows) ([]Export, error) {
+	var exports []Export
+	for rows.Next() {
+		var export Export
+		var cameraIDs string
+		var filePath, sha, errText sql.NullString
+		var finishedAt sql.NullTime
+
+		err := rows.Scan(&export.ID, &cameraIDs, &export.From, &export.To, &export.Status,
+			&export.Progress, &filePath, &sha, &errText, &export.CreatedAt, &finishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan export: %w", err)
+		}
+
+		if cameraIDs != "" {
+			export.CameraIDs = strings.Split(cameraIDs, ",")
+		}
+		export.FilePath = filePath.String
+		export.SHA256 = sha.String
+		export.Error = errText.String
+		if finishedAt.Valid {
+			export.FinishedAt = finishedAt.Time
+		}
+
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
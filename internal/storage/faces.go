@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Person известный человек из галереи лиц
+type Person struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Tags       []string  `json:"tags"`
+	PhotoCount int       `json:"photo_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// FacePhoto эталонное фото лица человека
+type FacePhoto struct {
+	ID         int       `json:"id"`
+	PersonID   int       `json:"person_id"`
+	PersonName string    `json:"-"`
+	ImagePath  string    `json:"-"`
+	Embedding  []float32 `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// GetPeople возвращает людей из галереи с количеством фото
+func (s *Storage) GetPeople() ([]Person, error) {
+	query := `SELECT p.id, p.name, p.tags, p.created_at, p.updated_at,
+			  (SELECT COUNT(*) FROM face_photos f WHERE f.person_id = p.id)
+			  FROM people p ORDER BY p.name`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query people: %w", err)
+	}
+	defer rows.Close()
+
+	var people []Person
+	for rows.Next() {
+		person, err := scanPerson(rows)
+		if err != nil {
+			return nil, err
+		}
+		people = append(people, *person)
+	}
+
+	return people, rows.Err()
+}
+
+// GetPerson возвращает человека по ID
+func (s *Storage) GetPerson(id int) (*Person, error) {
+	query := `SELECT p.id, p.name, p.tags, p.created_at, p.updated_at,
+			  (SELECT COUNT(*) FROM face_photos f WHERE f.person_id = p.id)
+			  FROM people p WHERE p.id = ?`
+
+	person, err := scanPerson(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return person, nil
+}
+
+// SavePerson создает человека или обновляет существующего
+func (s *Storage) SavePerson(person *Person) error {
+	tags := person.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal person tags: %w", err)
+	}
+
+	if person.ID == 0 {
+		result, err := s.db.Exec(`INSERT INTO people (name, tags) VALUES (?, ?)`, person.Name, string(tagsJSON))
+		if err != nil {
+			return fmt.Errorf("failed to create person: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get person ID: %w", err)
+		}
+
+		person.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE people SET name = ?, tags = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := s.db.Exec(query, person.Name, string(tagsJSON), person.ID); err != nil {
+		return fmt.Errorf("failed to update person: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePerson удаляет человека вместе с его фото
+func (s *Storage) DeletePerson(id int) error {
+	_, err := s.db.Exec("DELETE FROM people WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete person: %w", err)
+	}
+	return nil
+}
+
+// AddFacePhoto сохраняет эталонное фото лица
+func (s *Storage) AddFacePhoto(photo *FacePhoto) error {
+	embedding, err := json.Marshal(photo.Embedding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal face embedding: %w", err)
+	}
+
+	query := `INSERT INTO face_photos (person_id, image_path, embedding) VALUES (?, ?, ?)`
+
+	result, err := s.db.Exec(query, photo.PersonID, photo.ImagePath, string(embedding))
+	if err != nil {
+		return fmt.Errorf("failed to add face photo: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get face photo ID: %w", err)
+	}
+
+	photo.ID = int(id)
+	photo.CreatedAt = time.Now()
+	return nil
+}
+
+// GetFacePhotos возвращает эталонные фото человека
+func (s *Storage) GetFacePhotos(personID int) ([]FacePhoto, error) {
+	return s.queryFacePhotos(`WHERE f.person_id = ? ORDER BY f.id`, personID)
+}
+
+// GetFacePhoto возвращает эталонное фото по ID
+func (s *Storage) GetFacePhoto(id int) (*FacePhoto, error) {
+	photos, err := s.queryFacePhotos(`WHERE f.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(photos) == 0 {
+		return nil, nil
+	}
+	return &photos[0], nil
+}
+
+// GetFaceGallery возвращает все эталонные фото с именами людей
+func (s *Storage) GetFaceGallery() ([]FacePhoto, error) {
+	return s.queryFacePhotos(`ORDER BY f.person_id, f.id`)
+}
+
+// DeleteFacePhoto удаляет эталонное фото
+func (s *Storage) DeleteFacePhoto(id int) error {
+	_, err := s.db.Exec("DELETE FROM face_photos WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete face photo: %w", err)
+	}
+	return nil
+}
+
+// queryFacePhotos выбирает эталонные фото по условию
+func (s *Storage) queryFacePhotos(condition string, args ...interface{}) ([]FacePhoto, error) {
+	query := `SELECT f.id, f.person_id, p.name, f.image_path, f.embedding, f.created_at
+			  FROM face_photos f JOIN people p ON p.id = f.person_id ` + condition
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query face photos: %w", err)
+	}
+	defer rows.Close()
+
+	var photos []FacePhoto
+	for rows.Next() {
+		var photo FacePhoto
+		var embedding string
+
+		if err := rows.Scan(&photo.ID, &photo.PersonID, &photo.PersonName, &photo.ImagePath, &embedding, &photo.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan face photo: %w", err)
+		}
+		if err := json.Unmarshal([]byte(embedding), &photo.Embedding); err != nil {
+			return nil, fmt.Errorf("failed to parse face embedding: %w", err)
+		}
+
+		photos = append(photos, photo)
+	}
+
+	return photos, rows.Err()
+}
+
+// scanPerson читает человека из строки результата
+func scanPerson(row interface{ Scan(...interface{}) error }) (*Person, error) {
+	var person Person
+	var tags string
+
+	err := row.Scan(&person.ID, &person.Name, &tags, &person.CreatedAt, &person.UpdatedAt, &person.PhotoCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan person: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(tags), &person.Tags); err != nil {
+		return nil, fmt.Errorf("failed to parse person tags: %w", err)
+	}
+
+	return &person, nil
+}
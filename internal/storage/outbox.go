@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OutboxEntry запись журнала событий, из которого подписчики читают события по своим курсорам
+type OutboxEntry struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	EventID   int       `json:"event_id,omitempty"` // сохраненное событие; 0 - событие не сохраняется в ленту
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EventCursor позиция подписчика в журнале событий
+type EventCursor struct {
+	Subscriber string    `json:"subscriber"`
+	Position   int64     `json:"position"` // ID последней доставленной записи журнала
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// FailedDelivery событие, которое не удалось доставить подписчику после всех попыток
+type FailedDelivery struct {
+	ID         int       `json:"id"`
+	Subscriber string    `json:"subscriber"`
+	OutboxID   int64     `json:"outbox_id"`
+	Type       string    `json:"type"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// failedDeliveryColumns список колонок, читаемых scanFailedDelivery
+const failedDeliveryColumns = `id, subscriber, outbox_id, type, attempts, error, created_at, updated_at`
+
+// AppendOutbox добавляет запись в журнал событий. Если передано событие, оно сохраняется
+// в ленту в той же транзакции, и запись журнала ссылается на него.
+func (s *Storage) AppendOutbox(entry *OutboxEntry, event *Event) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var eventID interface{}
+	if event != nil {
+		if err := insertEvent(tx, event); err != nil {
+			return err
+		}
+		eventID = event.ID
+	}
+
+	result, err := tx.Exec(`INSERT INTO event_outbox (type, event_id, payload) VALUES (?, ?, ?)`,
+		entry.Type, eventID, entry.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to append to outbox: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get outbox id: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit outbox transaction: %w", err)
+	}
+
+	entry.ID = id
+	entry.EventID = 0
+	if event != nil {
+		entry.EventID = event.ID
+	}
+	return nil
+}
+
+// GetOutbox возвращает записи журнала с ID в интервале (after, until] нужных типов по порядку.
+// Пустой список типов - записи всех типов.
+func (s *Storage) GetOutbox(after, until int64, types []string, limit int) ([]OutboxEntry, error) {
+	query := `SELECT id, type, event_id, payload, created_at FROM event_outbox WHERE id > ? AND id <= ?`
+	args := []interface{}{after, until}
+	query, args = filterTypes(query, args, types)
+	query += ` ORDER BY id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var entry OutboxEntry
+		var eventID sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.Type, &eventID, &entry.Payload, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		entry.EventID = int(eventID.Int64)
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetOutboxEntry возвращает запись журнала по ID
+func (s *Storage) GetOutboxEntry(id int64) (*OutboxEntry, error) {
+	entries, err := s.GetOutbox(id-1, id, nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+// GetLatestOutboxID возвращает ID последней записи журнала
+func (s *Storage) GetLatestOutboxID() (int64, error) {
+	var id sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MAX(id) FROM event_outbox`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to get latest outbox id: %w", err)
+	}
+	return id.Int64, nil
+}
+
+// CountOutbox возвращает количество записей нужных типов после позиции
+func (s *Storage) CountOutbox(after int64, types []string) (int, error) {
+	query, args := filterTypes(`SELECT COUNT(*) FROM event_outbox WHERE id > ?`, []interface{}{after}, types)
+
+	var count int
+	if err := s.db.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count outbox entries: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteOutboxBefore удаляет записи журнала старше указанного времени с ID не больше maxID
+func (s *Storage) DeleteOutboxBefore(before time.Time, maxID int64) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM event_outbox WHERE created_at < ? AND id <= ?`, before.UTC(), maxID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetEventCursor возвращает позицию подписчика; false - подписчик еще не читал журнал
+func (s *Storage) GetEventCursor(subscriber string) (int64, bool, error) {
+	var position int64
+	err := s.db.QueryRow(`SELECT position FROM event_cursors WHERE subscriber = ?`, subscriber).Scan(&position)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get event cursor: %w", err)
+	}
+	return position, true, nil
+}
+
+// SetEventCursor сохраняет позицию подписчика в журнале
+func (s *Storage) SetEventCursor(subscriber string, position int64) error {
+	query := `INSERT INTO event_cursors (subscriber, position, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+			  ON CONFLICT(subscriber) DO UPDATE SET position = excluded.position, updated_at = CURRENT_TIMESTAMP`
+
+	if _, err := s.db.Exec(query, subscriber, position); err != nil {
+		return fmt.Errorf("failed to save event cursor: %w", err)
+	}
+	return nil
+}
+
+// DeleteEventCursor удаляет позицию подписчика
+func (s *Storage) DeleteEventCursor(subscriber string) error {
+	if _, err := s.db.Exec(`DELETE FROM event_cursors WHERE subscriber = ?`, subscriber); err != nil {
+		return fmt.Errorf("failed to delete event cursor: %w", err)
+	}
+	return nil
+}
+
+// GetEventCursors возвращает позиции всех подписчиков
+func (s *Storage) GetEventCursors() ([]EventCursor, error) {
+	rows, err := s.db.Query(`SELECT subscriber, position, updated_at FROM event_cursors ORDER BY subscriber`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event cursors: %w", err)
+	}
+	defer rows.Close()
+
+	var cursors []EventCursor
+	for rows.Next() {
+		var cursor EventCursor
+		if err := rows.Scan(&cursor.Subscriber, &cursor.Position, &cursor.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event cursor: %w", err)
+		}
+		cursors = append(cursors, cursor)
+	}
+
+	return cursors, rows.Err()
+}
+
+// SaveFailedDelivery сохраняет неудачную доставку
+func (s *Storage) SaveFailedDelivery(delivery *FailedDelivery) error {
+	if delivery.ID == 0 {
+		query := `INSERT INTO failed_deliveries (subscriber, outbox_id, type, attempts, error) VALUES (?, ?, ?, ?, ?)`
+		result, err := s.db.Exec(query, delivery.Subscriber, delivery.OutboxID, delivery.Type, delivery.Attempts, delivery.Error)
+		if err != nil {
+			return fmt.Errorf("failed to save failed delivery: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get failed delivery id: %w", err)
+		}
+		delivery.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE failed_deliveries SET attempts = ?, error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := s.db.Exec(query, delivery.Attempts, delivery.Error, delivery.ID); err != nil {
+		return fmt.Errorf("failed to update failed delivery: %w", err)
+	}
+	return nil
+}
+
+// GetFailedDeliveries возвращает последние неудачные доставки; пустой subscriber - всех подписчиков
+func (s *Storage) GetFailedDeliveries(subscriber string, limit int) ([]FailedDelivery, error) {
+	query := `SELECT ` + failedDeliveryColumns + ` FROM failed_deliveries`
+	var args []interface{}
+	if subscriber != "" {
+		query += ` WHERE subscriber = ?`
+		args = append(args, subscriber)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failed deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []FailedDelivery
+	for rows.Next() {
+		delivery, err := scanFailedDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetFailedDelivery возвращает неудачную доставку по ID
+func (s *Storage) GetFailedDelivery(id int) (*FailedDelivery, error) {
+	query := `SELECT ` + failedDeliveryColumns + ` FROM failed_deliveries WHERE id = ?`
+
+	delivery, err := scanFailedDelivery(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// CountFailedDeliveries возвращает количество неудачных доставок подписчика
+func (s *Storage) CountFailedDeliveries(subscriber string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM failed_deliveries WHERE subscriber = ?`, subscriber).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failed deliveries: %w", err)
+	}
+	return count, nil
+}
+
+// GetMinFailedOutboxID возвращает наименьший ID записи журнала среди неудачных доставок;
+// false - неудачных доставок нет
+func (s *Storage) GetMinFailedOutboxID() (int64, bool, error) {
+	var id sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MIN(outbox_id) FROM failed_deliveries`).Scan(&id); err != nil {
+		return 0, false, fmt.Errorf("failed to get min failed outbox id: %w", err)
+	}
+	return id.Int64, id.Valid, nil
+}
+
+// DeleteFailedDelivery удаляет неудачную доставку (после успешной повторной отправки)
+func (s *Storage) DeleteFailedDelivery(id int) error {
+	if _, err := s.db.Exec(`DELETE FROM failed_deliveries WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete failed delivery: %w", err)
+	}
+	return nil
+}
+
+// DeleteFailedDeliveries удаляет все неудачные доставки подписчика
+func (s *Storage) DeleteFailedDeliveries(subscriber string) error {
+	if _, err := s.db.Exec(`DELETE FROM failed_deliveries WHERE subscriber = ?`, subscriber); err != nil {
+		return fmt.Errorf("failed to delete failed deliveries: %w", err)
+	}
+	return nil
+}
+
+// scanFailedDelivery читает неудачную доставку из строки результата
+func scanFailedDelivery(row interface{ Scan(...interface{}) error }) (*FailedDelivery, error) {
+	var delivery FailedDelivery
+	var errText sql.NullString
+
+	err := row.Scan(&delivery.ID, &delivery.Subscriber, &delivery.OutboxID, &delivery.Type,
+		&delivery.Attempts, &errText, &delivery.CreatedAt, &delivery.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan failed delivery: %w", err)
+	}
+
+	delivery.Error = errText.String
+	return &delivery, nil
+}
+
+// filterTypes добавляет к запросу журнала условие на типы событий
+func filterTypes(query string, args []interface{}, types []string) (string, []interface{}) {
+	if len(types) == 0 {
+		return query, args
+	}
+
+	query += ` AND type IN (?` + strings.Repeat(`, ?`, len(types)-1) + `)`
+	for _, t := range types {
+		args = append(args, t)
+	}
+	return query, args
+}
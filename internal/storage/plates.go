@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Списки номеров
+const (
+	WatchlistAllow = "allow"
+	WatchlistDeny  = "deny"
+)
+
+// PlateRead распознанный номер транспорта
+type PlateRead struct {
+	ID         int       `json:"id"`
+	CameraID   string    `json:"camera_id"`
+	CameraName string    `json:"camera_name"`
+	Plate      string    `json:"plate"` // нормализованный номер
+	Confidence float32   `json:"confidence"`
+	Class      string    `json:"class"`
+	TrackID    string    `json:"track_id,omitempty"`
+	Watchlist  string    `json:"watchlist,omitempty"` // список, в котором номер был на момент распознавания
+	ImagePath  string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PlateFilter параметры поиска номеров
+type PlateFilter struct {
+	Query    string // часть нормализованного номера
+	CameraID string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+// WatchlistEntry номер из белого или черного списка
+type WatchlistEntry struct {
+	ID        int       `json:"id"`
+	Plate     string    `json:"plate"` // нормализованный номер
+	List      string    `json:"list"`  // allow, deny
+	Label     string    `json:"label"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// plateColumns колонки таблицы номеров в порядке scanPlateRead
+const plateColumns = `id, camera_id, camera_name, plate, confidence, class, track_id, watchlist, image_path, created_at`
+
+// watchlistColumns колонки списков номеров в порядке scanWatchlistEntry
+const watchlistColumns = `id, plate, list, label, enabled, created_at, updated_at`
+
+// SavePlateRead сохраняет распознанный номер
+func (s *Storage) SavePlateRead(read *PlateRead) error {
+	if read.CreatedAt.IsZero() {
+		read.CreatedAt = time.Now()
+	}
+
+	query := `INSERT INTO plates (camera_id, camera_name, plate, confidence, class, track_id, watchlist, image_path, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.db.Exec(query, read.CameraID, read.CameraName, read.Plate, read.Confidence, read.Class,
+		read.TrackID, read.Watchlist, read.ImagePath, read.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save plate read: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get plate read ID: %w", err)
+	}
+
+	read.ID = int(id)
+	return nil
+}
+
+// GetPlateRead возвращает распознанный номер по ID
+func (s *Storage) GetPlateRead(id int) (*PlateRead, error) {
+	query := `SELECT ` + plateColumns + ` FROM plates WHERE id = ?`
+
+	read, err := scanPlateRead(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return read, nil
+}
+
+// SearchPlates ищет распознанные номера по истории, новые первыми
+func (s *Storage) SearchPlates(filter PlateFilter) ([]PlateRead, error) {
+	query := `SELECT ` + plateColumns + ` FROM plates WHERE 1=1`
+	var args []interface{}
+
+	if filter.Query != "" {
+		query += ` AND plate LIKE ?`
+		args = append(args, "%"+filter.Query+"%")
+	}
+	if filter.CameraID != "" {
+		query += ` AND camera_id = ?`
+		args = append(args, filter.CameraID)
+	}
+	if !filter.From.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, filter.To.UTC())
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += ` ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, filter.Offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search plates: %w", err)
+	}
+	defer rows.Close()
+
+	var reads []PlateRead
+	for rows.Next() {
+		read, err := scanPlateRead(rows)
+		if err != nil {
+			return nil, err
+		}
+		reads = append(reads, *read)
+	}
+
+	return reads, rows.Err()
+}
+
+// GetWatchlist возвращает белый и черный списки номеров
+func (s *Storage) GetWatchlist() ([]WatchlistEntry, error) {
+	query := `SELECT ` + watchlistColumns + ` FROM plate_watchlist ORDER BY list, plate`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plate watchlist: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []WatchlistEntry
+	for rows.Next() {
+		entry, err := scanWatchlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetWatchlistEntry возвращает номер из списков по ID
+func (s *Storage) GetWatchlistEntry(id int) (*WatchlistEntry, error) {
+	query := `SELECT ` + watchlistColumns + ` FROM plate_watchlist WHERE id = ?`
+
+	entry, err := scanWatchlistEntry(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// FindWatchlistEntry ищет включенную запись списков для нормализованного номера
+func (s *Storage) FindWatchlistEntry(plate string) (*WatchlistEntry, error) {
+	query := `SELECT ` + watchlistColumns + ` FROM plate_watchlist WHERE plate = ? AND enabled = 1`
+
+	entry, err := scanWatchlistEntry(s.db.QueryRow(query, plate))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// SaveWatchlistEntry добавляет номер в список или обновляет запись
+func (s *Storage) SaveWatchlistEntry(entry *WatchlistEntry) error {
+	if entry.ID == 0 {
+		query := `INSERT INTO plate_watchlist (plate, list, label, enabled) VALUES (?, ?, ?, ?)`
+
+		result, err := s.db.Exec(query, entry.Plate, entry.List, entry.Label, entry.Enabled)
+		if err != nil {
+			return fmt.Errorf("failed to create watchlist entry: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get watchlist entry ID: %w", err)
+		}
+
+		entry.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE plate_watchlist SET plate = ?, list = ?, label = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = ?`
+
+	if _, err := s.db.Exec(query, entry.Plate, entry.List, entry.Label, entry.Enabled, entry.ID); err != nil {
+		return fmt.Errorf("failed to update watchlist entry: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteWatchlistEntry удаляет номер из списков
+func (s *Storage) DeleteWatchlistEntry(id int) error {
+	_, err := s.db.Exec("DELETE FROM plate_watchlist WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete watchlist entry: %w", err)
+	}
+	return nil
+}
+
+// scanPlateRead читает распознанный номер из строки результата
+func scanPlateRead(row interface{ Scan(...interface{}) error }) (*PlateRead, error) {
+	var read PlateRead
+
+	err := row.Scan(&read.ID, &read.CameraID, &read.CameraName, &read.Plate, &read.Confidence, &read.Class,
+		&read.TrackID, &read.Watchlist, &read.ImagePath, &read.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan plate read: %w", err)
+	}
+
+	return &read, nil
+}
+
+// scanWatchlistEntry читает запись списков номеров из строки результата
+func scanWatchlistEntry(row interface{ Scan(...interface{}) error }) (*WatchlistEntry, error) {
+	var entry WatchlistEntry
+
+	err := row.Scan(&entry.ID, &entry.Plate, &entry.List, &entry.Label, &entry.Enabled, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan watchlist entry: %w", err)
+	}
+
+	return &entry, nil
+}
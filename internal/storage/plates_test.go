@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newStorage(t *testing.T) *Storage {
+	s, err := New(filepath.Join(t.TempDir(), "ocuai.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestFindWatchlistEntry(t *testing.T) {
+	s := newStorage(t)
+
+	entries := []*WatchlistEntry{
+		{Plate: "A123BC77", List: WatchlistDeny, Label: "stolen", Enabled: true},
+		{Plate: "O777OO77", List: WatchlistAllow, Label: "owner", Enabled: true},
+		{Plate: "X001XY197", List: WatchlistDeny, Enabled: false},
+	}
+	for _, entry := range entries {
+		if err := s.SaveWatchlistEntry(entry); err != nil {
+			t.Fatalf("SaveWatchlistEntry: %v", err)
+		}
+	}
+
+	entry, err := s.FindWatchlistEntry("A123BC77")
+	if err != nil {
+		t.Fatalf("FindWatchlistEntry: %v", err)
+	}
+	if entry == nil || entry.ID != entries[0].ID || entry.List != WatchlistDeny || entry.Label != "stolen" {
+		t.Errorf("entry = %+v, want the deny list entry", entry)
+	}
+
+	// Выключенные записи и неизвестные номера не совпадают
+	for _, plate := range []string{"X001XY197", "A123BC78", ""} {
+		entry, err := s.FindWatchlistEntry(plate)
+		if err != nil || entry != nil {
+			t.Errorf("FindWatchlistEntry(%q) = %+v, %v, want no match", plate, entry, err)
+		}
+	}
+
+	// После включения запись начинает совпадать
+	entries[2].Enabled = true
+	if err := s.SaveWatchlistEntry(entries[2]); err != nil {
+		t.Fatalf("SaveWatchlistEntry: %v", err)
+	}
+	if entry, _ := s.FindWatchlistEntry("X001XY197"); entry == nil {
+		t.Error("enabled entry does not match")
+	}
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Статусы повторного анализа
+const (
+	ReanalysisStatusPending   = "pending"
+	ReanalysisStatusRunning   = "running"
+	ReanalysisStatusCompleted = "completed"
+	ReanalysisStatusFailed    = "failed"
+	ReanalysisStatusCanceled  = "canceled"
+)
+
+// ReanalysisJob представляет задание на повторный AI анализ записей камеры
+type ReanalysisJob struct {
+	ID         string    `json:"id"`
+	CameraID   string    `json:"camera_id"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Model      string    `json:"model,omitempty"` // имя модели из ai.models, пусто - модель по умолчанию
+	Threshold  float32   `json:"threshold"`
+	Classes    []string  `json:"classes,omitempty"`
+	FPS        float64   `json:"fps"`    // сколько кадров записи в секунду анализировать
+	Status     string    `json:"status"` // pending, running, completed, failed, canceled
+	Progress   float64   `json:"progress"`
+	EventCount int       `json:"event_count"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// reanalysisColumns список колонок, читаемых scanReanalysisJobs
+const reanalysisColumns = `id, camera_id, start_time, end_time, model, threshold, classes, fps, status, progress,
+	event_count, error, created_at, finished_at`
+
+// CreateReanalysisJob сохраняет новое задание на повторный анализ
+func (s *Storage) CreateReanalysisJob(job *ReanalysisJob) error {
+	query := `INSERT INTO reanalysis_jobs (id, camera_id, start_time, end_time, model, threshold, classes, fps, status)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.db.Exec(query, job.ID, job.CameraID, job.From.UTC(), job.To.UTC(), job.Model,
+		job.Threshold, strings.Join(job.Classes, ","), job.FPS, job.Status)
+	if err != nil {
+		return fmt.Errorf("failed to create reanalysis job: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateReanalysisJob сохраняет состояние задания на повторный анализ
+func (s *Storage) UpdateReanalysisJob(job *ReanalysisJob) error {
+	var finishedAt interface{}
+	if !job.FinishedAt.IsZero() {
+		finishedAt = job.FinishedAt.UTC()
+	}
+
+	query := `UPDATE reanalysis_jobs SET status = ?, progress = ?, event_count = ?, error = ?, finished_at = ?
+			  WHERE id = ?`
+
+	_, err := s.db.Exec(query, job.Status, job.Progress, job.EventCount, job.Error, finishedAt, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update reanalysis job: %w", err)
+	}
+
+	return nil
+}
+
+// GetReanalysisJob возвращает задание на повторный анализ по ID
+func (s *Storage) GetReanalysisJob(id string) (*ReanalysisJob, error) {
+	rows, err := s.db.Query(`SELECT `+reanalysisColumns+` FROM reanalysis_jobs WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reanalysis job: %w", err)
+	}
+	defer rows.Close()
+
+	jobs, err := scanReanalysisJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	return &jobs[0], nil
+}
+
+// GetReanalysisJobs возвращает последние задания на повторный анализ
+func (s *Storage) GetReanalysisJobs(limit int) ([]ReanalysisJob, error) {
+	rows, err := s.db.Query(`SELECT `+reanalysisColumns+` FROM reanalysis_jobs ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reanalysis jobs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanReanalysisJobs(rows)
+}
+
+// FailInterruptedReanalysisJobs помечает незавершенные задания как прерванные
+func (s *Storage) FailInterruptedReanalysisJobs() error {
+	query := `UPDATE reanalysis_jobs SET status = ?, error = 'interrupted by restart', finished_at = CURRENT_TIMESTAMP
+			  WHERE status IN (?, ?)`
+
+	_, err := s.db.Exec(query, ReanalysisStatusFailed, ReanalysisStatusPending, ReanalysisStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to update interrupted reanalysis jobs: %w", err)
+	}
+
+	return nil
+}
+
+// GetJobEvents возвращает события, созданные заданием повторного анализа, в хронологическом порядке
+func (s *Storage) GetJobEvents(jobID string, limit, offset int) ([]Event, error) {
+	query := `SELECT ` + eventColumns + `
+			  FROM events WHERE job_id = ? ORDER BY created_at LIMIT ? OFFSET ?`
+
+	rows, err := s.db.Query(query, jobID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// scanReanalysisJobs читает задания на повторный анализ из результата запроса
+func scanReanalysisJobs(rows *sql.Rows) ([]ReanalysisJob, error) {
+	var jobs []ReanalysisJob
+	for rows.Next() {
+		var job ReanalysisJob
+		var model, classes, errText sql.NullString
+		var finishedAt sql.NullTime
+
+		err := rows.Scan(&job.ID, &job.CameraID, &job.From, &job.To, &model, &job.Threshold, &classes,
+			&job.FPS, &job.Status, &job.Progress, &job.EventCount, &errText, &job.CreatedAt, &finishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reanalysis job: %w", err)
+		}
+
+		job.Model = model.String
+		if classes.String != "" {
+			job.Classes = strings.Split(classes.String, ",")
+		}
+		job.Error = errText.String
+		if finishedAt.Valid {
+			job.FinishedAt = finishedAt.Time
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Статусы сегментов записи
+const (
+	RecordingStatusRecording = "recording"
+	RecordingStatusComplete  = "complete"
+)
+
+// Recording представляет сегмент непрерывной записи
+type Recording struct {
+	ID        int       `json:"id"`
+	CameraID  string    `json:"camera_id"`
+	Path      string    `json:"path"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	SizeBytes int64     `json:"size_bytes"`
+	Status    string    `json:"status"` // recording, complete
+}
+
+// CreateRecording добавляет сегмент в индекс записей
+func (s *Storage) CreateRecording(recording *Recording) error {
+	query := `INSERT INTO recordings (camera_id, path, start_time, status) VALUES (?, ?, ?, ?)`
+
+	result, err := s.db.Exec(query, recording.CameraID, recording.Path, recording.StartTime.UTC(), RecordingStatusRecording)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get recording id: %w", err)
+	}
+
+	recording.ID = int(id)
+	recording.Status = RecordingStatusRecording
+	return nil
+}
+
+// FinishRecording помечает сегмент как завершенный
+func (s *Storage) FinishRecording(id int, endTime time.Time, sizeBytes int64) error {
+	query := `UPDATE recordings SET end_time = ?, size_bytes = ?, status = ? WHERE id = ?`
+	_, err := s.db.Exec(query, endTime.UTC(), sizeBytes, RecordingStatusComplete, id)
+	if err != nil {
+		return fmt.Errorf("failed to finish recording: %w", err)
+	}
+	return nil
+}
+
+// DeleteRecording удаляет сегмент из индекса
+func (s *Storage) DeleteRecording(id int) error {
+	_, err := s.db.Exec("DELETE FROM recordings WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recording: %w", err)
+	}
+	return nil
+}
+
+// GetRecordings возвращает сегменты камеры, пересекающиеся с интервалом [from, to]
+func (s *Storage) GetRecordings(cameraID string, from, to time.Time) ([]Recording, error) {
+	query := `SELECT id, camera_id, path, start_time, end_time, size_bytes, status
+			  FROM recordings
+			  WHERE camera_id = ? AND start_time <= ? AND (end_time IS NULL OR end_time >= ?)
+			  ORDER BY start_time`
+
+	rows, err := s.db.Query(query, cameraID, to.UTC(), from.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recordings: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRecordings(rows)
+}
+
+// GetRecording возвращает сегмент по ID
+func (s *Storage) GetRecording(id int) (*Recording, error) {
+	query := `SELECT id, camera_id, path, start_time, end_time, size_bytes, status
+			  FROM recordings WHERE id = ?`
+
+	rows, err := s.db.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recording: %w", err)
+	}
+	defer rows.Close()
+
+	recordings, err := scanRecordings(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordings) == 0 {
+		return nil, nil
+	}
+
+	return &recordings[0], nil
+}
+
+// GetIncompleteRecordings возвращает сегменты, запись которых не была завершена
+func (s *Storage) GetIncompleteRecordings() ([]Recording, error) {
+	query := `SELECT id, camera_id, path, start_time, end_time, size_bytes, status
+			  FROM recordings WHERE status = ? ORDER BY start_time`
+
+	rows, err := s.db.Query(query, RecordingStatusRecording)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incomplete recordings: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRecordings(rows)
+}
+
+// scanRecordings читает сегменты из результата запроса
+func scanRecordings(rows *sql.Rows) ([]Recording, error) {
+	var recordings []Recording
+	for rows.Next() {
+		var recording Recording
+		var endTime sql.NullTime
+
+		err := rows.Scan(&recording.ID, &recording.CameraID, &recording.Path, &recording.StartTime,
+			&endTime, &recording.SizeBytes, &recording.Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recording: %w", err)
+		}
+
+		if endTime.Valid {
+			recording.EndTime = endTime.Time
+		}
+
+		recordings = append(recordings, recording)
+	}
+
+	return recordings, rows.Err()
+}
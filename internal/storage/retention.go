@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetEvictableEvents возвращает самые старые неизбранные события, созданные до before.
+// Пустой cameraID означает все камеры.
+func (s *Storage) GetEvictableEvents(cameraID string, before time.Time, limit int) ([]Event, error) {
+	query := `SELECT ` + eventColumns + `
+			  FROM events
+			  WHERE starred = 0 AND created_at < ? AND (? = '' OR camera_id = ?)
+			  ORDER BY created_at LIMIT ?`
+
+	rows, err := s.db.Query(query, before.UTC(), cameraID, cameraID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query evictable events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// GetEvictableRecordings возвращает самые старые завершенные сегменты, закончившиеся до before.
+// Сегменты, на которые приходятся избранные события, не возвращаются.
+func (s *Storage) GetEvictableRecordings(cameraID string, before time.Time, limit int) ([]Recording, error) {
+	query := `SELECT r.id, r.camera_id, r.path, r.start_time, r.end_time, r.size_bytes, r.status
+			  FROM recordings r
+			  WHERE r.status = ? AND r.end_time < ? AND (? = '' OR r.camera_id = ?)
+			    AND NOT EXISTS (
+			        SELECT 1 FROM events e
+			        WHERE e.starred = 1 AND e.camera_id = r.camera_id
+			          AND e.created_at >= r.start_time AND e.created_at <= r.end_time
+			    )
+			  ORDER BY r.start_time LIMIT ?`
+
+	rows, err := s.db.Query(query, RecordingStatusComplete, before.UTC(), cameraID, cameraID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query evictable recordings: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRecordings(rows)
+}
+
+// GetMediaCameraIDs возвращает идентификаторы камер, у которых есть события, записи или номера
+func (s *Storage) GetMediaCameraIDs() ([]string, error) {
+	rows, err := s.db.Query(`SELECT camera_id FROM events WHERE camera_id IS NOT NULL
+							 UNION SELECT camera_id FROM recordings UNION SELECT camera_id FROM plates`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query camera ids: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan camera id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetEvictablePlateReads возвращает самые старые распознанные номера, сохраненные до before
+func (s *Storage) GetEvictablePlateReads(cameraID string, before time.Time, limit int) ([]PlateRead, error) {
+	query := `SELECT ` + plateColumns + `
+			  FROM plates
+			  WHERE created_at < ? AND (? = '' OR camera_id = ?)
+			  ORDER BY created_at LIMIT ?`
+
+	rows, err := s.db.Query(query, before.UTC(), cameraID, cameraID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query evictable plate reads: %w", err)
+	}
+	defer rows.Close()
+
+	var reads []PlateRead
+	for rows.Next() {
+		read, err := scanPlateRead(rows)
+		if err != nil {
+			return nil, err
+		}
+		reads = append(reads, *read)
+	}
+
+	return reads, rows.Err()
+}
+
+// DeletePlateRead удаляет распознанный номер
+func (s *Storage) DeletePlateRead(id int) error {
+	if _, err := s.db.Exec("DELETE FROM plates WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete plate read: %w", err)
+	}
+	return nil
+}
+
+// DeleteCameralessEventsBefore удаляет события без камеры (смена режима охраны), созданные до before
+func (s *Storage) DeleteCameralessEventsBefore(before time.Time) (int, error) {
+	result, err := s.db.Exec(`DELETE FROM events WHERE camera_id IS NULL AND starred = 0 AND created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete cameraless events: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+// DeleteEvent удаляет событие
+func (s *Storage) DeleteEvent(id int) error {
+	_, err := s.db.Exec("DELETE FROM events WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	return nil
+}
+
+// IsMediaReferenced проверяет, ссылается ли какое-либо событие на файл
+func (s *Storage) IsMediaReferenced(path string) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM events WHERE video_path = ? OR thumbnail_path = ?", path, path).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check media references: %w", err)
+	}
+	return count > 0, nil
+}
+
+// IsMediaStarred проверяет, ссылается ли на файл избранное событие, кроме eventID
+func (s *Storage) IsMediaStarred(path string, eventID int) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM events WHERE (video_path = ? OR thumbnail_path = ?) AND id != ? AND starred = 1",
+		path, path, eventID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check media references: %w", err)
+	}
+	return count > 0, nil
+}
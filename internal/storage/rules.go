@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Действия правил детекции
+const (
+	RuleActionAlert  = "alert"  // сообщать об объекте при выполнении условий
+	RuleActionIgnore = "ignore" // никогда не сообщать об объекте
+)
+
+// DetectionRule правило детекции камеры для класса объектов
+type DetectionRule struct {
+	ID             int       `json:"id"`
+	CameraID       string    `json:"camera_id"`
+	Class          string    `json:"class"`  // пусто - любой класс
+	Action         string    `json:"action"` // alert, ignore
+	MinConfidence  float32   `json:"min_confidence"`
+	ZoneID         int       `json:"zone_id,omitempty"` // 0 - весь кадр
+	StartTime      string    `json:"start_time"`        // ЧЧ:ММ, пусто - круглосуточно
+	EndTime        string    `json:"end_time"`          // ЧЧ:ММ, может быть раньше начала (через полночь)
+	MinArea        float64   `json:"min_area"`          // доля площади кадра
+	MinDwellFrames int       `json:"min_dwell_frames"`  // кадров с объектом до события
+	Enabled        bool      `json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// detectionRuleColumns колонки правила в порядке scanDetectionRule
+const detectionRuleColumns = `id, camera_id, class, action, min_confidence, zone_id, start_time, end_time,
+	min_area, min_dwell_frames, enabled, created_at, updated_at`
+
+// GetDetectionRules возвращает правила детекции камеры
+func (s *Storage) GetDetectionRules(cameraID string) ([]DetectionRule, error) {
+	query := `SELECT ` + detectionRuleColumns + ` FROM detection_rules WHERE camera_id = ? ORDER BY id`
+
+	rows, err := s.db.Query(query, cameraID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query detection rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []DetectionRule
+	for rows.Next() {
+		rule, err := scanDetectionRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// GetDetectionRule возвращает правило по ID
+func (s *Storage) GetDetectionRule(id int) (*DetectionRule, error) {
+	query := `SELECT ` + detectionRuleColumns + ` FROM detection_rules WHERE id = ?`
+
+	rule, err := scanDetectionRule(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// SaveDetectionRule создает правило или обновляет существующее
+func (s *Storage) SaveDetectionRule(rule *DetectionRule) error {
+	// Правило без зоны хранит NULL, чтобы не нарушать внешний ключ
+	var zoneID interface{}
+	if rule.ZoneID != 0 {
+		zoneID = rule.ZoneID
+	}
+
+	if rule.ID == 0 {
+		query := `INSERT INTO detection_rules
+				  (camera_id, class, action, min_confidence, zone_id, start_time, end_time, min_area, min_dwell_frames, enabled)
+				  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+		result, err := s.db.Exec(query, rule.CameraID, rule.Class, rule.Action, rule.MinConfidence, zoneID,
+			rule.StartTime, rule.EndTime, rule.MinArea, rule.MinDwellFrames, rule.Enabled)
+		if err != nil {
+			return fmt.Errorf("failed to create detection rule: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get detection rule ID: %w", err)
+		}
+
+		rule.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE detection_rules SET class = ?, action = ?, min_confidence = ?, zone_id = ?, start_time = ?,
+			  end_time = ?, min_area = ?, min_dwell_frames = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = ?`
+
+	_, err := s.db.Exec(query, rule.Class, rule.Action, rule.MinConfidence, zoneID, rule.StartTime,
+		rule.EndTime, rule.MinArea, rule.MinDwellFrames, rule.Enabled, rule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update detection rule: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDetectionRule удаляет правило
+func (s *Storage) DeleteDetectionRule(id int) error {
+	_, err := s.db.Exec("DELETE FROM detection_rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete detection rule: %w", err)
+	}
+	return nil
+}
+
+// scanDetectionRule читает правило из строки результата
+func scanDetectionRule(row interface{ Scan(...interface{}) error }) (*DetectionRule, error) {
+	var rule DetectionRule
+	var zoneID sql.NullInt64
+
+	err := row.Scan(&rule.ID, &rule.CameraID, &rule.Class, &rule.Action, &rule.MinConfidence, &zoneID,
+		&rule.StartTime, &rule.EndTime, &rule.MinArea, &rule.MinDwellFrames, &rule.Enabled,
+		&rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan detection rule: %w", err)
+	}
+
+	rule.ZoneID = int(zoneID.Int64)
+	return &rule, nil
+}
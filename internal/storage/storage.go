@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -20,18 +22,35 @@ func (s *Storage) GetDB() *sql.DB {
 
 // Event представляет событие в системе
 type Event struct {
-	ID            int       `json:"id"`
-	CameraID      string    `json:"camera_id"`
-	CameraName    string    `json:"camera_name"`
-	Type          string    `json:"type"` // motion, ai_detection
-	Description   string    `json:"description"`
-	Confidence    float32   `json:"confidence"`
-	VideoPath     string    `json:"video_path"`
-	ThumbnailPath string    `json:"thumbnail_path"`
-	CreatedAt     time.Time `json:"created_at"`
-	Processed     bool      `json:"processed"`
+	ID            int                    `json:"id"`
+	CameraID      string                 `json:"camera_id"`
+	CameraName    string                 `json:"camera_name"`
+	Type          string                 `json:"type"` // motion, ai_detection
+	Description   string                 `json:"description"`
+	Confidence    float32                `json:"confidence"`
+	VideoPath     string                 `json:"video_path"`
+	ThumbnailPath string                 `json:"thumbnail_path"`
+	CreatedAt     time.Time              `json:"created_at"`
+	Processed     bool                   `json:"processed"`
+	Starred       bool                   `json:"starred"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+	Track         *EventTrack            `json:"track,omitempty"`
+	JobID         string                 `json:"job_id,omitempty"` // задание повторного анализа; пусто - живое событие
 }
 
+// EventTrack время жизни отслеживаемого объекта, вызвавшего событие
+type EventTrack struct {
+	ID           string    `json:"id"`
+	StartedAt    time.Time `json:"started_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	EndedAt      time.Time `json:"ended_at,omitempty"` // пусто, пока объект в кадре
+	DwellSeconds float64   `json:"dwell_seconds"`
+}
+
+// eventColumns список колонок, читаемых scanEvents
+const eventColumns = `id, camera_id, camera_name, type, description, confidence, video_path, thumbnail_path, created_at, processed, starred, data,
+	track_id, track_started_at, track_last_seen, track_ended_at, dwell_seconds, job_id`
+
 // Camera представляет камеру в системе
 type Camera struct {
 	ID              string    `json:"id"`
@@ -41,6 +60,7 @@ type Camera struct {
 	LastSeen        time.Time `json:"last_seen"`
 	MotionDetection bool      `json:"motion_detection"`
 	AIDetection     bool      `json:"ai_detection"`
+	Sensitivity     float32   `json:"sensitivity"` // 0..1, 0 - значение по умолчанию
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -54,7 +74,7 @@ type Settings struct {
 
 // New создает новое хранилище
 func New(dbPath string) (*Storage, error) {
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_journal_mode=WAL")
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -90,7 +110,7 @@ func (s *Storage) migrate() error {
 
 		`CREATE TABLE IF NOT EXISTS events (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			camera_id TEXT NOT NULL,
+			camera_id TEXT,
 			camera_name TEXT NOT NULL,
 			type TEXT NOT NULL,
 			description TEXT,
@@ -108,10 +128,246 @@ func (s *Storage) migrate() error {
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
 
+		`CREATE TABLE IF NOT EXISTS recordings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			camera_id TEXT NOT NULL,
+			path TEXT NOT NULL UNIQUE,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME,
+			size_bytes INTEGER DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'recording'
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS exports (
+			id TEXT PRIMARY KEY,
+			camera_ids TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			progress REAL DEFAULT 0,
+			file_path TEXT,
+			sha256 TEXT,
+			error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			finished_at DATETIME
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS reanalysis_jobs (
+			id TEXT PRIMARY KEY,
+			camera_id TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			model TEXT,
+			threshold REAL DEFAULT 0,
+			classes TEXT,
+			fps REAL DEFAULT 1,
+			status TEXT NOT NULL DEFAULT 'pending',
+			progress REAL DEFAULT 0,
+			event_count INTEGER DEFAULT 0,
+			error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			finished_at DATETIME,
+			FOREIGN KEY (camera_id) REFERENCES cameras(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS camera_zones (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			camera_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT 'include',
+			points TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (camera_id) REFERENCES cameras(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS detection_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			camera_id TEXT NOT NULL,
+			class TEXT NOT NULL DEFAULT '',
+			action TEXT NOT NULL DEFAULT 'alert',
+			min_confidence REAL DEFAULT 0,
+			zone_id INTEGER,
+			start_time TEXT NOT NULL DEFAULT '',
+			end_time TEXT NOT NULL DEFAULT '',
+			min_area REAL DEFAULT 0,
+			min_dwell_frames INTEGER DEFAULT 0,
+			enabled BOOLEAN DEFAULT TRUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (camera_id) REFERENCES cameras(id) ON DELETE CASCADE,
+			FOREIGN KEY (zone_id) REFERENCES camera_zones(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS camera_analytics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			camera_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			points TEXT NOT NULL,
+			direction TEXT NOT NULL DEFAULT 'any',
+			classes TEXT NOT NULL DEFAULT '[]',
+			min_seconds REAL DEFAULT 0,
+			enabled BOOLEAN DEFAULT TRUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (camera_id) REFERENCES cameras(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS counts (
+			camera_id TEXT NOT NULL,
+			class TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			direction TEXT NOT NULL DEFAULT '',
+			bucket_start DATETIME NOT NULL,
+			count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (camera_id, class, kind, direction, bucket_start),
+			FOREIGN KEY (camera_id) REFERENCES cameras(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS people (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			tags TEXT NOT NULL DEFAULT '[]',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS face_photos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			person_id INTEGER NOT NULL,
+			image_path TEXT NOT NULL,
+			embedding TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (person_id) REFERENCES people(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS plates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			camera_id TEXT NOT NULL,
+			camera_name TEXT NOT NULL,
+			plate TEXT NOT NULL,
+			confidence REAL DEFAULT 0,
+			class TEXT NOT NULL DEFAULT '',
+			track_id TEXT NOT NULL DEFAULT '',
+			watchlist TEXT NOT NULL DEFAULT '',
+			image_path TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (camera_id) REFERENCES cameras(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS plate_watchlist (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			plate TEXT NOT NULL UNIQUE,
+			list TEXT NOT NULL,
+			label TEXT NOT NULL DEFAULT '',
+			enabled BOOLEAN DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS event_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL DEFAULT '',
+			event_types TEXT,
+			camera_ids TEXT,
+			classes TEXT,
+			zone_id INTEGER,
+			start_time TEXT NOT NULL DEFAULT '',
+			end_time TEXT NOT NULL DEFAULT '',
+			arming_modes TEXT,
+			cooldown_seconds INTEGER DEFAULT 0,
+			min_confidence REAL DEFAULT 0,
+			actions TEXT NOT NULL DEFAULT '[]',
+			enabled BOOLEAN DEFAULT 1,
+			last_triggered_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (zone_id) REFERENCES camera_zones(id) ON DELETE SET NULL
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS arming_profiles (
+			mode TEXT PRIMARY KEY,
+			notify_cameras TEXT,
+			record_cameras TEXT,
+			ai_cameras TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS arming_schedules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			mode TEXT NOT NULL,
+			days TEXT,
+			time TEXT NOT NULL,
+			enabled BOOLEAN DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL DEFAULT '',
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			event_types TEXT,
+			enabled BOOLEAN DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			sequence INTEGER NOT NULL,
+			event_id INTEGER,
+			event_type TEXT NOT NULL,
+			attempt INTEGER DEFAULT 1,
+			status_code INTEGER DEFAULT 0,
+			success BOOLEAN DEFAULT 0,
+			error TEXT,
+			duration_ms INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS event_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL,
+			event_id INTEGER,
+			payload TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS event_cursors (
+			subscriber TEXT PRIMARY KEY,
+			position INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS failed_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			subscriber TEXT NOT NULL,
+			outbox_id INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
 		`CREATE INDEX IF NOT EXISTS idx_events_camera_id ON events(camera_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_counts_bucket ON counts(bucket_start)`,
+		`CREATE INDEX IF NOT EXISTS idx_plates_created_at ON plates(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_plates_plate ON plates(plate)`,
 		`CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_events_type ON events(type)`,
 		`CREATE INDEX IF NOT EXISTS idx_cameras_status ON cameras(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_recordings_camera_time ON recordings(camera_id, start_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_recordings_status ON recordings(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, sequence)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_event_outbox_created_at ON event_outbox(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_failed_deliveries_subscriber ON failed_deliveries(subscriber)`,
 	}
 
 	for _, query := range queries {
@@ -120,12 +376,158 @@ func (s *Storage) migrate() error {
 		}
 	}
 
+	// Колонки, добавленные после первой версии схемы
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"events", "starred", "BOOLEAN DEFAULT 0"},
+		{"cameras", "sensitivity", "REAL DEFAULT 0"},
+		{"events", "data", "TEXT"},
+		{"events", "track_id", "TEXT"},
+		{"events", "track_started_at", "DATETIME"},
+		{"events", "track_last_seen", "DATETIME"},
+		{"events", "track_ended_at", "DATETIME"},
+		{"events", "dwell_seconds", "REAL DEFAULT 0"},
+		{"events", "job_id", "TEXT"},
+	}
+
+	for _, c := range columns {
+		if err := s.addColumnIfNotExists(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// Индексы по добавленным колонкам
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_events_track_id ON events(track_id)`); err != nil {
+		return fmt.Errorf("failed to create track index: %w", err)
+	}
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_events_job_id ON events(job_id)`); err != nil {
+		return fmt.Errorf("failed to create job index: %w", err)
+	}
+
+	return s.makeEventCameraOptional()
+}
+
+// makeEventCameraOptional разрешает события без камеры (смена режима охраны) в базах, созданных
+// с camera_id NOT NULL. SQLite не меняет ограничения колонки, поэтому таблица пересоздается.
+func (s *Storage) makeEventCameraOptional() error {
+	var notNull bool
+	err := s.db.QueryRow(`SELECT "notnull" FROM pragma_table_info('events') WHERE name = 'camera_id'`).Scan(&notNull)
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !notNull {
+		return nil
+	}
+
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Внешние ключи отключаются вне транзакции, иначе PRAGMA не действует
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return fmt.Errorf("failed to disable foreign keys: %w", err)
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin events migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	columns := `id, camera_id, camera_name, type, description, confidence, video_path, thumbnail_path, created_at,
+		processed, starred, data, track_id, track_started_at, track_last_seen, track_ended_at, dwell_seconds, job_id`
+
+	queries := []string{
+		`CREATE TABLE events_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			camera_id TEXT,
+			camera_name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			description TEXT,
+			confidence REAL DEFAULT 0,
+			video_path TEXT,
+			thumbnail_path TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			processed BOOLEAN DEFAULT 0,
+			starred BOOLEAN DEFAULT 0,
+			data TEXT,
+			track_id TEXT,
+			track_started_at DATETIME,
+			track_last_seen DATETIME,
+			track_ended_at DATETIME,
+			dwell_seconds REAL DEFAULT 0,
+			job_id TEXT,
+			FOREIGN KEY (camera_id) REFERENCES cameras(id) ON DELETE CASCADE
+		)`,
+		`INSERT INTO events_new (` + columns + `) SELECT ` + columns + ` FROM events`,
+		`DROP TABLE events`,
+		`ALTER TABLE events_new RENAME TO events`,
+		`CREATE INDEX IF NOT EXISTS idx_events_camera_id ON events(camera_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_type ON events(type)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_track_id ON events(track_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_job_id ON events(job_id)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to migrate events table: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit events migration: %w", err)
+	}
+
+	return nil
+}
+
+// addColumnIfNotExists добавляет колонку в таблицу, если ее еще нет
+func (s *Storage) addColumnIfNotExists(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    bool
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read table info: %w", err)
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := s.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+
 	return nil
 }
 
 // Cameras возвращает все камеры
 func (s *Storage) GetCameras() ([]Camera, error) {
-	query := `SELECT id, name, rtsp_url, status, last_seen, motion_detection, ai_detection, created_at, updated_at 
+	query := `SELECT id, name, rtsp_url, status, last_seen, motion_detection, ai_detection, sensitivity, created_at, updated_at 
 			  FROM cameras ORDER BY created_at`
 
 	rows, err := s.db.Query(query)
@@ -140,7 +542,7 @@ func (s *Storage) GetCameras() ([]Camera, error) {
 		var lastSeen sql.NullTime
 
 		err := rows.Scan(&camera.ID, &camera.Name, &camera.RTSPURL, &camera.Status,
-			&lastSeen, &camera.MotionDetection, &camera.AIDetection,
+			&lastSeen, &camera.MotionDetection, &camera.AIDetection, &camera.Sensitivity,
 			&camera.CreatedAt, &camera.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan camera: %w", err)
@@ -158,14 +560,14 @@ func (s *Storage) GetCameras() ([]Camera, error) {
 
 // GetCamera возвращает камеру по ID
 func (s *Storage) GetCamera(id string) (*Camera, error) {
-	query := `SELECT id, name, rtsp_url, status, last_seen, motion_detection, ai_detection, created_at, updated_at 
+	query := `SELECT id, name, rtsp_url, status, last_seen, motion_detection, ai_detection, sensitivity, created_at, updated_at 
 			  FROM cameras WHERE id = ?`
 
 	var camera Camera
 	var lastSeen sql.NullTime
 
 	err := s.db.QueryRow(query, id).Scan(&camera.ID, &camera.Name, &camera.RTSPURL, &camera.Status,
-		&lastSeen, &camera.MotionDetection, &camera.AIDetection,
+		&lastSeen, &camera.MotionDetection, &camera.AIDetection, &camera.Sensitivity,
 		&camera.CreatedAt, &camera.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -183,12 +585,18 @@ func (s *Storage) GetCamera(id string) (*Camera, error) {
 
 // SaveCamera сохраняет или обновляет камеру
 func (s *Storage) SaveCamera(camera *Camera) error {
-	query := `INSERT OR REPLACE INTO cameras 
-			  (id, name, rtsp_url, status, last_seen, motion_detection, ai_detection, created_at, updated_at)
-			  VALUES (?, ?, ?, ?, ?, ?, ?, COALESCE((SELECT created_at FROM cameras WHERE id = ?), CURRENT_TIMESTAMP), CURRENT_TIMESTAMP)`
+	// Upsert вместо INSERT OR REPLACE: замена строки каскадно удалила бы события и зоны камеры
+	query := `INSERT INTO cameras 
+			  (id, name, rtsp_url, status, last_seen, motion_detection, ai_detection, sensitivity, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+			  ON CONFLICT(id) DO UPDATE SET
+			      name = excluded.name, rtsp_url = excluded.rtsp_url, status = excluded.status,
+			      last_seen = excluded.last_seen, motion_detection = excluded.motion_detection,
+			      ai_detection = excluded.ai_detection, sensitivity = excluded.sensitivity,
+			      updated_at = CURRENT_TIMESTAMP`
 
 	_, err := s.db.Exec(query, camera.ID, camera.Name, camera.RTSPURL, camera.Status,
-		camera.LastSeen, camera.MotionDetection, camera.AIDetection, camera.ID)
+		camera.LastSeen, camera.MotionDetection, camera.AIDetection, camera.Sensitivity)
 	if err != nil {
 		return fmt.Errorf("failed to save camera: %w", err)
 	}
@@ -196,13 +604,26 @@ func (s *Storage) SaveCamera(camera *Camera) error {
 	return nil
 }
 
-// DeleteCamera удаляет камеру
+// DeleteCamera удаляет камеру вместе с ее зонами. Правила событий с зонами камеры выключаются:
+// без зоны они срабатывали бы на весь кадр.
 func (s *Storage) DeleteCamera(id string) error {
-	_, err := s.db.Exec("DELETE FROM cameras WHERE id = ?", id)
+	tx, err := s.db.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE event_rules SET enabled = 0, updated_at = CURRENT_TIMESTAMP
+			  WHERE zone_id IN (SELECT id FROM camera_zones WHERE camera_id = ?)`
+	if _, err := tx.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to disable camera zone rules: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM cameras WHERE id = ?", id); err != nil {
 		return fmt.Errorf("failed to delete camera: %w", err)
 	}
-	return nil
+
+	return tx.Commit()
 }
 
 // UpdateCameraStatus обновляет статус камеры
@@ -217,11 +638,52 @@ func (s *Storage) UpdateCameraStatus(id, status string) error {
 
 // SaveEvent сохраняет событие
 func (s *Storage) SaveEvent(event *Event) error {
-	query := `INSERT INTO events (camera_id, camera_name, type, description, confidence, video_path, thumbnail_path, processed)
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	return insertEvent(s.db, event)
+}
+
+// execer выполняет запросы в базе или в транзакции
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertEvent добавляет событие через базу или транзакцию
+func insertEvent(db execer, event *Event) error {
+	var data interface{}
+	if len(event.Data) > 0 {
+		encoded, err := json.Marshal(event.Data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event data: %w", err)
+		}
+		data = string(encoded)
+	}
+
+	var trackID, trackStartedAt, trackLastSeen interface{}
+	if event.Track != nil {
+		trackID = event.Track.ID
+		trackStartedAt = event.Track.StartedAt.UTC()
+		trackLastSeen = event.Track.LastSeen.UTC()
+	}
+
+	// События повторного анализа датируются временем кадра в записи
+	var jobID, createdAt interface{}
+	if event.JobID != "" {
+		jobID = event.JobID
+		createdAt = event.CreatedAt.UTC()
+	}
 
-	result, err := s.db.Exec(query, event.CameraID, event.CameraName, event.Type, event.Description,
-		event.Confidence, event.VideoPath, event.ThumbnailPath, event.Processed)
+	// События без камеры (смена режима охраны) хранятся с camera_id NULL
+	var cameraID interface{}
+	if event.CameraID != "" {
+		cameraID = event.CameraID
+	}
+
+	query := `INSERT INTO events (camera_id, camera_name, type, description, confidence, video_path, thumbnail_path, processed, data,
+			  track_id, track_started_at, track_last_seen, job_id, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))`
+
+	result, err := db.Exec(query, cameraID, event.CameraName, event.Type, event.Description,
+		event.Confidence, event.VideoPath, event.ThumbnailPath, event.Processed, data,
+		trackID, trackStartedAt, trackLastSeen, jobID, createdAt)
 	if err != nil {
 		return fmt.Errorf("failed to save event: %w", err)
 	}
@@ -235,18 +697,18 @@ func (s *Storage) SaveEvent(event *Event) error {
 	return nil
 }
 
-// GetEvents возвращает события с пагинацией
+// GetEvents возвращает живые события с пагинацией
 func (s *Storage) GetEvents(limit, offset int, cameraID string) ([]Event, error) {
 	var query string
 	var args []interface{}
 
 	if cameraID != "" {
-		query = `SELECT id, camera_id, camera_name, type, description, confidence, video_path, thumbnail_path, created_at, processed
-				 FROM events WHERE camera_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`
+		query = `SELECT ` + eventColumns + `
+				 FROM events WHERE camera_id = ? AND job_id IS NULL ORDER BY created_at DESC LIMIT ? OFFSET ?`
 		args = []interface{}{cameraID, limit, offset}
 	} else {
-		query = `SELECT id, camera_id, camera_name, type, description, confidence, video_path, thumbnail_path, created_at, processed
-				 FROM events ORDER BY created_at DESC LIMIT ? OFFSET ?`
+		query = `SELECT ` + eventColumns + `
+				 FROM events WHERE job_id IS NULL ORDER BY created_at DESC LIMIT ? OFFSET ?`
 		args = []interface{}{limit, offset}
 	}
 
@@ -256,25 +718,62 @@ func (s *Storage) GetEvents(limit, offset int, cameraID string) ([]Event, error)
 	}
 	defer rows.Close()
 
-	var events []Event
-	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.ID, &event.CameraID, &event.CameraName, &event.Type,
-			&event.Description, &event.Confidence, &event.VideoPath,
-			&event.ThumbnailPath, &event.CreatedAt, &event.Processed)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan event: %w", err)
-		}
-		events = append(events, event)
+	return scanEvents(rows)
+}
+
+// GetEventsBetween возвращает живые события всех камер за интервал времени в хронологическом порядке
+func (s *Storage) GetEventsBetween(from, to time.Time, limit int) ([]Event, error) {
+	query := `SELECT ` + eventColumns + `
+			  FROM events WHERE job_id IS NULL AND created_at >= ? AND created_at <= ?
+			  ORDER BY created_at LIMIT ?`
+
+	rows, err := s.db.Query(query, from.UTC(), to.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
+	defer rows.Close()
 
-	return events, rows.Err()
+	return scanEvents(rows)
+}
+
+// GetEventsInRange возвращает живые события камеры за интервал времени в хронологическом порядке
+func (s *Storage) GetEventsInRange(cameraID string, from, to time.Time) ([]Event, error) {
+	query := `SELECT ` + eventColumns + `
+			  FROM events WHERE camera_id = ? AND job_id IS NULL AND created_at >= ? AND created_at <= ?
+			  ORDER BY created_at`
+
+	rows, err := s.db.Query(query, cameraID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// GetEvent возвращает событие по ID
+func (s *Storage) GetEvent(id int) (*Event, error) {
+	rows, err := s.db.Query(`SELECT `+eventColumns+` FROM events WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event: %w", err)
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	return &events[0], nil
 }
 
 // GetUnprocessedEvents возвращает необработанные события
 func (s *Storage) GetUnprocessedEvents() ([]Event, error) {
-	query := `SELECT id, camera_id, camera_name, type, description, confidence, video_path, thumbnail_path, created_at, processed
-			  FROM events WHERE processed = 0 ORDER BY created_at`
+	query := `SELECT ` + eventColumns + `
+			  FROM events WHERE processed = 0 AND job_id IS NULL ORDER BY created_at`
 
 	rows, err := s.db.Query(query)
 	if err != nil {
@@ -282,15 +781,42 @@ func (s *Storage) GetUnprocessedEvents() ([]Event, error) {
 	}
 	defer rows.Close()
 
+	return scanEvents(rows)
+}
+
+// scanEvents читает события из результата запроса
+func scanEvents(rows *sql.Rows) ([]Event, error) {
 	var events []Event
 	for rows.Next() {
 		var event Event
-		err := rows.Scan(&event.ID, &event.CameraID, &event.CameraName, &event.Type,
-			&event.Description, &event.Confidence, &event.VideoPath,
-			&event.ThumbnailPath, &event.CreatedAt, &event.Processed)
+		var cameraID, videoPath, thumbnailPath, data, trackID, jobID sql.NullString
+		var trackStartedAt, trackLastSeen, trackEndedAt sql.NullTime
+		var dwell sql.NullFloat64
+		err := rows.Scan(&event.ID, &cameraID, &event.CameraName, &event.Type,
+			&event.Description, &event.Confidence, &videoPath,
+			&thumbnailPath, &event.CreatedAt, &event.Processed, &event.Starred, &data,
+			&trackID, &trackStartedAt, &trackLastSeen, &trackEndedAt, &dwell, &jobID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
 		}
+		event.CameraID = cameraID.String
+		event.VideoPath = videoPath.String
+		event.ThumbnailPath = thumbnailPath.String
+		event.JobID = jobID.String
+		if data.Valid && data.String != "" {
+			if err := json.Unmarshal([]byte(data.String), &event.Data); err != nil {
+				return nil, fmt.Errorf("failed to parse event data: %w", err)
+			}
+		}
+		if trackID.Valid && trackID.String != "" {
+			event.Track = &EventTrack{
+				ID:           trackID.String,
+				StartedAt:    trackStartedAt.Time,
+				LastSeen:     trackLastSeen.Time,
+				EndedAt:      trackEndedAt.Time,
+				DwellSeconds: dwell.Float64,
+			}
+		}
 		events = append(events, event)
 	}
 
@@ -306,6 +832,44 @@ func (s *Storage) MarkEventProcessed(id int) error {
 	return nil
 }
 
+// UpdateEventTrack обновляет время жизни трека на событии, созданном при его появлении.
+// Нулевой EndedAt означает, что объект все еще в кадре.
+func (s *Storage) UpdateEventTrack(track EventTrack, confidence float32) error {
+	var ended interface{}
+	if !track.EndedAt.IsZero() {
+		ended = track.EndedAt.UTC()
+	}
+
+	query := `UPDATE events
+			  SET track_last_seen = ?, track_ended_at = ?, dwell_seconds = ?, confidence = MAX(confidence, ?)
+			  WHERE track_id = ?`
+
+	_, err := s.db.Exec(query, track.LastSeen.UTC(), ended, track.DwellSeconds, confidence, track.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update event track: %w", err)
+	}
+
+	return nil
+}
+
+// SetEventStarred отмечает событие как избранное, защищая его от удаления
+func (s *Storage) SetEventStarred(id int, starred bool) error {
+	result, err := s.db.Exec("UPDATE events SET starred = ? WHERE id = ?", starred, id)
+	if err != nil {
+		return fmt.Errorf("failed to update event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 // DeleteOldEvents удаляет старые события
 func (s *Storage) DeleteOldEvents(days int) error {
 	query := `DELETE FROM events WHERE created_at < datetime('now', '-' || ? || ' days')`
@@ -361,7 +925,7 @@ func (s *Storage) GetStats() (map[string]interface{}, error) {
 
 	// События за сегодня
 	var todayEvents int
-	err = s.db.QueryRow("SELECT COUNT(*) FROM events WHERE DATE(created_at) = DATE('now')").Scan(&todayEvents)
+	err = s.db.QueryRow("SELECT COUNT(*) FROM events WHERE DATE(created_at) = DATE('now') AND job_id IS NULL").Scan(&todayEvents)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get today events count: %w", err)
 	}
@@ -369,7 +933,7 @@ func (s *Storage) GetStats() (map[string]interface{}, error) {
 
 	// Всего событий
 	var totalEvents int
-	err = s.db.QueryRow("SELECT COUNT(*) FROM events").Scan(&totalEvents)
+	err = s.db.QueryRow("SELECT COUNT(*) FROM events WHERE job_id IS NULL").Scan(&totalEvents)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total events count: %w", err)
 	}
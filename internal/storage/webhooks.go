@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Webhook внешний адрес, на который отправляются события
+type Webhook struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret"`      // ключ HMAC подписи запросов
+	EventTypes []string  `json:"event_types"` // пусто - все события
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery попытка отправки события на вебхук
+type WebhookDelivery struct {
+	ID         int       `json:"id"`
+	WebhookID  int       `json:"webhook_id"`
+	Sequence   int64     `json:"sequence"` // номер записи в журнале событий
+	EventID    int       `json:"event_id,omitempty"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// webhookColumns колонки вебхуков в порядке scanWebhook
+const webhookColumns = `id, name, url, secret, event_types, enabled, created_at, updated_at`
+
+// webhookDeliveryColumns колонки журнала доставок в порядке scanWebhookDelivery
+const webhookDeliveryColumns = `id, webhook_id, sequence, event_id, event_type, attempt, status_code, success, error,
+	duration_ms, created_at`
+
+// GetWebhooks возвращает все вебхуки
+func (s *Storage) GetWebhooks() ([]Webhook, error) {
+	rows, err := s.db.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// GetWebhook возвращает вебхук по ID
+func (s *Storage) GetWebhook(id int) (*Webhook, error) {
+	webhook, err := scanWebhook(s.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// SaveWebhook создает или обновляет вебхук
+func (s *Storage) SaveWebhook(webhook *Webhook) error {
+	eventTypes := strings.Join(webhook.EventTypes, ",")
+
+	if webhook.ID == 0 {
+		query := `INSERT INTO webhooks (name, url, secret, event_types, enabled) VALUES (?, ?, ?, ?, ?)`
+
+		result, err := s.db.Exec(query, webhook.Name, webhook.URL, webhook.Secret, eventTypes, webhook.Enabled)
+		if err != nil {
+			return fmt.Errorf("failed to create webhook: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get webhook ID: %w", err)
+		}
+
+		webhook.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE webhooks SET name = ?, url = ?, secret = ?, event_types = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = ?`
+
+	if _, err := s.db.Exec(query, webhook.Name, webhook.URL, webhook.Secret, eventTypes, webhook.Enabled, webhook.ID); err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteWebhook удаляет вебхук вместе с журналом доставок
+func (s *Storage) DeleteWebhook(id int) error {
+	if _, err := s.db.Exec("DELETE FROM webhooks WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// SaveWebhookDelivery записывает попытку отправки события на вебхук
+func (s *Storage) SaveWebhookDelivery(delivery *WebhookDelivery) error {
+	var eventID interface{}
+	if delivery.EventID != 0 {
+		eventID = delivery.EventID
+	}
+
+	query := `INSERT INTO webhook_deliveries (webhook_id, sequence, event_id, event_type, attempt, status_code, success,
+			  error, duration_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.db.Exec(query, delivery.WebhookID, delivery.Sequence, eventID, delivery.EventType, delivery.Attempt,
+		delivery.StatusCode, delivery.Success, delivery.Error, delivery.DurationMS)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get webhook delivery ID: %w", err)
+	}
+
+	delivery.ID = int(id)
+	return nil
+}
+
+// CountWebhookAttempts возвращает количество попыток отправить запись журнала на вебхук
+func (s *Storage) CountWebhookAttempts(webhookID int, sequence int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ? AND sequence = ?`,
+		webhookID, sequence).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count webhook attempts: %w", err)
+	}
+	return count, nil
+}
+
+// GetWebhookDeliveries возвращает последние попытки отправки на вебхук
+func (s *Storage) GetWebhookDeliveries(webhookID, limit, offset int) ([]WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = ?
+			  ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := s.db.Query(query, webhookID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// DeleteWebhookDeliveriesBefore удаляет записи журнала доставок старше указанного времени
+func (s *Storage) DeleteWebhookDeliveriesBefore(before time.Time) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM webhook_deliveries WHERE created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune webhook deliveries: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// scanWebhook читает вебхук из строки результата
+func scanWebhook(row interface{ Scan(...interface{}) error }) (*Webhook, error) {
+	var webhook Webhook
+	var eventTypes sql.NullString
+
+	err := row.Scan(&webhook.ID, &webhook.Name, &webhook.URL, &webhook.Secret, &eventTypes, &webhook.Enabled,
+		&webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan webhook: %w", err)
+	}
+
+	webhook.EventTypes = []string{}
+	if eventTypes.String != "" {
+		webhook.EventTypes = strings.Split(eventTypes.String, ",")
+	}
+
+	return &webhook, nil
+}
+
+// scanWebhookDelivery читает попытку отправки из строки результата
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	var eventID sql.NullInt64
+	var errText sql.NullString
+
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Sequence, &eventID, &delivery.EventType,
+		&delivery.Attempt, &delivery.StatusCode, &delivery.Success, &errText, &delivery.DurationMS, &delivery.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+	}
+
+	delivery.EventID = int(eventID.Int64)
+	delivery.Error = errText.String
+	return &delivery, nil
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Типы зон
+const (
+	ZoneTypeInclude = "include"
+	ZoneTypeExclude = "exclude"
+	ZoneTypeRule    = "rule" // область для правил: не ограничивает детекцию камеры
+)
+
+// ZonePoint вершина полигона в долях ширины и высоты кадра (0..1)
+type ZonePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Zone представляет полигональную зону камеры
+type Zone struct {
+	ID        int         `json:"id"`
+	CameraID  string      `json:"camera_id"`
+	Name      string      `json:"name"`
+	Type      string      `json:"type"` // include, exclude, rule
+	Points    []ZonePoint `json:"points"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// GetZones возвращает зоны камеры
+func (s *Storage) GetZones(cameraID string) ([]Zone, error) {
+	query := `SELECT id, camera_id, name, type, points, created_at, updated_at
+			  FROM camera_zones WHERE camera_id = ? ORDER BY id`
+
+	rows, err := s.db.Query(query, cameraID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query zones: %w", err)
+	}
+	defer rows.Close()
+
+	var zones []Zone
+	for rows.Next() {
+		zone, err := scanZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, *zone)
+	}
+
+	return zones, rows.Err()
+}
+
+// GetZone возвращает зону по ID
+func (s *Storage) GetZone(id int) (*Zone, error) {
+	query := `SELECT id, camera_id, name, type, points, created_at, updated_at
+			  FROM camera_zones WHERE id = ?`
+
+	zone, err := scanZone(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return zone, nil
+}
+
+// SaveZone создает зону или обновляет существующую
+func (s *Storage) SaveZone(zone *Zone) error {
+	points, err := json.Marshal(zone.Points)
+	if err != nil {
+		return fmt.Errorf("failed to marshal zone points: %w", err)
+	}
+
+	if zone.ID == 0 {
+		query := `INSERT INTO camera_zones (camera_id, name, type, points) VALUES (?, ?, ?, ?)`
+
+		result, err := s.db.Exec(query, zone.CameraID, zone.Name, zone.Type, string(points))
+		if err != nil {
+			return fmt.Errorf("failed to create zone: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get zone ID: %w", err)
+		}
+
+		zone.ID = int(id)
+		return nil
+	}
+
+	query := `UPDATE camera_zones SET name = ?, type = ?, points = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+
+	if _, err := s.db.Exec(query, zone.Name, zone.Type, string(points), zone.ID); err != nil {
+		return fmt.Errorf("failed to update zone: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteZone удаляет зону. Зону, на которую ссылаются правила детекции или событий, удалить нельзя:
+// правило с зоной не должно молча пропасть или начать срабатывать на весь кадр.
+func (s *Storage) DeleteZone(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var detectionRules, eventRules int
+	query := `SELECT (SELECT COUNT(*) FROM detection_rules WHERE zone_id = ?),
+			  (SELECT COUNT(*) FROM event_rules WHERE zone_id = ?)`
+	if err := tx.QueryRow(query, id, id).Scan(&detectionRules, &eventRules); err != nil {
+		return fmt.Errorf("failed to check zone rules: %w", err)
+	}
+	if detectionRules > 0 || eventRules > 0 {
+		return fmt.Errorf("zone is used by %d detection rule(s) and %d event rule(s), change or delete them first",
+			detectionRules, eventRules)
+	}
+
+	if _, err := tx.Exec("DELETE FROM camera_zones WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete zone: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// scanZone читает зону из строки результата
+func scanZone(row interface{ Scan(...interface{}) error }) (*Zone, error) {
+	var zone Zone
+	var points string
+
+	err := row.Scan(&zone.ID, &zone.CameraID, &zone.Name, &zone.Type, &points, &zone.CreatedAt, &zone.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan zone: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(points), &zone.Points); err != nil {
+		return nil, fmt.Errorf("failed to parse zone points: %w", err)
+	}
+
+	return &zone, nil
+}
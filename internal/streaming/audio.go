@@ -0,0 +1,73 @@
+package streaming
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"ocuai/internal/audio"
+	"ocuai/internal/events"
+)
+
+// monitorAudio анализирует аудиодорожку камеры через ее поток в go2rtc
+func (s *Server) monitorAudio(camera *CameraStream) {
+	defer camera.wg.Done()
+
+	if !s.ensureGo2rtcStream(camera) {
+		return
+	}
+
+	monitor := audio.NewMonitor(camera.ID, s.go2rtc.GetStreamURL(camera.ID, "rtsp"), s.audioConfig, s.audioClassifier,
+		func(detection audio.Detection) {
+			s.emitAudioDetection(camera, detection)
+		})
+	monitor.Run(camera.ctx)
+}
+
+// ensureGo2rtcStream регистрирует поток камеры в go2rtc, повторяя попытки до остановки камеры
+func (s *Server) ensureGo2rtcStream(camera *CameraStream) bool {
+	for {
+		exists, err := s.go2rtc.StreamExists(camera.ID)
+		if err == nil && !exists {
+			err = s.go2rtc.AddStream(camera.ID, camera.RTSPURL)
+		}
+		if err == nil {
+			return true
+		}
+
+		log.Printf("Failed to register camera %s in go2rtc for audio: %v", camera.ID, err)
+
+		select {
+		case <-camera.ctx.Done():
+			return false
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+// emitAudioDetection создает событие для звука из горутины звука, не дожидаясь видеокадра:
+// пока видеопоток переподключается, события получают последний кадр камеры
+func (s *Server) emitAudioDetection(camera *CameraStream, detection audio.Detection) {
+	now := detection.Timestamp
+
+	frame := camera.snapshot()
+	defer frame.Close()
+
+	s.eventManager.EmitAudioDetected(camera.ID, camera.Name, audioDescription(detection), detection.Confidence, events.Media{
+		VideoPath:     s.triggerClip(camera, now),
+		ThumbnailPath: s.saveThumbnail(camera, frame, nil, "audio", now),
+	}, map[string]interface{}{
+		"kind":     detection.Kind,
+		"class":    detection.Class,
+		"level_db": detection.Level,
+	})
+	log.Printf("Audio detected on camera %s: %s (%.1f dBFS)", camera.ID, detection.Class, detection.Level)
+}
+
+// audioDescription возвращает описание звукового события
+func audioDescription(detection audio.Detection) string {
+	if detection.Kind == audio.KindLoudness {
+		return fmt.Sprintf("Loud noise (%.0f dBFS)", detection.Level)
+	}
+	return fmt.Sprintf("Sound detected: %s", detection.Class)
+}
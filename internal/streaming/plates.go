@@ -0,0 +1,125 @@
+package streaming
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ocuai/internal/ai"
+	"ocuai/internal/events"
+	"ocuai/internal/storage"
+
+	"gocv.io/x/gocv"
+)
+
+// updatePlates распознает номера транспорта на камерах ALPR.
+// Номер читается на каждом кадре, пока не будет распознан с достаточной уверенностью,
+// после чего сохраняется в историю один раз на трек.
+func (s *Server) updatePlates(camera *CameraStream, update ai.TrackUpdate, frame gocv.Mat, now time.Time) {
+	for _, track := range update.Ended {
+		delete(camera.platesRead, track.ID)
+	}
+
+	if !camera.ALPR || s.plates == nil {
+		return
+	}
+
+	for _, track := range append(update.Started, update.Updated...) {
+		// Номера читаются только у объектов, прошедших правила детекции
+		if !camera.reported[track.ID] || camera.platesRead[track.ID] || !s.plates.IsVehicle(track.Class) {
+			continue
+		}
+
+		read, err := s.plates.Recognize(frame, track.BBox)
+		if err != nil {
+			log.Printf("Plate recognition error for camera %s: %v", camera.ID, err)
+			continue
+		}
+		if read == nil {
+			continue
+		}
+
+		camera.platesRead[track.ID] = true
+		s.savePlateRead(camera, track, read, frame, now)
+	}
+}
+
+// savePlateRead сохраняет номер в историю и создает событие, если номер есть в списках
+func (s *Server) savePlateRead(camera *CameraStream, track ai.Track, read *ai.PlateRead, frame gocv.Mat, now time.Time) {
+	entry, err := s.storage.FindWatchlistEntry(read.Plate)
+	if err != nil {
+		log.Printf("Failed to check plate watchlist: %v", err)
+	}
+
+	record := &storage.PlateRead{
+		CameraID:   camera.ID,
+		CameraName: camera.Name,
+		Plate:      read.Plate,
+		Confidence: read.Confidence,
+		Class:      track.Class,
+		TrackID:    track.ID,
+		ImagePath:  s.savePlateImage(camera, track.ID, read.Image, now),
+		CreatedAt:  now,
+	}
+	if entry != nil {
+		record.Watchlist = entry.List
+	}
+
+	if err := s.storage.SavePlateRead(record); err != nil {
+		log.Printf("Failed to save plate read for camera %s: %v", camera.ID, err)
+	}
+	log.Printf("Plate %s (%.2f) recognized on camera %s, track %s", read.Plate, read.Confidence, camera.ID, track.ID)
+
+	if entry == nil {
+		return
+	}
+
+	media := events.Media{
+		VideoPath:     s.triggerClip(camera, now),
+		ThumbnailPath: s.saveThumbnail(camera, frame, []ai.Detection{trackDetection(track)}, "plate", now),
+	}
+
+	s.eventManager.EmitPlateMatch(
+		camera.ID,
+		camera.Name,
+		read.Plate,
+		entry.List,
+		entry.Label,
+		read.Confidence,
+		media,
+		map[string]interface{}{
+			"plate":        read.Plate,
+			"plate_id":     record.ID,
+			"list":         entry.List,
+			"label":        entry.Label,
+			"watchlist_id": entry.ID,
+			"class":        track.Class,
+			"track_id":     track.ID,
+			"bbox":         track.BBox,
+			"plate_bbox":   read.BBox,
+		},
+	)
+}
+
+// savePlateImage сохраняет вырезку номера рядом с клипами камеры.
+// В имени файла есть трек: номера нескольких машин в одном кадре не перезаписывают друг друга.
+func (s *Server) savePlateImage(camera *CameraStream, trackID string, image []byte, now time.Time) string {
+	if len(image) == 0 {
+		return ""
+	}
+
+	dir := filepath.Join(s.storageConfig.VideoPath, camera.ID, "clips", now.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Failed to create plate directory for camera %s: %v", camera.ID, err)
+		return ""
+	}
+
+	path := filepath.Join(dir, now.Format("150405.000")+"_plate_"+trackID+".jpg")
+	if err := os.WriteFile(path, image, 0644); err != nil {
+		log.Printf("Failed to write plate image for camera %s: %v", camera.ID, err)
+		return ""
+	}
+
+	return path
+}
@@ -0,0 +1,235 @@
+package streaming
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ocuai/internal/config"
+
+	"gocv.io/x/gocv"
+)
+
+// bufferedFrame кадр из pre-roll буфера, сжатый в JPEG для экономии памяти
+type bufferedFrame struct {
+	data       []byte
+	capturedAt time.Time
+}
+
+// eventClip активный клип события
+type eventClip struct {
+	writer      *gocv.VideoWriter
+	path        string
+	width       int
+	height      int
+	until       time.Time
+	lastSizeChk time.Time
+}
+
+// clipRecorder записывает клипы событий с pre-roll и post-roll для одной камеры
+type clipRecorder struct {
+	cameraID      string
+	videoPath     string
+	codec         string
+	fps           float64
+	frameInterval time.Duration
+	preRoll       time.Duration
+	postRoll      time.Duration
+	maxBytes      int64
+
+	mu          sync.Mutex
+	buffer      []bufferedFrame
+	lastFrameAt time.Time
+	width       int
+	height      int
+	clip        *eventClip
+}
+
+// newClipRecorder создает рекордер клипов для камеры
+func newClipRecorder(cameraID string, storageCfg config.StorageConfig, cfg config.RecordingConfig) *clipRecorder {
+	fps := cfg.FPS
+	if fps <= 0 {
+		fps = 10
+	}
+
+	codec := cfg.Codec
+	if len(codec) != 4 {
+		codec = "avc1"
+	}
+
+	return &clipRecorder{
+		cameraID:      cameraID,
+		videoPath:     storageCfg.VideoPath,
+		codec:         codec,
+		fps:           float64(fps),
+		frameInterval: time.Second / time.Duration(fps),
+		preRoll:       time.Duration(cfg.PreRollSeconds) * time.Second,
+		postRoll:      time.Duration(cfg.PostRollSeconds) * time.Second,
+		maxBytes:      int64(storageCfg.MaxVideoSizeMB) * 1024 * 1024,
+	}
+}
+
+// AddFrame добавляет кадр в pre-roll буфер или в активный клип
+func (r *clipRecorder) AddFrame(frame gocv.Mat, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Ограничиваем частоту кадров записи
+	if now.Sub(r.lastFrameAt) < r.frameInterval {
+		return
+	}
+	r.lastFrameAt = now
+	r.width = frame.Cols()
+	r.height = frame.Rows()
+
+	if r.clip != nil {
+		r.writeClipFrame(frame, now)
+		return
+	}
+
+	if r.preRoll <= 0 {
+		return
+	}
+
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, frame)
+	if err != nil {
+		log.Printf("Failed to buffer frame for camera %s: %v", r.cameraID, err)
+		return
+	}
+	data := append([]byte(nil), buf.GetBytes()...)
+	buf.Close()
+
+	r.buffer = append(r.buffer, bufferedFrame{data: data, capturedAt: now})
+
+	// Отбрасываем кадры старше pre-roll окна
+	cutoff := now.Add(-r.preRoll)
+	drop := 0
+	for drop < len(r.buffer) && r.buffer[drop].capturedAt.Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		r.buffer = append(r.buffer[:0], r.buffer[drop:]...)
+	}
+}
+
+// Trigger начинает или продлевает клип и возвращает путь к файлу клипа
+func (r *clipRecorder) Trigger(now time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.clip != nil {
+		r.clip.until = now.Add(r.postRoll)
+		return r.clip.path
+	}
+
+	if r.width == 0 || r.height == 0 {
+		return ""
+	}
+
+	dir := filepath.Join(r.videoPath, r.cameraID, "clips", now.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Failed to create clip directory for camera %s: %v", r.cameraID, err)
+		return ""
+	}
+
+	// Миллисекунды в имени: клип, начатый сразу после предыдущего, не перезапишет его
+	path := filepath.Join(dir, now.Format("150405.000")+".mp4")
+	writer, err := gocv.VideoWriterFile(path, r.codec, r.fps, r.width, r.height, true)
+	if err != nil {
+		log.Printf("Failed to create clip for camera %s: %v", r.cameraID, err)
+		return ""
+	}
+	if !writer.IsOpened() {
+		writer.Close()
+		log.Printf("Failed to open clip writer for camera %s: %s", r.cameraID, path)
+		return ""
+	}
+
+	r.clip = &eventClip{
+		writer:      writer,
+		path:        path,
+		width:       r.width,
+		height:      r.height,
+		until:       now.Add(r.postRoll),
+		lastSizeChk: now,
+	}
+
+	// Записываем накопленный pre-roll
+	for _, buffered := range r.buffer {
+		mat, err := gocv.IMDecode(buffered.data, gocv.IMReadColor)
+		if err != nil {
+			continue
+		}
+		if mat.Cols() == r.clip.width && mat.Rows() == r.clip.height {
+			if err := writer.Write(mat); err != nil {
+				log.Printf("Failed to write pre-roll frame for camera %s: %v", r.cameraID, err)
+			}
+		}
+		mat.Close()
+	}
+	r.buffer = r.buffer[:0]
+
+	log.Printf("Started recording clip for camera %s: %s", r.cameraID, path)
+	return path
+}
+
+// writeClipFrame записывает кадр в активный клип и закрывает его по истечении post-roll
+func (r *clipRecorder) writeClipFrame(frame gocv.Mat, now time.Time) {
+	clip := r.clip
+
+	if frame.Cols() != clip.width || frame.Rows() != clip.height {
+		// Разрешение изменилось - продолжать запись в этот файл нельзя
+		r.finishClip("resolution changed")
+		return
+	}
+
+	if err := clip.writer.Write(frame); err != nil {
+		log.Printf("Failed to write clip frame for camera %s: %v", r.cameraID, err)
+	}
+
+	if now.After(clip.until) {
+		r.finishClip("post-roll elapsed")
+		return
+	}
+
+	// Проверяем размер файла раз в секунду
+	if r.maxBytes > 0 && now.Sub(clip.lastSizeChk) >= time.Second {
+		clip.lastSizeChk = now
+		if info, err := os.Stat(clip.path); err == nil && info.Size() >= r.maxBytes {
+			r.finishClip(fmt.Sprintf("size limit %d MB reached", r.maxBytes/1024/1024))
+		}
+	}
+}
+
+// IsRecording возвращает true, если сейчас пишется клип
+func (r *clipRecorder) IsRecording() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.clip != nil
+}
+
+// finishClip закрывает активный клип
+func (r *clipRecorder) finishClip(reason string) {
+	if r.clip == nil {
+		return
+	}
+
+	if err := r.clip.writer.Close(); err != nil {
+		log.Printf("Failed to close clip for camera %s: %v", r.cameraID, err)
+	}
+
+	log.Printf("Finished recording clip for camera %s (%s): %s", r.cameraID, reason, r.clip.path)
+	r.clip = nil
+}
+
+// Close закрывает активный клип и очищает буфер
+func (r *clipRecorder) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finishClip("camera stopped")
+	r.buffer = nil
+}
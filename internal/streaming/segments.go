@@ -0,0 +1,201 @@
+package streaming
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ocuai/internal/config"
+	"ocuai/internal/storage"
+
+	"gocv.io/x/gocv"
+)
+
+// activeSegment открытый сегмент непрерывной записи
+type activeSegment struct {
+	writer *gocv.VideoWriter
+	record storage.Recording
+	width  int
+	height int
+	endsAt time.Time
+}
+
+// segmentRecorder ведет непрерывную запись камеры сегментами фиксированной длины
+type segmentRecorder struct {
+	cameraID      string
+	videoPath     string
+	codec         string
+	format        string
+	fps           float64
+	frameInterval time.Duration
+	length        time.Duration
+	storage       *storage.Storage
+
+	lastFrameAt time.Time
+	segment     *activeSegment
+}
+
+// newSegmentRecorder создает рекордер непрерывной записи для камеры
+func newSegmentRecorder(cameraID string, store *storage.Storage, storageCfg config.StorageConfig, cfg config.RecordingConfig) *segmentRecorder {
+	fps := cfg.FPS
+	if fps <= 0 {
+		fps = 10
+	}
+
+	codec := cfg.Codec
+	if len(codec) != 4 {
+		codec = "avc1"
+	}
+
+	minutes := cfg.SegmentMinutes
+	if minutes < 1 {
+		minutes = 1
+	} else if minutes > 10 {
+		minutes = 10
+	}
+
+	format := cfg.SegmentFormat
+	switch format {
+	case "ts", "mkv", "mp4":
+	default:
+		format = "ts"
+	}
+
+	return &segmentRecorder{
+		cameraID:      cameraID,
+		videoPath:     storageCfg.VideoPath,
+		codec:         codec,
+		format:        format,
+		fps:           float64(fps),
+		frameInterval: time.Second / time.Duration(fps),
+		length:        time.Duration(minutes) * time.Minute,
+		storage:       store,
+	}
+}
+
+// AddFrame пишет кадр в текущий сегмент, при необходимости открывая следующий
+func (r *segmentRecorder) AddFrame(frame gocv.Mat, now time.Time) {
+	if now.Sub(r.lastFrameAt) < r.frameInterval {
+		return
+	}
+	r.lastFrameAt = now
+
+	if r.segment != nil && (!now.Before(r.segment.endsAt) ||
+		frame.Cols() != r.segment.width || frame.Rows() != r.segment.height) {
+		r.finishSegment(now)
+	}
+
+	if r.segment == nil {
+		if !r.openSegment(frame, now) {
+			return
+		}
+	}
+
+	if err := r.segment.writer.Write(frame); err != nil {
+		log.Printf("Failed to write segment frame for camera %s: %v", r.cameraID, err)
+	}
+}
+
+// openSegment открывает новый сегмент, выровненный по границе интервала
+func (r *segmentRecorder) openSegment(frame gocv.Mat, now time.Time) bool {
+	dir := filepath.Join(r.videoPath, r.cameraID, now.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Failed to create segment directory for camera %s: %v", r.cameraID, err)
+		return false
+	}
+
+	path := filepath.Join(dir, now.Format("150405")+"."+r.format)
+	writer, err := gocv.VideoWriterFile(path, r.codec, r.fps, frame.Cols(), frame.Rows(), true)
+	if err != nil {
+		log.Printf("Failed to create segment for camera %s: %v", r.cameraID, err)
+		return false
+	}
+	if !writer.IsOpened() {
+		writer.Close()
+		log.Printf("Failed to open segment writer for camera %s: %s", r.cameraID, path)
+		return false
+	}
+
+	record := storage.Recording{
+		CameraID:  r.cameraID,
+		Path:      path,
+		StartTime: now,
+	}
+	if err := r.storage.CreateRecording(&record); err != nil {
+		log.Printf("Failed to index segment for camera %s: %v", r.cameraID, err)
+	}
+
+	r.segment = &activeSegment{
+		writer: writer,
+		record: record,
+		width:  frame.Cols(),
+		height: frame.Rows(),
+		endsAt: now.Truncate(r.length).Add(r.length),
+	}
+
+	return true
+}
+
+// finishSegment закрывает текущий сегмент и обновляет индекс
+func (r *segmentRecorder) finishSegment(now time.Time) {
+	if r.segment == nil {
+		return
+	}
+
+	segment := r.segment
+	r.segment = nil
+
+	if err := segment.writer.Close(); err != nil {
+		log.Printf("Failed to close segment for camera %s: %v", r.cameraID, err)
+	}
+
+	if segment.record.ID == 0 {
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(segment.record.Path); err == nil {
+		size = info.Size()
+	}
+
+	if err := r.storage.FinishRecording(segment.record.ID, now, size); err != nil {
+		log.Printf("Failed to finalize segment for camera %s: %v", r.cameraID, err)
+	}
+}
+
+// Close завершает текущий сегмент
+func (r *segmentRecorder) Close() {
+	r.finishSegment(time.Now())
+}
+
+// recoverRecordings завершает сегменты, оставшиеся открытыми после аварийной остановки
+func recoverRecordings(store *storage.Storage) {
+	recordings, err := store.GetIncompleteRecordings()
+	if err != nil {
+		log.Printf("Failed to get incomplete recordings: %v", err)
+		return
+	}
+
+	for _, recording := range recordings {
+		info, statErr := os.Stat(recording.Path)
+		if statErr != nil || info.Size() == 0 {
+			// Данные не успели попасть в файл - удаляем сегмент
+			if err := store.DeleteRecording(recording.ID); err != nil {
+				log.Printf("Failed to drop broken recording %d: %v", recording.ID, err)
+			}
+			if statErr == nil {
+				os.Remove(recording.Path)
+			}
+			continue
+		}
+
+		// Время последней записи в файл - фактический конец сегмента
+		if err := store.FinishRecording(recording.ID, info.ModTime(), info.Size()); err != nil {
+			log.Printf("Failed to recover recording %d: %v", recording.ID, err)
+			continue
+		}
+
+		log.Printf("Recovered interrupted recording: %s", recording.Path)
+	}
+}
@@ -5,50 +5,97 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"ocuai/internal/ai"
+	"ocuai/internal/arming"
+	"ocuai/internal/audio"
 	"ocuai/internal/config"
 	"ocuai/internal/events"
 	"ocuai/internal/go2rtc"
+	"ocuai/internal/storage"
 
 	"gocv.io/x/gocv"
 )
 
+// Настройки детекции камеры, переключаемые через SetCameraDetection
+const (
+	SettingMotionDetection = "motion_detection"
+	SettingAIDetection     = "ai_detection"
+)
+
 // Server представляет стриминг сервер
 type Server struct {
-	config       config.StreamingConfig
-	eventManager *events.Manager
-	aiProcessor  *ai.Processor
-	cameras      map[string]*CameraStream
-	mu           sync.RWMutex
-	ctx          context.Context
-	cancel       context.CancelFunc
-	wg           sync.WaitGroup
-	go2rtc       *go2rtc.Manager
-	scanner      *go2rtc.CameraScanner
+	config          config.StreamingConfig
+	storageConfig   config.StorageConfig
+	recordingConfig config.RecordingConfig
+	motionConfig    config.MotionConfig
+	trackerConfig   config.TrackerConfig
+	storage         *storage.Storage
+	eventManager    *events.Manager
+	arming          *arming.Manager
+	aiProcessor     *ai.Processor
+	inference       *ai.InferencePool
+	inferenceFPS    float64
+	faces           *ai.FaceRecognizer
+	plates          *ai.PlateRecognizer
+	audioConfig     config.AudioConfig
+	tamperConfig    config.TamperConfig
+	audioClassifier audio.Classifier
+	cameras         map[string]*CameraStream
+	mu              sync.RWMutex
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	go2rtc          *go2rtc.Manager
+	scanner         *go2rtc.CameraScanner
 }
 
 // CameraStream представляет поток с камеры
 type CameraStream struct {
-	ID              string
-	Name            string
-	RTSPURL         string
-	Status          string
-	Stream          *gocv.VideoCapture
-	MotionDetection bool
-	AIDetection     bool
-	LastFrame       gocv.Mat
-	PrevFrame       gocv.Mat
-	LastMotionTime  time.Time
-	IsRecording     bool
-	ctx             context.Context
-	cancel          context.CancelFunc
-	wg              sync.WaitGroup
+	ID               string
+	Name             string
+	RTSPURL          string
+	Status           string
+	Stream           *gocv.VideoCapture
+	MotionDetection  atomic.Bool // меняется из API, правил и MQTT во время обработки кадров
+	AIDetection      atomic.Bool
+	AIModel          string
+	InferenceFPS     float64
+	ALPR             bool
+	AudioDetection   bool
+	RecordMotion     bool
+	RecordContinuous bool
+	Sensitivity      float32
+	LastFrame        gocv.Mat
+	nextFrame        gocv.Mat // буфер, в который читается следующий кадр
+	LastMotionTime   time.Time
+	IsRecording      bool
+	recorder         *clipRecorder
+	segments         *segmentRecorder
+	zones            *ai.ZoneMask
+	motion           ai.MotionDetector
+	tracker          *ai.Tracker
+	rules            *ai.RuleSet
+	analytics        *ai.Analytics
+	reported         map[string]bool // треки, о которых уже создано событие
+	platesRead       map[string]bool // треки транспорта, номер которых уже распознан
+	facesFound       map[string]bool // треки людей, лицо которых уже найдено
+	tamper           *ai.TamperDetector
+	lastTamperCheck  time.Time
+	inference        *ai.InferencePool
+	lastInference    time.Time
+	motionMu         sync.Mutex
+	trackMu          sync.Mutex
+	frameMu          sync.Mutex // смена LastFrame; вне цикла кадров кадр читается только через snapshot
+	ctx              context.Context
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
 }
 
 // New создает новый стриминг сервер
-func New(cfg config.StreamingConfig, eventManager *events.Manager, aiProcessor *ai.Processor) (*Server, error) {
+func New(cfg *config.Config, store *storage.Storage, eventManager *events.Manager, armingManager *arming.Manager, aiProcessor *ai.Processor, faces *ai.FaceRecognizer, plates *ai.PlateRecognizer) (*Server, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Создаем менеджер go2rtc
@@ -59,14 +106,35 @@ func New(cfg config.StreamingConfig, eventManager *events.Manager, aiProcessor *
 	}
 
 	server := &Server{
-		config:       cfg,
-		eventManager: eventManager,
-		aiProcessor:  aiProcessor,
-		cameras:      make(map[string]*CameraStream),
-		ctx:          ctx,
-		cancel:       cancel,
-		go2rtc:       go2rtcManager,
-		scanner:      go2rtc.NewScanner(go2rtcManager),
+		config:          cfg.Streaming,
+		storageConfig:   cfg.Storage,
+		recordingConfig: cfg.Recording,
+		motionConfig:    cfg.Motion,
+		trackerConfig:   cfg.AI.Tracker,
+		storage:         store,
+		eventManager:    eventManager,
+		arming:          armingManager,
+		aiProcessor:     aiProcessor,
+		inference:       ai.NewInferencePool(aiProcessor, cfg.AI.Workers),
+		inferenceFPS:    cfg.AI.InferenceFPS,
+		faces:           faces,
+		plates:          plates,
+		audioConfig:     cfg.Audio,
+		tamperConfig:    cfg.Tamper,
+		cameras:         make(map[string]*CameraStream),
+		ctx:             ctx,
+		cancel:          cancel,
+		go2rtc:          go2rtcManager,
+		scanner:         go2rtc.NewScanner(go2rtcManager),
+	}
+
+	// Классификатор звуков общий для всех камер; без него остается детекция громкости
+	if cfg.Audio.Enabled {
+		classifier, err := audio.NewClassifier(cfg.Audio)
+		if err != nil {
+			log.Printf("Failed to initialize audio classifier, using loudness only: %v", err)
+		}
+		server.audioClassifier = classifier
 	}
 
 	return server, nil
@@ -76,11 +144,17 @@ func New(cfg config.StreamingConfig, eventManager *events.Manager, aiProcessor *
 func (s *Server) Start() error {
 	log.Printf("Starting streaming server on ports RTSP:%d, WebRTC:%d", s.config.RTSPPort, s.config.WebRTCPort)
 
+	// Завершаем сегменты, прерванные предыдущим запуском
+	recoverRecordings(s.storage)
+
 	// Запускаем go2rtc
 	if err := s.go2rtc.Start(); err != nil {
 		return fmt.Errorf("failed to start go2rtc: %w", err)
 	}
 
+	// Запускаем воркеры AI до камер, которые отправляют им кадры
+	s.inference.Start()
+
 	// Запускаем обработку камер
 	s.wg.Add(1)
 	go s.processStreams()
@@ -101,6 +175,8 @@ func (s *Server) Close() {
 
 	s.wg.Wait()
 
+	s.inference.Close()
+
 	// Останавливаем go2rtc
 	if s.go2rtc != nil {
 		s.go2rtc.Stop()
@@ -109,6 +185,87 @@ func (s *Server) Close() {
 	log.Println("Streaming server stopped")
 }
 
+// AddCamera добавляет камеру и запускает обработку ее потока
+func (s *Server) AddCamera(cfg config.CameraConfig) error {
+	if cfg.ID == "" || cfg.RTSPURL == "" {
+		return fmt.Errorf("camera id and rtsp url are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.cameras[cfg.ID]; exists {
+		return fmt.Errorf("camera %s already exists", cfg.ID)
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	camera := &CameraStream{
+		ID:               cfg.ID,
+		Name:             cfg.Name,
+		RTSPURL:          cfg.RTSPURL,
+		Status:           "connecting",
+		AIModel:          cfg.AIModel,
+		InferenceFPS:     cfg.InferenceFPS,
+		ALPR:             cfg.ALPR,
+		AudioDetection:   cfg.AudioDetection,
+		RecordMotion:     cfg.RecordMotion,
+		RecordContinuous: cfg.RecordContinuous,
+		Sensitivity:      cfg.Sensitivity,
+		LastFrame:        gocv.NewMat(),
+		nextFrame:        gocv.NewMat(),
+		motion:           ai.NewMotionDetector(s.motionConfig, cfg.Sensitivity),
+		tracker:          ai.NewTracker(s.trackerConfig),
+		reported:         make(map[string]bool),
+		platesRead:       make(map[string]bool),
+		facesFound:       make(map[string]bool),
+		inference:        s.inference,
+		recorder:         newClipRecorder(cfg.ID, s.storageConfig, s.recordingConfig),
+		segments:         newSegmentRecorder(cfg.ID, s.storage, s.storageConfig, s.recordingConfig),
+		ctx:              ctx,
+		cancel:           cancel,
+	}
+	camera.MotionDetection.Store(cfg.MotionDetection)
+	camera.AIDetection.Store(cfg.AIDetection)
+	if camera.InferenceFPS <= 0 {
+		camera.InferenceFPS = s.inferenceFPS
+	}
+	if s.tamperConfig.Enabled {
+		camera.tamper = ai.NewTamperDetector(s.tamperConfig)
+	}
+
+	// Зоны детекции хранятся в базе и редактируются через API
+	if zones, err := s.storage.GetZones(cfg.ID); err != nil {
+		log.Printf("Failed to load zones for camera %s: %v", cfg.ID, err)
+	} else {
+		camera.zones = newZoneMask(zones)
+
+		if rules, err := s.storage.GetDetectionRules(cfg.ID); err != nil {
+			log.Printf("Failed to load detection rules for camera %s: %v", cfg.ID, err)
+		} else {
+			camera.rules = newRuleSet(cfg.ID, rules, zones)
+		}
+	}
+
+	if analytics, err := s.storage.GetAnalyticsZones(cfg.ID); err != nil {
+		log.Printf("Failed to load analytics for camera %s: %v", cfg.ID, err)
+	} else {
+		camera.analytics = newAnalytics(analytics)
+	}
+
+	s.cameras[cfg.ID] = camera
+
+	camera.wg.Add(1)
+	go s.processCameraStream(camera)
+
+	if camera.AudioDetection && s.audioConfig.Enabled {
+		camera.wg.Add(1)
+		go s.monitorAudio(camera)
+	}
+
+	log.Printf("Added camera %s (%s)", cfg.ID, cfg.Name)
+	return nil
+}
+
 // RemoveCamera удаляет камеру
 func (s *Server) RemoveCamera(id string) {
 	s.mu.Lock()
@@ -135,21 +292,230 @@ func (s *Server) GetCameraStatus(id string) string {
 
 // UpdateCameraSettings обновляет настройки камеры
 func (s *Server) UpdateCameraSettings(id string, motionDetection, aiDetection bool) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	camera, exists := s.cameras[id]
 	if !exists {
 		return fmt.Errorf("camera %s not found", id)
 	}
 
-	camera.MotionDetection = motionDetection
-	camera.AIDetection = aiDetection
+	camera.MotionDetection.Store(motionDetection)
+	camera.AIDetection.Store(aiDetection)
 
 	log.Printf("Updated camera %s settings: motion=%v, ai=%v", id, motionDetection, aiDetection)
 	return nil
 }
 
+// SetCameraDetection сохраняет включение детекции движения (motion_detection) или AI (ai_detection)
+// камеры и применяет его к работающей камере
+func (s *Server) SetCameraDetection(cameraID, setting string, enabled bool) error {
+	camera, err := s.storage.GetCamera(cameraID)
+	if err != nil {
+		return err
+	}
+	if camera == nil {
+		return fmt.Errorf("camera %s not found", cameraID)
+	}
+
+	switch setting {
+	case SettingMotionDetection:
+		camera.MotionDetection = enabled
+	case SettingAIDetection:
+		camera.AIDetection = enabled
+	default:
+		return fmt.Errorf("unknown setting %s", setting)
+	}
+	camera.UpdatedAt = time.Now()
+
+	if err := s.storage.SaveCamera(camera); err != nil {
+		return err
+	}
+
+	// Камера может быть не запущена - настройки подхватятся при старте
+	if err := s.UpdateCameraSettings(camera.ID, camera.MotionDetection, camera.AIDetection); err != nil {
+		log.Printf("Settings for camera %s saved but not applied: %v", camera.ID, err)
+	}
+	return nil
+}
+
+// UpdateCameraMotionSettings обновляет чувствительность и зоны детекции камеры
+func (s *Server) UpdateCameraMotionSettings(id string, sensitivity float32, zones []storage.Zone) error {
+	s.mu.RLock()
+	camera, exists := s.cameras[id]
+	s.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("camera %s not found", id)
+	}
+
+	mask := newZoneMask(zones)
+
+	camera.motionMu.Lock()
+	if sensitivity != camera.Sensitivity {
+		// Пороги детектора зависят от чувствительности - начинаем с новой моделью фона
+		camera.motion.Close()
+		camera.motion = ai.NewMotionDetector(s.motionConfig, sensitivity)
+		camera.Sensitivity = sensitivity
+	}
+	camera.zones.Close()
+	camera.zones = mask
+	camera.motionMu.Unlock()
+
+	log.Printf("Updated camera %s motion settings: sensitivity=%.2f, zones=%d", id, sensitivity, len(zones))
+	return nil
+}
+
+// UpdateCameraRules применяет правила детекции к камере
+func (s *Server) UpdateCameraRules(id string, rules []storage.DetectionRule, zones []storage.Zone) error {
+	s.mu.RLock()
+	camera, exists := s.cameras[id]
+	s.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("camera %s not found", id)
+	}
+
+	ruleSet := newRuleSet(id, rules, zones)
+
+	camera.trackMu.Lock()
+	camera.rules.Close()
+	camera.rules = ruleSet
+	camera.trackMu.Unlock()
+
+	log.Printf("Updated camera %s detection rules: %d", id, len(rules))
+	return nil
+}
+
+// UpdateCameraAnalytics применяет линии пересечения и области задержки к камере
+func (s *Server) UpdateCameraAnalytics(id string, zones []storage.AnalyticsZone) error {
+	s.mu.RLock()
+	camera, exists := s.cameras[id]
+	s.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("camera %s not found", id)
+	}
+
+	analytics := newAnalytics(zones)
+
+	camera.trackMu.Lock()
+	camera.analytics = analytics
+	camera.trackMu.Unlock()
+
+	log.Printf("Updated camera %s analytics: %d", id, len(zones))
+	return nil
+}
+
+// newAnalytics строит аналитику камеры из включенных линий и областей
+func newAnalytics(zones []storage.AnalyticsZone) *ai.Analytics {
+	var tripwires []ai.Tripwire
+	var areas []ai.LoiteringArea
+
+	for _, zone := range zones {
+		if !zone.Enabled {
+			continue
+		}
+
+		points := make([]ai.Point, 0, len(zone.Points))
+		for _, point := range zone.Points {
+			points = append(points, ai.Point{X: point.X, Y: point.Y})
+		}
+
+		switch zone.Type {
+		case storage.AnalyticsTypeTripwire:
+			if len(points) != 2 {
+				continue
+			}
+			tripwires = append(tripwires, ai.Tripwire{
+				ID:        zone.ID,
+				Name:      zone.Name,
+				A:         points[0],
+				B:         points[1],
+				Direction: zone.Direction,
+				Classes:   zone.Classes,
+			})
+		case storage.AnalyticsTypeLoitering:
+			areas = append(areas, ai.LoiteringArea{
+				ID:       zone.ID,
+				Name:     zone.Name,
+				Points:   points,
+				MinDwell: time.Duration(zone.MinSeconds * float64(time.Second)),
+				Classes:  zone.Classes,
+			})
+		}
+	}
+
+	return ai.NewAnalytics(tripwires, areas)
+}
+
+// newRuleSet строит правила детекции камеры, пропуская выключенные и некорректные
+func newRuleSet(cameraID string, rules []storage.DetectionRule, zones []storage.Zone) *ai.RuleSet {
+	zonesByID := make(map[int]storage.Zone, len(zones))
+	for _, zone := range zones {
+		zonesByID[zone.ID] = zone
+	}
+
+	converted := make([]ai.DetectionRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		window, err := ai.ParseTimeWindow(rule.StartTime, rule.EndTime)
+		if err != nil {
+			log.Printf("Skipping detection rule %d for camera %s: %v", rule.ID, cameraID, err)
+			continue
+		}
+
+		var zone *ai.ZoneMask
+		if rule.ZoneID != 0 {
+			ruleZone, ok := zonesByID[rule.ZoneID]
+			if !ok {
+				log.Printf("Skipping detection rule %d for camera %s: zone %d not found", rule.ID, cameraID, rule.ZoneID)
+				continue
+			}
+			// Правило проверяет, находится ли объект внутри своей зоны
+			ruleZone.Type = storage.ZoneTypeInclude
+			zone = newZoneMask([]storage.Zone{ruleZone})
+		}
+
+		converted = append(converted, ai.DetectionRule{
+			Class:          rule.Class,
+			Ignore:         rule.Action == storage.RuleActionIgnore,
+			MinConfidence:  rule.MinConfidence,
+			Zone:           zone,
+			Window:         window,
+			MinArea:        rule.MinArea,
+			MinDwellFrames: rule.MinDwellFrames,
+		})
+	}
+
+	return ai.NewRuleSet(converted)
+}
+
+// newZoneMask строит маску детекции из зон камеры; зоны правил детекцию не ограничивают
+func newZoneMask(zones []storage.Zone) *ai.ZoneMask {
+	converted := make([]ai.Zone, 0, len(zones))
+	for _, zone := range zones {
+		if zone.Type == storage.ZoneTypeRule {
+			continue
+		}
+
+		points := make([]ai.Point, 0, len(zone.Points))
+		for _, point := range zone.Points {
+			points = append(points, ai.Point{X: point.X, Y: point.Y})
+		}
+
+		converted = append(converted, ai.Zone{
+			Exclude: zone.Type == storage.ZoneTypeExclude,
+			Points:  points,
+		})
+	}
+
+	return ai.NewZoneMask(converted)
+}
+
 // processStreams основной цикл обработки потоков
 func (s *Server) processStreams() {
 	defer s.wg.Done()
@@ -201,14 +567,13 @@ func (s *Server) processCameraStream(camera *CameraStream) {
 	}
 
 	camera.Status = "online"
-	frameCounter := 0
 
 	for {
 		select {
 		case <-camera.ctx.Done():
 			return
 		default:
-			if !s.processFrame(camera, frameCounter) {
+			if !s.processFrame(camera) {
 				// Ошибка чтения кадра
 				time.Sleep(1 * time.Second)
 				if err := s.reconnectCamera(camera); err != nil {
@@ -217,7 +582,6 @@ func (s *Server) processCameraStream(camera *CameraStream) {
 					return
 				}
 			}
-			frameCounter++
 		}
 	}
 }
@@ -235,8 +599,6 @@ func (s *Server) connectToCamera(camera *CameraStream) error {
 	}
 
 	camera.Stream = stream
-	camera.LastFrame = gocv.NewMat()
-	camera.PrevFrame = gocv.NewMat()
 
 	log.Printf("Successfully connected to camera %s", camera.ID)
 	return nil
@@ -248,75 +610,444 @@ func (s *Server) reconnectCamera(camera *CameraStream) error {
 		camera.Stream.Close()
 	}
 
-	camera.LastFrame.Close()
-	camera.PrevFrame.Close()
-
 	return s.connectToCamera(camera)
 }
 
 // processFrame обрабатывает один кадр
-func (s *Server) processFrame(camera *CameraStream, frameCounter int) bool {
+func (s *Server) processFrame(camera *CameraStream) bool {
 	if camera.Stream == nil || !camera.Stream.IsOpened() {
 		return false
 	}
 
-	// Сохраняем предыдущий кадр для детекции движения
-	if !camera.LastFrame.Empty() {
-		camera.PrevFrame.Close()
-		camera.PrevFrame = camera.LastFrame.Clone()
+	// Читаем новый кадр во второй буфер и меняем буферы местами,
+	// чтобы звуковые события могли взять кадр из своей горутины
+	if !camera.Stream.Read(&camera.nextFrame) {
+		return false
 	}
 
-	// Читаем новый кадр
-	if !camera.Stream.Read(&camera.LastFrame) {
+	if camera.nextFrame.Empty() {
 		return false
 	}
 
-	if camera.LastFrame.Empty() {
-		return false
+	camera.frameMu.Lock()
+	camera.LastFrame, camera.nextFrame = camera.nextFrame, camera.LastFrame
+	camera.frameMu.Unlock()
+
+	now := time.Now()
+
+	// Пишем кадр в pre-roll буфер или активный клип
+	if camera.RecordMotion {
+		camera.recorder.AddFrame(camera.LastFrame, now)
+		camera.IsRecording = camera.recorder.IsRecording()
 	}
 
-	// Детекция движения (каждый кадр)
-	if camera.MotionDetection && !camera.PrevFrame.Empty() {
-		if ai.DetectMotion(camera.PrevFrame, camera.LastFrame, 30.0) {
-			now := time.Now()
+	// Непрерывная запись сегментами
+	if camera.RecordContinuous {
+		camera.segments.AddFrame(camera.LastFrame, now)
+	}
+
+	// Детекция движения (каждый кадр) внутри зон камеры
+	if camera.MotionDetection.Load() {
+		camera.motionMu.Lock()
+		regions := camera.motion.Detect(camera.LastFrame, camera.zones)
+		camera.motionMu.Unlock()
+
+		if len(regions) > 0 {
 			// Ограничиваем частоту событий движения (не чаще раза в 5 секунд)
 			if now.Sub(camera.LastMotionTime) > 5*time.Second {
 				camera.LastMotionTime = now
-				s.eventManager.EmitMotionDetected(camera.ID, camera.Name)
-				log.Printf("Motion detected on camera %s", camera.ID)
+				s.eventManager.EmitMotionDetected(camera.ID, camera.Name, events.Media{
+					VideoPath:     s.triggerClip(camera, now),
+					ThumbnailPath: s.saveThumbnail(camera, camera.LastFrame, nil, "motion", now),
+				}, map[string]interface{}{
+					"regions": regions,
+				})
+				log.Printf("Motion detected on camera %s (%d regions)", camera.ID, len(regions))
 			}
 		}
 	}
 
-	// AI детекция (каждую секунду, т.е. каждые 25 кадров при 25 FPS)
-	if camera.AIDetection && s.aiProcessor.IsEnabled() && frameCounter%25 == 0 {
-		detections, err := s.aiProcessor.ProcessFrame(camera.LastFrame)
-		if err != nil {
-			log.Printf("AI processing error for camera %s: %v", camera.ID, err)
-		} else if len(detections) > 0 {
-			for _, detection := range detections {
-				s.eventManager.EmitAIDetection(
-					camera.ID,
-					camera.Name,
-					detection.Class,
-					detection.Confidence,
-					map[string]interface{}{
-						"bbox": detection.BBox,
-					},
-				)
-				log.Printf("AI detection on camera %s: %s (%.2f)", camera.ID, detection.Class, detection.Confidence)
+	// Проверка на саботаж (закрытие объектива, расфокус, поворот)
+	s.checkTamper(camera, now)
+
+	// AI детекция с частотой inference FPS камеры; кадр обрабатывается в пуле воркеров,
+	// а пока модель занята, в очереди камеры остается только самый свежий кадр
+	// Режим охраны может отключить AI камеры, не меняя ее настроек
+	aiDetection := camera.AIDetection.Load() && s.arming.CanRunAI(camera.ID)
+	if aiDetection && s.aiProcessor.IsEnabled() {
+		if now.Sub(camera.lastInference) >= camera.inferenceInterval() {
+			camera.lastInference = now
+			camera.inference.Submit(camera.ID, camera.AIModel, camera.LastFrame.Clone(), now, func(result ai.InferenceResult) {
+				s.handleInference(camera, result)
+			})
+		}
+	} else if !aiDetection {
+		// AI выключили - закрываем треки, чтобы события получили время присутствия
+		camera.trackMu.Lock()
+		if camera.tracker.Active() > 0 {
+			for _, track := range camera.tracker.Flush() {
+				if camera.reported[track.ID] {
+					s.eventManager.EmitTrackEnded(camera.ID, camera.Name, track.Class, track.MaxConfidence, eventTrack(track, now))
+				}
 			}
+			camera.reported = make(map[string]bool)
+			camera.platesRead = make(map[string]bool)
+			camera.facesFound = make(map[string]bool)
+			camera.analytics.Reset()
 		}
+		camera.trackMu.Unlock()
 	}
 
 	return true
 }
 
+// handleInference обновляет треки камеры по результату инференса из пула
+func (s *Server) handleInference(camera *CameraStream, result ai.InferenceResult) {
+	if result.Err != nil {
+		log.Printf("AI processing error for camera %s: %v", camera.ID, result.Err)
+		return
+	}
+
+	// Учитываем только объекты внутри зон камеры
+	camera.motionMu.Lock()
+	detections := camera.zones.FilterDetections(result.Detections, result.Frame.Cols(), result.Frame.Rows())
+	camera.motionMu.Unlock()
+
+	camera.trackMu.Lock()
+	defer camera.trackMu.Unlock()
+
+	// Результат мог прийти уже после выключения AI на камере или смены режима охраны
+	if !camera.AIDetection.Load() || !s.arming.CanRunAI(camera.ID) {
+		return
+	}
+
+	update := camera.tracker.Update(detections, result.CapturedAt)
+	s.updateTracks(camera, update, detections, result.Frame, result.CapturedAt)
+	s.updateAnalytics(camera, update, result.Frame, result.CapturedAt)
+	s.updatePlates(camera, update, result.Frame, result.CapturedAt)
+}
+
+// inferenceInterval возвращает минимальный интервал между кадрами на AI
+func (camera *CameraStream) inferenceInterval() time.Duration {
+	if camera.InferenceFPS <= 0 {
+		return time.Second
+	}
+	return time.Duration(float64(time.Second) / camera.InferenceFPS)
+}
+
+// updateTracks создает события для объектов, прошедших правила детекции камеры,
+// и обновляет время жизни остальных. Трек, еще не прошедший правила, проверяется
+// на каждом кадре и порождает событие, как только условия выполнятся.
+func (s *Server) updateTracks(camera *CameraStream, update ai.TrackUpdate, detections []ai.Detection, frame gocv.Mat, now time.Time) {
+	var started, updated []ai.Track
+	for _, track := range append(update.Started, update.Updated...) {
+		if camera.reported[track.ID] {
+			updated = append(updated, track)
+		} else if camera.rules.Allows(track, frame.Cols(), frame.Rows(), now) {
+			camera.reported[track.ID] = true
+			started = append(started, track)
+		}
+	}
+
+	if len(started) > 0 {
+		media := events.Media{
+			VideoPath:     s.triggerClip(camera, now),
+			ThumbnailPath: s.saveThumbnail(camera, frame, detections, "ai", now),
+		}
+		for _, track := range started {
+			data := map[string]interface{}{
+				"class":        track.Class,
+				"bbox":         track.BBox,
+				"track_id":     track.ID,
+				"frame_width":  frame.Cols(),
+				"frame_height": frame.Rows(),
+			}
+
+			// Для людей пытаемся распознать лицо по галерее
+			label := track.Class
+			if face := s.recognizeFace(camera, frame, track); face != nil {
+				camera.facesFound[track.ID] = true
+				data["face"] = faceData(face)
+				label = fmt.Sprintf("%s (%s)", track.Class, face.Label())
+			}
+
+			s.eventManager.EmitTrackStarted(
+				camera.ID,
+				camera.Name,
+				label,
+				track.Confidence,
+				media,
+				eventTrack(track, time.Time{}),
+				data,
+			)
+			log.Printf("AI detection on camera %s: %s (%.2f), track %s", camera.ID, label, track.Confidence, track.ID)
+		}
+	} else if len(updated) > 0 {
+		// Пока объект в кадре, клип продолжает записываться
+		s.triggerClip(camera, now)
+	}
+
+	for _, track := range updated {
+		s.eventManager.EmitTrackUpdated(camera.ID, camera.Name, track.MaxConfidence, eventTrack(track, time.Time{}))
+	}
+
+	// Человек мог появиться спиной к камере - ищем лицо, пока оно не найдется
+	s.retryFaces(camera, updated, detections, frame, now)
+
+	for _, track := range update.Ended {
+		delete(camera.facesFound, track.ID)
+		if !camera.reported[track.ID] {
+			continue
+		}
+		delete(camera.reported, track.ID)
+
+		s.eventManager.EmitTrackEnded(camera.ID, camera.Name, track.Class, track.MaxConfidence, eventTrack(track, now))
+		log.Printf("Track %s (%s) ended on camera %s after %s", track.ID, track.Class, camera.ID, track.Dwell().Round(time.Second))
+	}
+}
+
+// retryFaces ищет лица людей, у которых лицо не нашлось при появлении.
+// Найденное лицо создает событие AI детекции с тем же треком, один раз на трек.
+func (s *Server) retryFaces(camera *CameraStream, tracks []ai.Track, detections []ai.Detection, frame gocv.Mat, now time.Time) {
+	for _, track := range tracks {
+		if camera.facesFound[track.ID] {
+			continue
+		}
+
+		face := s.recognizeFace(camera, frame, track)
+		if face == nil {
+			continue
+		}
+		camera.facesFound[track.ID] = true
+
+		label := fmt.Sprintf("%s (%s)", track.Class, face.Label())
+		s.eventManager.EmitAIDetection(camera.ID, camera.Name, label, track.Confidence, events.Media{
+			VideoPath:     s.triggerClip(camera, now),
+			ThumbnailPath: s.saveThumbnail(camera, frame, detections, "face", now),
+		}, map[string]interface{}{
+			"class":        track.Class,
+			"bbox":         track.BBox,
+			"track_id":     track.ID,
+			"frame_width":  frame.Cols(),
+			"frame_height": frame.Rows(),
+			"face":         faceData(face),
+		})
+		log.Printf("Face found on camera %s: %s, track %s", camera.ID, label, track.ID)
+	}
+}
+
+// recognizeFace ищет лицо в рамке человека и сравнивает его с галереей
+func (s *Server) recognizeFace(camera *CameraStream, frame gocv.Mat, track ai.Track) *ai.FaceMatch {
+	if s.faces == nil || track.Class != "person" {
+		return nil
+	}
+
+	face, err := s.faces.Recognize(frame, track.BBox)
+	if err != nil {
+		log.Printf("Face recognition error for camera %s: %v", camera.ID, err)
+		return nil
+	}
+
+	return face
+}
+
+// faceData переводит результат распознавания в данные события
+func faceData(face *ai.FaceMatch) map[string]interface{} {
+	data := map[string]interface{}{
+		"known":      face.Known,
+		"label":      face.Label(),
+		"similarity": face.Similarity,
+		"bbox":       face.BBox,
+	}
+	if face.Known {
+		data["person_id"] = face.PersonID
+		data["name"] = face.Name
+	}
+	return data
+}
+
+// ReloadFaceGallery загружает галерею известных лиц из базы
+func (s *Server) ReloadFaceGallery() error {
+	if s.faces == nil {
+		return nil
+	}
+
+	photos, err := s.storage.GetFaceGallery()
+	if err != nil {
+		return fmt.Errorf("failed to load face gallery: %w", err)
+	}
+
+	gallery := make([]ai.GalleryFace, 0, len(photos))
+	for _, photo := range photos {
+		gallery = append(gallery, ai.GalleryFace{
+			PersonID:  photo.PersonID,
+			Name:      photo.PersonName,
+			Embedding: photo.Embedding,
+		})
+	}
+
+	s.faces.SetGallery(gallery)
+	log.Printf("Face gallery loaded: %d photos", len(gallery))
+	return nil
+}
+
+// EmbedFacePhoto вычисляет эмбеддинг лица на эталонном фото (JPEG или PNG)
+func (s *Server) EmbedFacePhoto(data []byte) ([]float32, error) {
+	if s.faces == nil {
+		return nil, fmt.Errorf("face recognition is disabled")
+	}
+
+	photo, err := gocv.IMDecode(data, gocv.IMReadColor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode photo: %w", err)
+	}
+	defer photo.Close()
+
+	return s.faces.EmbedPhoto(photo)
+}
+
+// updateAnalytics создает события пересечения линий и задержки объектов в областях
+func (s *Server) updateAnalytics(camera *CameraStream, update ai.TrackUpdate, frame gocv.Mat, now time.Time) {
+	result := camera.analytics.Update(update, frame.Cols(), frame.Rows(), now)
+	if len(result.Crossings) == 0 && len(result.Loitering) == 0 {
+		return
+	}
+
+	var detections []ai.Detection
+	for _, crossing := range result.Crossings {
+		detections = append(detections, trackDetection(crossing.Track))
+	}
+	for _, loitering := range result.Loitering {
+		detections = append(detections, trackDetection(loitering.Track))
+	}
+
+	media := events.Media{
+		VideoPath:     s.triggerClip(camera, now),
+		ThumbnailPath: s.saveThumbnail(camera, frame, detections, "analytics", now),
+	}
+
+	for _, crossing := range result.Crossings {
+		s.eventManager.EmitLineCrossing(
+			camera.ID,
+			camera.Name,
+			crossing.Track.Class,
+			crossing.Tripwire.Name,
+			crossing.Direction,
+			crossing.Track.Confidence,
+			media,
+			map[string]interface{}{
+				"tripwire_id":  crossing.Tripwire.ID,
+				"tripwire":     crossing.Tripwire.Name,
+				"direction":    crossing.Direction,
+				"class":        crossing.Track.Class,
+				"track_id":     crossing.Track.ID,
+				"bbox":         crossing.Track.BBox,
+				"frame_width":  frame.Cols(),
+				"frame_height": frame.Rows(),
+			},
+		)
+		log.Printf("Line crossing on camera %s: %s crossed %s (%s)", camera.ID, crossing.Track.Class, crossing.Tripwire.Name, crossing.Direction)
+	}
+
+	for _, loitering := range result.Loitering {
+		s.eventManager.EmitLoitering(
+			camera.ID,
+			camera.Name,
+			loitering.Track.Class,
+			loitering.Area.Name,
+			loitering.Duration,
+			loitering.Track.Confidence,
+			media,
+			map[string]interface{}{
+				"area_id":       loitering.Area.ID,
+				"area":          loitering.Area.Name,
+				"class":         loitering.Track.Class,
+				"track_id":      loitering.Track.ID,
+				"dwell_seconds": loitering.Duration.Seconds(),
+				"bbox":          loitering.Track.BBox,
+				"frame_width":   frame.Cols(),
+				"frame_height":  frame.Rows(),
+			},
+		)
+		log.Printf("Loitering on camera %s: %s in %s for %s", camera.ID, loitering.Track.Class, loitering.Area.Name, loitering.Duration.Round(time.Second))
+	}
+}
+
+// trackDetection переводит трек в детекцию для отрисовки на миниатюре
+func trackDetection(track ai.Track) ai.Detection {
+	return ai.Detection{
+		Class:      track.Class,
+		Confidence: track.Confidence,
+		BBox:       track.BBox,
+	}
+}
+
+// eventTrack переводит трек в формат хранилища; нулевой endedAt - объект еще в кадре
+func eventTrack(track ai.Track, endedAt time.Time) storage.EventTrack {
+	return storage.EventTrack{
+		ID:           track.ID,
+		StartedAt:    track.StartedAt,
+		LastSeen:     track.LastSeen,
+		EndedAt:      endedAt,
+		DwellSeconds: track.Dwell().Seconds(),
+	}
+}
+
+// triggerClip запускает или продлевает запись клипа события, если камера записывает
+// в текущем режиме охраны, и возвращает путь к нему
+func (s *Server) triggerClip(camera *CameraStream, now time.Time) string {
+	if !s.arming.CanRecord(camera.ID) {
+		return ""
+	}
+	return s.recordClip(camera, now)
+}
+
+// recordClip запускает или продлевает запись клипа и возвращает путь к нему
+func (s *Server) recordClip(camera *CameraStream, now time.Time) string {
+	if !camera.RecordMotion || camera.recorder == nil {
+		return ""
+	}
+
+	path := camera.recorder.Trigger(now)
+	camera.IsRecording = path != ""
+	return path
+}
+
+// StartRecording запускает или продлевает запись клипа камеры и возвращает путь к нему.
+// Явный запрос записи (действие правила) не зависит от режима охраны.
+func (s *Server) StartRecording(cameraID string) (string, error) {
+	s.mu.RLock()
+	camera, exists := s.cameras[cameraID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return "", fmt.Errorf("camera %s not found", cameraID)
+	}
+
+	path := s.recordClip(camera, time.Now())
+	if path == "" {
+		return "", fmt.Errorf("clip recording is disabled for camera %s", cameraID)
+	}
+
+	return path, nil
+}
+
 // stop останавливает камеру
 func (camera *CameraStream) stop() {
 	camera.cancel()
 	camera.wg.Wait()
 
+	// Дожидаемся обработки последнего кадра камеры в пуле AI
+	camera.inference.RemoveCamera(camera.ID)
+
+	if camera.recorder != nil {
+		camera.recorder.Close()
+	}
+
+	if camera.segments != nil {
+		camera.segments.Close()
+	}
+
 	if camera.Stream != nil {
 		camera.Stream.Close()
 	}
@@ -324,10 +1055,29 @@ func (camera *CameraStream) stop() {
 	if !camera.LastFrame.Empty() {
 		camera.LastFrame.Close()
 	}
-
-	if !camera.PrevFrame.Empty() {
-		camera.PrevFrame.Close()
+	if !camera.nextFrame.Empty() {
+		camera.nextFrame.Close()
 	}
+
+	camera.trackMu.Lock()
+	camera.rules.Close()
+	camera.rules = nil
+	camera.trackMu.Unlock()
+
+	camera.motionMu.Lock()
+	camera.zones.Close()
+	camera.zones = nil
+	camera.motion.Close()
+	camera.motionMu.Unlock()
+
+	camera.tamper.Close()
+}
+
+// snapshot возвращает копию последнего кадра камеры; безопасен вне цикла кадров
+func (camera *CameraStream) snapshot() gocv.Mat {
+	camera.frameMu.Lock()
+	defer camera.frameMu.Unlock()
+	return camera.LastFrame.Clone()
 }
 
 // GetSnapshot возвращает снапшот с камеры
@@ -340,12 +1090,15 @@ func (s *Server) GetSnapshot(cameraID string) ([]byte, error) {
 		return nil, fmt.Errorf("camera %s not found", cameraID)
 	}
 
-	if camera.LastFrame.Empty() {
+	frame := camera.snapshot()
+	defer frame.Close()
+
+	if frame.Empty() {
 		return nil, fmt.Errorf("no frame available from camera %s", cameraID)
 	}
 
 	// Кодируем кадр в JPEG
-	buf, err := gocv.IMEncode(".jpg", camera.LastFrame)
+	buf, err := gocv.IMEncode(".jpg", frame)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode frame: %w", err)
 	}
@@ -354,6 +1107,11 @@ func (s *Server) GetSnapshot(cameraID string) ([]byte, error) {
 	return buf.GetBytes(), nil
 }
 
+// GetInferenceStats возвращает метрики пула AI по камерам
+func (s *Server) GetInferenceStats() []ai.InferenceStats {
+	return s.inference.Stats()
+}
+
 // GetCameraList возвращает список активных камер
 func (s *Server) GetCameraList() []map[string]interface{} {
 	s.mu.RLock()
@@ -365,8 +1123,8 @@ func (s *Server) GetCameraList() []map[string]interface{} {
 			"id":               camera.ID,
 			"name":             camera.Name,
 			"status":           camera.Status,
-			"motion_detection": camera.MotionDetection,
-			"ai_detection":     camera.AIDetection,
+			"motion_detection": camera.MotionDetection.Load(),
+			"ai_detection":     camera.AIDetection.Load(),
 			"last_motion":      camera.LastMotionTime,
 		})
 	}
@@ -0,0 +1,35 @@
+package streaming
+
+import (
+	"log"
+	"time"
+
+	"ocuai/internal/events"
+)
+
+// checkTamper периодически проверяет кадр камеры на саботаж и создает события
+func (s *Server) checkTamper(camera *CameraStream, now time.Time) {
+	if camera.tamper == nil {
+		return
+	}
+
+	interval := time.Duration(s.tamperConfig.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if now.Sub(camera.lastTamperCheck) < interval {
+		return
+	}
+	camera.lastTamperCheck = now
+
+	for _, alert := range camera.tamper.Check(camera.LastFrame) {
+		data := alert.Metrics.Data()
+		data["reason"] = alert.Reason
+
+		s.eventManager.EmitTamper(camera.ID, camera.Name, alert.Reason, events.Media{
+			VideoPath:     s.triggerClip(camera, now),
+			ThumbnailPath: s.saveThumbnail(camera, camera.LastFrame, nil, "tamper", now),
+		}, data)
+		log.Printf("Camera %s tampering detected: %s", camera.ID, alert.Reason)
+	}
+}
@@ -0,0 +1,43 @@
+package streaming
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ocuai/internal/ai"
+
+	"gocv.io/x/gocv"
+)
+
+// saveThumbnail сохраняет JPEG кадра события рядом с клипами камеры.
+// Для AI событий на кадре рисуются рамки детекций.
+func (s *Server) saveThumbnail(camera *CameraStream, frame gocv.Mat, detections []ai.Detection, kind string, now time.Time) string {
+	if frame.Empty() {
+		return ""
+	}
+
+	dir := filepath.Join(s.storageConfig.VideoPath, camera.ID, "clips", now.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Failed to create thumbnail directory for camera %s: %v", camera.ID, err)
+		return ""
+	}
+
+	path := filepath.Join(dir, now.Format("150405.000")+"_"+kind+".jpg")
+
+	image := frame
+	if len(detections) > 0 {
+		annotated := frame.Clone()
+		defer annotated.Close()
+		ai.DrawDetections(&annotated, detections)
+		image = annotated
+	}
+
+	if !gocv.IMWrite(path, image) {
+		log.Printf("Failed to write thumbnail for camera %s: %s", camera.ID, path)
+		return ""
+	}
+
+	return path
+}
@@ -7,9 +7,9 @@ import (
 	"sync"
 	"time"
 
+	"ocuai/internal/arming"
 	"ocuai/internal/config"
 	"ocuai/internal/events"
-	"ocuai/internal/storage"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -19,13 +19,14 @@ type Bot struct {
 	api          *tgbotapi.BotAPI
 	config       config.TelegramConfig
 	eventManager *events.Manager
+	arming       *arming.Manager
 	stopChan     chan struct{}
 	wg           sync.WaitGroup
 	allowedUsers map[int64]bool
 }
 
 // New создает новый Telegram бот
-func New(cfg config.TelegramConfig, eventManager *events.Manager) (*Bot, error) {
+func New(cfg config.TelegramConfig, eventManager *events.Manager, armingManager *arming.Manager) (*Bot, error) {
 	if cfg.Token == "" {
 		return nil, fmt.Errorf("telegram token is empty")
 	}
@@ -45,6 +46,7 @@ func New(cfg config.TelegramConfig, eventManager *events.Manager) (*Bot, error)
 		api:          api,
 		config:       cfg,
 		eventManager: eventManager,
+		arming:       armingManager,
 		stopChan:     make(chan struct{}),
 		allowedUsers: allowedUsers,
 	}
@@ -55,19 +57,24 @@ func New(cfg config.TelegramConfig, eventManager *events.Manager) (*Bot, error)
 
 // Start запускает бота
 func (b *Bot) Start() {
-	// Подписываемся на события
-	b.eventManager.Subscribe(events.EventTypeMotion, b.handleMotionEvent)
-	b.eventManager.Subscribe(events.EventTypeAI, b.handleAIEvent)
-	b.eventManager.Subscribe(events.EventTypeCameraLost, b.handleCameraLostEvent)
+	// Подписываемся на события: недоставленные уведомления повторяются
+	// и досылаются после перезапуска
+	b.eventManager.Subscribe("telegram", b.handleEvent,
+		events.EventTypeMotion,
+		events.EventTypeAudio,
+		events.EventTypeAI,
+		events.EventTypeCameraLost,
+		events.EventTypeTamper,
+		events.EventTypeLineCrossing,
+		events.EventTypeLoitering,
+		events.EventTypePlateMatch,
+		events.EventTypeArming,
+	)
 
 	// Запускаем обработку команд
 	b.wg.Add(1)
 	go b.handleUpdates()
 
-	// Запускаем периодическую отправку непроцессированых событий
-	b.wg.Add(1)
-	go b.processUnsentEvents()
-
 	log.Println("Telegram bot started")
 }
 
@@ -127,6 +134,8 @@ func (b *Bot) handleMessage(message *tgbotapi.Message) {
 		b.handleEventsCommand(userID, message.Text)
 	case strings.HasPrefix(message.Text, "/ai"):
 		b.handleAICommand(userID, message.Text)
+	case strings.HasPrefix(message.Text, "/arm"):
+		b.handleArmCommand(userID, message.Text)
 	case strings.HasPrefix(message.Text, "/help"):
 		b.handleHelpCommand(userID)
 	default:
@@ -159,6 +168,8 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 		b.handleToggleAI(userID, param == "enable")
 	case "camera_details":
 		b.handleCameraDetails(userID, param)
+	case "arm":
+		b.setArmingMode(userID, param)
 	}
 }
 
@@ -173,6 +184,7 @@ func (b *Bot) handleStartCommand(userID int64) {
 /cameras - список камер
 /events - последние события
 /ai - управление ИИ
+/arm - режим охраны
 /help - справка`
 
 	b.sendMessage(userID, message)
@@ -191,11 +203,13 @@ func (b *Bot) handleStatusCommand(userID int64) {
 🎥 Камеры: %d (онлайн: %d)
 📅 События сегодня: %d
 📈 Всего событий: %d
+🛡 Режим охраны: %s
 🕒 Время: %s`,
 		stats["cameras_total"],
 		stats["cameras_online"],
 		stats["events_today"],
 		stats["events_total"],
+		armingModeNames[b.arming.Mode()],
 		time.Now().Format("15:04:05 02.01.2006"))
 
 	// Добавляем inline клавиатуру
@@ -250,6 +264,16 @@ func (b *Bot) handleEventsCommand(userID int64, text string) {
 			icon = "🤖"
 		case "camera_lost":
 			icon = "📵"
+		case "line_crossing":
+			icon = "🚧"
+		case "loitering":
+			icon = "⏳"
+		case "plate_match":
+			icon = "🚗"
+		case "audio":
+			icon = "🔊"
+		case "tamper":
+			icon = "🚨"
 		}
 
 		message += fmt.Sprintf("%s *%s*\n📹 %s\n🕒 %s\n\n",
@@ -306,6 +330,7 @@ func (b *Bot) handleHelpCommand(userID int64) {
 🎥 */cameras* - список камер
 📋 */events* [N] - последние N событий
 🤖 */ai* - управление ИИ
+🛡 */arm* [home|away|night|disarmed] - режим охраны
 ❓ */help* - эта справка
 
 *Автоматические уведомления:*
@@ -313,12 +338,65 @@ func (b *Bot) handleHelpCommand(userID int64) {
 • 🤖 Обнаружение объектов ИИ
 • 📵 Потеря связи с камерой
 
-*Время уведомлений:* %s`
+*Время уведомлений:* %s, если режим охраны их разрешает`
 
 	msg := fmt.Sprintf(message, b.config.NotificationHours)
 	b.sendMessage(userID, msg)
 }
 
+// armingModeNames названия режимов охраны
+var armingModeNames = map[string]string{
+	arming.ModeHome:     "🏠 Дома",
+	arming.ModeAway:     "🚪 Никого нет",
+	arming.ModeNight:    "🌙 Ночь",
+	arming.ModeDisarmed: "🔓 Снято с охраны",
+}
+
+// armingSources описания источников смены режима
+var armingSources = map[string]string{
+	arming.SourceAPI:      "веб-интерфейс",
+	arming.SourceTelegram: "Telegram",
+	arming.SourceSchedule: "расписание",
+}
+
+// handleArmCommand обрабатывает команду /arm: без параметра показывает режим охраны
+// и кнопки переключения, с параметром переключает режим
+func (b *Bot) handleArmCommand(userID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) > 1 {
+		b.setArmingMode(userID, strings.ToLower(parts[1]))
+		return
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, mode := range arming.Modes {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(armingModeNames[mode], "arm:"+mode))
+	}
+
+	msg := tgbotapi.NewMessage(userID, fmt.Sprintf("🛡 *Режим охраны:* %s", armingModeNames[b.arming.Mode()]))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons[:2], buttons[2:])
+	b.api.Send(msg)
+}
+
+// setArmingMode переключает режим охраны; об успешной смене всем пользователям
+// сообщит событие arming_changed
+func (b *Bot) setArmingMode(userID int64, mode string) {
+	if !arming.IsMode(mode) {
+		b.sendMessage(userID, "❓ Неизвестный режим. Доступны: "+strings.Join(arming.Modes, ", "))
+		return
+	}
+
+	if mode == b.arming.Mode() {
+		b.sendMessage(userID, fmt.Sprintf("🛡 Режим %s уже включен", armingModeNames[mode]))
+		return
+	}
+
+	if err := b.arming.SetMode(mode, arming.SourceTelegram); err != nil {
+		b.sendMessage(userID, "❌ Не удалось переключить режим: "+err.Error())
+	}
+}
+
 // handleToggleAI обрабатывает переключение ИИ
 func (b *Bot) handleToggleAI(userID int64, enable bool) {
 	// Здесь нужно переключить AI в процессоре
@@ -342,10 +420,33 @@ func (b *Bot) handleCameraDetails(userID int64, cameraID string) {
 
 // Event handlers
 
+// handleEvent отправляет уведомление о событии обработчиком его типа
+func (b *Bot) handleEvent(event events.Event) error {
+	switch event.Type {
+	case events.EventTypeMotion:
+		return b.handleMotionEvent(event)
+	case events.EventTypeAudio:
+		return b.handleAudioEvent(event)
+	case events.EventTypeAI:
+		return b.handleAIEvent(event)
+	case events.EventTypeCameraLost:
+		return b.handleCameraLostEvent(event)
+	case events.EventTypeTamper:
+		return b.handleTamperEvent(event)
+	case events.EventTypeLineCrossing, events.EventTypeLoitering:
+		return b.handleAnalyticsEvent(event)
+	case events.EventTypePlateMatch:
+		return b.handlePlateMatchEvent(event)
+	case events.EventTypeArming:
+		return b.handleArmingEvent(event)
+	}
+	return nil
+}
+
 // handleMotionEvent обрабатывает события движения
-func (b *Bot) handleMotionEvent(event events.Event) {
-	if !b.isNotificationTimeAllowed() {
-		return
+func (b *Bot) handleMotionEvent(event events.Event) error {
+	if !b.isNotificationAllowed(event) {
+		return nil
 	}
 
 	message := fmt.Sprintf(`🏃 *Обнаружено движение*
@@ -355,13 +456,62 @@ func (b *Bot) handleMotionEvent(event events.Event) {
 		event.CameraName,
 		event.Timestamp.Format("15:04:05 02.01.2006"))
 
-	b.broadcastMessage(message)
+	return b.broadcastEvent(message, event.ThumbnailPath)
+}
+
+// tamperReasons описания причин саботажа камеры
+var tamperReasons = map[string]string{
+	"covered":   "объектив закрыт или закрашен",
+	"defocused": "камера расфокусирована",
+	"moved":     "камеру повернули, сцена изменилась",
+}
+
+// handleTamperEvent обрабатывает события саботажа камеры.
+// Саботаж отправляется без учета часов уведомлений, но только в режимах охраны, где камера уведомляет.
+func (b *Bot) handleTamperEvent(event events.Event) error {
+	if !b.arming.CanNotify(event.CameraID) {
+		return nil
+	}
+
+	reason, _ := event.Data["reason"].(string)
+	if text, ok := tamperReasons[reason]; ok {
+		reason = text
+	}
+
+	message := fmt.Sprintf(`🚨 *Саботаж камеры*
+
+⚠️ %s
+🎥 Камера: %s
+🕒 Время: %s`,
+		reason,
+		event.CameraName,
+		event.Timestamp.Format("15:04:05 02.01.2006"))
+
+	return b.broadcastEvent(message, event.ThumbnailPath)
+}
+
+// handleAudioEvent обрабатывает события звука
+func (b *Bot) handleAudioEvent(event events.Event) error {
+	if !b.isNotificationAllowed(event) {
+		return nil
+	}
+
+	message := fmt.Sprintf(`🔊 *Обнаружен звук*
+
+📝 %s
+🎥 Камера: %s
+🕒 Время: %s`,
+		event.Description,
+		event.CameraName,
+		event.Timestamp.Format("15:04:05 02.01.2006"))
+
+	return b.broadcastEvent(message, event.ThumbnailPath)
 }
 
 // handleAIEvent обрабатывает события ИИ детекции
-func (b *Bot) handleAIEvent(event events.Event) {
-	if !b.isNotificationTimeAllowed() {
-		return
+func (b *Bot) handleAIEvent(event events.Event) error {
+	if !b.isNotificationAllowed(event) {
+		return nil
 	}
 
 	confidence := ""
@@ -369,108 +519,171 @@ func (b *Bot) handleAIEvent(event events.Event) {
 		confidence = fmt.Sprintf(" (%.0f%%)", event.Confidence*100)
 	}
 
-	message := fmt.Sprintf(`🤖 *ИИ Детекция*
+	title := "🤖 *ИИ Детекция*"
+	if face, ok := event.Data["face"].(map[string]interface{}); ok {
+		// Известные и неизвестные лица различаем уже в заголовке
+		if known, _ := face["known"].(bool); known {
+			title = "👤 *Известное лицо*"
+		} else {
+			title = "❓ *Неизвестное лицо*"
+		}
+	}
+
+	message := fmt.Sprintf(`%s
 
 📝 %s%s
 🎥 Камера: %s
 🕒 Время: %s`,
+		title,
 		event.Description,
 		confidence,
 		event.CameraName,
 		event.Timestamp.Format("15:04:05 02.01.2006"))
 
-	b.broadcastMessage(message)
+	return b.broadcastEvent(message, event.ThumbnailPath)
 }
 
-// handleCameraLostEvent обрабатывает события потери камеры
-func (b *Bot) handleCameraLostEvent(event events.Event) {
-	message := fmt.Sprintf(`📵 *Потеря связи с камерой*
+// handlePlateMatchEvent обрабатывает события номеров из белого и черного списков
+func (b *Bot) handlePlateMatchEvent(event events.Event) error {
+	if !b.isNotificationAllowed(event) {
+		return nil
+	}
 
-🎥 Камера: %s
-🕒 Время: %s
+	title := "✅ *Номер из белого списка*"
+	if list, _ := event.Data["list"].(string); list == "deny" {
+		title = "⛔ *Номер из черного списка*"
+	}
 
-⚠️ Проверьте подключение камеры`,
+	plate, _ := event.Data["plate"].(string)
+	label, _ := event.Data["label"].(string)
+	if label != "" {
+		plate += " (" + label + ")"
+	}
+
+	message := fmt.Sprintf(`%s
+
+🚗 Номер: %s
+🎥 Камера: %s
+🕒 Время: %s`,
+		title,
+		plate,
 		event.CameraName,
 		event.Timestamp.Format("15:04:05 02.01.2006"))
 
-	b.broadcastMessage(message)
+	return b.broadcastEvent(message, event.ThumbnailPath)
 }
 
-// processUnsentEvents обрабатывает неотправленные события
-func (b *Bot) processUnsentEvents() {
-	defer b.wg.Done()
-
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// handleAnalyticsEvent обрабатывает события пересечения линий и задержки объектов
+func (b *Bot) handleAnalyticsEvent(event events.Event) error {
+	if !b.isNotificationAllowed(event) {
+		return nil
+	}
 
-	for {
-		select {
-		case <-ticker.C:
-			events, err := b.eventManager.GetUnprocessedEvents()
-			if err != nil {
-				log.Printf("Failed to get unprocessed events: %v", err)
-				continue
-			}
+	title := "🚧 *Пересечение линии*"
+	if event.Type == events.EventTypeLoitering {
+		title = "⏳ *Задержка в зоне*"
+	}
 
-			for _, event := range events {
-				// Отправляем событие
-				b.sendEventNotification(storage.Event(event))
+	message := fmt.Sprintf(`%s
 
-				// Помечаем как обработанное
-				if err := b.eventManager.MarkEventProcessed(event.ID); err != nil {
-					log.Printf("Failed to mark event as processed: %v", err)
-				}
-			}
+📝 %s
+🎥 Камера: %s
+🕒 Время: %s`,
+		title,
+		event.Description,
+		event.CameraName,
+		event.Timestamp.Format("15:04:05 02.01.2006"))
 
-		case <-b.stopChan:
-			return
-		}
-	}
+	return b.broadcastEvent(message, event.ThumbnailPath)
 }
 
-// sendEventNotification отправляет уведомление о событии
-func (b *Bot) sendEventNotification(event storage.Event) {
-	if !b.isNotificationTimeAllowed() {
-		return
+// handleArmingEvent сообщает о смене режима охраны
+func (b *Bot) handleArmingEvent(event events.Event) error {
+	mode, _ := event.Data["mode"].(string)
+	source, _ := event.Data["source"].(string)
+	if text, ok := armingSources[source]; ok {
+		source = text
 	}
 
-	icon := "📱"
-	switch event.Type {
-	case "motion":
-		icon = "🏃"
-	case "ai_detection":
-		icon = "🤖"
-	case "camera_lost":
-		icon = "📵"
-	}
+	message := fmt.Sprintf(`🛡 *Режим охраны:* %s
+
+🔁 Источник: %s
+🕒 Время: %s`,
+		armingModeNames[mode],
+		source,
+		event.Timestamp.Format("15:04:05 02.01.2006"))
+
+	return b.broadcastMessage(message)
+}
 
-	message := fmt.Sprintf(`%s *%s*
+// handleCameraLostEvent обрабатывает события потери камеры
+func (b *Bot) handleCameraLostEvent(event events.Event) error {
+	message := fmt.Sprintf(`📵 *Потеря связи с камерой*
 
 🎥 Камера: %s
-🕒 Время: %s`,
-		icon,
-		event.Description,
+🕒 Время: %s
+
+⚠️ Проверьте подключение камеры`,
 		event.CameraName,
-		event.CreatedAt.Format("15:04:05 02.01.2006"))
+		event.Timestamp.Format("15:04:05 02.01.2006"))
 
-	b.broadcastMessage(message)
+	return b.broadcastMessage(message)
 }
 
 // sendMessage отправляет сообщение пользователю
 func (b *Bot) sendMessage(userID int64, text string) {
+	if err := b.SendMessage(userID, text); err != nil {
+		log.Printf("Failed to send message to user %d: %v", userID, err)
+	}
+}
+
+// SendMessage отправляет сообщение пользователю и возвращает ошибку отправки
+func (b *Bot) SendMessage(userID int64, text string) error {
 	msg := tgbotapi.NewMessage(userID, text)
 	msg.ParseMode = "Markdown"
 
 	if _, err := b.api.Send(msg); err != nil {
-		log.Printf("Failed to send message to user %d: %v", userID, err)
+		return fmt.Errorf("failed to send message: %w", err)
 	}
+
+	return nil
+}
+
+// Broadcast отправляет сообщение всем пользователям, прикладывая снимок, если он есть
+func (b *Bot) Broadcast(text, photoPath string) error {
+	return b.broadcastEvent(text, photoPath)
 }
 
-// broadcastMessage отправляет сообщение всем пользователям
-func (b *Bot) broadcastMessage(text string) {
+// broadcastMessage отправляет сообщение всем пользователям.
+// Ошибка означает, что хотя бы один пользователь сообщение не получил.
+func (b *Bot) broadcastMessage(text string) error {
+	return b.broadcastEvent(text, "")
+}
+
+// broadcastEvent отправляет уведомление о событии всем пользователям,
+// прикладывая снимок кадра, если он есть. Ошибка означает, что хотя бы
+// один пользователь уведомление не получил - тогда доставка повторяется.
+func (b *Bot) broadcastEvent(text, thumbnailPath string) error {
+	failed := 0
 	for userID := range b.allowedUsers {
-		b.sendMessage(userID, text)
+		if thumbnailPath != "" {
+			err := b.SendPhoto(userID, thumbnailPath, text)
+			if err == nil {
+				continue
+			}
+			log.Printf("Failed to send event photo to user %d: %v", userID, err)
+		}
+
+		if err := b.SendMessage(userID, text); err != nil {
+			log.Printf("Failed to send message to user %d: %v", userID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("notification not delivered to %d of %d users", failed, len(b.allowedUsers))
 	}
+	return nil
 }
 
 // sendVideo отправляет видео пользователю
@@ -501,6 +714,12 @@ func (b *Bot) SendPhoto(userID int64, photoPath, caption string) error {
 	return nil
 }
 
+// isNotificationAllowed проверяет, что камера события уведомляет в текущем режиме охраны
+// и сейчас часы уведомлений
+func (b *Bot) isNotificationAllowed(event events.Event) bool {
+	return b.arming.CanNotify(event.CameraID) && b.isNotificationTimeAllowed()
+}
+
 // isNotificationTimeAllowed проверяет, разрешено ли отправлять уведомления
 func (b *Bot) isNotificationTimeAllowed() bool {
 	if b.config.NotificationHours == "" {
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// AnalyticsZoneRequest представляет запрос на создание/обновление линии или области аналитики
+type AnalyticsZoneRequest struct {
+	Name       string              `json:"name"`
+	Type       string              `json:"type"` // tripwire, loitering
+	Points     []storage.ZonePoint `json:"points"`
+	Direction  string              `json:"direction"`
+	Classes    []string            `json:"classes"`
+	MinSeconds float64             `json:"min_seconds"`
+	Enabled    *bool               `json:"enabled"` // по умолчанию true
+}
+
+// getAnalyticsZonesHandler возвращает линии и области аналитики камеры
+func (s *Server) getAnalyticsZonesHandler(w http.ResponseWriter, r *http.Request) {
+	zones, err := s.storage.GetAnalyticsZones(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get analytics: " + err.Error(),
+		})
+		return
+	}
+
+	if zones == nil {
+		zones = []storage.AnalyticsZone{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    zones,
+	})
+}
+
+// createAnalyticsZoneHandler добавляет линию или область аналитики камеры
+func (s *Server) createAnalyticsZoneHandler(w http.ResponseWriter, r *http.Request) {
+	cameraID := chi.URLParam(r, "id")
+
+	camera, err := s.storage.GetCamera(cameraID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get camera: " + err.Error(),
+		})
+		return
+	}
+
+	if camera == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Camera not found",
+		})
+		return
+	}
+
+	zone := &storage.AnalyticsZone{CameraID: cameraID}
+	if err := decodeAnalyticsZone(r, zone); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SaveAnalyticsZone(zone); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to create analytics: " + err.Error(),
+		})
+		return
+	}
+
+	s.applyAnalytics(cameraID)
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    zone,
+	})
+}
+
+// updateAnalyticsZoneHandler обновляет линию или область аналитики камеры
+func (s *Server) updateAnalyticsZoneHandler(w http.ResponseWriter, r *http.Request) {
+	zone, ok := s.findAnalyticsZone(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decodeAnalyticsZone(r, zone); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SaveAnalyticsZone(zone); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to update analytics: " + err.Error(),
+		})
+		return
+	}
+
+	s.applyAnalytics(zone.CameraID)
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    zone,
+	})
+}
+
+// deleteAnalyticsZoneHandler удаляет линию или область аналитики камеры
+func (s *Server) deleteAnalyticsZoneHandler(w http.ResponseWriter, r *http.Request) {
+	zone, ok := s.findAnalyticsZone(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteAnalyticsZone(zone.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete analytics: " + err.Error(),
+		})
+		return
+	}
+
+	s.applyAnalytics(zone.CameraID)
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// findAnalyticsZone находит линию или область из URL и проверяет, что она принадлежит камере
+func (s *Server) findAnalyticsZone(w http.ResponseWriter, r *http.Request) (*storage.AnalyticsZone, bool) {
+	analyticsID, err := strconv.Atoi(chi.URLParam(r, "analyticsID"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid analytics ID",
+		})
+		return nil, false
+	}
+
+	zone, err := s.storage.GetAnalyticsZone(analyticsID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get analytics: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if zone == nil || zone.CameraID != chi.URLParam(r, "id") {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Analytics not found",
+		})
+		return nil, false
+	}
+
+	return zone, true
+}
+
+// applyAnalytics передает актуальные линии и области камеры в стриминг
+func (s *Server) applyAnalytics(cameraID string) {
+	zones, err := s.storage.GetAnalyticsZones(cameraID)
+	if err != nil {
+		log.Printf("Failed to load analytics for camera %s: %v", cameraID, err)
+		return
+	}
+
+	// Камера может быть не запущена - аналитика подхватится при старте
+	if err := s.streamingServer.UpdateCameraAnalytics(cameraID, zones); err != nil {
+		log.Printf("Analytics for camera %s saved but not applied: %v", cameraID, err)
+	}
+}
+
+// decodeAnalyticsZone читает и проверяет линию или область из тела запроса
+func decodeAnalyticsZone(r *http.Request, zone *storage.AnalyticsZone) error {
+	var req AnalyticsZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	switch req.Type {
+	case storage.AnalyticsTypeTripwire:
+		if len(req.Points) != 2 {
+			return fmt.Errorf("tripwire must have exactly 2 points")
+		}
+		if req.Direction == "" {
+			req.Direction = storage.DirectionAny
+		}
+		if req.Direction != storage.DirectionAny && req.Direction != storage.DirectionLeftToRight && req.Direction != storage.DirectionRightToLeft {
+			return fmt.Errorf("direction must be %q, %q or %q", storage.DirectionAny, storage.DirectionLeftToRight, storage.DirectionRightToLeft)
+		}
+		req.MinSeconds = 0
+	case storage.AnalyticsTypeLoitering:
+		if len(req.Points) < 3 {
+			return fmt.Errorf("loitering area must have at least 3 points")
+		}
+		if req.MinSeconds <= 0 {
+			return fmt.Errorf("min_seconds must be positive for a loitering area")
+		}
+		req.Direction = storage.DirectionAny
+	default:
+		return fmt.Errorf("analytics type must be %q or %q", storage.AnalyticsTypeTripwire, storage.AnalyticsTypeLoitering)
+	}
+
+	for _, point := range req.Points {
+		if point.X < 0 || point.X > 1 || point.Y < 0 || point.Y > 1 {
+			return fmt.Errorf("points must be normalized to the 0..1 range")
+		}
+	}
+
+	if req.Name == "" {
+		req.Name = req.Type
+	}
+
+	zone.Name = req.Name
+	zone.Type = req.Type
+	zone.Points = req.Points
+	zone.Direction = req.Direction
+	zone.Classes = req.Classes
+	zone.MinSeconds = req.MinSeconds
+	zone.Enabled = req.Enabled == nil || *req.Enabled
+
+	return nil
+}
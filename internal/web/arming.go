@@ -0,0 +1,310 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocuai/internal/arming"
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// ArmingStatus текущий режим охраны с профилями режимов и расписанием
+type ArmingStatus struct {
+	Mode      string                   `json:"mode"`
+	Modes     []string                 `json:"modes"`
+	Profiles  []storage.ArmingProfile  `json:"profiles"`
+	Schedules []storage.ArmingSchedule `json:"schedules"`
+}
+
+// ArmingModeRequest представляет запрос на смену режима охраны
+type ArmingModeRequest struct {
+	Mode string `json:"mode"`
+}
+
+// ArmingProfileRequest представляет запрос на изменение профиля режима охраны
+type ArmingProfileRequest struct {
+	NotifyCameras []string `json:"notify_cameras"` // ["*"] - все камеры
+	RecordCameras []string `json:"record_cameras"`
+	AICameras     []string `json:"ai_cameras"`
+}
+
+// ArmingScheduleRequest представляет запрос на создание/обновление записи расписания охраны
+type ArmingScheduleRequest struct {
+	Mode    string   `json:"mode"`
+	Days    []string `json:"days"` // sun, mon, ... sat; пусто - каждый день
+	Time    string   `json:"time"` // ЧЧ:ММ
+	Enabled *bool    `json:"enabled"`
+}
+
+// getArmingHandler возвращает текущий режим охраны, профили режимов и расписание
+func (s *Server) getArmingHandler(w http.ResponseWriter, r *http.Request) {
+	schedules, err := s.storage.GetArmingSchedules()
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get arming schedules: " + err.Error(),
+		})
+		return
+	}
+
+	if schedules == nil {
+		schedules = []storage.ArmingSchedule{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data: ArmingStatus{
+			Mode:      s.armingManager.Mode(),
+			Modes:     arming.Modes,
+			Profiles:  s.armingManager.Profiles(),
+			Schedules: schedules,
+		},
+	})
+}
+
+// setArmingModeHandler переключает режим охраны
+func (s *Server) setArmingModeHandler(w http.ResponseWriter, r *http.Request) {
+	var req ArmingModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := s.armingManager.SetMode(strings.TrimSpace(req.Mode), arming.SourceAPI); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to set arming mode: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    map[string]string{"mode": s.armingManager.Mode()},
+	})
+}
+
+// getArmingHistoryHandler возвращает журнал смены режимов охраны (limit, offset)
+func (s *Server) getArmingHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	offset := 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	changes, err := s.storage.GetArmingChanges(limit, offset)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get arming history: " + err.Error(),
+		})
+		return
+	}
+
+	if changes == nil {
+		changes = []storage.ArmingChange{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    changes,
+	})
+}
+
+// updateArmingProfileHandler задает камеры, которые уведомляют, записывают и запускают AI в режиме
+func (s *Server) updateArmingProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var req ArmingProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	profile := storage.ArmingProfile{
+		Mode:          chi.URLParam(r, "mode"),
+		NotifyCameras: trimList(req.NotifyCameras),
+		RecordCameras: trimList(req.RecordCameras),
+		AICameras:     trimList(req.AICameras),
+	}
+
+	if err := s.armingManager.SaveProfile(&profile); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to save arming profile: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    profile,
+	})
+}
+
+// getArmingSchedulesHandler возвращает расписание переключения режимов охраны
+func (s *Server) getArmingSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	schedules, err := s.storage.GetArmingSchedules()
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get arming schedules: " + err.Error(),
+		})
+		return
+	}
+
+	if schedules == nil {
+		schedules = []storage.ArmingSchedule{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    schedules,
+	})
+}
+
+// createArmingScheduleHandler добавляет переключение режима по расписанию
+func (s *Server) createArmingScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var schedule storage.ArmingSchedule
+	if err := decodeArmingSchedule(r, &schedule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	s.saveArmingSchedule(w, r, &schedule)
+}
+
+// updateArmingScheduleHandler обновляет запись расписания охраны
+func (s *Server) updateArmingScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := s.findArmingSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decodeArmingSchedule(r, schedule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	s.saveArmingSchedule(w, r, schedule)
+}
+
+// deleteArmingScheduleHandler удаляет запись расписания охраны
+func (s *Server) deleteArmingScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := s.findArmingSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteArmingSchedule(schedule.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete arming schedule: " + err.Error(),
+		})
+		return
+	}
+
+	s.reloadArmingSchedules()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// saveArmingSchedule сохраняет запись расписания и перезапускает расписание
+func (s *Server) saveArmingSchedule(w http.ResponseWriter, r *http.Request, schedule *storage.ArmingSchedule) {
+	if err := s.storage.SaveArmingSchedule(schedule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to save arming schedule: " + err.Error(),
+		})
+		return
+	}
+
+	s.reloadArmingSchedules()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    schedule,
+	})
+}
+
+// reloadArmingSchedules перечитывает расписание охраны после изменения
+func (s *Server) reloadArmingSchedules() {
+	if err := s.armingManager.ReloadSchedules(); err != nil {
+		log.Printf("Failed to reload arming schedules: %v", err)
+	}
+}
+
+// findArmingSchedule находит запись расписания охраны по ID из URL
+func (s *Server) findArmingSchedule(w http.ResponseWriter, r *http.Request) (*storage.ArmingSchedule, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid schedule ID",
+		})
+		return nil, false
+	}
+
+	schedule, err := s.storage.GetArmingSchedule(id)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get arming schedule: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if schedule == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Schedule not found",
+		})
+		return nil, false
+	}
+
+	return schedule, true
+}
+
+// decodeArmingSchedule читает запись расписания охраны из тела запроса и проверяет ее
+func decodeArmingSchedule(r *http.Request, schedule *storage.ArmingSchedule) error {
+	var req ArmingScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	schedule.Mode = strings.TrimSpace(req.Mode)
+	schedule.Time = strings.TrimSpace(req.Time)
+	if at, err := time.Parse("15:04", schedule.Time); err == nil {
+		schedule.Time = at.Format("15:04")
+	}
+	schedule.Days = trimList(req.Days)
+	for i, day := range schedule.Days {
+		schedule.Days[i] = strings.ToLower(day)
+	}
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+
+	return arming.ValidateSchedule(schedule)
+}
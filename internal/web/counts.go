@@ -0,0 +1,128 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/render"
+)
+
+// maxCountsRange максимальный интервал отчета по счетчикам
+const maxCountsRange = 366 * 24 * time.Hour
+
+// countsHandler возвращает счетчики объектов по часам или дням (format=csv - в CSV)
+func (s *Server) countsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseCountFilter(r)
+	csvFormat := r.URL.Query().Get("format") == "csv"
+
+	if err != nil {
+		if csvFormat {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	counts, err := s.storage.GetCounts(filter)
+	if err != nil {
+		if csvFormat {
+			http.Error(w, "Failed to get counts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get counts: " + err.Error(),
+		})
+		return
+	}
+
+	if csvFormat {
+		writeCountsCSV(w, filter, counts)
+		return
+	}
+
+	if counts == nil {
+		counts = []storage.CountBucket{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"from":   filter.From,
+			"to":     filter.To,
+			"bucket": filter.Bucket,
+			"counts": counts,
+		},
+	})
+}
+
+// parseCountFilter разбирает параметры camera, class, kind, from, to и bucket
+func parseCountFilter(r *http.Request) (storage.CountFilter, error) {
+	query := r.URL.Query()
+	now := time.Now()
+
+	filter := storage.CountFilter{
+		CameraID: query.Get("camera"),
+		Class:    query.Get("class"),
+		Kind:     query.Get("kind"),
+		Bucket:   query.Get("bucket"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from"), now.Add(-24*time.Hour)); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseTimeParam(query.Get("to"), now); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	if !filter.To.After(filter.From) {
+		return filter, fmt.Errorf("to must be after from")
+	}
+	if filter.To.Sub(filter.From) > maxCountsRange {
+		return filter, fmt.Errorf("time range must not exceed %s", maxCountsRange)
+	}
+
+	if filter.Bucket == "" {
+		filter.Bucket = storage.CountBucketHour
+	}
+	if filter.Bucket != storage.CountBucketHour && filter.Bucket != storage.CountBucketDay {
+		return filter, fmt.Errorf("bucket must be %q or %q", storage.CountBucketHour, storage.CountBucketDay)
+	}
+
+	if filter.Kind != "" && filter.Kind != storage.CountKindDetection && filter.Kind != storage.CountKindLineCrossing {
+		return filter, fmt.Errorf("kind must be %q or %q", storage.CountKindDetection, storage.CountKindLineCrossing)
+	}
+
+	return filter, nil
+}
+
+// writeCountsCSV отдает счетчики файлом CSV
+func writeCountsCSV(w http.ResponseWriter, filter storage.CountFilter, counts []storage.CountBucket) {
+	filename := fmt.Sprintf("counts_%s_%s.csv", filter.From.Format("20060102"), filter.To.Format("20060102"))
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"start", "camera_id", "class", "kind", "direction", "count"})
+	for _, count := range counts {
+		writer.Write([]string{
+			count.Start.Format(time.RFC3339),
+			count.CameraID,
+			count.Class,
+			count.Kind,
+			count.Direction,
+			strconv.Itoa(count.Count),
+		})
+	}
+	writer.Flush()
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// getEventBusHandler возвращает позиции подписчиков журнала событий и количество потерянных событий
+func (s *Server) getEventBusHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.eventManager.GetDeliveryStats()
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get delivery stats: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    stats,
+	})
+}
+
+// getFailedDeliveriesHandler возвращает события, не доставленные подписчикам
+func (s *Server) getFailedDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	deliveries, err := s.storage.GetFailedDeliveries(r.URL.Query().Get("subscriber"), limit)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get failed deliveries: " + err.Error(),
+		})
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []storage.FailedDelivery{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    deliveries,
+	})
+}
+
+// retryFailedDeliveryHandler повторно доставляет событие подписчику
+func (s *Server) retryFailedDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid delivery ID",
+		})
+		return
+	}
+
+	if err := s.eventManager.RetryFailedDelivery(id); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to deliver event: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
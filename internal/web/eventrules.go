@@ -0,0 +1,313 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocuai/internal/rules"
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// EventRuleRequest представляет запрос на создание/обновление правила событий
+type EventRuleRequest struct {
+	Name            string                `json:"name"`
+	Enabled         *bool                 `json:"enabled"` // по умолчанию true
+	EventTypes      []string              `json:"event_types"`
+	CameraIDs       []string              `json:"camera_ids"`
+	Classes         []string              `json:"classes"`
+	ZoneID          int                   `json:"zone_id"`
+	StartTime       string                `json:"start_time"`
+	EndTime         string                `json:"end_time"`
+	ArmingModes     []string              `json:"arming_modes"`
+	CooldownSeconds int                   `json:"cooldown_seconds"`
+	MinConfidence   float32               `json:"min_confidence"`
+	Actions         []storage.EventAction `json:"actions"`
+}
+
+// getEventRulesHandler возвращает все правила событий
+func (s *Server) getEventRulesHandler(w http.ResponseWriter, r *http.Request) {
+	eventRules, err := s.storage.GetEventRules()
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get rules: " + err.Error(),
+		})
+		return
+	}
+
+	if eventRules == nil {
+		eventRules = []storage.EventRule{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    eventRules,
+	})
+}
+
+// getEventRuleHandler возвращает правило по ID
+func (s *Server) getEventRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.findEventRule(w, r)
+	if !ok {
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    rule,
+	})
+}
+
+// createEventRuleHandler создает правило событий
+func (s *Server) createEventRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var rule storage.EventRule
+	if err := s.decodeEventRule(r, &rule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	s.saveEventRule(w, r, &rule)
+}
+
+// updateEventRuleHandler обновляет правило событий
+func (s *Server) updateEventRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.findEventRule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.decodeEventRule(r, rule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	s.saveEventRule(w, r, rule)
+}
+
+// deleteEventRuleHandler удаляет правило событий
+func (s *Server) deleteEventRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.findEventRule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteEventRule(rule.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete rule: " + err.Error(),
+		})
+		return
+	}
+
+	s.reloadEventRules()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// dryRunEventRuleHandler проверяет сохраненное правило на прошлых событиях (from, to, limit)
+func (s *Server) dryRunEventRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.findEventRule(w, r)
+	if !ok {
+		return
+	}
+
+	s.dryRunEventRule(w, r, *rule)
+}
+
+// dryRunDraftEventRuleHandler проверяет еще не сохраненное правило из тела запроса на прошлых событиях
+func (s *Server) dryRunDraftEventRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var rule storage.EventRule
+	if err := s.decodeEventRule(r, &rule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	s.dryRunEventRule(w, r, rule)
+}
+
+// dryRunEventRule выполняет пробный прогон правила без действий; по умолчанию за последние сутки
+func (s *Server) dryRunEventRule(w http.ResponseWriter, r *http.Request, rule storage.EventRule) {
+	now := time.Now()
+
+	from, err := parseTimeParam(r.URL.Query().Get("from"), now.Add(-24*time.Hour))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid from: " + err.Error(),
+		})
+		return
+	}
+
+	to, err := parseTimeParam(r.URL.Query().Get("to"), now)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid to: " + err.Error(),
+		})
+		return
+	}
+
+	if !to.After(from) {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "to must be after from",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	result, err := s.ruleEngine.DryRun(rule, from, to, limit)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Dry run failed: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// saveEventRule сохраняет правило и применяет изменения к движку правил
+func (s *Server) saveEventRule(w http.ResponseWriter, r *http.Request, rule *storage.EventRule) {
+	if err := s.storage.SaveEventRule(rule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to save rule: " + err.Error(),
+		})
+		return
+	}
+
+	s.reloadEventRules()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    rule,
+	})
+}
+
+// reloadEventRules перечитывает правила в движке после изменения
+func (s *Server) reloadEventRules() {
+	if err := s.ruleEngine.Reload(); err != nil {
+		log.Printf("Failed to reload event rules: %v", err)
+	}
+}
+
+// findEventRule находит правило по ID из URL
+func (s *Server) findEventRule(w http.ResponseWriter, r *http.Request) (*storage.EventRule, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid rule ID",
+		})
+		return nil, false
+	}
+
+	rule, err := s.storage.GetEventRule(id)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get rule: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if rule == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Rule not found",
+		})
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// decodeEventRule читает правило из тела запроса и проверяет его, включая зону и вебхуки
+func (s *Server) decodeEventRule(r *http.Request, rule *storage.EventRule) error {
+	var req EventRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+	rule.EventTypes = trimList(req.EventTypes)
+	rule.CameraIDs = trimList(req.CameraIDs)
+	rule.Classes = trimList(req.Classes)
+	rule.ZoneID = req.ZoneID
+	rule.StartTime = strings.TrimSpace(req.StartTime)
+	rule.EndTime = strings.TrimSpace(req.EndTime)
+	rule.ArmingModes = trimList(req.ArmingModes)
+	rule.CooldownSeconds = req.CooldownSeconds
+	rule.MinConfidence = req.MinConfidence
+	rule.Actions = req.Actions
+
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if err := rules.Validate(rule); err != nil {
+		return err
+	}
+
+	if rule.ZoneID != 0 {
+		zone, err := s.storage.GetZone(rule.ZoneID)
+		if err != nil {
+			return fmt.Errorf("failed to get zone: %w", err)
+		}
+		if zone == nil {
+			return fmt.Errorf("zone %d not found", rule.ZoneID)
+		}
+	}
+
+	for _, action := range rule.Actions {
+		if action.Type != storage.EventActionWebhook {
+			continue
+		}
+		webhook, err := s.storage.GetWebhook(action.WebhookID)
+		if err != nil {
+			return fmt.Errorf("failed to get webhook: %w", err)
+		}
+		if webhook == nil {
+			return fmt.Errorf("webhook %d not found", action.WebhookID)
+		}
+	}
+
+	return nil
+}
+
+// trimList убирает пробелы и пустые значения из списка
+func trimList(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,210 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// ExportRequest представляет запрос на экспорт видео
+type ExportRequest struct {
+	CameraIDs []string  `json:"camera_ids"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+// createExportHandler ставит в очередь экспорт записей камер за интервал
+func (s *Server) createExportHandler(w http.ResponseWriter, r *http.Request) {
+	var req ExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if len(req.CameraIDs) == 0 {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "At least one camera is required",
+		})
+		return
+	}
+
+	if !req.To.After(req.From) {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "to must be after from",
+		})
+		return
+	}
+
+	if req.To.Sub(req.From) > maxTimelineRange {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("time range must not exceed %s", maxTimelineRange),
+		})
+		return
+	}
+
+	for _, cameraID := range req.CameraIDs {
+		camera, err := s.storage.GetCamera(cameraID)
+		if err != nil {
+			render.JSON(w, r, APIResponse{
+				Success: false,
+				Error:   "Failed to get camera: " + err.Error(),
+			})
+			return
+		}
+		if camera == nil {
+			render.JSON(w, r, APIResponse{
+				Success: false,
+				Error:   "Camera not found: " + cameraID,
+			})
+			return
+		}
+	}
+
+	job, err := s.exportManager.Submit(req.CameraIDs, req.From, req.To)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to create export: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// getExportsHandler возвращает последние задания на экспорт
+func (s *Server) getExportsHandler(w http.ResponseWriter, r *http.Request) {
+	exports, err := s.storage.GetExports(50)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get exports: " + err.Error(),
+		})
+		return
+	}
+
+	if exports == nil {
+		exports = []storage.Export{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    exports,
+	})
+}
+
+// getExportHandler возвращает состояние задания на экспорт
+func (s *Server) getExportHandler(w http.ResponseWriter, r *http.Request) {
+	export, err := s.storage.GetExport(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get export: " + err.Error(),
+		})
+		return
+	}
+
+	if export == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Export not found",
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    export,
+	})
+}
+
+// deleteExportHandler удаляет завершенное задание на экспорт и его архив
+func (s *Server) deleteExportHandler(w http.ResponseWriter, r *http.Request) {
+	export, err := s.storage.GetExport(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get export: " + err.Error(),
+		})
+		return
+	}
+
+	if export == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Export not found",
+		})
+		return
+	}
+
+	if err := s.exportManager.Delete(export); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete export: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// downloadExportHandler отдает готовый архив экспорта
+func (s *Server) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	export, err := s.storage.GetExport(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to get export: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if export == nil {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+
+	if export.Status != storage.ExportStatusCompleted {
+		http.Error(w, "Export is not ready", http.StatusConflict)
+		return
+	}
+
+	file, err := os.Open(export.FilePath)
+	if err != nil {
+		http.Error(w, "Export file not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Failed to read export", http.StatusInternalServerError)
+		return
+	}
+
+	// Архив может скачиваться дольше WriteTimeout сервера
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear write deadline for export %s: %v", export.ID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(export.FilePath)))
+	http.ServeContent(w, r, filepath.Base(export.FilePath), stat.ModTime(), file)
+}
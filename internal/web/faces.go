@@ -0,0 +1,384 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// maxFacePhotoSize максимальный размер загружаемого эталонного фото
+const maxFacePhotoSize = 10 << 20
+
+// PersonRequest представляет запрос на создание/обновление человека в галерее
+type PersonRequest struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// getPeopleHandler возвращает людей из галереи лиц
+func (s *Server) getPeopleHandler(w http.ResponseWriter, r *http.Request) {
+	people, err := s.storage.GetPeople()
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get people: " + err.Error(),
+		})
+		return
+	}
+
+	if people == nil {
+		people = []storage.Person{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    people,
+	})
+}
+
+// getPersonHandler возвращает человека из галереи
+func (s *Server) getPersonHandler(w http.ResponseWriter, r *http.Request) {
+	person, ok := s.findPerson(w, r)
+	if !ok {
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    person,
+	})
+}
+
+// createPersonHandler добавляет человека в галерею
+func (s *Server) createPersonHandler(w http.ResponseWriter, r *http.Request) {
+	person := &storage.Person{}
+	if err := decodePerson(r, person); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SavePerson(person); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to create person: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    person,
+	})
+}
+
+// updatePersonHandler обновляет имя и теги человека
+func (s *Server) updatePersonHandler(w http.ResponseWriter, r *http.Request) {
+	person, ok := s.findPerson(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decodePerson(r, person); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SavePerson(person); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to update person: " + err.Error(),
+		})
+		return
+	}
+
+	// Имя хранится в галерее распознавания
+	s.reloadFaceGallery()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    person,
+	})
+}
+
+// deletePersonHandler удаляет человека вместе с эталонными фото
+func (s *Server) deletePersonHandler(w http.ResponseWriter, r *http.Request) {
+	person, ok := s.findPerson(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeletePerson(person.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete person: " + err.Error(),
+		})
+		return
+	}
+
+	if err := os.RemoveAll(s.facePhotoDir(person.ID)); err != nil {
+		log.Printf("Failed to remove face photos of person %d: %v", person.ID, err)
+	}
+
+	s.reloadFaceGallery()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// getFacePhotosHandler возвращает эталонные фото человека
+func (s *Server) getFacePhotosHandler(w http.ResponseWriter, r *http.Request) {
+	person, ok := s.findPerson(w, r)
+	if !ok {
+		return
+	}
+
+	photos, err := s.storage.GetFacePhotos(person.ID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get face photos: " + err.Error(),
+		})
+		return
+	}
+
+	if photos == nil {
+		photos = []storage.FacePhoto{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    photos,
+	})
+}
+
+// uploadFacePhotoHandler добавляет эталонное фото (multipart поле photo)
+func (s *Server) uploadFacePhotoHandler(w http.ResponseWriter, r *http.Request) {
+	person, ok := s.findPerson(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFacePhotoSize)
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Photo is required: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to read photo: " + err.Error(),
+		})
+		return
+	}
+
+	ext := photoExtension(data)
+	if ext == "" {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Photo must be a JPEG or PNG image",
+		})
+		return
+	}
+
+	embedding, err := s.streamingServer.EmbedFacePhoto(data)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to process photo: " + err.Error(),
+		})
+		return
+	}
+
+	dir := s.facePhotoDir(person.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to save photo: " + err.Error(),
+		})
+		return
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%d%s", time.Now().UnixNano(), ext))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to save photo: " + err.Error(),
+		})
+		return
+	}
+
+	photo := &storage.FacePhoto{
+		PersonID:   person.ID,
+		PersonName: person.Name,
+		ImagePath:  path,
+		Embedding:  embedding,
+	}
+	if err := s.storage.AddFacePhoto(photo); err != nil {
+		os.Remove(path)
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to save photo: " + err.Error(),
+		})
+		return
+	}
+
+	s.reloadFaceGallery()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    photo,
+	})
+}
+
+// facePhotoFileHandler отдает файл эталонного фото
+func (s *Server) facePhotoFileHandler(w http.ResponseWriter, r *http.Request) {
+	photo, ok := s.findFacePhoto(w, r)
+	if !ok {
+		return
+	}
+
+	http.ServeFile(w, r, photo.ImagePath)
+}
+
+// deleteFacePhotoHandler удаляет эталонное фото
+func (s *Server) deleteFacePhotoHandler(w http.ResponseWriter, r *http.Request) {
+	photo, ok := s.findFacePhoto(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteFacePhoto(photo.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete face photo: " + err.Error(),
+		})
+		return
+	}
+
+	os.Remove(photo.ImagePath)
+	s.reloadFaceGallery()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// findPerson находит человека из URL
+func (s *Server) findPerson(w http.ResponseWriter, r *http.Request) (*storage.Person, bool) {
+	personID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid person ID",
+		})
+		return nil, false
+	}
+
+	person, err := s.storage.GetPerson(personID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get person: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if person == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Person not found",
+		})
+		return nil, false
+	}
+
+	return person, true
+}
+
+// findFacePhoto находит фото из URL и проверяет, что оно принадлежит человеку
+func (s *Server) findFacePhoto(w http.ResponseWriter, r *http.Request) (*storage.FacePhoto, bool) {
+	photoID, err := strconv.Atoi(chi.URLParam(r, "photoID"))
+	if err != nil {
+		http.Error(w, "Invalid photo ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	photo, err := s.storage.GetFacePhoto(photoID)
+	if err != nil {
+		http.Error(w, "Failed to get face photo: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if photo == nil || strconv.Itoa(photo.PersonID) != chi.URLParam(r, "id") {
+		http.Error(w, "Face photo not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return photo, true
+}
+
+// reloadFaceGallery обновляет галерею распознавания после изменений
+func (s *Server) reloadFaceGallery() {
+	if err := s.streamingServer.ReloadFaceGallery(); err != nil {
+		log.Printf("Failed to reload face gallery: %v", err)
+	}
+}
+
+// facePhotoDir возвращает директорию эталонных фото человека
+func (s *Server) facePhotoDir(personID int) string {
+	return filepath.Join(s.config.Storage.VideoPath, "faces", strconv.Itoa(personID))
+}
+
+// decodePerson читает и проверяет человека из тела запроса
+func decodePerson(r *http.Request, person *storage.Person) error {
+	var req PersonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	person.Name = req.Name
+	person.Tags = req.Tags
+	return nil
+}
+
+// photoExtension возвращает расширение файла по содержимому изображения
+func photoExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	default:
+		return ""
+	}
+}
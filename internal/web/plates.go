@@ -0,0 +1,248 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocuai/internal/ai"
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// WatchlistRequest представляет запрос на создание/обновление номера в списках
+type WatchlistRequest struct {
+	Plate   string `json:"plate"`
+	List    string `json:"list"` // allow, deny
+	Label   string `json:"label"`
+	Enabled *bool  `json:"enabled"` // по умолчанию true
+}
+
+// searchPlatesHandler ищет распознанные номера по истории (q, camera, from, to, limit, offset)
+func (s *Server) searchPlatesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := storage.PlateFilter{
+		Query:    ai.NormalizePlate(query.Get("q")),
+		CameraID: query.Get("camera"),
+		Limit:    100,
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from"), time.Time{}); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid from: " + err.Error(),
+		})
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to"), time.Time{}); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid to: " + err.Error(),
+		})
+		return
+	}
+
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l <= 1000 {
+		filter.Limit = l
+	}
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o >= 0 {
+		filter.Offset = o
+	}
+
+	reads, err := s.storage.SearchPlates(filter)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to search plates: " + err.Error(),
+		})
+		return
+	}
+
+	if reads == nil {
+		reads = []storage.PlateRead{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    reads,
+	})
+}
+
+// plateImageHandler отдает вырезку распознанного номера
+func (s *Server) plateImageHandler(w http.ResponseWriter, r *http.Request) {
+	readID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid plate ID", http.StatusBadRequest)
+		return
+	}
+
+	read, err := s.storage.GetPlateRead(readID)
+	if err != nil {
+		http.Error(w, "Failed to get plate: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if read == nil || read.ImagePath == "" {
+		http.Error(w, "Plate image not found", http.StatusNotFound)
+		return
+	}
+
+	http.ServeFile(w, r, read.ImagePath)
+}
+
+// getWatchlistHandler возвращает белый и черный списки номеров
+func (s *Server) getWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.storage.GetWatchlist()
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get watchlist: " + err.Error(),
+		})
+		return
+	}
+
+	if entries == nil {
+		entries = []storage.WatchlistEntry{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    entries,
+	})
+}
+
+// createWatchlistEntryHandler добавляет номер в список
+func (s *Server) createWatchlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	entry := &storage.WatchlistEntry{}
+	if err := decodeWatchlistEntry(r, entry); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SaveWatchlistEntry(entry); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to create watchlist entry: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    entry,
+	})
+}
+
+// updateWatchlistEntryHandler обновляет номер в списках
+func (s *Server) updateWatchlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.findWatchlistEntry(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decodeWatchlistEntry(r, entry); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SaveWatchlistEntry(entry); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to update watchlist entry: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    entry,
+	})
+}
+
+// deleteWatchlistEntryHandler удаляет номер из списков
+func (s *Server) deleteWatchlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.findWatchlistEntry(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteWatchlistEntry(entry.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete watchlist entry: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// findWatchlistEntry находит номер из списков по ID из URL
+func (s *Server) findWatchlistEntry(w http.ResponseWriter, r *http.Request) (*storage.WatchlistEntry, bool) {
+	entryID, err := strconv.Atoi(chi.URLParam(r, "entryID"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid watchlist entry ID",
+		})
+		return nil, false
+	}
+
+	entry, err := s.storage.GetWatchlistEntry(entryID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get watchlist entry: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if entry == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Watchlist entry not found",
+		})
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// decodeWatchlistEntry читает и проверяет номер из тела запроса
+func decodeWatchlistEntry(r *http.Request, entry *storage.WatchlistEntry) error {
+	var req WatchlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	plate := ai.NormalizePlate(req.Plate)
+	if plate == "" {
+		return fmt.Errorf("plate is required")
+	}
+
+	list := strings.ToLower(req.List)
+	if list != storage.WatchlistAllow && list != storage.WatchlistDeny {
+		return fmt.Errorf("list must be %q or %q", storage.WatchlistAllow, storage.WatchlistDeny)
+	}
+
+	entry.Plate = plate
+	entry.List = list
+	entry.Label = strings.TrimSpace(req.Label)
+	entry.Enabled = req.Enabled == nil || *req.Enabled
+	return nil
+}
@@ -0,0 +1,257 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// maxTimelineRange максимальный интервал, который можно запросить за раз
+const maxTimelineRange = 7 * 24 * time.Hour
+
+// TimelineRecording сегмент записи на таймлайне
+type TimelineRecording struct {
+	storage.Recording
+	URL string `json:"url"`
+}
+
+// Timeline содержимое таймлайна камеры за интервал
+type Timeline struct {
+	CameraID   string              `json:"camera_id"`
+	From       time.Time           `json:"from"`
+	To         time.Time           `json:"to"`
+	Recordings []TimelineRecording `json:"recordings"`
+	Events     []storage.Event     `json:"events"`
+}
+
+// timelineHandler возвращает сегменты записи и события камеры за интервал
+func (s *Server) timelineHandler(w http.ResponseWriter, r *http.Request) {
+	cameraID := chi.URLParam(r, "id")
+
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	recordings, err := s.storage.GetRecordings(cameraID, from, to)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get recordings: " + err.Error(),
+		})
+		return
+	}
+
+	events, err := s.storage.GetEventsInRange(cameraID, from, to)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get events: " + err.Error(),
+		})
+		return
+	}
+
+	timeline := Timeline{
+		CameraID:   cameraID,
+		From:       from,
+		To:         to,
+		Recordings: make([]TimelineRecording, 0, len(recordings)),
+		Events:     events,
+	}
+	for _, recording := range recordings {
+		timeline.Recordings = append(timeline.Recordings, TimelineRecording{
+			Recording: recording,
+			URL:       recordingURL(cameraID, recording.ID),
+		})
+	}
+	if timeline.Events == nil {
+		timeline.Events = []storage.Event{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    timeline,
+	})
+}
+
+// recordingFileHandler отдает файл сегмента с поддержкой HTTP Range
+func (s *Server) recordingFileHandler(w http.ResponseWriter, r *http.Request) {
+	cameraID := chi.URLParam(r, "id")
+
+	recordingID, err := strconv.Atoi(chi.URLParam(r, "recordingID"))
+	if err != nil {
+		http.Error(w, "Invalid recording ID", http.StatusBadRequest)
+		return
+	}
+
+	recording, err := s.storage.GetRecording(recordingID)
+	if err != nil {
+		http.Error(w, "Failed to get recording: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if recording == nil || recording.CameraID != cameraID {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	file, err := os.Open(recording.Path)
+	if err != nil {
+		http.Error(w, "Recording file not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+
+	// Сегмент может скачиваться дольше WriteTimeout сервера
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear write deadline for recording %d: %v", recording.ID, err)
+	}
+
+	w.Header().Set("Content-Type", videoContentType(recording.Path))
+	http.ServeContent(w, r, filepath.Base(recording.Path), stat.ModTime(), file)
+}
+
+// playbackPlaylistHandler возвращает HLS VOD плейлист, склеивающий сегменты за интервал
+func (s *Server) playbackPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	cameraID := chi.URLParam(r, "id")
+
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recordings, err := s.storage.GetRecordings(cameraID, from, to)
+	if err != nil {
+		http.Error(w, "Failed to get recordings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// В VOD плейлист попадают только завершенные сегменты в формате MPEG-TS
+	segments := make([]storage.Recording, 0, len(recordings))
+	for _, recording := range recordings {
+		if recording.Status != storage.RecordingStatusComplete {
+			continue
+		}
+		if !strings.EqualFold(filepath.Ext(recording.Path), ".ts") {
+			http.Error(w, "HLS playback requires recordings in ts format", http.StatusConflict)
+			return
+		}
+		segments = append(segments, recording)
+	}
+
+	if len(segments) == 0 {
+		http.Error(w, "No recordings in the requested range", http.StatusNotFound)
+		return
+	}
+
+	targetDuration := 1
+	for _, segment := range segments {
+		duration := int(math.Ceil(segment.EndTime.Sub(segment.StartTime).Seconds()))
+		if duration > targetDuration {
+			targetDuration = duration
+		}
+	}
+
+	var playlist strings.Builder
+	playlist.WriteString("#EXTM3U\n")
+	playlist.WriteString("#EXT-X-VERSION:3\n")
+	playlist.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	playlist.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", targetDuration))
+	playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+
+	// Начинаем воспроизведение с запрошенного момента внутри первого сегмента
+	if offset := from.Sub(segments[0].StartTime); offset > 0 {
+		playlist.WriteString(fmt.Sprintf("#EXT-X-START:TIME-OFFSET=%.3f,PRECISE=YES\n", offset.Seconds()))
+	}
+
+	for i, segment := range segments {
+		// Каждый сегмент - отдельный файл со своими временными метками
+		if i > 0 {
+			playlist.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		playlist.WriteString(fmt.Sprintf("#EXT-X-PROGRAM-DATE-TIME:%s\n", segment.StartTime.UTC().Format("2006-01-02T15:04:05.000Z")))
+		playlist.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n", segment.EndTime.Sub(segment.StartTime).Seconds()))
+		playlist.WriteString(recordingURL(cameraID, segment.ID) + "\n")
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write([]byte(playlist.String()))
+}
+
+// parseTimeRange разбирает параметры from и to (RFC3339 или unix-время в секундах)
+func parseTimeRange(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+
+	from, err := parseTimeParam(r.URL.Query().Get("from"), now.Add(-time.Hour))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+	}
+
+	to, err := parseTimeParam(r.URL.Query().Get("to"), now)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must be after from")
+	}
+
+	if to.Sub(from) > maxTimelineRange {
+		return time.Time{}, time.Time{}, fmt.Errorf("time range must not exceed %s", maxTimelineRange)
+	}
+
+	return from, to, nil
+}
+
+// parseTimeParam разбирает время в формате RFC3339 или unix-время в секундах
+func parseTimeParam(value string, defaultValue time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// recordingURL возвращает адрес файла сегмента
+func recordingURL(cameraID string, recordingID int) string {
+	return fmt.Sprintf("/api/streaming/cameras/%s/recordings/%d", cameraID, recordingID)
+}
+
+// videoContentType возвращает MIME тип видеофайла по расширению
+func videoContentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ts":
+		return "video/mp2t"
+	case ".mkv":
+		return "video/x-matroska"
+	default:
+		return "video/mp4"
+	}
+}
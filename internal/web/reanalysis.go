@@ -0,0 +1,214 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// maxReanalysisFPS предельная частота анализа кадров записи
+const maxReanalysisFPS = 30
+
+// ReanalysisRequest представляет запрос на повторный анализ записей камеры
+type ReanalysisRequest struct {
+	CameraID  string    `json:"camera_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Model     string    `json:"model"`     // имя модели из ai.models, пусто - модель по умолчанию
+	Threshold float32   `json:"threshold"` // 0 - порог из конфигурации
+	Classes   []string  `json:"classes"`   // пусто - классы из конфигурации
+	FPS       float64   `json:"fps"`       // 0 - один кадр в секунду
+}
+
+// createReanalysisHandler ставит в очередь повторный анализ записей камеры за интервал
+func (s *Server) createReanalysisHandler(w http.ResponseWriter, r *http.Request) {
+	var req ReanalysisRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := validateReanalysis(req); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	camera, err := s.storage.GetCamera(req.CameraID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get camera: " + err.Error(),
+		})
+		return
+	}
+	if camera == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Camera not found",
+		})
+		return
+	}
+
+	job, err := s.reanalysisManager.Submit(storage.ReanalysisJob{
+		CameraID:  req.CameraID,
+		From:      req.From,
+		To:        req.To,
+		Model:     req.Model,
+		Threshold: req.Threshold,
+		Classes:   req.Classes,
+		FPS:       req.FPS,
+	})
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to create reanalysis job: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// validateReanalysis проверяет параметры повторного анализа
+func validateReanalysis(req ReanalysisRequest) error {
+	if req.CameraID == "" {
+		return fmt.Errorf("camera_id is required")
+	}
+	if !req.To.After(req.From) {
+		return fmt.Errorf("to must be after from")
+	}
+	if req.To.Sub(req.From) > maxTimelineRange {
+		return fmt.Errorf("time range must not exceed %s", maxTimelineRange)
+	}
+	if req.Threshold < 0 || req.Threshold > 1 {
+		return fmt.Errorf("threshold must be between 0 and 1")
+	}
+	if req.FPS < 0 || req.FPS > maxReanalysisFPS {
+		return fmt.Errorf("fps must be between 0 and %d", maxReanalysisFPS)
+	}
+	return nil
+}
+
+// getReanalysisJobsHandler возвращает последние задания на повторный анализ
+func (s *Server) getReanalysisJobsHandler(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.storage.GetReanalysisJobs(50)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get reanalysis jobs: " + err.Error(),
+		})
+		return
+	}
+
+	if jobs == nil {
+		jobs = []storage.ReanalysisJob{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    jobs,
+	})
+}
+
+// getReanalysisJobHandler возвращает состояние задания на повторный анализ
+func (s *Server) getReanalysisJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := s.storage.GetReanalysisJob(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get reanalysis job: " + err.Error(),
+		})
+		return
+	}
+
+	if job == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Reanalysis job not found",
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// cancelReanalysisHandler отменяет ожидающее или выполняемое задание на повторный анализ
+func (s *Server) cancelReanalysisHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.reanalysisManager.Cancel(chi.URLParam(r, "id")); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to cancel reanalysis job: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// getReanalysisEventsHandler возвращает события, найденные заданием повторного анализа
+func (s *Server) getReanalysisEventsHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := s.storage.GetReanalysisJob(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get reanalysis job: " + err.Error(),
+		})
+		return
+	}
+
+	if job == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Reanalysis job not found",
+		})
+		return
+	}
+
+	limit := 100
+	offset := 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	events, err := s.storage.GetJobEvents(job.ID, limit, offset)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get events: " + err.Error(),
+		})
+		return
+	}
+
+	if events == nil {
+		events = []storage.Event{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    events,
+	})
+}
@@ -0,0 +1,254 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ocuai/internal/ai"
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// DetectionRuleRequest представляет запрос на создание/обновление правила детекции
+type DetectionRuleRequest struct {
+	Class          string  `json:"class"`
+	Action         string  `json:"action"` // alert, ignore
+	MinConfidence  float32 `json:"min_confidence"`
+	ZoneID         int     `json:"zone_id"`
+	StartTime      string  `json:"start_time"`
+	EndTime        string  `json:"end_time"`
+	MinArea        float64 `json:"min_area"`
+	MinDwellFrames int     `json:"min_dwell_frames"`
+	Enabled        *bool   `json:"enabled"` // по умолчанию true
+}
+
+// getDetectionRulesHandler возвращает правила детекции камеры
+func (s *Server) getDetectionRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.storage.GetDetectionRules(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get detection rules: " + err.Error(),
+		})
+		return
+	}
+
+	if rules == nil {
+		rules = []storage.DetectionRule{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    rules,
+	})
+}
+
+// createDetectionRuleHandler добавляет правило детекции камеры
+func (s *Server) createDetectionRuleHandler(w http.ResponseWriter, r *http.Request) {
+	cameraID := chi.URLParam(r, "id")
+
+	camera, err := s.storage.GetCamera(cameraID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get camera: " + err.Error(),
+		})
+		return
+	}
+
+	if camera == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Camera not found",
+		})
+		return
+	}
+
+	rule := &storage.DetectionRule{CameraID: cameraID}
+	if err := s.decodeDetectionRule(r, rule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SaveDetectionRule(rule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to create detection rule: " + err.Error(),
+		})
+		return
+	}
+
+	s.applyDetectionRules(cameraID)
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    rule,
+	})
+}
+
+// updateDetectionRuleHandler обновляет правило детекции камеры
+func (s *Server) updateDetectionRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.findDetectionRule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.decodeDetectionRule(r, rule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SaveDetectionRule(rule); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to update detection rule: " + err.Error(),
+		})
+		return
+	}
+
+	s.applyDetectionRules(rule.CameraID)
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    rule,
+	})
+}
+
+// deleteDetectionRuleHandler удаляет правило детекции камеры
+func (s *Server) deleteDetectionRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.findDetectionRule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteDetectionRule(rule.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete detection rule: " + err.Error(),
+		})
+		return
+	}
+
+	s.applyDetectionRules(rule.CameraID)
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// findDetectionRule находит правило из URL и проверяет, что оно принадлежит камере
+func (s *Server) findDetectionRule(w http.ResponseWriter, r *http.Request) (*storage.DetectionRule, bool) {
+	ruleID, err := strconv.Atoi(chi.URLParam(r, "ruleID"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid rule ID",
+		})
+		return nil, false
+	}
+
+	rule, err := s.storage.GetDetectionRule(ruleID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get detection rule: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if rule == nil || rule.CameraID != chi.URLParam(r, "id") {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Detection rule not found",
+		})
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// applyDetectionRules передает актуальные правила камеры в стриминг
+func (s *Server) applyDetectionRules(cameraID string) {
+	rules, err := s.storage.GetDetectionRules(cameraID)
+	if err != nil {
+		log.Printf("Failed to load detection rules for camera %s: %v", cameraID, err)
+		return
+	}
+
+	zones, err := s.storage.GetZones(cameraID)
+	if err != nil {
+		log.Printf("Failed to load zones for camera %s: %v", cameraID, err)
+		return
+	}
+
+	// Камера может быть не запущена - правила подхватятся при старте
+	if err := s.streamingServer.UpdateCameraRules(cameraID, rules, zones); err != nil {
+		log.Printf("Detection rules for camera %s saved but not applied: %v", cameraID, err)
+	}
+}
+
+// decodeDetectionRule читает и проверяет правило из тела запроса
+func (s *Server) decodeDetectionRule(r *http.Request, rule *storage.DetectionRule) error {
+	var req DetectionRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	if req.Action == "" {
+		req.Action = storage.RuleActionAlert
+	}
+	if req.Action != storage.RuleActionAlert && req.Action != storage.RuleActionIgnore {
+		return fmt.Errorf("rule action must be %q or %q", storage.RuleActionAlert, storage.RuleActionIgnore)
+	}
+
+	if req.MinConfidence < 0 || req.MinConfidence > 1 {
+		return fmt.Errorf("min_confidence must be in the 0..1 range")
+	}
+	if req.MinArea < 0 || req.MinArea > 1 {
+		return fmt.Errorf("min_area must be a fraction of the frame in the 0..1 range")
+	}
+	if req.MinDwellFrames < 0 {
+		return fmt.Errorf("min_dwell_frames must not be negative")
+	}
+
+	if _, err := ai.ParseTimeWindow(req.StartTime, req.EndTime); err != nil {
+		return err
+	}
+
+	if req.ZoneID != 0 {
+		zone, err := s.storage.GetZone(req.ZoneID)
+		if err != nil {
+			return fmt.Errorf("failed to get zone: %w", err)
+		}
+		if zone == nil || zone.CameraID != rule.CameraID {
+			return fmt.Errorf("zone %d not found", req.ZoneID)
+		}
+		// Объект в исключенной зоне не детектируется, и правило никогда бы не сработало
+		if zone.Type == storage.ZoneTypeExclude {
+			return fmt.Errorf("zone %d excludes detection, use a %q zone for rules", req.ZoneID, storage.ZoneTypeRule)
+		}
+	}
+
+	rule.Class = req.Class
+	rule.Action = req.Action
+	rule.MinConfidence = req.MinConfidence
+	rule.ZoneID = req.ZoneID
+	rule.StartTime = req.StartTime
+	rule.EndTime = req.EndTime
+	rule.MinArea = req.MinArea
+	rule.MinDwellFrames = req.MinDwellFrames
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -14,11 +15,17 @@ import (
 	"strconv"
 	"time"
 
+	"ocuai/internal/arming"
 	"ocuai/internal/auth"
 	"ocuai/internal/config"
 	"ocuai/internal/events"
+	"ocuai/internal/export"
+	"ocuai/internal/reanalysis"
+	"ocuai/internal/rules"
 	"ocuai/internal/storage"
 	"ocuai/internal/streaming"
+	"ocuai/internal/webhooks"
+	wshub "ocuai/internal/websocket"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -31,14 +38,20 @@ var startTime = time.Now()
 
 // Server представляет веб-сервер
 type Server struct {
-	config          *config.Config
-	storage         *storage.Storage
-	eventManager    *events.Manager
-	streamingServer *streaming.Server
-	webAssets       embed.FS
-	upgrader        websocket.Upgrader
-	authService     *auth.AuthService
-	authHandlers    *auth.AuthHandlers
+	config            *config.Config
+	storage           *storage.Storage
+	eventManager      *events.Manager
+	streamingServer   *streaming.Server
+	webAssets         embed.FS
+	upgrader          websocket.Upgrader
+	authService       *auth.AuthService
+	authHandlers      *auth.AuthHandlers
+	hub               *wshub.Hub
+	exportManager     *export.Manager
+	reanalysisManager *reanalysis.Manager
+	webhookManager    *webhooks.Manager
+	ruleEngine        *rules.Engine
+	armingManager     *arming.Manager
 }
 
 // APIResponse представляет стандартный ответ API
@@ -56,7 +69,7 @@ type CameraRequest struct {
 	Password        string  `json:"password,omitempty"`
 	MotionDetection bool    `json:"motion_detection"`
 	AIDetection     bool    `json:"ai_detection"`
-	Sensitivity     float32 `json:"sensitivity"`
+	Sensitivity     float32 `json:"sensitivity"` // 0..1, 0 - значение по умолчанию
 	RecordMotion    bool    `json:"record_motion"`
 	SendTelegram    bool    `json:"send_telegram"`
 }
@@ -71,7 +84,7 @@ type SystemStats struct {
 }
 
 // New создает новый веб-сервер
-func New(cfg *config.Config, storage *storage.Storage, eventManager *events.Manager, streamingServer *streaming.Server, webAssets embed.FS, db *sql.DB) (*Server, error) {
+func New(cfg *config.Config, storage *storage.Storage, eventManager *events.Manager, streamingServer *streaming.Server, webAssets embed.FS, db *sql.DB, hub *wshub.Hub, exportManager *export.Manager, reanalysisManager *reanalysis.Manager, webhookManager *webhooks.Manager, ruleEngine *rules.Engine, armingManager *arming.Manager) (*Server, error) {
 	// Инициализируем сервис авторизации
 	authService, err := auth.New(db, cfg.Security.SessionSecret)
 	if err != nil {
@@ -81,13 +94,19 @@ func New(cfg *config.Config, storage *storage.Storage, eventManager *events.Mana
 	authHandlers := auth.NewHandlers(authService)
 
 	return &Server{
-		config:          cfg,
-		storage:         storage,
-		eventManager:    eventManager,
-		streamingServer: streamingServer,
-		webAssets:       webAssets,
-		authService:     authService,
-		authHandlers:    authHandlers,
+		config:            cfg,
+		storage:           storage,
+		eventManager:      eventManager,
+		streamingServer:   streamingServer,
+		webAssets:         webAssets,
+		authService:       authService,
+		authHandlers:      authHandlers,
+		hub:               hub,
+		exportManager:     exportManager,
+		reanalysisManager: reanalysisManager,
+		webhookManager:    webhookManager,
+		ruleEngine:        ruleEngine,
+		armingManager:     armingManager,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // В продакшене нужна более строгая проверка
@@ -141,19 +160,129 @@ func (s *Server) Router() http.Handler {
 				r.Put("/{id}", s.updateCameraHandler)
 				r.Delete("/{id}", s.deleteCameraHandler)
 				r.Post("/{id}/test", s.testCameraHandler)
+
+				// Зоны детекции
+				r.Get("/{id}/zones", s.getZonesHandler)
+				r.Post("/{id}/zones", s.createZoneHandler)
+				r.Put("/{id}/zones/{zoneID}", s.updateZoneHandler)
+				r.Delete("/{id}/zones/{zoneID}", s.deleteZoneHandler)
+
+				// Правила детекции
+				r.Get("/{id}/rules", s.getDetectionRulesHandler)
+				r.Post("/{id}/rules", s.createDetectionRuleHandler)
+				r.Put("/{id}/rules/{ruleID}", s.updateDetectionRuleHandler)
+				r.Delete("/{id}/rules/{ruleID}", s.deleteDetectionRuleHandler)
+
+				// Линии пересечения и области задержки
+				r.Get("/{id}/analytics", s.getAnalyticsZonesHandler)
+				r.Post("/{id}/analytics", s.createAnalyticsZoneHandler)
+				r.Put("/{id}/analytics/{analyticsID}", s.updateAnalyticsZoneHandler)
+				r.Delete("/{id}/analytics/{analyticsID}", s.deleteAnalyticsZoneHandler)
+			})
+
+			// Распознанные номера и списки номеров
+			r.Route("/plates", func(r chi.Router) {
+				r.Get("/", s.searchPlatesHandler)
+				r.Get("/{id}/image", s.plateImageHandler)
+				r.Get("/watchlist", s.getWatchlistHandler)
+				r.Post("/watchlist", s.createWatchlistEntryHandler)
+				r.Put("/watchlist/{entryID}", s.updateWatchlistEntryHandler)
+				r.Delete("/watchlist/{entryID}", s.deleteWatchlistEntryHandler)
+			})
+
+			// Галерея известных лиц
+			r.Route("/faces", func(r chi.Router) {
+				r.Get("/people", s.getPeopleHandler)
+				r.Post("/people", s.createPersonHandler)
+				r.Get("/people/{id}", s.getPersonHandler)
+				r.Put("/people/{id}", s.updatePersonHandler)
+				r.Delete("/people/{id}", s.deletePersonHandler)
+				r.Get("/people/{id}/photos", s.getFacePhotosHandler)
+				r.Post("/people/{id}/photos", s.uploadFacePhotoHandler)
+				r.Get("/people/{id}/photos/{photoID}", s.facePhotoFileHandler)
+				r.Delete("/people/{id}/photos/{photoID}", s.deleteFacePhotoHandler)
 			})
 
+			// Отчеты по счетчикам объектов
+			r.Get("/analytics/counts", s.countsHandler)
+
 			// События
 			r.Route("/events", func(r chi.Router) {
 				r.Get("/", s.getEventsHandler)
 				r.Get("/{id}", s.getEventHandler)
 				r.Delete("/{id}", s.deleteEventHandler)
+				r.Get("/{id}/thumbnail", s.eventThumbnailHandler)
+				r.Put("/{id}/star", s.starEventHandler)
+				r.Delete("/{id}/star", s.starEventHandler)
 			})
 
 			// Стриминг
 			r.Route("/streaming", func(r chi.Router) {
 				r.Get("/cameras/{id}/stream", s.streamHandler)
 				r.Get("/cameras/{id}/snapshot", s.snapshotHandler)
+				r.Get("/cameras/{id}/timeline", s.timelineHandler)
+				r.Get("/cameras/{id}/playback.m3u8", s.playbackPlaylistHandler)
+				r.Get("/cameras/{id}/recordings/{recordingID}", s.recordingFileHandler)
+				r.Get("/inference", s.inferenceStatsHandler)
+			})
+
+			// Экспорт записей
+			r.Route("/exports", func(r chi.Router) {
+				r.Get("/", s.getExportsHandler)
+				r.Post("/", s.createExportHandler)
+				r.Get("/{id}", s.getExportHandler)
+				r.Delete("/{id}", s.deleteExportHandler)
+				r.Get("/{id}/download", s.downloadExportHandler)
+			})
+
+			// Журнал событий: позиции подписчиков и недоставленные события
+			r.Route("/eventbus", func(r chi.Router) {
+				r.Get("/", s.getEventBusHandler)
+				r.Get("/failed", s.getFailedDeliveriesHandler)
+				r.Post("/failed/{id}/retry", s.retryFailedDeliveryHandler)
+			})
+
+			// Вебхуки
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Get("/", s.getWebhooksHandler)
+				r.Post("/", s.createWebhookHandler)
+				r.Get("/{id}", s.getWebhookHandler)
+				r.Put("/{id}", s.updateWebhookHandler)
+				r.Delete("/{id}", s.deleteWebhookHandler)
+				r.Post("/{id}/test", s.testWebhookHandler)
+				r.Get("/{id}/deliveries", s.getWebhookDeliveriesHandler)
+			})
+
+			// Правила событий
+			r.Route("/rules", func(r chi.Router) {
+				r.Get("/", s.getEventRulesHandler)
+				r.Post("/", s.createEventRuleHandler)
+				r.Post("/dry-run", s.dryRunDraftEventRuleHandler)
+				r.Get("/{id}", s.getEventRuleHandler)
+				r.Put("/{id}", s.updateEventRuleHandler)
+				r.Delete("/{id}", s.deleteEventRuleHandler)
+				r.Post("/{id}/dry-run", s.dryRunEventRuleHandler)
+			})
+
+			// Режимы охраны
+			r.Route("/arming", func(r chi.Router) {
+				r.Get("/", s.getArmingHandler)
+				r.Put("/", s.setArmingModeHandler)
+				r.Get("/history", s.getArmingHistoryHandler)
+				r.Put("/profiles/{mode}", s.updateArmingProfileHandler)
+				r.Get("/schedules", s.getArmingSchedulesHandler)
+				r.Post("/schedules", s.createArmingScheduleHandler)
+				r.Put("/schedules/{id}", s.updateArmingScheduleHandler)
+				r.Delete("/schedules/{id}", s.deleteArmingScheduleHandler)
+			})
+
+			// Повторный AI анализ записей
+			r.Route("/reanalysis", func(r chi.Router) {
+				r.Get("/", s.getReanalysisJobsHandler)
+				r.Post("/", s.createReanalysisHandler)
+				r.Get("/{id}", s.getReanalysisJobHandler)
+				r.Get("/{id}/events", s.getReanalysisEventsHandler)
+				r.Post("/{id}/cancel", s.cancelReanalysisHandler)
 			})
 
 			// Настройки
@@ -167,6 +296,9 @@ func (s *Server) Router() http.Handler {
 	// WebSocket для реального времени (защищен)
 	r.With(s.authService.RequireAuth()).Get("/ws", s.websocketHandler)
 
+	// WebSocket уведомлений (прогресс экспорта и т.п.)
+	r.With(s.authService.RequireAuth()).Get("/ws/live", s.hub.ServeWS)
+
 	// Статические файлы веб-интерфейса
 	s.setupStaticFiles(r)
 
@@ -286,6 +418,7 @@ func (s *Server) updateCameraHandler(w http.ResponseWriter, r *http.Request) {
 	camera.RTSPURL = req.RTSPURL
 	camera.MotionDetection = req.MotionDetection
 	camera.AIDetection = req.AIDetection
+	camera.Sensitivity = req.Sensitivity
 	camera.UpdatedAt = time.Now()
 
 	if err := s.storage.SaveCamera(camera); err != nil {
@@ -296,6 +429,8 @@ func (s *Server) updateCameraHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.applyMotionSettings(camera)
+
 	render.JSON(w, r, APIResponse{
 		Success: true,
 		Data:    camera,
@@ -316,6 +451,7 @@ func (s *Server) deleteCameraHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Удаляем из стриминг сервера
 	s.streamingServer.RemoveCamera(id)
+	s.reloadEventRules()
 
 	render.JSON(w, r, APIResponse{
 		Success: true,
@@ -419,6 +555,73 @@ func (s *Server) deleteEventHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// eventThumbnailHandler отдает JPEG кадра, вызвавшего событие
+func (s *Server) eventThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	event, err := s.storage.GetEvent(id)
+	if err != nil {
+		http.Error(w, "Failed to get event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if event == nil || event.ThumbnailPath == "" {
+		http.Error(w, "Thumbnail not found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := os.Stat(event.ThumbnailPath); err != nil {
+		http.Error(w, "Thumbnail not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(w, r, event.ThumbnailPath)
+}
+
+// starEventHandler добавляет событие в избранное (PUT) или убирает из него (DELETE).
+// Избранные события и их записи не удаляются при очистке хранилища.
+func (s *Server) starEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid event ID",
+		})
+		return
+	}
+
+	starred := r.Method == http.MethodPut
+	if err := s.storage.SetEventStarred(id, starred); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			render.JSON(w, r, APIResponse{
+				Success: false,
+				Error:   "Event not found",
+			})
+			return
+		}
+
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to update event: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"id":      id,
+			"starred": starred,
+		},
+	})
+}
+
 // streamHandler обрабатывает стриминг камеры
 func (s *Server) streamHandler(w http.ResponseWriter, r *http.Request) {
 	cameraID := chi.URLParam(r, "id")
@@ -440,6 +643,14 @@ func (s *Server) snapshotHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Snapshot not available for camera: " + cameraID))
 }
 
+// inferenceStatsHandler возвращает метрики очереди и задержки AI по камерам
+func (s *Server) inferenceStatsHandler(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    s.streamingServer.GetInferenceStats(),
+	})
+}
+
 // getSettingsHandler возвращает настройки системы
 func (s *Server) getSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	settings := map[string]interface{}{
@@ -448,6 +659,7 @@ func (s *Server) getSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		"motion_detection":   true,
 		"telegram_enabled":   s.config.Telegram.Token != "",
 		"notification_hours": s.config.Telegram.NotificationHours,
+		"arming_mode":        s.armingManager.Mode(),
 		"retention_days":     s.config.Storage.RetentionDays,
 		"max_video_size_mb":  s.config.Storage.MaxVideoSizeMB,
 	}
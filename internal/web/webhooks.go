@@ -0,0 +1,242 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// WebhookRequest представляет запрос на создание/обновление вебхука
+type WebhookRequest struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`      // пусто - сгенерировать при создании, не менять при обновлении
+	EventTypes []string `json:"event_types"` // пусто - все события
+	Enabled    *bool    `json:"enabled"`     // по умолчанию true
+}
+
+// getWebhooksHandler возвращает все вебхуки
+func (s *Server) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := s.storage.GetWebhooks()
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get webhooks: " + err.Error(),
+		})
+		return
+	}
+
+	if webhooks == nil {
+		webhooks = []storage.Webhook{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    webhooks,
+	})
+}
+
+// getWebhookHandler возвращает вебхук по ID
+func (s *Server) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    webhook,
+	})
+}
+
+// createWebhookHandler регистрирует вебхук
+func (s *Server) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var webhook storage.Webhook
+	if err := decodeWebhook(r, &webhook); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.webhookManager.Create(&webhook); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to create webhook: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    webhook,
+	})
+}
+
+// updateWebhookHandler обновляет вебхук
+func (s *Server) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decodeWebhook(r, webhook); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.webhookManager.Update(webhook); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to update webhook: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    webhook,
+	})
+}
+
+// deleteWebhookHandler удаляет вебхук
+func (s *Server) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.webhookManager.Delete(webhook.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete webhook: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// testWebhookHandler отправляет на вебхук тестовое событие и возвращает результат попытки
+func (s *Server) testWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := s.webhookManager.Test(*webhook)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Data:    delivery,
+			Error:   "Webhook test failed: " + err.Error(),
+		})
+		return
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    delivery,
+	})
+}
+
+// getWebhookDeliveriesHandler возвращает журнал доставок вебхука (limit, offset)
+func (s *Server) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	offset := 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	deliveries, err := s.storage.GetWebhookDeliveries(webhook.ID, limit, offset)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get webhook deliveries: " + err.Error(),
+		})
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []storage.WebhookDelivery{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    deliveries,
+	})
+}
+
+// findWebhook находит вебхук по ID из URL
+func (s *Server) findWebhook(w http.ResponseWriter, r *http.Request) (*storage.Webhook, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid webhook ID",
+		})
+		return nil, false
+	}
+
+	webhook, err := s.storage.GetWebhook(id)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get webhook: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if webhook == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Webhook not found",
+		})
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// decodeWebhook читает вебхук из тела запроса
+func decodeWebhook(r *http.Request, webhook *storage.Webhook) error {
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	webhook.Name = strings.TrimSpace(req.Name)
+	webhook.URL = strings.TrimSpace(req.URL)
+	if req.Secret != "" {
+		webhook.Secret = req.Secret
+	}
+	webhook.EventTypes = []string{}
+	for _, t := range req.EventTypes {
+		if t = strings.TrimSpace(t); t != "" {
+			webhook.EventTypes = append(webhook.EventTypes, t)
+		}
+	}
+	webhook.Enabled = req.Enabled == nil || *req.Enabled
+	return nil
+}
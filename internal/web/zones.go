@@ -0,0 +1,235 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ocuai/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// ZoneRequest представляет запрос на создание/обновление зоны камеры
+type ZoneRequest struct {
+	Name   string              `json:"name"`
+	Type   string              `json:"type"` // include, exclude, rule
+	Points []storage.ZonePoint `json:"points"`
+}
+
+// getZonesHandler возвращает зоны камеры
+func (s *Server) getZonesHandler(w http.ResponseWriter, r *http.Request) {
+	zones, err := s.storage.GetZones(chi.URLParam(r, "id"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get zones: " + err.Error(),
+		})
+		return
+	}
+
+	if zones == nil {
+		zones = []storage.Zone{}
+	}
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    zones,
+	})
+}
+
+// createZoneHandler добавляет зону камеры
+func (s *Server) createZoneHandler(w http.ResponseWriter, r *http.Request) {
+	cameraID := chi.URLParam(r, "id")
+
+	camera, err := s.storage.GetCamera(cameraID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get camera: " + err.Error(),
+		})
+		return
+	}
+
+	if camera == nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Camera not found",
+		})
+		return
+	}
+
+	zone := &storage.Zone{CameraID: cameraID}
+	if err := decodeZone(r, zone); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SaveZone(zone); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to create zone: " + err.Error(),
+		})
+		return
+	}
+
+	s.applyMotionSettings(camera)
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    zone,
+	})
+}
+
+// updateZoneHandler обновляет зону камеры
+func (s *Server) updateZoneHandler(w http.ResponseWriter, r *http.Request) {
+	zone, ok := s.findZone(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decodeZone(r, zone); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := s.storage.SaveZone(zone); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to update zone: " + err.Error(),
+		})
+		return
+	}
+
+	s.reloadMotionSettings(zone.CameraID)
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+		Data:    zone,
+	})
+}
+
+// deleteZoneHandler удаляет зону камеры
+func (s *Server) deleteZoneHandler(w http.ResponseWriter, r *http.Request) {
+	zone, ok := s.findZone(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteZone(zone.ID); err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to delete zone: " + err.Error(),
+		})
+		return
+	}
+
+	s.reloadMotionSettings(zone.CameraID)
+	s.reloadEventRules()
+
+	render.JSON(w, r, APIResponse{
+		Success: true,
+	})
+}
+
+// findZone находит зону из URL и проверяет, что она принадлежит камере
+func (s *Server) findZone(w http.ResponseWriter, r *http.Request) (*storage.Zone, bool) {
+	zoneID, err := strconv.Atoi(chi.URLParam(r, "zoneID"))
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Invalid zone ID",
+		})
+		return nil, false
+	}
+
+	zone, err := s.storage.GetZone(zoneID)
+	if err != nil {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Failed to get zone: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if zone == nil || zone.CameraID != chi.URLParam(r, "id") {
+		render.JSON(w, r, APIResponse{
+			Success: false,
+			Error:   "Zone not found",
+		})
+		return nil, false
+	}
+
+	return zone, true
+}
+
+// reloadMotionSettings передает актуальные зоны и чувствительность камеры в стриминг
+func (s *Server) reloadMotionSettings(cameraID string) {
+	camera, err := s.storage.GetCamera(cameraID)
+	if err != nil || camera == nil {
+		log.Printf("Failed to reload motion settings for camera %s: %v", cameraID, err)
+		return
+	}
+
+	s.applyMotionSettings(camera)
+}
+
+// applyMotionSettings применяет зоны и чувствительность камеры к запущенному потоку
+func (s *Server) applyMotionSettings(camera *storage.Camera) {
+	zones, err := s.storage.GetZones(camera.ID)
+	if err != nil {
+		log.Printf("Failed to load zones for camera %s: %v", camera.ID, err)
+		return
+	}
+
+	// Камера может быть не запущена - настройки подхватятся при старте
+	if err := s.streamingServer.UpdateCameraMotionSettings(camera.ID, camera.Sensitivity, zones); err != nil {
+		log.Printf("Motion settings for camera %s saved but not applied: %v", camera.ID, err)
+	}
+
+	// Правила детекции ссылаются на зоны - перестраиваем их маски
+	s.applyDetectionRules(camera.ID)
+}
+
+// decodeZone читает и проверяет зону из тела запроса
+func decodeZone(r *http.Request, zone *storage.Zone) error {
+	var req ZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	if req.Type == "" {
+		req.Type = storage.ZoneTypeInclude
+	}
+	if req.Type != storage.ZoneTypeInclude && req.Type != storage.ZoneTypeExclude && req.Type != storage.ZoneTypeRule {
+		return fmt.Errorf("zone type must be %q, %q or %q", storage.ZoneTypeInclude, storage.ZoneTypeExclude, storage.ZoneTypeRule)
+	}
+
+	if len(req.Points) < 3 {
+		return fmt.Errorf("zone must have at least 3 points")
+	}
+	for _, point := range req.Points {
+		if point.X < 0 || point.X > 1 || point.Y < 0 || point.Y > 1 {
+			return fmt.Errorf("zone points must be normalized to the 0..1 range")
+		}
+	}
+
+	if req.Name == "" {
+		req.Name = "Zone"
+	}
+
+	zone.Name = req.Name
+	zone.Type = req.Type
+	zone.Points = req.Points
+
+	return nil
+}
@@ -0,0 +1,349 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ocuai/internal/config"
+	"ocuai/internal/events"
+	"ocuai/internal/storage"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Заголовки запросов вебхука
+const (
+	HeaderEvent     = "X-Ocuai-Event"
+	HeaderDelivery  = "X-Ocuai-Delivery"
+	HeaderTimestamp = "X-Ocuai-Timestamp"
+	HeaderSignature = "X-Ocuai-Signature"
+)
+
+const (
+	requestTimeout     = 10 * time.Second
+	deliveryRetention  = 30 * 24 * time.Hour
+	maxErrorBodyLength = 200
+)
+
+// Payload тело запроса вебхука
+type Payload struct {
+	Event        events.Event `json:"event"`
+	ThumbnailURL string       `json:"thumbnail_url,omitempty"`
+}
+
+// Manager отправляет события на зарегистрированные вебхуки. Каждый вебхук - отдельный
+// подписчик журнала событий, поэтому недоступный адрес не задерживает остальные,
+// а повторы с нарастающей паузой и продолжение после перезапуска выполняет журнал.
+type Manager struct {
+	storage      *storage.Storage
+	config       *config.Config
+	eventManager *events.Manager
+	client       *http.Client
+	cron         *cron.Cron
+	webhooks     map[int]storage.Webhook
+	mu           sync.RWMutex
+}
+
+// New создает менеджер вебхуков
+func New(store *storage.Storage, cfg *config.Config, eventManager *events.Manager) *Manager {
+	return &Manager{
+		storage:      store,
+		config:       cfg,
+		eventManager: eventManager,
+		client:       &http.Client{Timeout: requestTimeout},
+		cron:         cron.New(),
+		webhooks:     make(map[int]storage.Webhook),
+	}
+}
+
+// Start подписывает включенные вебхуки на события и запускает очистку журнала доставок
+func (m *Manager) Start() error {
+	webhooks, err := m.storage.GetWebhooks()
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		m.subscribe(webhook)
+	}
+
+	if _, err := m.cron.AddFunc("30 4 * * *", m.pruneDeliveries); err != nil {
+		log.Printf("Failed to add webhook cron job: %v", err)
+	}
+	m.cron.Start()
+
+	log.Printf("Webhooks started: %d registered", len(webhooks))
+	return nil
+}
+
+// Stop останавливает доставку на вебхуки
+func (m *Manager) Stop() {
+	ctx := m.cron.Stop()
+	<-ctx.Done()
+
+	m.mu.RLock()
+	ids := make([]int, 0, len(m.webhooks))
+	for id := range m.webhooks {
+		ids = append(ids, id)
+	}
+	m.mu.RUnlock()
+
+	for _, id := range ids {
+		m.eventManager.Unsubscribe(subscriberName(id))
+	}
+}
+
+// Create регистрирует вебхук. Если секрет не задан, он генерируется.
+func (m *Manager) Create(webhook *storage.Webhook) error {
+	if err := Validate(webhook); err != nil {
+		return err
+	}
+
+	if webhook.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return err
+		}
+		webhook.Secret = secret
+	}
+
+	if err := m.storage.SaveWebhook(webhook); err != nil {
+		return err
+	}
+
+	m.subscribe(*webhook)
+	return nil
+}
+
+// Update сохраняет изменения вебхука и переподписывает его с новыми типами событий.
+// Выключенный вебхук теряет позицию в журнале: после включения он получает только новые события.
+func (m *Manager) Update(webhook *storage.Webhook) error {
+	if err := Validate(webhook); err != nil {
+		return err
+	}
+
+	if err := m.storage.SaveWebhook(webhook); err != nil {
+		return err
+	}
+
+	m.eventManager.Unsubscribe(subscriberName(webhook.ID))
+	m.mu.Lock()
+	delete(m.webhooks, webhook.ID)
+	m.mu.Unlock()
+
+	if !webhook.Enabled {
+		return m.eventManager.RemoveSubscriber(subscriberName(webhook.ID))
+	}
+
+	m.subscribe(*webhook)
+	return nil
+}
+
+// Delete удаляет вебхук, его позицию в журнале и журнал доставок
+func (m *Manager) Delete(id int) error {
+	m.mu.Lock()
+	delete(m.webhooks, id)
+	m.mu.Unlock()
+
+	if err := m.eventManager.RemoveSubscriber(subscriberName(id)); err != nil {
+		return err
+	}
+
+	return m.storage.DeleteWebhook(id)
+}
+
+// Test отправляет на вебхук тестовое событие один раз, без повторов
+func (m *Manager) Test(webhook storage.Webhook) (*storage.WebhookDelivery, error) {
+	event := events.Event{
+		Type:        events.EventTypeSystemLog,
+		Description: "Webhook test",
+		Timestamp:   time.Now(),
+		Data: map[string]interface{}{
+			"test": true,
+		},
+	}
+
+	return m.send(webhook, event)
+}
+
+// Send отправляет событие на вебхук один раз, без повторов, независимо от его фильтра типов
+func (m *Manager) Send(id int, event events.Event) error {
+	webhook, err := m.storage.GetWebhook(id)
+	if err != nil {
+		return err
+	}
+	if webhook == nil {
+		return fmt.Errorf("webhook %d not found", id)
+	}
+
+	_, err = m.send(*webhook, event)
+	return err
+}
+
+// Validate проверяет адрес и типы событий вебхука
+func Validate(webhook *storage.Webhook) error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+
+	for _, t := range webhook.EventTypes {
+		if !events.IsJournalType(events.EventType(t)) {
+			return fmt.Errorf("unknown event type: %s", t)
+		}
+	}
+
+	return nil
+}
+
+// subscribe подписывает включенный вебхук на журнал событий
+func (m *Manager) subscribe(webhook storage.Webhook) {
+	if !webhook.Enabled {
+		return
+	}
+
+	m.mu.Lock()
+	m.webhooks[webhook.ID] = webhook
+	m.mu.Unlock()
+
+	types := make([]events.EventType, 0, len(webhook.EventTypes))
+	for _, t := range webhook.EventTypes {
+		types = append(types, events.EventType(t))
+	}
+
+	id := webhook.ID
+	m.eventManager.Subscribe(subscriberName(id), func(event events.Event) error {
+		m.mu.RLock()
+		webhook, ok := m.webhooks[id]
+		m.mu.RUnlock()
+		if !ok {
+			return nil
+		}
+
+		_, err := m.send(webhook, event)
+		return err
+	}, types...)
+}
+
+// send отправляет событие на вебхук и записывает попытку в журнал доставок
+func (m *Manager) send(webhook storage.Webhook, event events.Event) (*storage.WebhookDelivery, error) {
+	delivery := &storage.WebhookDelivery{
+		WebhookID: webhook.ID,
+		Sequence:  event.Sequence,
+		EventID:   event.ID,
+		EventType: string(event.Type),
+		Attempt:   1,
+		CreatedAt: time.Now(),
+	}
+
+	if event.Sequence != 0 {
+		attempts, err := m.storage.CountWebhookAttempts(webhook.ID, event.Sequence)
+		if err != nil {
+			log.Printf("Webhook %d: %v", webhook.ID, err)
+		}
+		delivery.Attempt = attempts + 1
+	}
+
+	started := time.Now()
+	statusCode, err := m.post(webhook, event)
+	delivery.DurationMS = time.Since(started).Milliseconds()
+	delivery.StatusCode = statusCode
+	delivery.Success = err == nil
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	if saveErr := m.storage.SaveWebhookDelivery(delivery); saveErr != nil {
+		log.Printf("Webhook %d: %v", webhook.ID, saveErr)
+	}
+
+	return delivery, err
+}
+
+// post выполняет подписанный запрос вебхука и возвращает код ответа
+func (m *Manager) post(webhook storage.Webhook, event events.Event) (int, error) {
+	payload := Payload{Event: event}
+	if event.ID != 0 && event.ThumbnailPath != "" {
+		payload.ThumbnailURL = fmt.Sprintf("%s/api/events/%d/thumbnail", strings.TrimRight(m.config.Server.PublicURL, "/"), event.ID)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Ocuai-Webhook")
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(event.Sequence, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLength))
+		return resp.StatusCode, fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(text)))
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// Sign возвращает подпись запроса: HMAC-SHA256 от "<timestamp>.<body>" в hex с префиксом sha256=
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// pruneDeliveries удаляет старые записи журнала доставок
+func (m *Manager) pruneDeliveries() {
+	deleted, err := m.storage.DeleteWebhookDeliveriesBefore(time.Now().Add(-deliveryRetention))
+	if err != nil {
+		log.Printf("Failed to prune webhook deliveries: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Pruned %d webhook deliveries", deleted)
+	}
+}
+
+// subscriberName имя подписчика журнала событий для вебхука
+func subscriberName(id int) string {
+	return fmt.Sprintf("webhook:%d", id)
+}
+
+// generateSecret создает случайный секрет подписи
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,247 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ocuai/internal/config"
+	"ocuai/internal/events"
+	"ocuai/internal/storage"
+)
+
+// request запрос, полученный тестовым приемником
+type request struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+// receiver тестовый приемник вебхуков, отвечающий ошибкой на первые failures запросов
+type receiver struct {
+	server   *httptest.Server
+	failures int
+	requests []request
+	mu       sync.Mutex
+}
+
+func newReceiver(t *testing.T, failures int) *receiver {
+	r := &receiver{failures: failures}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		r.requests = append(r.requests, request{header: req.Header.Clone(), body: body, at: time.Now()})
+		fail := len(r.requests) <= r.failures
+		r.mu.Unlock()
+
+		if fail {
+			http.Error(w, "receiver is busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *receiver) received() []request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]request(nil), r.requests...)
+}
+
+// newManager создает менеджер вебхуков с журналом событий во временной базе
+func newManager(t *testing.T) (*Manager, *storage.Storage, *events.Manager) {
+	store, err := storage.New(filepath.Join(t.TempDir(), "ocuai.db"))
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{}
+	cfg.Server.PublicURL = "http://ocuai.local"
+
+	eventManager := events.New(store, cfg)
+	t.Cleanup(eventManager.Close)
+
+	manager := New(store, cfg, eventManager)
+	if err := manager.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(manager.Stop)
+
+	return manager, store, eventManager
+}
+
+// waitFor ждет выполнения условия
+func waitFor(t *testing.T, timeout time.Duration, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSign(t *testing.T) {
+	got := Sign("secret", "1700000000", []byte(`{"event":{}}`))
+	want := "sha256=b7b0d7e6d31827c7e2435f56a9a4a4a8ce5520178d61a4243e416b4508f8211c"
+	if got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+
+	if Sign("other", "1700000000", []byte(`{"event":{}}`)) == want {
+		t.Fatal("signature does not depend on the secret")
+	}
+	if Sign("secret", "1700000001", []byte(`{"event":{}}`)) == want {
+		t.Fatal("signature does not depend on the timestamp")
+	}
+}
+
+func TestTestSendsSignedRequestOnce(t *testing.T) {
+	manager, _, _ := newManager(t)
+	recv := newReceiver(t, 1)
+
+	webhook := storage.Webhook{ID: 1, URL: recv.server.URL, Secret: "s3cret", Enabled: true}
+	delivery, err := manager.Test(webhook)
+	if err == nil {
+		t.Fatal("expected an error for a 503 response")
+	}
+
+	requests := recv.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1: test deliveries are not retried", len(requests))
+	}
+
+	req := requests[0]
+	if got := req.header.Get(HeaderEvent); got != string(events.EventTypeSystemLog) {
+		t.Errorf("%s = %q, want %q", HeaderEvent, got, events.EventTypeSystemLog)
+	}
+	timestamp := req.header.Get(HeaderTimestamp)
+	if got, want := req.header.Get(HeaderSignature), Sign("s3cret", timestamp, req.body); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload.Event.Description != "Webhook test" {
+		t.Errorf("payload event description = %q", payload.Event.Description)
+	}
+
+	if delivery.Success || delivery.StatusCode != http.StatusServiceUnavailable || delivery.Attempt != 1 {
+		t.Errorf("delivery = %+v, want failed attempt 1 with status 503", delivery)
+	}
+	if !strings.Contains(delivery.Error, "receiver is busy") {
+		t.Errorf("delivery error %q does not include the response body", delivery.Error)
+	}
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	manager, store, eventManager := newManager(t)
+	recv := newReceiver(t, 2)
+
+	webhook := &storage.Webhook{Name: "test", URL: recv.server.URL, Secret: "s3cret", Enabled: true}
+	if err := manager.Create(webhook); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	eventManager.EmitSystemLog("disk is almost full")
+
+	waitFor(t, 10*time.Second, "three delivery attempts", func() bool {
+		deliveries, err := store.GetWebhookDeliveries(webhook.ID, 10, 0)
+		return err == nil && len(deliveries) == 3
+	})
+
+	requests := recv.received()
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(requests))
+	}
+
+	// Пауза между попытками растет: 1s, затем 2s
+	first, second := requests[1].at.Sub(requests[0].at), requests[2].at.Sub(requests[1].at)
+	if first < 900*time.Millisecond {
+		t.Errorf("first retry after %s, want about 1s", first)
+	}
+	if second < first+800*time.Millisecond {
+		t.Errorf("second retry after %s, want about twice the first (%s)", second, first)
+	}
+
+	sequence := requests[0].header.Get(HeaderDelivery)
+	for i, req := range requests {
+		if got := req.header.Get(HeaderDelivery); got != sequence || got == "0" {
+			t.Errorf("request %d: %s = %q, want the same outbox sequence %q", i, HeaderDelivery, got, sequence)
+		}
+		if got, want := req.header.Get(HeaderSignature), Sign("s3cret", req.header.Get(HeaderTimestamp), req.body); got != want {
+			t.Errorf("request %d: bad signature", i)
+		}
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(webhook.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+
+	attempts := map[int]storage.WebhookDelivery{}
+	for _, delivery := range deliveries {
+		attempts[delivery.Attempt] = delivery
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		delivery, ok := attempts[attempt]
+		if !ok {
+			t.Fatalf("no delivery log entry for attempt %d: %+v", attempt, deliveries)
+		}
+		if wantSuccess := attempt == 3; delivery.Success != wantSuccess {
+			t.Errorf("attempt %d success = %v, want %v", attempt, delivery.Success, wantSuccess)
+		}
+		if delivery.EventType != string(events.EventTypeSystemLog) {
+			t.Errorf("attempt %d event type = %q", attempt, delivery.EventType)
+		}
+	}
+	if attempts[1].StatusCode != http.StatusServiceUnavailable || attempts[3].StatusCode != http.StatusNoContent {
+		t.Errorf("status codes = %d, %d, %d", attempts[1].StatusCode, attempts[2].StatusCode, attempts[3].StatusCode)
+	}
+}
+
+func TestDeliverySkipsFilteredTypes(t *testing.T) {
+	manager, store, eventManager := newManager(t)
+	recv := newReceiver(t, 0)
+
+	webhook := &storage.Webhook{
+		URL:        recv.server.URL,
+		EventTypes: []string{string(events.EventTypeArming)},
+		Enabled:    true,
+	}
+	if err := manager.Create(webhook); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if webhook.Secret == "" {
+		t.Error("Create did not generate a secret")
+	}
+
+	eventManager.EmitSystemLog("not for this webhook")
+	eventManager.EmitArmingChanged("night", "home", "api")
+
+	waitFor(t, 5*time.Second, "a delivery", func() bool {
+		deliveries, err := store.GetWebhookDeliveries(webhook.ID, 10, 0)
+		return err == nil && len(deliveries) > 0
+	})
+
+	requests := recv.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if got := requests[0].header.Get(HeaderEvent); got != string(events.EventTypeArming) {
+		t.Errorf("%s = %q, want %q", HeaderEvent, got, events.EventTypeArming)
+	}
+}
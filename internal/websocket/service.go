@@ -107,6 +107,24 @@ func (s *NotificationService) NotifyStatsUpdate(stats interface{}) {
 	log.Printf("Broadcasted stats update")
 }
 
+// NotifyExportProgress отправляет состояние задания на экспорт
+func (s *NotificationService) NotifyExportProgress(export interface{}) {
+	message := &Message{
+		Type: "export_progress",
+		Data: export,
+	}
+	s.hub.Broadcast(message)
+}
+
+// NotifyReanalysisProgress отправляет состояние задания на повторный анализ
+func (s *NotificationService) NotifyReanalysisProgress(job interface{}) {
+	message := &Message{
+		Type: "reanalysis_progress",
+		Data: job,
+	}
+	s.hub.Broadcast(message)
+}
+
 // StartHeartbeat запускает периодическую отправку статистики
 func (s *NotificationService) StartHeartbeat(ctx context.Context, statsProvider func() interface{}) {
 	ticker := time.NewTicker(30 * time.Second)